@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -8,11 +9,18 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/junhaideng/sphincs/keystate"
 )
 
-var signatureAlgorithms = []string{LAMPORT, WOTS, WOTSPLUS, HORS, HORST, SPHINCS}
+var signatureAlgorithms = []string{LAMPORT, WOTS, WOTSPLUS, HORS, HORST, SPHINCS, SLHDSA}
 
+// New 密钥以及签名次数都保存在内存中，见 NewWithConfig
 func New() *gin.Engine {
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig 使用 cfg 中的 Store 保存 /api/keys 生成的密钥，Tracker 记录签名次数
+func NewWithConfig(cfg Config) *gin.Engine {
 	f, err := os.OpenFile("signature.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		panic(err)
@@ -20,7 +28,7 @@ func New() *gin.Engine {
 	app := gin.New()
 	app.Use(gin.LoggerWithWriter(io.MultiWriter(f, os.Stdout)))
 	app.Use(gin.Recovery())
-	setup(app)
+	setup(app, NewService(cfg))
 	return app
 }
 
@@ -44,14 +52,15 @@ func cors() gin.HandlerFunc {
 	}
 }
 
-func setup(app *gin.Engine) {
+func setup(app *gin.Engine, service *Service) {
 	app.Use(cors())
 	api := app.Group("/api")
 
 	{
 		api.POST("/signature/:algorithm", func(c *gin.Context) {
 			start := time.Now()
-			r, err := GenSignature(c.Param("algorithm"), []byte(c.Param("message")))
+			// message 不是路由参数，从 query 或者表单中读取
+			r, err := GenSignature(c.Param("algorithm"), []byte(c.Request.FormValue("message")))
 			//fmt.Printf("%#v\n", r)
 			if err != nil {
 				c.JSON(http.StatusOK, gin.H{
@@ -76,5 +85,106 @@ func setup(app *gin.Engine) {
 				"data":    signatureAlgorithms,
 			})
 		})
+
+		// 密钥生成，签名以及校验分开，私钥保存在 service 中，只返回 ID
+		// 公钥为 DER 编码，二进制字段使用 hex 或者 base64
+		api.POST("/keys", func(c *gin.Context) {
+			var req KeysRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				fail(c, err)
+				return
+			}
+			res, err := service.GenerateKeys(&req)
+			if err != nil {
+				fail(c, err)
+				return
+			}
+			success(c, res)
+		})
+
+		api.POST("/sign", func(c *gin.Context) {
+			var req SignRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				fail(c, err)
+				return
+			}
+			res, err := service.Sign(&req)
+			if err != nil {
+				fail(c, err)
+				return
+			}
+			success(c, res)
+		})
+
+		api.POST("/verify", func(c *gin.Context) {
+			var req VerifyRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				fail(c, err)
+				return
+			}
+			res, err := service.Verify(&req)
+			if err != nil {
+				fail(c, err)
+				return
+			}
+			success(c, res)
+		})
+
+		api.POST("/params/estimate", func(c *gin.Context) {
+			var req EstimateRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				fail(c, err)
+				return
+			}
+			res, err := Estimate(&req)
+			if err != nil {
+				fail(c, err)
+				return
+			}
+			success(c, res)
+		})
+
+		api.GET("/keys/:id", func(c *gin.Context) {
+			res, err := service.PublicKey(c.Param("id"), c.Query("encoding"))
+			if err != nil {
+				fail(c, err)
+				return
+			}
+			success(c, res)
+		})
+
+		api.DELETE("/keys/:id", func(c *gin.Context) {
+			if err := service.DeleteKey(c.Param("id")); err != nil {
+				fail(c, err)
+				return
+			}
+			success(c, nil)
+		})
+	}
+}
+
+func success(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "ok",
+		"data":    data,
+	})
+}
+
+// fail 密钥不存在时为 404，密钥已经用完时为 409，存储出错时为 500，其余都是请求中的参数不正确
+func fail(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, keystate.ErrKeyReused), errors.Is(err, keystate.ErrLimitExceeded):
+		status = http.StatusConflict
+	case errors.Is(err, ErrStore):
+		status = http.StatusInternalServerError
 	}
+	c.JSON(status, gin.H{
+		"code":    -1,
+		"message": err.Error(),
+		"data":    nil,
+	})
 }
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/junhaideng/sphincs/keystate"
+	"github.com/junhaideng/sphincs/keystore"
+	"github.com/junhaideng/sphincs/params"
+	"github.com/junhaideng/sphincs/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+type response struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func newTestApp() *gin.Engine {
+	return newTestAppWithStore(keystore.NewMemoryStore())
+}
+
+func newTestAppWithStore(store keystore.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	setup(app, NewService(Config{Store: store}))
+	return app
+}
+
+// request 发送没有 body 的请求
+func request(app *gin.Engine, method, path string) (int, response) {
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	var res response
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res
+}
+
+// post 发送 JSON 请求，data 不为空时解析返回的 data
+func post(t *testing.T, app *gin.Engine, path string, body interface{}, data interface{}) (int, response) {
+	b, err := json.Marshal(body)
+	assert.Nil(t, err)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b)))
+	var res response
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	if data != nil && res.Code == 0 {
+		assert.Nil(t, json.Unmarshal(res.Data, data))
+	}
+	return w.Code, res
+}
+
+func TestKeysSignVerify(t *testing.T) {
+	assert := assert.New(t)
+	app := newTestApp()
+	message := hex.EncodeToString([]byte("hello world"))
+
+	for _, alg := range []string{SLHDSA, WOTSPLUS, HORST, LAMPORT} {
+		var keys KeysResponse
+		code, res := post(t, app, "/api/keys", KeysRequest{Algorithm: alg}, &keys)
+		assert.Equal(http.StatusOK, code, res.Message)
+		assert.Equal(alg, keys.Algorithm)
+
+		var sig SignResponse
+		code, res = post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID, Message: message}, &sig)
+		assert.Equal(http.StatusOK, code, res.Message)
+		assert.Equal(alg, sig.Algorithm)
+
+		var v VerifyResponse
+		code, res = post(t, app, "/api/verify", VerifyRequest{
+			Algorithm: alg,
+			PublicKey: keys.PublicKey,
+			Message:   message,
+			Signature: sig.Signature,
+		}, &v)
+		assert.Equal(http.StatusOK, code, res.Message)
+		assert.True(v.Valid, alg)
+
+		// 使用 key_id 校验
+		code, _ = post(t, app, "/api/verify", VerifyRequest{KeyID: keys.KeyID, Message: message, Signature: sig.Signature}, &v)
+		assert.Equal(http.StatusOK, code)
+		assert.True(v.Valid, alg)
+
+		// 消息被修改
+		code, _ = post(t, app, "/api/verify", VerifyRequest{
+			PublicKey: keys.PublicKey,
+			Message:   hex.EncodeToString([]byte("hello")),
+			Signature: sig.Signature,
+		}, &v)
+		assert.Equal(http.StatusOK, code)
+		assert.False(v.Valid, alg)
+		assert.NotEmpty(v.Reason, alg)
+	}
+}
+
+func TestBase64(t *testing.T) {
+	assert := assert.New(t)
+	app := newTestApp()
+	message := base64.StdEncoding.EncodeToString([]byte("hello world"))
+
+	var keys KeysResponse
+	code, _ := post(t, app, "/api/keys", KeysRequest{Algorithm: SLHDSA, Encoding: EncodingBase64}, &keys)
+	assert.Equal(http.StatusOK, code)
+	_, err := base64.StdEncoding.DecodeString(keys.PublicKey)
+	assert.Nil(err)
+
+	var sig SignResponse
+	code, _ = post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID, Message: message, Encoding: EncodingBase64}, &sig)
+	assert.Equal(http.StatusOK, code)
+
+	var v VerifyResponse
+	code, _ = post(t, app, "/api/verify", VerifyRequest{
+		PublicKey: keys.PublicKey,
+		Message:   message,
+		Signature: sig.Signature,
+		Encoding:  EncodingBase64,
+	}, &v)
+	assert.Equal(http.StatusOK, code)
+	assert.True(v.Valid)
+}
+
+func TestBadRequest(t *testing.T) {
+	assert := assert.New(t)
+	app := newTestApp()
+
+	var keys KeysResponse
+	code, _ := post(t, app, "/api/keys", KeysRequest{Algorithm: SLHDSA}, &keys)
+	assert.Equal(http.StatusOK, code)
+
+	for _, c := range []struct {
+		path string
+		body interface{}
+	}{
+		{"/api/keys", KeysRequest{}},
+		{"/api/keys", KeysRequest{Algorithm: "rsa"}},
+		{"/api/keys", KeysRequest{Algorithm: SLHDSA, Encoding: "base32"}},
+		{"/api/sign", SignRequest{Message: "00"}},
+		{"/api/sign", SignRequest{KeyID: keys.KeyID, Message: "0"}},
+		{"/api/sign", SignRequest{KeyID: keys.KeyID, Algorithm: SPHINCS}},
+		{"/api/verify", VerifyRequest{PublicKey: keys.PublicKey}},
+		{"/api/verify", VerifyRequest{PublicKey: "00", Signature: "00"}},
+		{"/api/verify", VerifyRequest{KeyID: keys.KeyID, PublicKey: keys.PublicKey, Signature: "00"}},
+		{"/api/verify", VerifyRequest{Signature: "00"}},
+	} {
+		code, res := post(t, app, c.path, c.body, nil)
+		assert.Equal(http.StatusBadRequest, code, c)
+		assert.Equal(-1, res.Code, c)
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	assert := assert.New(t)
+	store, err := keystore.NewFileStore(t.TempDir(), make([]byte, 32))
+	assert.Nil(err)
+	app := newTestAppWithStore(store)
+
+	var keys KeysResponse
+	code, res := post(t, app, "/api/keys", KeysRequest{Algorithm: SLHDSA}, &keys)
+	assert.Equal(http.StatusOK, code)
+	assert.NotEmpty(keys.KeyID)
+	// 响应中没有私钥
+	assert.NotContains(string(res.Data), "private")
+	e, err := store.Get(keys.KeyID)
+	assert.Nil(err)
+	assert.NotContains(string(res.Data), hex.EncodeToString(e.PrivateKey))
+
+	code, res = request(app, http.MethodGet, "/api/keys/"+keys.KeyID)
+	assert.Equal(http.StatusOK, code)
+	var pub KeysResponse
+	assert.Nil(json.Unmarshal(res.Data, &pub))
+	assert.Equal(keys.PublicKey, pub.PublicKey)
+	assert.Equal(SLHDSA, pub.Algorithm)
+
+	code, _ = request(app, http.MethodDelete, "/api/keys/"+keys.KeyID)
+	assert.Equal(http.StatusOK, code)
+
+	// 删除之后无法使用
+	code, _ = request(app, http.MethodGet, "/api/keys/"+keys.KeyID)
+	assert.Equal(http.StatusNotFound, code)
+	code, _ = request(app, http.MethodDelete, "/api/keys/"+keys.KeyID)
+	assert.Equal(http.StatusNotFound, code)
+	code, _ = post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID}, nil)
+	assert.Equal(http.StatusNotFound, code)
+	code, _ = post(t, app, "/api/sign", SignRequest{KeyID: "../key"}, nil)
+	assert.Equal(http.StatusNotFound, code)
+}
+
+func TestKeyUsage(t *testing.T) {
+	assert := assert.New(t)
+	tracker, err := keystate.NewFileTracker(t.TempDir())
+	assert.Nil(err)
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	setup(app, NewService(Config{Tracker: tracker, Policy: &keystate.Policy{FewTimeWarn: 1, FewTimeMax: 2}}))
+	message := hex.EncodeToString([]byte("hello world"))
+
+	// OTS 只能签名一次
+	var keys KeysResponse
+	code, _ := post(t, app, "/api/keys", KeysRequest{Algorithm: WOTSPLUS}, &keys)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(&Capacity{Limit: 1, Remaining: 1}, keys.Capacity)
+
+	var sig SignResponse
+	code, _ = post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID, Message: message}, &sig)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(&Capacity{Signatures: 1, Limit: 1, Remaining: 0}, sig.Capacity)
+	code, res := post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID, Message: message}, nil)
+	assert.Equal(http.StatusConflict, code)
+	assert.Equal(keystate.ErrKeyReused.Error(), res.Message)
+
+	code, res = request(app, http.MethodGet, "/api/keys/"+keys.KeyID)
+	assert.Equal(http.StatusOK, code)
+	assert.Nil(json.Unmarshal(res.Data, &keys))
+	assert.Equal(int64(0), keys.Capacity.Remaining)
+
+	// HORST 第二次签名警告，第三次拒绝
+	code, _ = post(t, app, "/api/keys", KeysRequest{Algorithm: HORST}, &keys)
+	assert.Equal(http.StatusOK, code)
+	code, _ = post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID, Message: message}, &sig)
+	assert.Equal(http.StatusOK, code)
+	assert.False(sig.Capacity.Warning)
+	code, _ = post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID, Message: message}, &sig)
+	assert.Equal(http.StatusOK, code)
+	assert.True(sig.Capacity.Warning)
+	code, res = post(t, app, "/api/sign", SignRequest{KeyID: keys.KeyID, Message: message}, nil)
+	assert.Equal(http.StatusConflict, code)
+	assert.Equal(keystate.ErrLimitExceeded.Error(), res.Message)
+
+	// 无状态的签名不限制
+	code, _ = post(t, app, "/api/keys", KeysRequest{Algorithm: SLHDSA}, &keys)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(int64(-1), keys.Capacity.Remaining)
+}
+
+func TestGenSignatureMessage(t *testing.T) {
+	assert := assert.New(t)
+	app := newTestApp()
+
+	// message 放在表单中
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/signature/"+LAMPORT, strings.NewReader("message=hello"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	app.ServeHTTP(w, req)
+	assert.Equal(http.StatusOK, w.Code)
+
+	var res response
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(0, res.Code)
+	var data SignatureResponse
+	assert.Nil(json.Unmarshal(res.Data, &data))
+
+	// 签名的是表单中的 message，而不是空消息
+	pk, err := hex.DecodeString(data.PK)
+	assert.Nil(err)
+	sigma, err := hex.DecodeString(data.Sigma)
+	assert.Nil(err)
+	s, err := signature.NewLamportSignature(signature.Size256)
+	assert.Nil(err)
+	assert.True(s.Verify([]byte("hello"), pk, sigma))
+	assert.False(s.Verify(nil, pk, sigma))
+}
+
+func TestEstimate(t *testing.T) {
+	assert := assert.New(t)
+	app := newTestApp()
+
+	req := EstimateRequest{Params: params.SPHINCS256}
+	var e params.Estimate
+	code, res := post(t, app, "/api/params/estimate", req, &e)
+	assert.Equal(http.StatusOK, code, res.Message)
+	assert.Equal(uint64(1<<50), e.Signatures)
+	assert.Equal(128.0, e.Quantum)
+	assert.Equal(41000, e.Signature)
+
+	req = EstimateRequest{Params: params.Params{Scheme: params.HORST, N: 256, Tau: 16, K: 32}, Signatures: "2^3"}
+	code, _ = post(t, app, "/api/params/estimate", req, &e)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(uint64(8), e.Signatures)
+
+	for _, req := range []EstimateRequest{
+		{},
+		{Params: params.SPHINCS256, Signatures: "0"},
+		{Params: params.Params{Scheme: params.SPHINCS, N: 256, H: 61, D: 12, W: 16, Tau: 16, K: 32}},
+	} {
+		code, res := post(t, app, "/api/params/estimate", req, nil)
+		assert.Equal(http.StatusBadRequest, code, req)
+		assert.Equal(-1, res.Code)
+	}
+}
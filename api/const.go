@@ -9,4 +9,5 @@ const (
 	SPHINCS  = "sphincs"
 	WOTS     = "wots"
 	WOTSPLUS = "wots+"
+	SLHDSA   = "slh-dsa" // 参数集 SLH-DSA-SHA2-128f
 )
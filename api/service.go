@@ -6,33 +6,89 @@ import (
 	"time"
 
 	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/keys"
+	"github.com/junhaideng/sphincs/keystate"
+	"github.com/junhaideng/sphincs/keystore"
+	"github.com/junhaideng/sphincs/params"
 	"github.com/junhaideng/sphincs/signature"
 )
 
-func GenSignature(algorithm string, message []byte) (*SignatureResponse, error) {
+var (
+	ErrAlgorithm         = errors.New("不支持该算法")
+	ErrEncoding          = errors.New("encoding 只能为 hex 或者 base64")
+	ErrAlgorithmMismatch = errors.New("algorithm 和密钥的算法不一致")
+	ErrKeyOrPublicKey    = errors.New("key_id 和 public_key 需要指定其中一个")
+	ErrKeyNotFound       = errors.New("密钥不存在")
+	ErrStore             = errors.New("密钥存储出错")
+)
+
+// newScheme 这里使用的参数都是 SPHINCS-256 中对应的
+func newScheme(algorithm string) (signature.Scheme, error) {
 	var s signature.Signature
 	var err error
-	// 这里使用的参数都是 SPHINCS-256 中对应的
 	switch SignatureAlgorithm(algorithm) {
 	case HORS:
 		s, err = signature.NewHorsSignature(16, 32)
 	case HORST:
 		s, err = signature.NewHorstSignature(16, 32, genRandBytes(32), genRandBytes(32*2*16))
-		message = hash.Sha512(message) // horst 的输入直接为 512 bits，和 sphincs 中的对齐，需要自己先 hash 一波
 	case LAMPORT:
 		s, err = signature.NewLamportSignature(256)
 	case SPHINCS:
-		s, err = signature.NewSphincs(256, 512, 60, 12, 4, 16, 32, genRandBytes(32))
+		s, err = signature.NewSphincs(256, 512, 60, 12, 4, 16, 32, nil)
+	case SLHDSA:
+		s, err = signature.NewSLHDSA("SLH-DSA-SHA2-128f")
 	case WOTS:
 		s, err = signature.NewWinternitzSignature(4, 256)
 	case WOTSPLUS:
-		s, err = signature.NewWOTSPlusSignature(4, 256, genRandBytes(32), genRandBytes(32*15))
+		s, err = signature.NewWOTSPlusSignature(4, 256, nil, genRandBytes(32*15))
 	default:
-		return nil, errors.New("不支持该算法")
+		return nil, ErrAlgorithm
 	}
 	if err != nil {
 		return nil, err
 	}
+	return s.(signature.Scheme), nil
+}
+
+// Estimate 估计参数集合的安全性，密钥和签名的大小以及哈希函数的调用次数
+func Estimate(req *EstimateRequest) (*params.Estimate, error) {
+	signatures := req.DefaultSignatures()
+	if req.Signatures != "" {
+		var err error
+		if signatures, err = params.ParseSignatures(req.Signatures); err != nil {
+			return nil, err
+		}
+	}
+	return req.Params.Estimate(signatures)
+}
+
+// algorithmName 和 signatureAlgorithms 中的名称一致
+func algorithmName(s signature.Scheme) string {
+	return s.Identifier().Alg.String()
+}
+
+// prepareMessage horst 的输入直接为 512 bits，和 sphincs 中的对齐，需要自己先 hash 一波
+func prepareMessage(s signature.Scheme, message []byte) []byte {
+	if _, ok := s.(*signature.Horst); ok {
+		return hash.Sha512(message)
+	}
+	return message
+}
+
+// checkAlgorithm algorithm 为空时不检查
+func checkAlgorithm(algorithm string, s signature.Scheme) error {
+	if algorithm != "" && algorithm != algorithmName(s) {
+		return ErrAlgorithmMismatch
+	}
+	return nil
+}
+
+func GenSignature(algorithm string, message []byte) (*SignatureResponse, error) {
+	s, err := newScheme(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	message = prepareMessage(s, message)
 
 	start := time.Now()
 	sk, pk := s.GenerateKey()
@@ -43,7 +99,6 @@ func GenSignature(algorithm string, message []byte) (*SignatureResponse, error)
 	last := time.Now()
 	fmt.Printf("algorithm: %s, flag: %t\n", algorithm, flag)
 	return &SignatureResponse{
-		SK:    toHex(sk),
 		PK:    toHex(pk),
 		Sigma: toHex(sigma),
 		Cost: &Cost{
@@ -53,3 +108,229 @@ func GenSignature(algorithm string, message []byte) (*SignatureResponse, error)
 		},
 	}, nil
 }
+
+// Config 为空的字段使用默认值
+type Config struct {
+	// Store 保存生成的密钥，默认保存在内存中
+	Store keystore.Store
+	// Tracker 记录每一个密钥的签名次数，默认保存在内存中
+	// Store 为持久化的实现时，Tracker 也需要持久化，否则重启之后 OTS 私钥可以被再次使用
+	Tracker keystate.Tracker
+	// Policy 默认为 keystate.DefaultPolicy
+	Policy *keystate.Policy
+}
+
+// Service 密钥保存在 store 中，请求和响应中只出现密钥的 ID 以及公钥
+type Service struct {
+	store   keystore.Store
+	tracker keystate.Tracker
+	policy  keystate.Policy
+}
+
+func NewService(cfg Config) *Service {
+	s := &Service{store: cfg.Store, tracker: cfg.Tracker, policy: keystate.DefaultPolicy}
+	if s.store == nil {
+		s.store = keystore.NewMemoryStore()
+	}
+	if s.tracker == nil {
+		s.tracker = keystate.NewMemoryTracker()
+	}
+	if cfg.Policy != nil {
+		s.policy = *cfg.Policy
+	}
+	return s
+}
+
+// storeErr 不存在以及格式不正确的 ID 都返回 ErrKeyNotFound，其余为 ErrStore
+func storeErr(err error) error {
+	if errors.Is(err, keystore.ErrNotFound) || errors.Is(err, keystore.ErrInvalidID) {
+		return ErrKeyNotFound
+	}
+	return fmt.Errorf("%w: %v", ErrStore, err)
+}
+
+// stateErr 密钥已经被使用或者达到上限时原样返回，其余为 ErrStore
+func stateErr(err error) error {
+	if errors.Is(err, keystate.ErrKeyReused) || errors.Is(err, keystate.ErrLimitExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrStore, err)
+}
+
+// capacity 返回 id 当前的使用情况
+func (s *Service) capacity(id string, scheme signature.Scheme) (*Capacity, error) {
+	u, err := keystate.Current(s.tracker, id, s.policy.Limit(scheme))
+	if err != nil {
+		return nil, stateErr(err)
+	}
+	return newCapacity(u), nil
+}
+
+// GenerateKeys 在服务端生成一对密钥并保存，私钥编码为 PKCS#8，公钥编码为 SubjectPublicKeyInfo
+func (s *Service) GenerateKeys(req *KeysRequest) (*KeysResponse, error) {
+	encode, _, err := codec(req.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	scheme, err := newScheme(req.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	sk, pk := scheme.GenerateKey()
+	cost := time.Since(start)
+
+	pkDER, err := keys.MarshalPKIXPublicKey(scheme, pk)
+	if err != nil {
+		return nil, err
+	}
+	skDER, err := keys.MarshalPKCS8PrivateKey(scheme, sk)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.store.Put(skDER, pkDER)
+	if err != nil {
+		return nil, storeErr(err)
+	}
+	capacity, err := s.capacity(id, scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &KeysResponse{
+		KeyID:      id,
+		Algorithm:  algorithmName(scheme),
+		Parameters: scheme.Identifier().String(),
+		PublicKey:  encode(pkDER),
+		Capacity:   capacity,
+		Cost:       &Cost{Gen: cost.String()},
+	}, nil
+}
+
+// PublicKey 返回 id 对应的公钥以及剩余的签名次数
+func (s *Service) PublicKey(id, encoding string) (*KeysResponse, error) {
+	encode, _, err := codec(encoding)
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.store.Get(id)
+	if err != nil {
+		return nil, storeErr(err)
+	}
+	scheme, _, err := keys.ParsePKIXPublicKey(e.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := s.capacity(id, scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &KeysResponse{
+		KeyID:      id,
+		Algorithm:  algorithmName(scheme),
+		Parameters: scheme.Identifier().String(),
+		PublicKey:  encode(e.PublicKey),
+		Capacity:   capacity,
+	}, nil
+}
+
+// DeleteKey 删除 id 对应的密钥
+func (s *Service) DeleteKey(id string) error {
+	if err := s.store.Delete(id); err != nil {
+		return storeErr(err)
+	}
+	return nil
+}
+
+// Sign 使用 key_id 对应的私钥对消息进行签名，算法和参数由私钥确定
+// 签名之前先通过 tracker 记录签名次数，OTS 私钥第二次签名返回 keystate.ErrKeyReused
+func (s *Service) Sign(req *SignRequest) (*SignResponse, error) {
+	encode, decode, err := codec(req.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	message, err := decode(req.Message)
+	if err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+	e, err := s.store.Get(req.KeyID)
+	if err != nil {
+		return nil, storeErr(err)
+	}
+	scheme, sk, err := keys.ParsePKCS8PrivateKey(e.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAlgorithm(req.Algorithm, scheme); err != nil {
+		return nil, err
+	}
+
+	// 计数落盘之后才能签名，失败时不回滚
+	usage, err := s.tracker.Reserve(e.ID, s.policy.Limit(scheme))
+	if err != nil {
+		return nil, stateErr(err)
+	}
+
+	start := time.Now()
+	sig, err := signature.SignMessage(scheme, prepareMessage(scheme, message), sk)
+	if err != nil {
+		return nil, err
+	}
+	return &SignResponse{
+		Algorithm:  algorithmName(scheme),
+		Parameters: scheme.Identifier().String(),
+		Signature:  encode(sig.Value),
+		Capacity:   newCapacity(usage),
+		Cost:       &Cost{Sign: time.Since(start).String()},
+	}, nil
+}
+
+// Verify 使用 key_id 或者 public_key 对应的公钥校验签名，签名可以由其他地方生成
+// 请求的格式不正确时返回 error，签名不正确时 Valid 为 false
+func (s *Service) Verify(req *VerifyRequest) (*VerifyResponse, error) {
+	_, decode, err := codec(req.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if (req.KeyID == "") == (req.PublicKey == "") {
+		return nil, ErrKeyOrPublicKey
+	}
+	message, err := decode(req.Message)
+	if err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+	sig, err := decode(req.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	var der []byte
+	if req.KeyID != "" {
+		e, err := s.store.Get(req.KeyID)
+		if err != nil {
+			return nil, storeErr(err)
+		}
+		der = e.PublicKey
+	} else if der, err = decode(req.PublicKey); err != nil {
+		return nil, fmt.Errorf("public_key: %w", err)
+	}
+	scheme, pk, err := keys.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAlgorithm(req.Algorithm, scheme); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	err = signature.VerifyMessage(scheme, prepareMessage(scheme, message), pk, &signature.Sig{ID: pk.ID, Value: sig})
+	res := &VerifyResponse{
+		Algorithm:  algorithmName(scheme),
+		Parameters: scheme.Identifier().String(),
+		Valid:      err == nil,
+		Cost:       &Cost{Verify: time.Since(start).String()},
+	}
+	if err != nil {
+		res.Reason = err.Error()
+	}
+	return res, nil
+}
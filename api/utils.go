@@ -1,32 +1,32 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
-	"math/rand"
-	"time"
+
+	"github.com/junhaideng/sphincs/keystate"
+	"github.com/junhaideng/sphincs/params"
 )
 
 type Cost struct {
-	Gen    string `json:"gen"`
-	Sign   string `json:"sign"`
-	Verify string `json:"verify"`
+	Gen    string `json:"gen,omitempty"`
+	Sign   string `json:"sign,omitempty"`
+	Verify string `json:"verify,omitempty"`
 }
 
+// SignatureResponse 私钥不会离开服务端，所以只返回公钥和签名
 type SignatureResponse struct {
-	SK    string `json:"sk"`
 	PK    string `json:"pk"`
 	Sigma string `json:"sigma"`
 	Cost  *Cost  `json:"cost"`
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
+// genRandBytes 从 crypto/rand 中读取 n bytes
 func genRandBytes(n int) []byte {
 	res := make([]byte, n)
-	for i := 0; i < len(res); i++ {
-		res[i] = byte(rand.Intn(128))
+	if _, err := rand.Read(res); err != nil {
+		panic(err)
 	}
 	return res
 }
@@ -34,3 +34,101 @@ func genRandBytes(n int) []byte {
 func toHex(data []byte) string {
 	return hex.EncodeToString(data)
 }
+
+// 二进制字段的编码方式，默认为 hex
+const (
+	EncodingHex    = "hex"
+	EncodingBase64 = "base64"
+)
+
+// KeysRequest POST /api/keys
+type KeysRequest struct {
+	Algorithm string `json:"algorithm" binding:"required"`
+	Encoding  string `json:"encoding"`
+}
+
+// KeysResponse 私钥保存在服务端，只返回 ID 以及 DER 格式的 SubjectPublicKeyInfo (见 keys 包)
+type KeysResponse struct {
+	KeyID      string    `json:"key_id"`
+	Algorithm  string    `json:"algorithm"`
+	Parameters string    `json:"parameters"`
+	PublicKey  string    `json:"public_key"`
+	Capacity   *Capacity `json:"capacity"`
+	Cost       *Cost     `json:"cost,omitempty"`
+}
+
+// SignRequest POST /api/sign，key_id 为 /api/keys 返回的 ID
+// algorithm 不为空时需要和私钥的算法一致
+type SignRequest struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id" binding:"required"`
+	Message   string `json:"message"`
+	Encoding  string `json:"encoding"`
+}
+
+// SignResponse Capacity 为这一次签名之后的使用情况
+type SignResponse struct {
+	Algorithm  string    `json:"algorithm"`
+	Parameters string    `json:"parameters"`
+	Signature  string    `json:"signature"`
+	Capacity   *Capacity `json:"capacity"`
+	Cost       *Cost     `json:"cost"`
+}
+
+// Capacity 密钥的签名次数，见 keystate
+type Capacity struct {
+	Signatures uint64 `json:"signatures"`
+	// Limit 为 0 时不限制
+	Limit uint64 `json:"limit"`
+	// Remaining 为 -1 时不限制
+	Remaining int64 `json:"remaining"`
+	// Warning few-time 签名 (HORS, HORST) 的次数超过了警告阈值，安全性已经下降
+	Warning bool `json:"warning"`
+}
+
+func newCapacity(u keystate.Usage) *Capacity {
+	return &Capacity{
+		Signatures: u.Signatures,
+		Limit:      u.Limit,
+		Remaining:  u.Remaining(),
+		Warning:    u.Warning,
+	}
+}
+
+// VerifyRequest POST /api/verify，key_id 和 public_key 二选一，public_key 可以是其他地方生成的公钥
+// algorithm 不为空时需要和公钥的算法一致
+type VerifyRequest struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	Message   string `json:"message"`
+	Signature string `json:"signature" binding:"required"`
+	Encoding  string `json:"encoding"`
+}
+
+// VerifyResponse 签名不正确时 Valid 为 false，Reason 为失败的原因
+type VerifyResponse struct {
+	Algorithm  string `json:"algorithm"`
+	Parameters string `json:"parameters"`
+	Valid      bool   `json:"valid"`
+	Reason     string `json:"reason,omitempty"`
+	Cost       *Cost  `json:"cost"`
+}
+
+// EstimateRequest POST /api/params/estimate，参数的含义见 params.Params
+// signatures 为十进制整数或者 2^x，为空时 SPHINCS 为 2^50，HORS 和 HORST 为 1
+type EstimateRequest struct {
+	params.Params
+	Signatures string `json:"signatures"`
+}
+
+// codec 根据 encoding 返回编码和解码函数
+func codec(encoding string) (func([]byte) string, func(string) ([]byte, error), error) {
+	switch encoding {
+	case "", EncodingHex:
+		return hex.EncodeToString, hex.DecodeString, nil
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString, base64.StdEncoding.DecodeString, nil
+	}
+	return nil, nil, ErrEncoding
+}
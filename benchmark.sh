@@ -7,5 +7,20 @@ go test github.com/junhaideng/sphincs/signature -bench BenchmarkHorst -benchtime
 echo "sphincs"
 go test github.com/junhaideng/sphincs/signature -bench BenchmarkSphincs -benchtime=1000x -benchmem -count=1 -timeout=24h -cpu 1 
 
+echo "sphincs parallel"
+go test github.com/junhaideng/sphincs/signature -bench BenchmarkSphincsParallel -benchtime=20x -benchmem -count=1 -timeout=24h -cpu 1,2,4,8
+
 echo "hash"
-go test github.com/junhaideng/sphincs/merkle -bench ^BenchmarkTreeHashAndChainHash$ -benchtime=10000x -benchmem -count=1 -timeout=24h -cpu 1  
\ No newline at end of file
+go test github.com/junhaideng/sphincs/merkle -bench ^BenchmarkTreeHashAndChainHash$ -benchtime=10000x -benchmem -count=1 -timeout=24h -cpu 1  
+
+echo "tree hash"
+go test github.com/junhaideng/sphincs/merkle -bench ^BenchmarkTreeHash$ -benchtime=10x -benchmem -count=1 -timeout=24h -cpu 1
+
+echo "bds"
+go test github.com/junhaideng/sphincs/merkle -bench ^BenchmarkBDS$ -benchtime=10x -benchmem -count=1 -timeout=24h -cpu 1
+
+echo "hasher"
+go test github.com/junhaideng/sphincs/hash -bench ^BenchmarkHasher$ -benchtime=100000x -benchmem -count=1 -timeout=24h -cpu 1
+
+echo "sphincs hash suites"
+go test github.com/junhaideng/sphincs/signature -run ^$ -bench ^BenchmarkSphincsSuites$ -benchtime=20x -benchmem -count=1 -timeout=24h -cpu 1
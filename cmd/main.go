@@ -1,14 +1,74 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/junhaideng/sphincs/api"
+	"github.com/junhaideng/sphincs/keystate"
+	"github.com/junhaideng/sphincs/keystore"
 )
 
+// newConfig 从环境变量中读取配置
+//
+//	SPHINCS_KEYSTORE_DIR  设置时密钥加密之后保存在该目录中，否则只保存在内存中，重启之后丢失
+//	SPHINCS_KEYSTORE_KEY  hex 编码的 32 bytes 主密钥
+//	SPHINCS_KEYSTATE_DIR  签名次数保存的目录，默认为 $SPHINCS_KEYSTORE_DIR/state
+//	SPHINCS_FEWTIME_WARN  HORS/HORST 签名次数超过该值之后警告
+//	SPHINCS_FEWTIME_MAX   HORS/HORST 最多签名的次数
+func newConfig() (api.Config, error) {
+	var cfg api.Config
+	policy := keystate.DefaultPolicy
+	for name, v := range map[string]*uint64{
+		"SPHINCS_FEWTIME_WARN": &policy.FewTimeWarn,
+		"SPHINCS_FEWTIME_MAX":  &policy.FewTimeMax,
+	} {
+		if s := os.Getenv(name); s != "" {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", name, err)
+			}
+			*v = n
+		}
+	}
+	cfg.Policy = &policy
+
+	dir := os.Getenv("SPHINCS_KEYSTORE_DIR")
+	stateDir := os.Getenv("SPHINCS_KEYSTATE_DIR")
+	if dir != "" {
+		key, err := hex.DecodeString(os.Getenv("SPHINCS_KEYSTORE_KEY"))
+		if err != nil {
+			return cfg, fmt.Errorf("SPHINCS_KEYSTORE_KEY: %w", err)
+		}
+		if cfg.Store, err = keystore.NewFileStore(dir, key); err != nil {
+			return cfg, err
+		}
+		// 密钥持久化时签名次数也必须持久化
+		if stateDir == "" {
+			stateDir = filepath.Join(dir, "state")
+		}
+	}
+	if stateDir != "" {
+		tracker, err := keystate.NewFileTracker(stateDir)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Tracker = tracker
+	}
+	return cfg, nil
+}
+
 func main() {
-	app := api.New()
-	err := app.Run(":8080")
+	cfg, err := newConfig()
+	if err != nil {
+		fmt.Println("读取配置失败：", err)
+		os.Exit(1)
+	}
+	app := api.NewWithConfig(cfg)
+	err = app.Run(":8080")
 	if err != nil {
 		fmt.Println("运行后端失败：", err)
 		return
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/junhaideng/sphincs/keys"
+	"github.com/junhaideng/sphincs/signature"
+)
+
+// newScheme 根据算法名称构造签名算法
+// sphincs-256 为论文中的参数，sphincs 需要通过 params 指定 n,h,d,w,tau,k，其余为 SLH-DSA 的参数集名称 (不区分大小写)
+func newScheme(alg, params string) (signature.Scheme, error) {
+	alg = strings.ToLower(alg)
+	if params != "" && alg != "sphincs" {
+		return nil, usagef("--params 只能和 --alg sphincs 一起使用")
+	}
+	switch alg {
+	case "sphincs-256":
+		return signature.NewSphincsWithParams(signature.SPHINCS256Params, nil)
+	case "sphincs":
+		p, err := parseSphincsParams(params)
+		if err != nil {
+			return nil, err
+		}
+		return signature.NewSphincsWithParams(p, nil)
+	}
+	for _, name := range signature.SLHDSAParameterSets() {
+		if strings.EqualFold(name, alg) {
+			return signature.NewSLHDSA(name)
+		}
+	}
+	return nil, usagef("不支持的算法 %q，可选 sphincs-256, sphincs, %s", alg, strings.Join(signature.SLHDSAParameterSets(), ", "))
+}
+
+// parseSphincsParams 解析 n,h,d,w,tau,k，其中 w 为 Winternitz 参数本身，例如 16
+func parseSphincsParams(s string) (signature.SphincsParams, error) {
+	values, err := parseInts(s, "n,h,d,w,tau,k")
+	if err != nil {
+		return signature.SphincsParams{}, err
+	}
+	return signature.SphincsParams{
+		N: values[0], H: values[1], D: values[2], W: values[3], Tau: values[4], K: values[5],
+	}, nil
+}
+
+// parseInts 解析逗号分隔的整数，个数需要和 format 中的一致
+func parseInts(s, format string) ([]int, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != len(strings.Split(format, ",")) {
+		return nil, usagef("--params 应该为 %s", format)
+	}
+	values := make([]int, len(parts))
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, usagef("--params 中的 %q 不是整数", part)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// readInput 读取文件，name 为 "-" 时读取标准输入
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// writeFile 写入文件，force 为 false 时不覆盖已经存在的文件
+func writeFile(name string, data []byte, perm os.FileMode, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(name, flags, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func keygen(args []string, std stdio) error {
+	fs := newFlagSet("keygen", std.err)
+	alg := fs.String("alg", "sphincs-256", "签名算法: sphincs-256, sphincs 或者 SLH-DSA 参数集名称")
+	params := fs.String("params", "", "--alg sphincs 时的参数 n,h,d,w,tau,k")
+	out := fs.String("out", "", "私钥文件，公钥写入 <out>.pub")
+	force := fs.Bool("force", false, "覆盖已经存在的文件")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usagef("缺少 --out")
+	}
+	s, err := newScheme(*alg, *params)
+	if err != nil {
+		return err
+	}
+
+	sk, pk := s.GenerateKey()
+	skPEM, err := keys.MarshalPrivateKeyPEM(s, sk)
+	if err != nil {
+		return err
+	}
+	pkPEM, err := keys.MarshalPublicKeyPEM(s, pk)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(*out, skPEM, 0600, *force); err != nil {
+		return err
+	}
+	if err := writeFile(*out+".pub", pkPEM, 0644, *force); err != nil {
+		return err
+	}
+	fmt.Fprintf(std.out, "%s: %s, %s.pub\n", s.Identifier(), *out, *out)
+	return nil
+}
+
+func sign(args []string, std stdio) error {
+	fs := newFlagSet("sign", std.err)
+	key := fs.String("key", "", "PEM 格式的私钥")
+	out := fs.String("out", "", "签名文件，默认为 <file>.sig，为 - 时写入标准输出")
+	force := fs.Bool("force", false, "覆盖已经存在的签名文件")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if *key == "" {
+		return usagef("缺少 --key")
+	}
+	file := fs.Arg(0)
+	if *out == "" {
+		if file == "-" {
+			return usagef("从标准输入读取时需要指定 --out")
+		}
+		*out = file + ".sig"
+	}
+
+	b, err := ioutil.ReadFile(*key)
+	if err != nil {
+		return err
+	}
+	s, sk, err := keys.ParsePrivateKeyPEM(b)
+	if err != nil {
+		return err
+	}
+	message, err := readInput(file, std.in)
+	if err != nil {
+		return err
+	}
+	sig, err := signature.SignMessage(s, message, sk)
+	if err != nil {
+		return err
+	}
+	if *out == "-" {
+		_, err = std.out.Write(sig.Value)
+		return err
+	}
+	return writeFile(*out, sig.Value, 0644, *force)
+}
+
+func verify(args []string, std stdio) error {
+	fs := newFlagSet("verify", std.err)
+	pub := fs.String("pub", "", "PEM 格式的公钥")
+	sigFile := fs.String("sig", "", "签名文件")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if *pub == "" || *sigFile == "" {
+		return usagef("缺少 --pub 或者 --sig")
+	}
+
+	b, err := ioutil.ReadFile(*pub)
+	if err != nil {
+		return err
+	}
+	s, pk, err := keys.ParsePublicKeyPEM(b)
+	if err != nil {
+		return err
+	}
+	sig, err := ioutil.ReadFile(*sigFile)
+	if err != nil {
+		return err
+	}
+	message, err := readInput(fs.Arg(0), std.in)
+	if err != nil {
+		return err
+	}
+	err = signature.VerifyMessage(s, message, pk, &signature.Sig{ID: pk.ID, Value: sig})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalid, err)
+	}
+	fmt.Fprintln(std.out, "OK")
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/junhaideng/sphincs/params"
+	"github.com/junhaideng/sphincs/signature"
+)
+
+func estimate(args []string, std stdio) error {
+	fs := newFlagSet("estimate", std.err)
+	alg := fs.String("alg", "sphincs-256", "sphincs-256, sphincs, hors 或者 horst")
+	p := fs.String("params", "", "sphincs 为 n,h,d,w,tau,k，hors 为 tau,k，horst 为 tau,k,n")
+	q := fs.String("signatures", "", "最多签名的次数，例如 1000 或者 2^50，SPHINCS 默认为 2^50，HORS 和 HORST 默认为 1")
+	asJSON := fs.Bool("json", false, "输出 JSON")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	ps, err := newParams(strings.ToLower(*alg), *p)
+	if err != nil {
+		return err
+	}
+	signatures := ps.DefaultSignatures()
+	if *q != "" {
+		if signatures, err = params.ParseSignatures(*q); err != nil {
+			return usagef("--signatures: %s", err)
+		}
+	}
+
+	e, err := ps.Estimate(signatures)
+	var pe *signature.ParamsError
+	if errors.As(err, &pe) {
+		return usagef("%s", err)
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(std.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+	}
+	printEstimate(std.out, e)
+	return nil
+}
+
+// newParams 参数的顺序和密钥中的算法标识一致
+func newParams(alg, s string) (params.Params, error) {
+	if alg == "sphincs-256" {
+		if s != "" {
+			return params.Params{}, usagef("--alg sphincs-256 不需要 --params")
+		}
+		return params.SPHINCS256, nil
+	}
+	if s == "" {
+		return params.Params{}, usagef("--alg %s 需要指定 --params", alg)
+	}
+	switch params.Scheme(alg) {
+	case params.SPHINCS:
+		p, err := parseSphincsParams(s)
+		if err != nil {
+			return params.Params{}, err
+		}
+		return params.Params{Scheme: params.SPHINCS, N: p.N, H: p.H, D: p.D, W: p.W, Tau: p.Tau, K: p.K}, nil
+	case params.HORS:
+		v, err := parseInts(s, "tau,k")
+		if err != nil {
+			return params.Params{}, err
+		}
+		return params.Params{Scheme: params.HORS, Tau: v[0], K: v[1]}, nil
+	case params.HORST:
+		v, err := parseInts(s, "tau,k,n")
+		if err != nil {
+			return params.Params{}, err
+		}
+		return params.Params{Scheme: params.HORST, Tau: v[0], K: v[1], N: v[2]}, nil
+	}
+	return params.Params{}, usagef("不支持的算法 %q，可选 sphincs-256, sphincs, hors, horst", alg)
+}
+
+func printEstimate(w io.Writer, e *params.Estimate) {
+	fmt.Fprintf(w, "params       %s\n", e.Params)
+	fmt.Fprintf(w, "signatures   %d\n", e.Signatures)
+	fmt.Fprintf(w, "classical    %.1f bits\n", e.Classical)
+	fmt.Fprintf(w, "quantum      %.1f bits\n", e.Quantum)
+	for _, b := range e.Bounds {
+		fmt.Fprintf(w, "  %-10s %.1f / %.1f bits\n", b.Name, b.Classical, b.Quantum)
+	}
+	fmt.Fprintf(w, "public key   %d bytes\n", e.PublicKey)
+	fmt.Fprintf(w, "private key  %d bytes\n", e.PrivateKey)
+	fmt.Fprintf(w, "signature    %d bytes\n", e.Signature)
+	fmt.Fprintf(w, "hash calls   keygen %.0f, sign %.0f, verify %.0f\n", e.HashCalls.KeyGen, e.HashCalls.Sign, e.HashCalls.Verify)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/keys"
+	"github.com/junhaideng/sphincs/signature"
+)
+
+// inspectResult inspect 的输出，所有字节串都是十六进制
+type inspectResult struct {
+	Algorithm string         `json:"algorithm"`
+	Size      int            `json:"size"`
+	Index     string         `json:"index"`
+	R1        string         `json:"r1"`
+	Horst     inspectHorst   `json:"horst"`
+	Layers    []inspectLayer `json:"layers"`
+}
+
+type inspectHorst struct {
+	Top    []string            `json:"top"`
+	Blocks []inspectHorstBlock `json:"blocks"`
+}
+
+type inspectHorstBlock struct {
+	SK   string   `json:"sk"`
+	Auth []string `json:"auth"`
+}
+
+type inspectLayer struct {
+	Layer int      `json:"layer"`
+	Tree  uint64   `json:"tree"`
+	Leaf  uint64   `json:"leaf"`
+	Sigma []string `json:"sigma"`
+	Auth  []string `json:"auth"`
+}
+
+func inspect(args []string, std stdio) error {
+	fs := newFlagSet("inspect", std.err)
+	pub := fs.String("pub", "", "PEM 格式的公钥，用来确定参数")
+	alg := fs.String("alg", "", "没有公钥时指定算法，同 keygen")
+	params := fs.String("params", "", "--alg sphincs 时的参数 n,h,d,w,tau,k")
+	asJSON := fs.Bool("json", false, "输出 JSON")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if (*pub == "") == (*alg == "") {
+		return usagef("需要指定 --pub 或者 --alg 其中一个")
+	}
+
+	var s signature.Scheme
+	if *pub != "" {
+		b, err := ioutil.ReadFile(*pub)
+		if err != nil {
+			return err
+		}
+		if s, _, err = keys.ParsePublicKeyPEM(b); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if s, err = newScheme(*alg, *params); err != nil {
+			return err
+		}
+	}
+	sphincs, ok := s.(*signature.Sphincs)
+	if !ok {
+		return errors.New("inspect 只支持 SPHINCS 签名")
+	}
+
+	sig, err := readInput(fs.Arg(0), std.in)
+	if err != nil {
+		return err
+	}
+	parsed, err := sphincs.ParseSignature(sig)
+	if err != nil {
+		return err
+	}
+	res := newInspectResult(sphincs, parsed, len(sig))
+	if *asJSON {
+		enc := json.NewEncoder(std.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	printInspectResult(std.out, sphincs.Params(), res)
+	return nil
+}
+
+func newInspectResult(s *signature.Sphincs, sig *signature.SphincsSignature, size int) *inspectResult {
+	res := &inspectResult{
+		Algorithm: s.Identifier().String(),
+		Size:      size,
+		Index:     hex.EncodeToString(sig.Index),
+		R1:        hex.EncodeToString(sig.R1),
+		Horst:     inspectHorst{Top: hexList(sig.Horst.Top)},
+	}
+	for _, b := range sig.Horst.Blocks {
+		res.Horst.Blocks = append(res.Horst.Blocks, inspectHorstBlock{
+			SK:   hex.EncodeToString(b.SK),
+			Auth: hexList(b.Auth),
+		})
+	}
+	for i, layer := range sig.Layers {
+		res.Layers = append(res.Layers, inspectLayer{
+			Layer: i,
+			Tree:  layer.Tree,
+			Leaf:  layer.Leaf,
+			Sigma: hexList(layer.Sigma),
+			Auth:  hexList(layer.Auth),
+		})
+	}
+	return res
+}
+
+func hexList(b [][]byte) []string {
+	res := make([]string, len(b))
+	for i := range b {
+		res[i] = hex.EncodeToString(b[i])
+	}
+	return res
+}
+
+func printInspectResult(w io.Writer, p signature.SphincsParams, res *inspectResult) {
+	fmt.Fprintf(w, "algorithm  %s\n", res.Algorithm)
+	fmt.Fprintf(w, "size       %d bytes\n", res.Size)
+	// h 不超过 64 时同时输出十进制的索引
+	if p.H <= 64 {
+		index, _ := hex.DecodeString(res.Index)
+		fmt.Fprintf(w, "i          %s (%d)\n", res.Index, common.ReadBits(index, 0, uint64(p.H)))
+	} else {
+		fmt.Fprintf(w, "i          %s\n", res.Index)
+	}
+	fmt.Fprintf(w, "R1         %s\n", res.R1)
+
+	fmt.Fprintf(w, "HORST      %d top nodes, %d blocks\n", len(res.Horst.Top), len(res.Horst.Blocks))
+	for i, node := range res.Horst.Top {
+		fmt.Fprintf(w, "  top[%d]   %s\n", i, node)
+	}
+	for i, b := range res.Horst.Blocks {
+		fmt.Fprintf(w, "  sk[%d]    %s\n", i, b.SK)
+		for j, node := range b.Auth {
+			fmt.Fprintf(w, "    auth[%d] %s\n", j, node)
+		}
+	}
+
+	for _, layer := range res.Layers {
+		fmt.Fprintf(w, "layer %d    tree %d, leaf %d\n", layer.Layer, layer.Tree, layer.Leaf)
+		for i, node := range layer.Sigma {
+			fmt.Fprintf(w, "  sigma[%d] %s\n", i, node)
+		}
+		for i, node := range layer.Auth {
+			fmt.Fprintf(w, "  auth[%d]  %s\n", i, node)
+		}
+	}
+}
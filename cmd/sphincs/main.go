@@ -0,0 +1,141 @@
+// sphincs 命令行工具，生成密钥，对文件进行签名，校验签名以及查看签名的各个部分
+//
+//	sphincs keygen [--alg sphincs-256] [--params n,h,d,w,tau,k] [--force] --out key
+//	sphincs sign --key key [--out file.sig] file
+//	sphincs verify --pub key.pub --sig file.sig file
+//	sphincs inspect (--pub key.pub | --alg sphincs-256 [--params ...]) [--json] file.sig
+//	sphincs estimate [--alg sphincs-256] [--params ...] [--signatures 2^50] [--json]
+//
+// 私钥保存为 PEM 格式的 PKCS#8，公钥保存为 PEM 格式的 SubjectPublicKeyInfo (见 keys 包)
+// 签名为分离式的原始字节，文件名为 "-" 时从标准输入读取
+//
+// 退出码:
+//
+//	0 成功，verify 时表示签名正确
+//	1 签名校验失败
+//	2 参数错误
+//	3 其他错误，例如文件不存在或者密钥格式错误
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitUsage   = 2
+	exitError   = 3
+)
+
+// errInvalid 签名校验失败，对应 exitInvalid
+var errInvalid = errors.New("签名校验失败")
+
+// usageError 参数错误，对应 exitUsage
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// stdio 子命令的标准输入输出，测试时替换
+type stdio struct {
+	in       io.Reader
+	out, err io.Writer
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, std stdio) error
+}
+
+var commands = []command{
+	{"keygen", "生成密钥对，私钥写入 --out，公钥写入 --out.pub", keygen},
+	{"sign", "对文件进行签名，生成分离式签名", sign},
+	{"verify", "校验分离式签名", verify},
+	{"inspect", "拆分签名，输出 i, R1, HORST 签名以及每一层的 WOTS+ 签名和鉴权路径", inspect},
+	{"estimate", "估计参数的安全性，密钥和签名的大小以及哈希函数的调用次数", estimate},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 执行子命令并返回退出码
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:], stdio{stdin, stdout, stderr})
+		var ue *usageError
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.Is(err, errInvalid):
+			fmt.Fprintln(stderr, err)
+			return exitInvalid
+		case errors.As(err, &ue):
+			fmt.Fprintf(stderr, "sphincs %s: %s\n", c.name, err)
+			return exitUsage
+		default:
+			fmt.Fprintf(stderr, "sphincs %s: %s\n", c.name, err)
+			return exitError
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "未知的子命令 %q\n", args[0])
+	printUsage(stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sphincs <command> [flags]")
+	fmt.Fprintln(w)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "退出码: 0 成功, 1 签名校验失败, 2 参数错误, 3 其他错误")
+}
+
+// newFlagSet 解析错误通过返回值交给 run 处理，用法输出到 w
+func newFlagSet(name string, w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("sphincs "+name, flag.ContinueOnError)
+	fs.SetOutput(w)
+	return fs
+}
+
+// parseFlags 解析参数，要求剩下 n 个位置参数
+func parseFlags(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usagef("%s", err)
+	}
+	if fs.NArg() != n {
+		return usagef("需要 %d 个位置参数，实际为 %d 个", n, fs.NArg())
+	}
+	return nil
+}
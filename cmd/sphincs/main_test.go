@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/junhaideng/sphincs/params"
+	"github.com/stretchr/testify/assert"
+)
+
+// 使用较小的参数，保证测试速度
+var testParams = []string{"--alg", "sphincs", "--params", "256,8,2,16,8,64"}
+
+func runCmd(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	key := filepath.Join(dir, "key")
+	file := filepath.Join(dir, "file")
+	assert.Nil(ioutil.WriteFile(file, []byte("hello world"), 0644))
+
+	code, _, stderr := runCmd("", append([]string{"keygen", "--out", key}, testParams...)...)
+	assert.Equal(exitOK, code, stderr)
+	// 不覆盖已有的密钥
+	code, _, _ = runCmd("", append([]string{"keygen", "--out", key}, testParams...)...)
+	assert.Equal(exitError, code)
+
+	code, _, stderr = runCmd("", "sign", "--key", key, file)
+	assert.Equal(exitOK, code, stderr)
+	code, stdout, stderr := runCmd("", "verify", "--pub", key+".pub", "--sig", file+".sig", file)
+	assert.Equal(exitOK, code, stderr)
+	assert.Equal("OK\n", stdout)
+
+	// 从标准输入读取消息
+	code, _, _ = runCmd("hello world", "verify", "--pub", key+".pub", "--sig", file+".sig", "-")
+	assert.Equal(exitOK, code)
+	code, _, _ = runCmd("hello", "verify", "--pub", key+".pub", "--sig", file+".sig", "-")
+	assert.Equal(exitInvalid, code)
+
+	// 签名被修改
+	sig, err := ioutil.ReadFile(file + ".sig")
+	assert.Nil(err)
+	sig[len(sig)-1] ^= 1
+	assert.Nil(ioutil.WriteFile(file+".sig", sig, 0644))
+	code, _, _ = runCmd("", "verify", "--pub", key+".pub", "--sig", file+".sig", file)
+	assert.Equal(exitInvalid, code)
+
+	// 公钥不存在
+	code, _, _ = runCmd("", "verify", "--pub", key+".missing", "--sig", file+".sig", file)
+	assert.Equal(exitError, code)
+}
+
+func TestCLIInspect(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	key := filepath.Join(dir, "key")
+	file := filepath.Join(dir, "file")
+	assert.Nil(ioutil.WriteFile(file, []byte("hello world"), 0644))
+
+	code, _, _ := runCmd("", append([]string{"keygen", "--out", key}, testParams...)...)
+	assert.Equal(exitOK, code)
+	code, _, _ = runCmd("", "sign", "--key", key, file)
+	assert.Equal(exitOK, code)
+
+	code, stdout, stderr := runCmd("", "inspect", "--pub", key+".pub", "--json", file+".sig")
+	assert.Equal(exitOK, code, stderr)
+	var res inspectResult
+	assert.Nil(json.Unmarshal([]byte(stdout), &res))
+	assert.Equal("sphincs(256,8,2,16,8,64)", res.Algorithm)
+	assert.Len(res.Index, 2)
+	assert.Len(res.R1, 64)
+	assert.Len(res.Horst.Blocks, 64)
+	assert.Len(res.Layers, 2)
+	assert.Len(res.Layers[0].Sigma, 67)
+	assert.Len(res.Layers[0].Auth, 4)
+
+	// 不需要公钥，直接指定参数
+	code, stdout, _ = runCmd("", append([]string{"inspect"}, append(testParams, file+".sig")...)...)
+	assert.Equal(exitOK, code)
+	assert.Contains(stdout, "R1 ")
+	assert.Contains(stdout, "layer 1 ")
+
+	// 参数和签名的长度不对应
+	code, _, _ = runCmd("", "inspect", "--alg", "sphincs-256", file+".sig")
+	assert.Equal(exitError, code)
+}
+
+func TestCLIUsage(t *testing.T) {
+	assert := assert.New(t)
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"keygen"},
+		{"keygen", "--out", "key", "--alg", "rsa"},
+		{"keygen", "--out", "key", "--alg", "sphincs", "--params", "1,2,3"},
+		{"sign", "file"},
+		{"sign", "--key", "key"},
+		{"verify", "--pub", "key.pub", "file"},
+		{"inspect", "file.sig"},
+		{"sign", "--unknown"},
+		{"estimate", "--alg", "hors"},
+		{"estimate", "--alg", "sphincs-256", "--params", "1,2"},
+		{"estimate", "--alg", "horst", "--params", "33,16,256"},
+		{"estimate", "--signatures", "0"},
+	} {
+		code, _, _ := runCmd("", args...)
+		assert.Equal(exitUsage, code, args)
+	}
+	code, stdout, _ := runCmd("", "help")
+	assert.Equal(exitOK, code)
+	assert.Contains(stdout, "keygen")
+}
+
+func TestCLIEstimate(t *testing.T) {
+	assert := assert.New(t)
+	code, stdout, stderr := runCmd("", "estimate")
+	assert.Equal(exitOK, code, stderr)
+	assert.Contains(stdout, "quantum      128.0 bits")
+	assert.Contains(stdout, "signature    41000 bytes")
+
+	code, stdout, stderr = runCmd("", "estimate", "--alg", "hors", "--params", "16,16", "--signatures", "2", "--json")
+	assert.Equal(exitOK, code, stderr)
+	var e params.Estimate
+	assert.Nil(json.Unmarshal([]byte(stdout), &e))
+	assert.Equal(params.HORS, e.Params.Scheme)
+	assert.Equal(uint64(2), e.Signatures)
+	assert.Equal(128.0, e.Classical)
+}
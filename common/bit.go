@@ -65,6 +65,17 @@ func Xor(a, b []byte) []byte {
 	return tmp
 }
 
+// AppendXor 将 a ^ b 追加到 dst 后面，dst 的容量足够时不分配内存
+func AppendXor(dst, a, b []byte) []byte {
+	if len(a) != len(b) {
+		panic("xor 操作的两个 byte 数组长度应该一样长")
+	}
+	for i := range a {
+		dst = append(dst, a[i]^b[i])
+	}
+	return dst
+}
+
 // Chop 从 b 中截取前 h 位
 func Chop(b []byte, h uint64) (uint64, []byte) {
 	// h 之前已经限制过大小了，h <= 64
@@ -82,3 +93,60 @@ func Cut(i, total, begin, bits uint64) uint64 {
 	move := total - begin - bits
 	return i >> move & (1<<bits - 1)
 }
+
+// Pack 将 values 按照 widths 中对应的比特数紧凑地拼接起来
+// 第一个字段占据最低的比特位，之后的字段依次向高位排列，
+// 结果按照小端序保存，长度为 ceil(sum(widths)/8) 字节
+// 这与 SPHINCS-256 参考实现中 leafaddr 的编码方式一致
+// 每一个字段的宽度不需要是 8 的倍数，但是不能超过 64
+func Pack(values []uint64, widths []uint64) []byte {
+	if len(values) != len(widths) {
+		panic("values 和 widths 的长度应该一致")
+	}
+	var total uint64
+	for _, w := range widths {
+		if w > 64 {
+			panic("每一个字段的宽度不能超过 64 bits")
+		}
+		total += w
+	}
+
+	res := make([]byte, (total+BitSize-1)/BitSize)
+	var pos uint64
+	for i, v := range values {
+		for b := uint64(0); b < widths[i]; b++ {
+			res[pos/BitSize] |= byte(v>>b&1) << (pos % BitSize)
+			pos++
+		}
+	}
+	return res
+}
+
+// Unpack 是 Pack 的逆操作，按照 widths 从 b 中依次取出各个字段
+func Unpack(b []byte, widths []uint64) []uint64 {
+	res := make([]uint64, len(widths))
+	var pos uint64
+	for i, w := range widths {
+		for j := uint64(0); j < w; j++ {
+			res[i] |= uint64(b[pos/BitSize]>>(pos%BitSize)&1) << j
+			pos++
+		}
+	}
+	return res
+}
+
+// ReadBits 将 b 看作低位在前的比特串 (第 i 个 bit 为 b[i/8] 的第 i%8 位)
+// 从第 start 个 bit 开始读取 width bits，低位在前组成一个整数，width 不超过 64
+// 超出 b 的部分按 0 处理
+// 当 width 为 8 的因数或者 8 的倍数时，和按照小端序逐字节解析的结果一致
+func ReadBits(b []byte, start, width uint64) uint64 {
+	var res uint64
+	for j := uint64(0); j < width; j++ {
+		pos := start + j
+		if pos/BitSize >= uint64(len(b)) {
+			break
+		}
+		res |= uint64(b[pos/BitSize]>>(pos%BitSize)&1) << j
+	}
+	return res
+}
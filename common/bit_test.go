@@ -1,6 +1,7 @@
 package common
 
 import (
+	"encoding/binary"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -98,3 +99,51 @@ func TestCut(t *testing.T) {
 	var j uint64 = 0x00000000000000ff // 14 个 0
 	assert.Equal(t, uint64(0), Cut(j, 64, 8, 8))
 }
+
+func TestPack(t *testing.T) {
+	assert := assert.New(t)
+
+	// SPHINCS-256: 4 bits layer, 55 bits tree, 5 bits leaf
+	widths := []uint64{4, 55, 5}
+	values := []uint64{12, 1<<55 - 1, 31}
+	b := Pack(values, widths)
+	assert.Equal(8, len(b))
+	assert.Equal(uint64(12)|(1<<55-1)<<4|31<<59, binary.LittleEndian.Uint64(b))
+	assert.Equal(values, Unpack(b, widths))
+
+	// 宽度不是 8 的倍数，并且总长度超过 64 bits
+	widths = []uint64{3, 61, 7}
+	values = []uint64{5, 1<<60 + 3, 100}
+	b = Pack(values, widths)
+	assert.Equal(9, len(b))
+	assert.Equal(values, Unpack(b, widths))
+
+	assert.Equal([]byte{0x21}, Pack([]uint64{1, 2}, []uint64{4, 4}))
+}
+
+func TestReadBits(t *testing.T) {
+	assert := assert.New(t)
+
+	b := []byte{0x21, 0x43, 0x65, 0x87, 0xa9, 0xcb, 0xed, 0x0f, 0xff}
+	// 宽度为 8 的倍数时和小端序一致
+	assert.Equal(binary.LittleEndian.Uint64(b), ReadBits(b, 0, 64))
+	assert.Equal(uint64(0x4321), ReadBits(b, 0, 16))
+	// 宽度为 4 时，每个字节先取低位再取高位
+	for i, v := range []uint64{1, 2, 3, 4, 5, 6, 7, 8} {
+		assert.Equal(v, ReadBits(b, uint64(4*i), 4))
+	}
+	// 跨字节读取
+	assert.Equal(uint64(0x4321>>5&0x3ff), ReadBits(b, 5, 10))
+	assert.Equal(uint64(0x10), ReadBits(b, 60, 5))
+	// 超出部分为 0
+	assert.Equal(uint64(0xff), ReadBits(b, 64, 16))
+	assert.Equal(uint64(0), ReadBits(b, 72, 8))
+
+	// 和 Pack 互为逆操作
+	widths := []uint64{3, 61, 7}
+	values := []uint64{5, 1<<60 + 3, 100}
+	packed := Pack(values, widths)
+	assert.Equal(values[0], ReadBits(packed, 0, 3))
+	assert.Equal(values[1], ReadBits(packed, 3, 61))
+	assert.Equal(values[2], ReadBits(packed, 64, 7))
+}
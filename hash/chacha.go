@@ -0,0 +1,70 @@
+package hash
+
+import "encoding/binary"
+
+// ChaCha 的常量 "expand 32-byte k"
+var sigma = [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+
+func rotl(v uint32, c uint) uint32 {
+	return v<<c | v>>(32-c)
+}
+
+func quarterRound(x *[16]uint32, a, b, c, d int) {
+	x[a] += x[b]
+	x[d] = rotl(x[d]^x[a], 16)
+	x[c] += x[d]
+	x[b] = rotl(x[b]^x[c], 12)
+	x[a] += x[b]
+	x[d] = rotl(x[d]^x[a], 8)
+	x[c] += x[d]
+	x[b] = rotl(x[b]^x[c], 7)
+}
+
+// chachaRounds 对状态 x 进行 rounds 轮 ChaCha 运算
+// rounds 应该是偶数，每两轮为一次列变换和一次对角线变换
+func chachaRounds(x *[16]uint32, rounds int) {
+	for i := rounds; i > 0; i -= 2 {
+		quarterRound(x, 0, 4, 8, 12)
+		quarterRound(x, 1, 5, 9, 13)
+		quarterRound(x, 2, 6, 10, 14)
+		quarterRound(x, 3, 7, 11, 15)
+		quarterRound(x, 0, 5, 10, 15)
+		quarterRound(x, 1, 6, 11, 12)
+		quarterRound(x, 2, 7, 8, 13)
+		quarterRound(x, 3, 4, 9, 14)
+	}
+}
+
+// ChaChaPermute 对 64 bytes 的输入进行 rounds 轮 ChaCha 置换，结果写入 out
+// 和 ChaCha 流密码不同，这里没有最后的前馈加法，是一个可逆的置换
+// out 和 in 可以是同一个 slice
+func ChaChaPermute(out, in []byte, rounds int) {
+	var x [16]uint32
+	for i := 0; i < 16; i++ {
+		x[i] = binary.LittleEndian.Uint32(in[4*i:])
+	}
+	chachaRounds(&x, rounds)
+	for i := 0; i < 16; i++ {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i])
+	}
+}
+
+// ChaChaBlock 计算 ChaCha 密钥流中的第 counter 个分组 (64 bytes)，结果写入 out
+// 这里采用的是 Bernstein 最初的版本: 256 bits 的 key，64 bits 的 nonce 以及 64 bits 的计数器
+func ChaChaBlock(out, key []byte, nonce, counter uint64, rounds int) {
+	var x, in [16]uint32
+	copy(in[:4], sigma[:])
+	for i := 0; i < 8; i++ {
+		in[4+i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+	in[12] = uint32(counter)
+	in[13] = uint32(counter >> 32)
+	in[14] = uint32(nonce)
+	in[15] = uint32(nonce >> 32)
+
+	x = in
+	chachaRounds(&x, rounds)
+	for i := 0; i < 16; i++ {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i]+in[i])
+	}
+}
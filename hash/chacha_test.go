@@ -0,0 +1,113 @@
+package hash
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/chacha20"
+)
+
+func TestChaChaBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	// 20 轮时应该和标准的 ChaCha20 一致
+	key := make([]byte, 32)
+	for i := 0; i < len(key); i++ {
+		key[i] = byte(i)
+	}
+	c, err := chacha20.NewUnauthenticatedCipher(key, make([]byte, chacha20.NonceSize))
+	assert.Nil(err)
+	expected := make([]byte, 64*3)
+	c.XORKeyStream(expected, expected)
+
+	out := make([]byte, 64)
+	for i := uint64(0); i < 3; i++ {
+		ChaChaBlock(out, key, 0, i, 20)
+		assert.Equal(expected[i*64:(i+1)*64], out)
+	}
+
+	// ChaCha12, key 和 nonce 全为 0
+	ChaChaBlock(out, make([]byte, 32), 0, 0, 12)
+	assert.Equal("9bf49a6a0755f953811fce125f2683d50429c3bb49e074147e0089a52eae155f"+
+		"0564f879d27ae3c02ce82834acfa8c793a629f2ca0de6919610be82f411326be", hex.EncodeToString(out))
+}
+
+func TestChaChaPermute(t *testing.T) {
+	assert := assert.New(t)
+
+	// ChaCha 分组 = 置换(状态) + 状态
+	key := make([]byte, 32)
+	state := make([]byte, 64)
+	copy(state, "expand 32-byte k")
+	block := make([]byte, 64)
+	ChaChaBlock(block, key, 0, 0, 12)
+
+	out := make([]byte, 64)
+	ChaChaPermute(out, state, 12)
+	for i := 0; i < 64; i += 4 {
+		x := uint32(out[i]) | uint32(out[i+1])<<8 | uint32(out[i+2])<<16 | uint32(out[i+3])<<24
+		y := uint32(state[i]) | uint32(state[i+1])<<8 | uint32(state[i+2])<<16 | uint32(state[i+3])<<24
+		x += y
+		assert.Equal([]byte{byte(x), byte(x >> 8), byte(x >> 16), byte(x >> 24)}, block[i:i+4])
+	}
+}
+
+func TestSphincsHash(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("716f6e863f744b9ac22c97ec7b76ea5f5908bc5b2f67c61510bfc4751384ea7a", hex.EncodeToString(Blake256(nil)))
+	assert.Equal("a8cfbbd73726062df0c6864dda65defe58ef0cc52a5625090fa17601e1eecd1b"+
+		"628e94f396ae402a00acc9eab77b4d4c2e852aaaa25a636d80af3fc7913ef5b8", hex.EncodeToString(Blake512(nil)))
+
+	m := make([]byte, 64)
+	for i := 0; i < len(m); i++ {
+		m[i] = byte(i)
+	}
+	// F(M) 为 π(M || C) 的前 256 bits
+	x := make([]byte, 64)
+	copy(x, m[:32])
+	copy(x[32:], "expand 32-byte to 64-byte state!")
+	ChaChaPermute(x, x, 12)
+	assert.Equal(x[:32], F(m[:32]))
+
+	// H(M1 || M2) 在此基础上异或 M2 再做一次置换
+	for i := 0; i < 32; i++ {
+		x[i] ^= m[32+i]
+	}
+	ChaChaPermute(x, x, 12)
+	assert.Equal(x[:32], H(m))
+
+	assert.Panics(func() { F(m) })
+	assert.Panics(func() { H(m[:32]) })
+}
+
+func TestChopHash(t *testing.T) {
+	assert := assert.New(t)
+
+	m := make([]byte, 64)
+	for i := 0; i < len(m); i++ {
+		m[i] = byte(i)
+	}
+	// n = 256 时和 F, H 一致
+	assert.Equal(F(m[:32]), NewF(256)(m[:32]))
+	assert.Equal(H(m), NewH(256)(m))
+
+	// n = 128 时，M 后面补 0 再进行置换
+	f, h := NewF(128), NewH(128)
+	x := make([]byte, 64)
+	copy(x, m[:16])
+	copy(x[32:], "expand 32-byte to 64-byte state!")
+	ChaChaPermute(x, x, 12)
+	assert.Equal(x[:16], f(m[:16]))
+	for i := 0; i < 16; i++ {
+		x[i] ^= m[16+i]
+	}
+	ChaChaPermute(x, x, 12)
+	assert.Equal(x[:16], h(m[:32]))
+
+	assert.Panics(func() { f(m[:32]) })
+	assert.Panics(func() { h(m[:16]) })
+	assert.Panics(func() { NewF(100) })
+	assert.Panics(func() { NewH(512) })
+}
@@ -0,0 +1,281 @@
+package hash
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Haraka v2，SPHINCS+ 中使用的短输入哈希函数，见 https://eprint.iacr.org/2016/098
+// 每一轮对每个 128 bits 的块进行两次 AES 轮函数，然后用 MIX 重新排列 32 bits 的字，一共 5 轮
+// 这里用查表实现 AES 轮函数，不依赖 AES-NI，结果和 SPHINCS+ 参考实现 (haraka-aesni/haraka.c) 一致
+// 状态中的每个块保存为 4 个小端序的 32 bits 字，和 __m128i 的 epi32 分量相同
+
+// harakaRC 标准的轮常量，和参考实现 load_haraka_constants 中 _mm_set_epi32 的参数顺序相同，高位的字在前
+var harakaRC = [40][4]uint32{
+	{0x0684704c, 0xe620c00a, 0xb2c5fef0, 0x75817b9d},
+	{0x8b66b4e1, 0x88f3a06b, 0x640f6ba4, 0x2f08f717},
+	{0x3402de2d, 0x53f28498, 0xcf029d60, 0x9f029114},
+	{0x0ed6eae6, 0x2e7b4f08, 0xbbf3bcaf, 0xfd5b4f79},
+	{0xcbcfb0cb, 0x4872448b, 0x79eecd1c, 0xbe397044},
+	{0x7eeacdee, 0x6e9032b7, 0x8d5335ed, 0x2b8a057b},
+	{0x67c28f43, 0x5e2e7cd0, 0xe2412761, 0xda4fef1b},
+	{0x2924d9b0, 0xafcacc07, 0x675ffde2, 0x1fc70b3b},
+	{0xab4d63f1, 0xe6867fe9, 0xecdb8fca, 0xb9d465ee},
+	{0x1c30bf84, 0xd4b7cd64, 0x5b2a404f, 0xad037e33},
+	{0xb2cc0bb9, 0x941723bf, 0x69028b2e, 0x8df69800},
+	{0xfa0478a6, 0xde6f5572, 0x4aaa9ec8, 0x5c9d2d8a},
+	{0xdfb49f2b, 0x6b772a12, 0x0efa4f2e, 0x29129fd4},
+	{0x1ea10344, 0xf449a236, 0x32d611ae, 0xbb6a12ee},
+	{0xaf044988, 0x4b050084, 0x5f9600c9, 0x9ca8eca6},
+	{0x21025ed8, 0x9d199c4f, 0x78a2c7e3, 0x27e593ec},
+	{0xbf3aaaf8, 0xa759c9b7, 0xb9282ecd, 0x82d40173},
+	{0x6260700d, 0x6186b017, 0x37f2efd9, 0x10307d6b},
+	{0x5aca45c2, 0x21300443, 0x81c29153, 0xf6fc9ac6},
+	{0x9223973c, 0x226b68bb, 0x2caf92e8, 0x36d1943a},
+	{0xd3bf9238, 0x225886eb, 0x6cbab958, 0xe51071b4},
+	{0xdb863ce5, 0xaef0c677, 0x933dfddd, 0x24e1128d},
+	{0xbb606268, 0xffeba09c, 0x83e48de3, 0xcb2212b1},
+	{0x734bd3dc, 0xe2e4d19c, 0x2db91a4e, 0xc72bf77d},
+	{0x43bb47c3, 0x61301b43, 0x4b1415c4, 0x2cb3924e},
+	{0xdba775a8, 0xe707eff6, 0x03b231dd, 0x16eb6899},
+	{0x6df3614b, 0x3c755977, 0x8e5e2302, 0x7eca472c},
+	{0xcda75a17, 0xd6de7d77, 0x6d1be5b9, 0xb88617f9},
+	{0xec6b43f0, 0x6ba8e9aa, 0x9d6c069d, 0xa946ee5d},
+	{0xcb1e6950, 0xf957332b, 0xa2531159, 0x3bf327c1},
+	{0x2cee0c75, 0x00da619c, 0xe4ed0353, 0x600ed0d9},
+	{0xf0b1a5a1, 0x96e90cab, 0x80bbbabc, 0x63a4a350},
+	{0xae3db102, 0x5e962988, 0xab0dde30, 0x938dca39},
+	{0x17bb8f38, 0xd554a40b, 0x8814f3a8, 0x2e75b442},
+	{0x34bb8a5b, 0x5f427fd7, 0xaeb6b779, 0x360a16f6},
+	{0x26f65241, 0xcbe55438, 0x43ce5918, 0xffbaafde},
+	{0x4ce99a54, 0xb9f3026a, 0xa2ca9cf7, 0x839ec978},
+	{0xae51a51a, 0x1bdff7be, 0x40c06e28, 0x22901235},
+	{0xa0c1613c, 0xba7ed22b, 0xc173bc0f, 0x48a659cf},
+	{0x756acc03, 0x02288288, 0x4ad6bdfd, 0xe9c59da1},
+}
+
+type block [4]uint32
+
+var (
+	// rc 按照内存中的顺序保存的轮常量，rc[i][0] 为最低位的字
+	rc [40]block
+	// te AES 轮函数中 SubBytes 和 MixColumns 合并之后的表，只保存第 0 行，其余三行为循环移位
+	te [256]uint32
+)
+
+func init() {
+	for i, c := range harakaRC {
+		rc[i] = block{c[3], c[2], c[1], c[0]}
+	}
+
+	// AES S 盒，p 遍历 GF(2^8) 中的非零元素 (每次乘 3)，q 为 p 的逆元 (每次除以 3)
+	var sbox [256]byte
+	p, q := byte(1), byte(1)
+	for {
+		p ^= xtime(p)
+		q ^= q << 1
+		q ^= q << 2
+		q ^= q << 4
+		if q&0x80 != 0 {
+			q ^= 0x09
+		}
+		sbox[p] = q ^ bits.RotateLeft8(q, 1) ^ bits.RotateLeft8(q, 2) ^ bits.RotateLeft8(q, 3) ^ bits.RotateLeft8(q, 4) ^ 0x63
+		if p == 1 {
+			break
+		}
+	}
+	sbox[0] = 0x63
+
+	for x := range te {
+		s := sbox[x]
+		s2 := xtime(s)
+		te[x] = uint32(s2) | uint32(s)<<8 | uint32(s)<<16 | uint32(s2^s)<<24
+	}
+}
+
+// xtime GF(2^8) 中乘 2，模 x^8 + x^4 + x^3 + x + 1
+func xtime(b byte) byte {
+	return b<<1 ^ (b>>7)*0x1b
+}
+
+// aesRound 和 _mm_aesenc_si128 相同: ShiftRows, SubBytes, MixColumns, AddRoundKey
+func aesRound(s *block, key *block) {
+	var t block
+	for c := 0; c < 4; c++ {
+		t[c] = te[byte(s[c])] ^
+			bits.RotateLeft32(te[byte(s[(c+1)&3]>>8)], 8) ^
+			bits.RotateLeft32(te[byte(s[(c+2)&3]>>16)], 16) ^
+			bits.RotateLeft32(te[byte(s[(c+3)&3]>>24)], 24) ^
+			key[c]
+	}
+	*s = t
+}
+
+// unpackLo 和 _mm_unpacklo_epi32 相同
+func unpackLo(a, b block) block {
+	return block{a[0], b[0], a[1], b[1]}
+}
+
+// unpackHi 和 _mm_unpackhi_epi32 相同
+func unpackHi(a, b block) block {
+	return block{a[2], b[2], a[3], b[3]}
+}
+
+func mix2(s *[2]block) {
+	s[0], s[1] = unpackLo(s[0], s[1]), unpackHi(s[0], s[1])
+}
+
+// mix4 和参考实现中的 MIX4 宏相同
+func mix4(s *[4]block) {
+	tmp := unpackLo(s[0], s[1])
+	s0 := unpackHi(s[0], s[1])
+	s1 := unpackLo(s[2], s[3])
+	s2 := unpackHi(s[2], s[3])
+	s[3] = unpackLo(s0, s2)
+	s[0] = unpackHi(s0, s2)
+	s[2] = unpackHi(s1, tmp)
+	s[1] = unpackLo(s1, tmp)
+}
+
+func loadBlocks(dst []block, in []byte) {
+	for i := range dst {
+		for j := range dst[i] {
+			dst[i][j] = binary.LittleEndian.Uint32(in[16*i+4*j:])
+		}
+	}
+}
+
+func storeBlocks(out []byte, src []block) {
+	for i := range src {
+		for j := range src[i] {
+			binary.LittleEndian.PutUint32(out[16*i+4*j:], src[i][j])
+		}
+	}
+}
+
+func haraka512Rounds(s *[4]block) {
+	for r := 0; r < 5; r++ {
+		k := rc[8*r:]
+		for i := range s {
+			aesRound(&s[i], &k[i])
+		}
+		for i := range s {
+			aesRound(&s[i], &k[4+i])
+		}
+		mix4(s)
+	}
+}
+
+// Haraka512Perm Haraka-512 中的 512 bits 置换，没有前馈和截取，Haraka 海绵结构中使用
+// out 和 in 都为 64 bytes，可以是同一个 slice
+func Haraka512Perm(out, in []byte) {
+	if len(in) != 64 || len(out) != 64 {
+		panic("Haraka-512 置换的输入和输出应该为 512 bits")
+	}
+	var s [4]block
+	loadBlocks(s[:], in)
+	haraka512Rounds(&s)
+	storeBlocks(out, s[:])
+}
+
+// Haraka512 Haraka-512 v2: {0,1}^512 -> {0,1}^256
+// 置换的结果和输入异或之后，取第 0, 1 块的高 64 bits 以及第 2, 3 块的低 64 bits
+// out 为 32 bytes，可以和 in 重叠
+func Haraka512(out, in []byte) {
+	if len(in) != 64 || len(out) != 32 {
+		panic("Haraka-512 的输入应该为 512 bits，输出为 256 bits")
+	}
+	var s, m [4]block
+	loadBlocks(m[:], in)
+	s = m
+	haraka512Rounds(&s)
+	for i := range s {
+		for j := range s[i] {
+			s[i][j] ^= m[i][j]
+		}
+	}
+	trunc := [2]block{{s[0][2], s[0][3], s[1][2], s[1][3]}, {s[2][0], s[2][1], s[3][0], s[3][1]}}
+	storeBlocks(out, trunc[:])
+}
+
+// Haraka256 Haraka-256 v2: {0,1}^256 -> {0,1}^256，out 和 in 都为 32 bytes，可以是同一个 slice
+func Haraka256(out, in []byte) {
+	if len(in) != 32 || len(out) != 32 {
+		panic("Haraka-256 的输入和输出应该为 256 bits")
+	}
+	var s, m [2]block
+	loadBlocks(m[:], in)
+	s = m
+	for r := 0; r < 5; r++ {
+		k := rc[4*r:]
+		aesRound(&s[0], &k[0])
+		aesRound(&s[1], &k[1])
+		aesRound(&s[0], &k[2])
+		aesRound(&s[1], &k[3])
+		mix2(&s)
+	}
+	for i := range s {
+		for j := range s[i] {
+			s[i][j] ^= m[i][j]
+		}
+	}
+	storeBlocks(out, s[:])
+}
+
+// harakaRate Haraka 海绵结构的 rate，256 bits
+const harakaRate = 32
+
+// HarakaS SPHINCS+ 中的 Haraka 海绵结构 (Haraka-S)，置换为 Haraka512Perm，rate 为 256 bits
+// 填充和 SHAKE 一样为 0x1F || 0* || 0x80，输出长度不限
+// 先通过 Write 吸收输入，第一次 Read 之后不能再 Write
+type HarakaS struct {
+	state [64]byte
+	// 吸收时为当前块中已经写入的字节数，挤压时为当前块中还没有读取的字节数
+	n         int
+	squeezing bool
+}
+
+// NewHarakaS 返回一个新的 Haraka 海绵
+func NewHarakaS() *HarakaS {
+	return &HarakaS{}
+}
+
+// Reset 清空状态，重新开始吸收
+func (h *HarakaS) Reset() {
+	*h = HarakaS{}
+}
+
+func (h *HarakaS) Write(p []byte) (int, error) {
+	if h.squeezing {
+		panic("HarakaS 在读取之后不能写入")
+	}
+	for _, b := range p {
+		h.state[h.n] ^= b
+		h.n++
+		if h.n == harakaRate {
+			Haraka512Perm(h.state[:], h.state[:])
+			h.n = 0
+		}
+	}
+	return len(p), nil
+}
+
+func (h *HarakaS) Read(p []byte) (int, error) {
+	if !h.squeezing {
+		h.state[h.n] ^= 0x1f
+		h.state[harakaRate-1] ^= 0x80
+		h.squeezing = true
+		h.n = 0
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if h.n == 0 {
+			Haraka512Perm(h.state[:], h.state[:])
+			h.n = harakaRate
+		}
+		c := copy(p, h.state[harakaRate-h.n:harakaRate])
+		h.n -= c
+		p = p[c:]
+	}
+	return n, nil
+}
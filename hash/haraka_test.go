@@ -0,0 +1,92 @@
+package hash
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试向量由 SPHINCS+ 参考实现 (haraka-aesni/haraka.c) 生成，输入为 0, 1, 2, ...
+// Haraka256 和 Haraka512 的结果和 Haraka v2 论文中的测试向量相同
+
+func harakaInput(n int) []byte {
+	in := make([]byte, n)
+	for i := range in {
+		in[i] = byte(i)
+	}
+	return in
+}
+
+func TestHaraka(t *testing.T) {
+	assert := assert.New(t)
+
+	out := make([]byte, 32)
+	Haraka256(out, harakaInput(32))
+	assert.Equal("8027ccb87949774b78d0545fb72bf70c695c2a0923cbd47bba1159efbf2b2c1c", hex.EncodeToString(out))
+
+	Haraka512(out, harakaInput(64))
+	assert.Equal("be7f723b4e80a99813b292287f306f625a6d57331cae5f34dd9277b0945be2aa", hex.EncodeToString(out))
+
+	perm := make([]byte, 64)
+	Haraka512Perm(perm, harakaInput(64))
+	assert.Equal("c7caf3dad89bdfeeb6767830428da797bdc681cb931b3ad50bab8833632d717d"+
+		"7a4c7510388b79133e460893770652dceda34583a06ed49ddeeeed2e9ab78e12", hex.EncodeToString(perm))
+
+	// 输入和输出可以是同一个 slice
+	in := harakaInput(32)
+	Haraka256(in, in)
+	Haraka256(out, harakaInput(32))
+	assert.Equal(out, in)
+
+	assert.Panics(func() { Haraka256(out, harakaInput(31)) })
+	assert.Panics(func() { Haraka512(perm, harakaInput(64)) })
+}
+
+func TestHarakaS(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		inLen    int
+		expected string
+	}{
+		{0, "ae551e5b5bfb0c3e4febd1003dc18065769bae2d06ab3870aa4169fd7a529b52ccd04a93dcefb0cc882c3983acb0ca619793" +
+			"26da387d73a1349434a8b03dce4b7684eb9c54c81e5624186c59ee803999dc3f4e3271218a52f139a351685c4f3d2ab12069"},
+		{1, "a9632012c6361aef66681cfc1841d41d96c52a2d70ba06d8bc3d50127ee209a63986210ed06d8848472c653209c8f262726e" +
+			"2c3aa984ac6639c04e0fa365b37f11fdc700a79e6d78d89858b44b280921ea1c5e7afaa8c0ae418d1ab6f60dd0185136a961"},
+		{31, "22bce7ebfaf59bc8c4479a05b3f26b87df17aece54b0b31be5eae8f7b5d77991e0470878172b8a84d6a2842083677fa42043" +
+			"6e121031926bf76ea10bc3eeadb9654fbb76446d3d790c44f0f98049ec0208f6e252b8ce17e28e15dbeb6c9ca6a4776d5fdf"},
+		{32, "4b50398c5072bd5d2f255ea8fc7b2c7735e3d9b32fc4ab86abde9953a9453306c88554c56c914cefbb3883df4337e3e291d3" +
+			"36d13ddf02bbda6c1f14a5376a3ffa0cad80d329b6f13dddf362ccde2358e992949891a860c4fe0c4396105c3531516a5414"},
+		{33, "c90f93bdfb170829cc5290dbaccaddc75958c90ef770aed8d51051a37ffc006ab6488cd3e271d208cf0892f5001d1cea40eb" +
+			"fc5475efce80efa1b1cb9335a1af31a70248947eab064b9c620a648f500ff72391e7a350af5fd26ef0f4402b9a46103ccc7b"},
+		{64, "cfbc92bc9b22ec2dd8245e3f7335083551a3c22754d45a2939e58682971989999d75c22d9fe41f831d55cb05220baf98f864" +
+			"9d5b7aeef2f0b3b8b550573c8eab1d613713ecea6fd0d7031e945198388af771168325bafc5f2850217a20318ce633218790"},
+		{200, "f29cf607b0aa5b7f1385a73b8bf9c4ef1d520ba60d7e930600d30df2fbc0f655eb0b401db468b612cc2c322b5d7a9dee0789" +
+			"b56cc279a32b453871285dc94bf216ea56965944323b5209db5c1cf586e46a0b757d510e1e61a2926b78f0e088c6b8cae05a"},
+	}
+	for _, test := range tests {
+		in := harakaInput(test.inLen)
+		h := NewHarakaS()
+		h.Write(in)
+		out := make([]byte, 100)
+		h.Read(out)
+		assert.Equal(test.expected, hex.EncodeToString(out), test.inLen)
+
+		// 分多次写入和读取的结果相同
+		h.Reset()
+		h.Write(in[:test.inLen/3])
+		h.Write(in[test.inLen/3:])
+		parts := make([]byte, 0, 100)
+		for _, l := range []int{1, 31, 33, 35} {
+			b := make([]byte, l)
+			h.Read(b)
+			parts = append(parts, b...)
+		}
+		assert.Equal(out, parts, test.inLen)
+	}
+
+	h := NewHarakaS()
+	h.Read(make([]byte, 1))
+	assert.Panics(func() { h.Write([]byte{1}) })
+}
@@ -45,6 +45,7 @@ func h(hash Hash, a, b []byte) []byte {
 }
 
 // HashTimesWithMask 哈希消息 end-start 次，同时会加入掩码计算
+// 每一步都会分配内存，大量调用时使用 Hasher.Chain
 // 假设 message n bits ，那么掩码 (end-start) * n bits
 func HashTimesWithMask(message []byte, start, end int, hash Hash, mask []byte) []byte {
 	n := len(message)
@@ -61,7 +62,7 @@ func HashTimesWithMask(message []byte, start, end int, hash Hash, mask []byte) [
 }
 
 // CombineAndHash 首先连接两个字符串，然后进行哈希
-// TODO: 这里涉及的内存分配太多了，能不能尽量减少内存的分配
+// 每次调用都会分配内存，大量调用时使用 Hasher.Combine
 func CombineAndHash(a, b []byte, hash Hash) []byte {
 	tmp := make([]byte, len(a)+len(b))
 	copy(tmp[:len(a)], a)
@@ -0,0 +1,219 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	stdhash "hash"
+
+	"github.com/dchest/blake256"
+	"github.com/dchest/blake512"
+	"github.com/junhaideng/sphincs/common"
+	"golang.org/x/crypto/sha3"
+)
+
+// Hasher 复用内部状态以及缓冲区的哈希函数
+// Sum 和标准库 hash.Hash 的 Sum 一样，把结果追加到 dst 后面，dst 的容量足够时不会分配内存
+// 所以调用者可以一直使用同一个缓冲区，WOTS+ 的链式哈希，Merkle 树等大量调用哈希函数的地方不再每次分配内存
+//
+// Hasher 不能被多个 goroutine 同时使用，签名算法中保存的 Hasher 只作为原型，每次使用之前 Clone
+type Hasher struct {
+	size int
+	// 以下几种实现中只有一个不为空
+	// 流式的哈希函数，例如 SHA-256, BLAKE-512
+	state    stdhash.Hash
+	newState func() stdhash.Hash
+	// SHAKE，输出 size bytes
+	shake    sha3.ShakeHash
+	newShake func() sha3.ShakeHash
+	// 输入长度固定的哈希函数，例如基于 ChaCha12 置换的 F 和 H，结果写入 out
+	fixed func(out, in []byte)
+	// 普通的 Hash，每次调用都会分配内存
+	fn Hash
+
+	// in 连接之后的输入，out 哈希值，xor 异或掩码之后的节点，chain 链式哈希的中间值
+	in, out, xor, chain []byte
+}
+
+// NewHasher 使用标准库 hash.Hash 接口的流式哈希函数，输出为完整的哈希值
+func NewHasher(newState func() stdhash.Hash) *Hasher {
+	s := newState()
+	return &Hasher{size: s.Size(), state: s, newState: newState}
+}
+
+// NewTruncatedHasher 和 NewHasher 一样，只保留哈希值的前 size bytes
+func NewTruncatedHasher(newState func() stdhash.Hash, size int) *Hasher {
+	h := NewHasher(newState)
+	if size <= 0 || size > h.size {
+		panic("size 应该为正数，并且不超过哈希值的长度")
+	}
+	h.size = size
+	return h
+}
+
+// NewShakeHasher 使用 SHAKE 等可扩展输出的哈希函数，输出 size bytes
+func NewShakeHasher(newShake func() sha3.ShakeHash, size int) *Hasher {
+	return &Hasher{size: size, shake: newShake(), newShake: newShake}
+}
+
+// NewFixedHasher f 把 in 的哈希值写入 out，out 为 size bytes
+// 适用于基于置换的哈希函数，f 中不应该分配内存
+func NewFixedHasher(size int, f func(out, in []byte)) *Hasher {
+	return &Hasher{size: size, fixed: f}
+}
+
+// FromHash 将普通的 Hash 包装成 Hasher，用于 WithHash 等传入任意哈希函数的情况
+// 每次调用都会分配内存，size 为输出的字节数
+func FromHash(h Hash, size int) *Hasher {
+	return &Hasher{size: size, fn: h}
+}
+
+// NewSha256Hasher SHA-256
+func NewSha256Hasher() *Hasher {
+	return NewHasher(sha256.New)
+}
+
+// NewSha512Hasher SHA-512
+func NewSha512Hasher() *Hasher {
+	return NewHasher(sha512.New)
+}
+
+// NewBlake256Hasher BLAKE-256，SPHINCS-256 中的 Fα
+func NewBlake256Hasher() *Hasher {
+	return NewHasher(blake256.New)
+}
+
+// NewBlake512Hasher BLAKE-512，SPHINCS-256 中的 H_msg 以及 F
+func NewBlake512Hasher() *Hasher {
+	return NewHasher(blake512.New)
+}
+
+// NewFHasher 和 NewF(n) 相同的 F
+func NewFHasher(n int) *Hasher {
+	checkChopSize(n)
+	size := n / 8
+	return NewFixedHasher(size, func(out, in []byte) {
+		if len(in) != size {
+			panic("F 的输入长度不正确")
+		}
+		var x [64]byte
+		copy(x[:], in)
+		copy(x[32:], hashc)
+		ChaChaPermute(x[:], x[:], chachaRounds12)
+		copy(out, x[:size])
+	})
+}
+
+// NewHHasher 和 NewH(n) 相同的 H
+func NewHHasher(n int) *Hasher {
+	checkChopSize(n)
+	size := n / 8
+	return NewFixedHasher(size, func(out, in []byte) {
+		if len(in) != 2*size {
+			panic("H 的输入长度不正确")
+		}
+		var x [64]byte
+		copy(x[:], in[:size])
+		copy(x[32:], hashc)
+		ChaChaPermute(x[:], x[:], chachaRounds12)
+		for i := 0; i < size; i++ {
+			x[i] ^= in[size+i]
+		}
+		ChaChaPermute(x[:], x[:], chachaRounds12)
+		copy(out, x[:size])
+	})
+}
+
+// Size 哈希值的字节数
+func (h *Hasher) Size() int {
+	return h.size
+}
+
+// Clone 返回一个新的 Hasher，只复制哈希函数，不复制缓冲区
+// 只读取 h 中不会改变的字段，所以可以在多个 goroutine 中同时 Clone 同一个原型
+func (h *Hasher) Clone() *Hasher {
+	c := &Hasher{size: h.size, newState: h.newState, newShake: h.newShake, fixed: h.fixed, fn: h.fn}
+	if h.newState != nil {
+		c.state = h.newState()
+	}
+	if h.newShake != nil {
+		c.shake = h.newShake()
+	}
+	return c
+}
+
+// Sum 计算 parts 连接之后的哈希值，追加到 dst 后面并返回
+// 读取完所有输入之后才会写入 dst，所以 dst 可以和 parts 重叠，例如 h.Sum(x[:0], x)
+func (h *Hasher) Sum(dst []byte, parts ...[]byte) []byte {
+	switch {
+	case h.state != nil:
+		h.state.Reset()
+		for _, p := range parts {
+			h.state.Write(p)
+		}
+		h.out = h.state.Sum(h.out[:0])
+		return append(dst, h.out[:h.size]...)
+	case h.shake != nil:
+		h.shake.Reset()
+		for _, p := range parts {
+			h.shake.Write(p)
+		}
+		h.out = grow(h.out[:0], h.size)
+		h.shake.Read(h.out)
+		return append(dst, h.out...)
+	}
+
+	h.in = h.in[:0]
+	for _, p := range parts {
+		h.in = append(h.in, p...)
+	}
+	if h.fixed != nil {
+		h.out = grow(h.out[:0], h.size)
+		h.fixed(h.out, h.in)
+		return append(dst, h.out...)
+	}
+	return append(dst, h.fn(h.in)...)
+}
+
+// Chain 和 HashTimesWithMask 相同，从 start 到 end 依次计算 x = Hash(x ⊕ mask_i)，结果追加到 dst 后面
+// mask 为空时不使用掩码，此时和 HashTimes(x, end-start, hash) 相同
+func (h *Hasher) Chain(dst, x []byte, start, end int, mask []byte) []byte {
+	n := len(x)
+	h.chain = append(h.chain[:0], x...)
+	for i := start; i < end; i++ {
+		if mask == nil {
+			h.chain = h.Sum(h.chain[:0], h.chain)
+			continue
+		}
+		h.xor = common.AppendXor(h.xor[:0], h.chain, mask[i*n:(i+1)*n])
+		h.chain = h.Sum(h.chain[:0], h.xor)
+	}
+	return append(dst, h.chain...)
+}
+
+// Combine 计算 Hash((left ⊕ mask_0) || (right ⊕ mask_1))，结果追加到 dst 后面
+// mask 为两个和 left 一样长的块，为空时和 CombineAndHash(left, right, hash) 相同
+func (h *Hasher) Combine(dst, left, right, mask []byte) []byte {
+	if mask == nil {
+		return h.Sum(dst, left, right)
+	}
+	n := len(left)
+	h.xor = common.AppendXor(h.xor[:0], left, mask[:n])
+	h.xor = common.AppendXor(h.xor, right, mask[n:2*n])
+	return h.Sum(dst, h.xor)
+}
+
+// Hash 返回使用 h 计算的 Hash，结果每次都是新分配的
+// 和 h 一样不能被多个 goroutine 同时使用，用于只接受 Hash 的函数，例如 merkle.ComputeRootFromLeafWithMask
+func (h *Hasher) Hash() Hash {
+	return func(b []byte) []byte {
+		return h.Sum(make([]byte, 0, h.size), b)
+	}
+}
+
+// grow 返回长度为 n 的 b，容量不够时才重新分配
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
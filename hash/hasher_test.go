@@ -0,0 +1,138 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestHasher(t *testing.T) {
+	assert := assert.New(t)
+	shake := func(b []byte) []byte {
+		out := make([]byte, 32)
+		sha3.ShakeSum256(out, b)
+		return out
+	}
+	for _, c := range []struct {
+		name   string
+		hasher *Hasher
+		hash   Hash
+		input  int
+	}{
+		{"sha256", NewSha256Hasher(), Sha256, 32},
+		{"sha512", NewSha512Hasher(), Sha512, 64},
+		{"blake256", NewBlake256Hasher(), Blake256, 32},
+		{"blake512", NewBlake512Hasher(), Blake512, 64},
+		{"f", NewFHasher(256), F, 32},
+		{"f-128", NewFHasher(128), NewF(128), 16},
+		{"h", NewHHasher(256), H, 64},
+		{"h-192", NewHHasher(192), NewH(192), 48},
+		{"shake256", NewShakeHasher(sha3.NewShake256, 32), shake, 32},
+		{"truncated", NewTruncatedHasher(sha256.New, 16), func(b []byte) []byte { return Sha256(b)[:16] }, 16},
+		{"from-hash", FromHash(Sha256, 32), Sha256, 32},
+	} {
+		h := c.hasher.Clone()
+		in := make([]byte, c.input)
+		rand.Read(in)
+		assert.Equal(c.hash(in), h.Sum(nil, in), c.name)
+		assert.Equal(c.hash(in), h.Sum(nil, in[:c.input/2], nil, in[c.input/2:]), c.name)
+		assert.Equal(c.hash(in), h.Hash()(in), c.name)
+		assert.Equal(c.hasher.Size(), len(h.Sum(nil, in)), c.name)
+
+		// dst 和输入重叠
+		buf := append([]byte(nil), in...)
+		assert.Equal(c.hash(in), h.Sum(buf[:0], buf), c.name)
+
+		if c.input/2 != c.hasher.Size() {
+			continue
+		}
+		// 输入为两个块的哈希函数
+		left, right := in[:c.input/2], in[c.input/2:]
+		mask := make([]byte, c.input)
+		rand.Read(mask)
+		assert.Equal(CombineAndHash(left, right, c.hash), h.Combine(nil, left, right, nil), c.name)
+		assert.Equal(CombineAndHash(common.Xor(left, mask[:len(left)]), common.Xor(right, mask[len(left):]), c.hash),
+			h.Combine(nil, left, right, mask), c.name)
+	}
+
+	for _, c := range []struct {
+		hasher *Hasher
+		hash   Hash
+	}{
+		{NewSha256Hasher(), Sha256},
+		{NewFHasher(256), F},
+		{NewFHasher(128), NewF(128)},
+	} {
+		n := c.hasher.Size()
+		x := make([]byte, n)
+		mask := make([]byte, 15*n)
+		rand.Read(x)
+		rand.Read(mask)
+		assert.Equal(HashTimesWithMask(x, 0, 15, c.hash, mask), c.hasher.Chain(nil, x, 0, 15, mask))
+		assert.Equal(HashTimesWithMask(x, 3, 9, c.hash, mask), c.hasher.Chain(nil, x, 3, 9, mask))
+		assert.Equal(HashTimes(x, 7, c.hash), c.hasher.Chain(nil, x, 0, 7, nil))
+		assert.Equal(x, c.hasher.Chain(nil, x, 4, 4, mask))
+	}
+}
+
+// TestHasherAllocs dst 的容量足够时不分配内存
+func TestHasherAllocs(t *testing.T) {
+	assert := assert.New(t)
+	x := make([]byte, 64)
+	mask := make([]byte, 15*32)
+	dst := make([]byte, 0, 64)
+	for _, c := range []struct {
+		hasher *Hasher
+		// 单个块的哈希函数可以计算链式哈希，两个块的可以合并节点
+		chain bool
+	}{
+		{NewSha256Hasher(), true},
+		{NewBlake256Hasher(), true},
+		{NewShakeHasher(sha3.NewShake256, 32), true},
+		{NewFHasher(256), true},
+		{NewHHasher(256), false},
+	} {
+		h := c.hasher
+		if c.chain {
+			// 第一次调用时分配内部的缓冲区
+			h.Chain(dst, x[:32], 0, 15, mask)
+			assert.Equal(0.0, testing.AllocsPerRun(100, func() {
+				dst = h.Sum(dst[:0], x[:32])
+			}))
+			assert.Equal(0.0, testing.AllocsPerRun(100, func() {
+				dst = h.Chain(dst[:0], x[:32], 0, 15, mask)
+			}))
+			continue
+		}
+		h.Combine(dst, x[:32], x[32:], mask[:64])
+		assert.Equal(0.0, testing.AllocsPerRun(100, func() {
+			dst = h.Combine(dst[:0], x[:32], x[32:], mask[:64])
+		}))
+		assert.Equal(0.0, testing.AllocsPerRun(100, func() {
+			dst = h.Combine(dst[:0], x[:32], x[32:], nil)
+		}))
+	}
+}
+
+func BenchmarkHasher(b *testing.B) {
+	x := make([]byte, 32)
+	mask := make([]byte, 15*32)
+	b.Run("hash-times-with-mask", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = HashTimesWithMask(x, 0, 15, F, mask)
+		}
+	})
+	b.Run("chain", func(b *testing.B) {
+		b.ReportAllocs()
+		h := NewFHasher(256)
+		dst := make([]byte, 0, 32)
+		for i := 0; i < b.N; i++ {
+			dst = h.Chain(dst[:0], x, 0, 15, mask)
+		}
+	})
+}
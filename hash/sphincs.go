@@ -1,42 +1,143 @@
 package hash
 
-import "github.com/junhaideng/sphincs/common"
+import (
+	"github.com/dchest/blake256"
+	"github.com/dchest/blake512"
+)
 
 // 哈希函数 FOR SPHINCS-256
-// 见论文的 表1(p22) 定义，这里并没严格实现，不过保证输出的长度相同
+// 见论文的 表1(p22) 定义，和参考实现保持一致
+//   H_msg: BLAKE-512
+//   F_a:   BLAKE-256
+//   F, H:  基于 ChaCha12 置换
+//   G:     ChaCha12 流密码，见 rand.NewChaCha12
+
+// chachaRounds12 SPHINCS-256 中 ChaCha 置换的轮数
+const chachaRounds12 = 12
+
+// hashc F 和 H 中使用的常量，填充置换输入的后 256 bits
+var hashc = []byte("expand 32-byte to 64-byte state!")
+
+// Blake256 maps data to 256 bits using BLAKE-256
+func Blake256(b []byte) []byte {
+	s := blake256.New()
+	s.Write(b)
+	return s.Sum(nil)
+}
+
+// Blake512 maps data to 512 bits using BLAKE-512
+func Blake512(b []byte) []byte {
+	s := blake512.New()
+	s.Write(b)
+	return s.Sum(nil)
+}
 
 // HashMessage 对消息进行哈希，加了一个随机数，生成随机摘要值
+// H(R, M) = BLAKE-512(R || M)
 func HashMessage(rand, message []byte) []byte {
 	tmp := make([]byte, len(message)+len(rand))
 	copy(tmp, rand)
 	copy(tmp[len(rand):], message)
-	return Sha512(tmp)
+	return Blake512(tmp)
 }
 
-// FuncAlpha PRG Fα
+// FuncAlpha PRF Fα，根据密钥对的地址生成对应的随机数种子
+// Fα(A, K) = BLAKE-256(K || A)
 func FuncAlpha(address, key []byte) []byte {
 	tmp := make([]byte, len(address)+len(key))
-	copy(tmp, address)
-	copy(tmp[len(address):], key)
-	return Sha256(tmp)
+	copy(tmp, key)
+	copy(tmp[len(key):], address)
+	return Blake256(tmp)
 }
 
-// Func PRG F
+// Func PRF F
 // 对消息进行处理，生成 512 bit 的摘要值
+// F(M, K) = BLAKE-512(K || M)
 func Func(message, key []byte) []byte {
 	tmp := make([]byte, len(message)+len(key))
 	copy(tmp, key)
 	copy(tmp[len(key):], message)
-	return Sha512(tmp)
+	return Blake512(tmp)
 }
 
-// F hash function F
+// F hash function F: {0,1}^256 -> {0,1}^256
+// F(M) = Chop(π(M || C), 256)
 func F(message []byte) []byte {
-	return Sha256(message)
+	if len(message) != 32 {
+		panic("F 的输入应该为 256 bits")
+	}
+	x := make([]byte, 64)
+	copy(x, message)
+	copy(x[32:], hashc)
+	ChaChaPermute(x, x, chachaRounds12)
+	return x[:32]
+}
+
+// H hash function H: {0,1}^512 -> {0,1}^256
+// message 为两个 256 bits 的块 M1 || M2
+// H(M1 || M2) = Chop(π(π(M1 || C) ⊕ (M2 || 0^256)), 256)
+func H(message []byte) []byte {
+	if len(message) != 64 {
+		panic("H 的输入应该为 512 bits")
+	}
+	x := make([]byte, 64)
+	copy(x, message[:32])
+	copy(x[32:], hashc)
+	ChaChaPermute(x, x, chachaRounds12)
+	for i := 0; i < 32; i++ {
+		x[i] ^= message[32+i]
+	}
+	ChaChaPermute(x, x, chachaRounds12)
+	return x[:32]
+}
+
+// NewF 返回输出为 n bits 的 F，n 为 8 的倍数并且不超过 256
+// n = 256 时就是 F，否则输入不足 256 bits 的部分补 0 之后再截取前 n bits
+// F(M) = Chop(π(M || 0^{256-n} || C), n)
+func NewF(n int) Hash {
+	if n == 256 {
+		return F
+	}
+	checkChopSize(n)
+	size := n / 8
+	return func(message []byte) []byte {
+		if len(message) != size {
+			panic("F 的输入长度不正确")
+		}
+		x := make([]byte, 64)
+		copy(x, message)
+		copy(x[32:], hashc)
+		ChaChaPermute(x, x, chachaRounds12)
+		return x[:size]
+	}
+}
+
+// NewH 返回输出为 n bits 的 H，输入为两个 n bits 的块
+// H(M1 || M2) = Chop(π(π(M1 || 0^{256-n} || C) ⊕ (M2 || 0^{512-n})), n)
+func NewH(n int) Hash {
+	if n == 256 {
+		return H
+	}
+	checkChopSize(n)
+	size := n / 8
+	return func(message []byte) []byte {
+		if len(message) != 2*size {
+			panic("H 的输入长度不正确")
+		}
+		x := make([]byte, 64)
+		copy(x, message[:size])
+		copy(x[32:], hashc)
+		ChaChaPermute(x, x, chachaRounds12)
+		for i := 0; i < size; i++ {
+			x[i] ^= message[size+i]
+		}
+		ChaChaPermute(x, x, chachaRounds12)
+		return x[:size]
+	}
 }
 
-// H hash function H
-func H(m1, m2 []byte) []byte {
-	tmp := common.Xor(m1, m2)
-	return Sha256(tmp)
+func checkChopSize(n int) {
+	if n <= 0 || n%8 != 0 || n > 256 {
+		panic("n 应该是 8 的倍数，并且不超过 256")
+	}
 }
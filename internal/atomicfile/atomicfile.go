@@ -0,0 +1,46 @@
+// Package atomicfile 原子地替换文件，keystore 和 keystate 共用
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// WriteFile 写入 dir 中的临时文件并 fsync，然后 rename 成 name，最后 fsync dir
+// name 应该在 dir 中，返回 nil 时新的内容以及目录项一定已经落盘
+// 临时文件的权限为 0600
+func WriteFile(dir, name string, data []byte) error {
+	f, err := ioutil.TempFile(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return SyncDir(dir)
+}
+
+// SyncDir rename 之后 fsync 目录，保证新的目录项落盘
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,34 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFile(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "key")
+
+	assert.Nil(WriteFile(dir, name, []byte("first")))
+	assert.Nil(WriteFile(dir, name, []byte("second")))
+	b, err := ioutil.ReadFile(name)
+	assert.Nil(err)
+	assert.Equal("second", string(b))
+
+	info, err := os.Stat(name)
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// 失败时不留下临时文件
+	assert.NotNil(WriteFile(dir, filepath.Join(dir, "missing", "key"), []byte("x")))
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(err)
+	assert.Equal(1, len(files))
+
+	assert.NotNil(SyncDir(filepath.Join(dir, "missing")))
+}
@@ -0,0 +1,474 @@
+// Package keys 将哈希签名的密钥编码成 SubjectPublicKeyInfo (公钥) 以及 PKCS#8 (私钥)，DER 或者 PEM 格式
+// 可以交给 openssl 一类的工具处理，或者保存到密钥管理系统中
+//
+// 支持的算法以及 OID:
+//
+//	SLH-DSA   NIST 分配的 id-slh-dsa-*，2.16.840.1.101.3.4.3.20 ~ 31，parameters 为空
+//	SPHINCS   PrivateArc.1 为 SPHINCS-256，parameters 为空
+//	          PrivateArc.2 为其他参数集，parameters 为 SEQUENCE { n, h, d, w, tau, k }
+//	WOTS+     PrivateArc.3，parameters 为 SEQUENCE { w, n, mask }
+//	HORST     PrivateArc.4，parameters 为 SEQUENCE { tau, k, n, mask }
+//	Lamport   PrivateArc.5，parameters 为 SEQUENCE { n }
+//	WOTS      PrivateArc.6，parameters 为 SEQUENCE { w, n }
+//	HORS      PrivateArc.7，parameters 为 SEQUENCE { tau, k }，t = 2^tau
+//
+// 通过 signature.WithHashSuite 设置了哈希函数族的 SPHINCS (默认的 suite.SPHINCS256 除外)，WOTS+ 以及 HORST
+// 使用单独的 OID，parameters 的最后为哈希函数族的编号 (suite.ID)
+//
+//	SPHINCS   PrivateArc.8，parameters 为 SEQUENCE { n, h, d, w, tau, k, suite }
+//	WOTS+     PrivateArc.9，parameters 为 SEQUENCE { w, n, mask, suite }
+//	HORST     PrivateArc.10，parameters 为 SEQUENCE { tau, k, n, mask, suite }
+//
+// 自定义的 (没有编号的) 哈希函数族无法编码，返回 ErrUnsupportedSuite
+//
+// 公钥以及私钥的内容都是 GenerateKey 返回的字节串，不再进行额外的编码
+package keys
+
+import (
+	crand "crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+
+	"github.com/junhaideng/sphincs/signature"
+	"github.com/junhaideng/sphincs/suite"
+)
+
+// PrivateArc 没有标准 OID 的算法使用的 OID 前缀
+// 注意！这是私有并且不稳定的 OID，之后的版本可能会改变，不要用于长期保存的密钥或者和其他实现互通
+//
+// 默认值为 IANA 分配给文档和示例使用的企业编号 32473 (RFC 5612) 下的 1.3.6.1.4.1.32473.1，不会和已经注册的 OID 冲突
+// 部署之前应该替换成自己组织的 OID (1.3.6.1.4.1.<PEN>)
+// 不使用 2.25.<UUID>，因为 crypto/x509 以及 encoding/asn1 无法解析超过 31 bits 的 OID 分量，生成的证书会无法解析
+var PrivateArc = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 1}
+
+var (
+	ErrUnknownAlgorithm = errors.New("keys: unknown algorithm")
+	ErrInvalidKey       = errors.New("keys: invalid key encoding")
+	ErrUnsupportedSuite = errors.New("keys: hash suite has no registered id")
+)
+
+// PEM 的类型，和 x509.MarshalPKIXPublicKey, MarshalPKCS8PrivateKey 一致
+const (
+	pemPublicKey  = "PUBLIC KEY"
+	pemPrivateKey = "PRIVATE KEY"
+)
+
+// slhDSAOIDs 按照 signature.SLHDSAParameterSets 中的名称
+var slhDSAOIDs = map[string]asn1.ObjectIdentifier{
+	"SLH-DSA-SHA2-128s":  {2, 16, 840, 1, 101, 3, 4, 3, 20},
+	"SLH-DSA-SHA2-128f":  {2, 16, 840, 1, 101, 3, 4, 3, 21},
+	"SLH-DSA-SHA2-192s":  {2, 16, 840, 1, 101, 3, 4, 3, 22},
+	"SLH-DSA-SHA2-192f":  {2, 16, 840, 1, 101, 3, 4, 3, 23},
+	"SLH-DSA-SHA2-256s":  {2, 16, 840, 1, 101, 3, 4, 3, 24},
+	"SLH-DSA-SHA2-256f":  {2, 16, 840, 1, 101, 3, 4, 3, 25},
+	"SLH-DSA-SHAKE-128s": {2, 16, 840, 1, 101, 3, 4, 3, 26},
+	"SLH-DSA-SHAKE-128f": {2, 16, 840, 1, 101, 3, 4, 3, 27},
+	"SLH-DSA-SHAKE-192s": {2, 16, 840, 1, 101, 3, 4, 3, 28},
+	"SLH-DSA-SHAKE-192f": {2, 16, 840, 1, 101, 3, 4, 3, 29},
+	"SLH-DSA-SHAKE-256s": {2, 16, 840, 1, 101, 3, 4, 3, 30},
+	"SLH-DSA-SHAKE-256f": {2, 16, 840, 1, 101, 3, 4, 3, 31},
+}
+
+// 私有 OID 的最后一位
+const (
+	arcSphincs256 = 1
+	arcSphincs    = 2
+	arcWOTSPlus   = 3
+	arcHorst      = 4
+	arcLamport    = 5
+	arcWinternitz = 6
+	arcHors       = 7
+	// 使用哈希函数族的 SPHINCS，WOTS+ 以及 HORST
+	arcSphincsSuite  = 8
+	arcWOTSPlusSuite = 9
+	arcHorstSuite    = 10
+)
+
+func privateOID(arc int) asn1.ObjectIdentifier {
+	oid := make(asn1.ObjectIdentifier, 0, len(PrivateArc)+1)
+	oid = append(oid, PrivateArc...)
+	return append(oid, arc)
+}
+
+type sphincsParams struct {
+	N, H, D, W, Tau, K int
+}
+
+type wotsPlusParams struct {
+	W, N int
+	Mask []byte
+}
+
+type horstParams struct {
+	Tau, K, N int
+	Mask      []byte
+}
+
+type sphincsSuiteParams struct {
+	N, H, D, W, Tau, K, Suite int
+}
+
+type wotsPlusSuiteParams struct {
+	W, N  int
+	Mask  []byte
+	Suite int
+}
+
+type horstSuiteParams struct {
+	Tau, K, N int
+	Mask      []byte
+	Suite     int
+}
+
+type lamportParams struct {
+	N int
+}
+
+type winternitzParams struct {
+	W, N int
+}
+
+type horsParams struct {
+	Tau, K int
+}
+
+// subjectPublicKeyInfo RFC 5280 4.1
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// oneAsymmetricKey RFC 5958，version 为 0 时即 PKCS#8 PrivateKeyInfo
+type oneAsymmetricKey struct {
+	Version    int
+	Algorithm  pkix.AlgorithmIdentifier
+	PrivateKey []byte
+	Attributes asn1.RawValue  `asn1:"optional,tag:0"`
+	PublicKey  asn1.BitString `asn1:"optional,tag:1"`
+}
+
+// AlgorithmIdentifier 返回 s 对应的 OID 以及参数，同时用于 SubjectPublicKeyInfo 以及证书中的签名算法
+func AlgorithmIdentifier(s signature.Scheme) (pkix.AlgorithmIdentifier, error) {
+	var params interface{}
+	var ai pkix.AlgorithmIdentifier
+	switch s := s.(type) {
+	case *signature.SLHDSA:
+		ai.Algorithm = slhDSAOIDs[s.Name()]
+	case *signature.Sphincs:
+		p := s.Params()
+		if s.HashSuite() != suite.SPHINCS256 {
+			id, err := suiteID(s.HashSuite())
+			if err != nil {
+				return ai, err
+			}
+			ai.Algorithm = privateOID(arcSphincsSuite)
+			params = sphincsSuiteParams{p.N, p.H, p.D, p.W, p.Tau, p.K, id}
+		} else if p == signature.SPHINCS256Params {
+			ai.Algorithm = privateOID(arcSphincs256)
+		} else {
+			ai.Algorithm = privateOID(arcSphincs)
+			params = sphincsParams{p.N, p.H, p.D, p.W, p.Tau, p.K}
+		}
+	case *signature.WOTSPlus:
+		id := s.Identifier()
+		if s.HashSuite() != nil {
+			st, err := suiteID(s.HashSuite())
+			if err != nil {
+				return ai, err
+			}
+			ai.Algorithm = privateOID(arcWOTSPlusSuite)
+			params = wotsPlusSuiteParams{int(id.Params[0]), int(id.Params[1]), s.Mask(), st}
+		} else {
+			ai.Algorithm = privateOID(arcWOTSPlus)
+			params = wotsPlusParams{int(id.Params[0]), int(id.Params[1]), s.Mask()}
+		}
+	case *signature.Horst:
+		id := s.Identifier()
+		if s.HashSuite() != nil {
+			st, err := suiteID(s.HashSuite())
+			if err != nil {
+				return ai, err
+			}
+			ai.Algorithm = privateOID(arcHorstSuite)
+			params = horstSuiteParams{int(id.Params[0]), int(id.Params[1]), int(id.Params[2]), s.Mask(), st}
+		} else {
+			ai.Algorithm = privateOID(arcHorst)
+			params = horstParams{int(id.Params[0]), int(id.Params[1]), int(id.Params[2]), s.Mask()}
+		}
+	case *signature.Lamport:
+		id := s.Identifier()
+		ai.Algorithm = privateOID(arcLamport)
+		params = lamportParams{int(id.Params[0])}
+	case *signature.Winternitz:
+		id := s.Identifier()
+		ai.Algorithm = privateOID(arcWinternitz)
+		params = winternitzParams{int(id.Params[0]), int(id.Params[1])}
+	case *signature.Hors:
+		id := s.Identifier()
+		ai.Algorithm = privateOID(arcHors)
+		params = horsParams{int(id.Params[0]), int(id.Params[1])}
+	}
+	if ai.Algorithm == nil {
+		return ai, ErrUnknownAlgorithm
+	}
+	if params != nil {
+		b, err := asn1.Marshal(params)
+		if err != nil {
+			return ai, err
+		}
+		ai.Parameters = asn1.RawValue{FullBytes: b}
+	}
+	return ai, nil
+}
+
+// ParseAlgorithm 根据 OID 以及参数构造签名算法，是 AlgorithmIdentifier 的逆操作
+// WOTS+ 和 HORST 的实例只用于校验签名，生成密钥时使用 crypto/rand
+func ParseAlgorithm(ai pkix.AlgorithmIdentifier) (signature.Scheme, error) {
+	params := ai.Parameters.FullBytes
+	for name, oid := range slhDSAOIDs {
+		if ai.Algorithm.Equal(oid) {
+			if len(params) != 0 {
+				return nil, ErrInvalidKey
+			}
+			return signature.NewSLHDSA(name)
+		}
+	}
+	if len(ai.Algorithm) != len(PrivateArc)+1 || !ai.Algorithm[:len(PrivateArc)].Equal(PrivateArc) {
+		return nil, ErrUnknownAlgorithm
+	}
+	switch ai.Algorithm[len(PrivateArc)] {
+	case arcSphincs256:
+		if len(params) != 0 {
+			return nil, ErrInvalidKey
+		}
+		return signature.NewSphincsWithParams(signature.SPHINCS256Params, nil)
+	case arcSphincs:
+		var p sphincsParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return signature.NewSphincsWithParams(signature.SphincsParams{
+			N: p.N, H: p.H, D: p.D, W: p.W, Tau: p.Tau, K: p.K,
+		}, nil)
+	case arcSphincsSuite:
+		var p sphincsSuiteParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		st, err := suiteByID(p.Suite)
+		if err != nil {
+			return nil, err
+		}
+		// 默认的哈希函数族使用 arcSphincs256 或者 arcSphincs，编码唯一
+		if st == suite.SPHINCS256 {
+			return nil, ErrInvalidKey
+		}
+		return signature.NewSphincsWithParams(signature.SphincsParams{
+			N: p.N, H: p.H, D: p.D, W: p.W, Tau: p.Tau, K: p.K,
+		}, nil, signature.WithHashSuite(st))
+	case arcWOTSPlus:
+		var p wotsPlusParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return scheme(signature.NewWOTSPlusSignature(p.W, signature.Size(p.N), nil, p.Mask))
+	case arcWOTSPlusSuite:
+		var p wotsPlusSuiteParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		st, err := suiteByID(p.Suite)
+		if err != nil {
+			return nil, err
+		}
+		return scheme(signature.NewWOTSPlusSignature(p.W, signature.Size(p.N), nil, p.Mask, signature.WithHashSuite(st)))
+	case arcHorst:
+		var p horstParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return newHorst(p.Tau, p.K, p.N, p.Mask)
+	case arcHorstSuite:
+		var p horstSuiteParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		st, err := suiteByID(p.Suite)
+		if err != nil {
+			return nil, err
+		}
+		return newHorst(p.Tau, p.K, p.N, p.Mask, signature.WithHashSuite(st))
+	case arcLamport:
+		var p lamportParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return scheme(signature.NewLamportSignature(signature.Size(p.N), signature.WithRand(crand.Reader)))
+	case arcWinternitz:
+		var p winternitzParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return scheme(signature.NewWinternitzSignature(p.W, signature.Size(p.N), signature.WithRand(crand.Reader)))
+	case arcHors:
+		var p horsParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		// t = 2^64 时密钥的大小溢出
+		if p.Tau > 32 {
+			return nil, ErrInvalidKey
+		}
+		return scheme(signature.NewHorsSignature(p.Tau, p.K, signature.WithRand(crand.Reader)))
+	}
+	return nil, ErrUnknownAlgorithm
+}
+
+// newHorst seed 只用来确定 n
+func newHorst(tau, k, n int, mask []byte, opts ...signature.Option) (signature.Scheme, error) {
+	if n <= 0 || n%8 != 0 {
+		return nil, ErrInvalidKey
+	}
+	opts = append(opts, signature.WithRand(crand.Reader))
+	return scheme(signature.NewHorstSignature(tau, k, make([]byte, n/8), mask, opts...))
+}
+
+// suiteID 自定义的哈希函数族没有编号，返回 ErrUnsupportedSuite
+func suiteID(s suite.HashSuite) (int, error) {
+	id := suite.ID(s)
+	if id == 0 {
+		return 0, ErrUnsupportedSuite
+	}
+	return int(id), nil
+}
+
+// suiteByID 编号不存在时返回 ErrUnknownAlgorithm
+func suiteByID(id int) (suite.HashSuite, error) {
+	if id <= 0 {
+		return nil, ErrUnknownAlgorithm
+	}
+	s, err := suite.ByID(uint32(id))
+	if err != nil {
+		return nil, ErrUnknownAlgorithm
+	}
+	return s, nil
+}
+
+// scheme 将构造函数返回的 signature.Signature 转换成 signature.Scheme
+func scheme(s signature.Signature, err error) (signature.Scheme, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s.(signature.Scheme), nil
+}
+
+// unmarshalParams 参数后面不能有多余的字节
+func unmarshalParams(b []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(b, v)
+	if err != nil || len(rest) != 0 {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// MarshalPKIXPublicKey 将 s 生成的公钥编码成 DER 格式的 SubjectPublicKeyInfo
+func MarshalPKIXPublicKey(s signature.Scheme, pk []byte) ([]byte, error) {
+	if _, err := signature.NewPublicKey(s, pk); err != nil {
+		return nil, err
+	}
+	ai, err := AlgorithmIdentifier(s)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: ai,
+		PublicKey: asn1.BitString{Bytes: pk, BitLength: 8 * len(pk)},
+	})
+}
+
+// ParsePKIXPublicKey 解析 MarshalPKIXPublicKey 的结果，返回对应的签名算法以及公钥
+// 公钥的长度和参数不对应时返回错误
+func ParsePKIXPublicKey(der []byte) (signature.Scheme, *signature.PublicKey, error) {
+	var spki subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(der, &spki)
+	if err != nil || len(rest) != 0 || spki.PublicKey.BitLength%8 != 0 {
+		return nil, nil, ErrInvalidKey
+	}
+	s, err := ParseAlgorithm(spki.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	pk, err := signature.NewPublicKey(s, spki.PublicKey.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, pk, nil
+}
+
+// MarshalPKCS8PrivateKey 将 s 生成的私钥编码成 DER 格式的 PKCS#8
+func MarshalPKCS8PrivateKey(s signature.Scheme, sk []byte) ([]byte, error) {
+	if _, err := signature.NewPrivateKey(s, sk); err != nil {
+		return nil, err
+	}
+	ai, err := AlgorithmIdentifier(s)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(oneAsymmetricKey{Algorithm: ai, PrivateKey: sk})
+}
+
+// ParsePKCS8PrivateKey 解析 MarshalPKCS8PrivateKey 的结果，返回对应的签名算法以及私钥
+func ParsePKCS8PrivateKey(der []byte) (signature.Scheme, *signature.PrivateKey, error) {
+	var key oneAsymmetricKey
+	rest, err := asn1.Unmarshal(der, &key)
+	if err != nil || len(rest) != 0 || (key.Version != 0 && key.Version != 1) {
+		return nil, nil, ErrInvalidKey
+	}
+	s, err := ParseAlgorithm(key.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	sk, err := signature.NewPrivateKey(s, key.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, sk, nil
+}
+
+// MarshalPublicKeyPEM 和 MarshalPKIXPublicKey 一样，返回 PEM 格式
+func MarshalPublicKeyPEM(s signature.Scheme, pk []byte) ([]byte, error) {
+	der, err := MarshalPKIXPublicKey(s, pk)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPublicKey, Bytes: der}), nil
+}
+
+// ParsePublicKeyPEM 解析 PEM 格式的公钥，只使用第一个 PEM 块
+func ParsePublicKeyPEM(b []byte) (signature.Scheme, *signature.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != pemPublicKey {
+		return nil, nil, ErrInvalidKey
+	}
+	return ParsePKIXPublicKey(block.Bytes)
+}
+
+// MarshalPrivateKeyPEM 和 MarshalPKCS8PrivateKey 一样，返回 PEM 格式
+func MarshalPrivateKeyPEM(s signature.Scheme, sk []byte) ([]byte, error) {
+	der, err := MarshalPKCS8PrivateKey(s, sk)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil
+}
+
+// ParsePrivateKeyPEM 解析 PEM 格式的私钥，只使用第一个 PEM 块
+func ParsePrivateKeyPEM(b []byte) (signature.Scheme, *signature.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != pemPrivateKey {
+		return nil, nil, ErrInvalidKey
+	}
+	return ParsePKCS8PrivateKey(block.Bytes)
+}
@@ -0,0 +1,246 @@
+package keys
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/signature"
+	"github.com/junhaideng/sphincs/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSchemes(t *testing.T) map[string]signature.Scheme {
+	assert := assert.New(t)
+	schemes := map[string]signature.Scheme{}
+
+	slh, err := signature.NewSLHDSA("SLH-DSA-SHA2-128f")
+	assert.Nil(err)
+	schemes["slh-dsa"] = slh
+	sphincs256, err := signature.NewSphincsWithParams(signature.SPHINCS256Params, nil)
+	assert.Nil(err)
+	schemes["sphincs-256"] = sphincs256
+	sphincs, err := signature.NewSphincs(256, 512, 8, 2, 4, 8, 64, nil)
+	assert.Nil(err)
+	schemes["sphincs"] = sphincs
+
+	mask := make([]byte, 32*15)
+	for i := range mask {
+		mask[i] = byte(i)
+	}
+	wots, err := signature.NewWOTSPlusSignature(4, signature.Size256, nil, mask)
+	assert.Nil(err)
+	schemes["wots+"] = wots.(signature.Scheme)
+	horst, err := signature.NewHorstSignature(8, 32, make([]byte, 32), make([]byte, 2*32*8))
+	assert.Nil(err)
+	schemes["horst"] = horst.(signature.Scheme)
+	lamport, err := signature.NewLamportSignature(signature.Size256)
+	assert.Nil(err)
+	schemes["lamport"] = lamport.(signature.Scheme)
+	winternitz, err := signature.NewWinternitzSignature(4, signature.Size256)
+	assert.Nil(err)
+	schemes["wots"] = winternitz.(signature.Scheme)
+	hors, err := signature.NewHorsSignature(8, 32)
+	assert.Nil(err)
+	schemes["hors"] = hors.(signature.Scheme)
+	return schemes
+}
+
+func TestRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	msg := hash.Sha256([]byte("hello world"))
+	for name, s := range newSchemes(t) {
+		sk, pk := s.GenerateKey()
+		sig := s.Sign(msg, sk)
+
+		b, err := MarshalPublicKeyPEM(s, pk)
+		assert.Nil(err, name)
+		s2, pk2, err := ParsePublicKeyPEM(b)
+		if !assert.Nil(err, name) {
+			continue
+		}
+		assert.Equal(s.Identifier(), s2.Identifier(), name)
+		assert.Equal(pk, pk2.Key, name)
+		// 解析得到的算法可以校验原来的签名
+		assert.True(s2.Verify(msg, pk2.Key, sig), name)
+
+		b, err = MarshalPrivateKeyPEM(s, sk)
+		assert.Nil(err, name)
+		s3, sk3, err := ParsePrivateKeyPEM(b)
+		if !assert.Nil(err, name) {
+			continue
+		}
+		assert.Equal(s.Identifier(), s3.Identifier(), name)
+		assert.Equal(sk, sk3.Key, name)
+
+		// 私钥和公钥的 PEM 不能混用
+		_, _, err = ParsePublicKeyPEM(b)
+		assert.Equal(ErrInvalidKey, err, name)
+
+		// 长度不对
+		_, err = MarshalPKIXPublicKey(s, pk[1:])
+		assert.NotNil(err, name)
+		_, err = MarshalPKCS8PrivateKey(s, append(sk, 0))
+		assert.NotNil(err, name)
+	}
+}
+
+// 每一个哈希函数族的 SPHINCS，WOTS+ 以及 HORST，解析之后可以校验原来的签名
+func TestRoundTripHashSuite(t *testing.T) {
+	assert := assert.New(t)
+	msg := hash.Sha256([]byte("hello world"))
+	mask := make([]byte, 32*15)
+	for _, name := range suite.Names() {
+		st, _ := suite.ByName(name)
+		opt := signature.WithHashSuite(st)
+		sphincs, err := signature.NewSphincsWithParams(signature.SphincsParams{N: 128, H: 12, D: 4, W: 16, Tau: 8, K: 16}, nil, opt)
+		assert.Nil(err, name)
+		wots, err := signature.NewWOTSPlusSignature(4, signature.Size256, nil, mask, opt)
+		assert.Nil(err, name)
+		horst, err := signature.NewHorstSignature(8, 32, make([]byte, 32), make([]byte, 2*32*8), opt)
+		assert.Nil(err, name)
+
+		for _, s := range []signature.Scheme{sphincs, wots.(signature.Scheme), horst.(signature.Scheme)} {
+			id := s.Identifier().String()
+			sk, pk := s.GenerateKey()
+			sig := s.Sign(msg, sk)
+
+			der, err := MarshalPKIXPublicKey(s, pk)
+			assert.Nil(err, id)
+			s2, pk2, err := ParsePKIXPublicKey(der)
+			if !assert.Nil(err, id) {
+				continue
+			}
+			assert.Equal(s.Identifier(), s2.Identifier(), id)
+			assert.True(s2.Verify(msg, pk2.Key, sig), id)
+
+			der, err = MarshalPKCS8PrivateKey(s, sk)
+			assert.Nil(err, id)
+			s3, sk3, err := ParsePKCS8PrivateKey(der)
+			if !assert.Nil(err, id) {
+				continue
+			}
+			assert.Equal(s.Identifier(), s3.Identifier(), id)
+			// 解析得到的算法和原来的算法生成相同的签名
+			if _, ok := s.(*signature.Sphincs); ok {
+				assert.Equal(sig, s3.Sign(msg, sk3.Key), id)
+			}
+		}
+	}
+}
+
+// unregisteredSuite 没有编号的哈希函数族
+type unregisteredSuite struct {
+	suite.HashSuite
+}
+
+func TestHashSuiteEncoding(t *testing.T) {
+	assert := assert.New(t)
+	p := signature.SphincsParams{N: 128, H: 12, D: 4, W: 16, Tau: 8, K: 16}
+
+	// 默认的哈希函数族使用原来的 OID，其他的使用 arcSphincsSuite
+	s, err := signature.NewSphincsWithParams(signature.SPHINCS256Params, nil, signature.WithHashSuite(suite.SPHINCS256))
+	assert.Nil(err)
+	ai, err := AlgorithmIdentifier(s)
+	assert.Nil(err)
+	assert.Equal(privateOID(arcSphincs256), ai.Algorithm)
+	s, err = signature.NewSphincsWithParams(signature.SPHINCS256Params, nil, signature.WithHashSuite(suite.HARAKA))
+	assert.Nil(err)
+	ai, err = AlgorithmIdentifier(s)
+	assert.Nil(err)
+	assert.Equal(privateOID(arcSphincsSuite), ai.Algorithm)
+	var params sphincsSuiteParams
+	assert.Nil(unmarshalParams(ai.Parameters.FullBytes, &params))
+	assert.Equal(sphincsSuiteParams{256, 60, 12, 16, 16, 32, int(suite.ID(suite.HARAKA))}, params)
+
+	// 没有编号的哈希函数族
+	s, err = signature.NewSphincsWithParams(p, nil, signature.WithHashSuite(unregisteredSuite{suite.SHA2}))
+	assert.Nil(err)
+	_, pk := s.GenerateKey()
+	_, err = MarshalPKIXPublicKey(s, pk)
+	assert.Equal(ErrUnsupportedSuite, err)
+	wots, err := signature.NewWOTSPlusSignature(4, signature.Size256, nil, make([]byte, 32*15), signature.WithHashSuite(unregisteredSuite{suite.SHA2}))
+	assert.Nil(err)
+	_, err = AlgorithmIdentifier(wots.(signature.Scheme))
+	assert.Equal(ErrUnsupportedSuite, err)
+
+	// 未知的编号，以及 SPHINCS256 不能使用 arcSphincsSuite
+	for _, id := range []int{0, len(suite.Names()) + 1, int(suite.ID(suite.SPHINCS256))} {
+		b, err := asn1.Marshal(sphincsSuiteParams{128, 12, 4, 16, 8, 16, id})
+		assert.Nil(err)
+		_, err = ParseAlgorithm(pkix.AlgorithmIdentifier{Algorithm: privateOID(arcSphincsSuite), Parameters: asn1.RawValue{FullBytes: b}})
+		assert.NotNil(err, id)
+	}
+}
+
+func TestSLHDSAEncoding(t *testing.T) {
+	assert := assert.New(t)
+	s, err := signature.NewSLHDSA("SLH-DSA-SHA2-128f")
+	assert.Nil(err)
+	sk, pk := s.GenerateKey()
+
+	// SEQUENCE { SEQUENCE { OID 2.16.840.1.101.3.4.3.21 }, BIT STRING }，parameters 为空
+	der, err := MarshalPKIXPublicKey(s, pk)
+	assert.Nil(err)
+	assert.Equal("3030300b0609608648016503040315032100", hex.EncodeToString(der[:18]))
+	assert.Equal(pk, der[18:])
+
+	// SEQUENCE { INTEGER 0, SEQUENCE { OID }, OCTET STRING }
+	der, err = MarshalPKCS8PrivateKey(s, sk)
+	assert.Nil(err)
+	assert.Equal("3052020100300b06096086480165030403150440", hex.EncodeToString(der[:20]))
+	assert.Equal(sk, der[20:])
+
+	// 多余的字节
+	_, _, err = ParsePKCS8PrivateKey(append(der, 0))
+	assert.Equal(ErrInvalidKey, err)
+}
+
+func TestParseErr(t *testing.T) {
+	assert := assert.New(t)
+	s, err := signature.NewSphincs(256, 512, 8, 2, 4, 8, 64, nil)
+	assert.Nil(err)
+	_, pk := s.GenerateKey()
+
+	// 未知的 OID
+	der, err := asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkixAlgorithm(asn1.ObjectIdentifier{1, 2, 3}),
+		PublicKey: asn1.BitString{Bytes: pk, BitLength: 8 * len(pk)},
+	})
+	assert.Nil(err)
+	_, _, err = ParsePKIXPublicKey(der)
+	assert.Equal(ErrUnknownAlgorithm, err)
+
+	// 公钥的长度和参数不对应
+	der, err = asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkixAlgorithm(privateOID(arcSphincs256)),
+		PublicKey: asn1.BitString{Bytes: pk, BitLength: 8 * len(pk)},
+	})
+	assert.Nil(err)
+	_, _, err = ParsePKIXPublicKey(der)
+	assert.Equal(signature.ErrInvalidEncoding, err)
+
+	_, _, err = ParsePKIXPublicKey(nil)
+	assert.Equal(ErrInvalidKey, err)
+	_, _, err = ParsePrivateKeyPEM([]byte("hello"))
+	assert.Equal(ErrInvalidKey, err)
+
+	// 不支持的算法
+	lmots, err := signature.NewLMOTS(signature.LMOTS_SHA256_N32_W8)
+	assert.Nil(err)
+	_, lpk := lmots.GenerateKey()
+	_, err = MarshalPKIXPublicKey(lmots, lpk)
+	assert.Equal(ErrUnknownAlgorithm, err)
+
+	// t = 2^64
+	params, err := asn1.Marshal(horsParams{64, 4})
+	assert.Nil(err)
+	_, err = ParseAlgorithm(pkix.AlgorithmIdentifier{Algorithm: privateOID(arcHors), Parameters: asn1.RawValue{FullBytes: params}})
+	assert.Equal(ErrInvalidKey, err)
+}
+
+func pkixAlgorithm(oid asn1.ObjectIdentifier) pkix.AlgorithmIdentifier {
+	return pkix.AlgorithmIdentifier{Algorithm: oid}
+}
@@ -0,0 +1,83 @@
+package keystate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/junhaideng/sphincs/internal/atomicfile"
+)
+
+var ErrCorruptState = errors.New("keystate: corrupt state file")
+
+// FileTracker 每一个密钥的计数保存在 dir 下的一个文件中，文件名为 SHA-256(id) 的 hex
+// Reserve 写入临时文件，fsync 之后 rename，再 fsync 目录，返回时新的计数一定已经落盘
+// 只保证同一个进程内的互斥，不要让多个进程同时使用同一个目录
+type FileTracker struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileTracker dir 不存在时创建，权限为 0700
+func NewFileTracker(dir string) (*FileTracker, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileTracker{dir: dir}, nil
+}
+
+// path id 可以是任意字符串，哈希之后作为文件名
+func (t *FileTracker) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+".state")
+}
+
+func (t *FileTracker) Reserve(id string, limit Limit) (Usage, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	count, err := t.read(id)
+	if err != nil {
+		return Usage{}, err
+	}
+	u, err := check(count, limit)
+	if err != nil {
+		return u, err
+	}
+	if err := t.write(id, u.Signatures); err != nil {
+		return Usage{}, err
+	}
+	return u, nil
+}
+
+func (t *FileTracker) Usage(id string) (uint64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.read(id)
+}
+
+// read 文件不存在时为 0，内容不是十进制整数时返回 ErrCorruptState
+// 计数无法确定时不能假设为 0，否则 OTS 私钥可能被再次使用
+func (t *FileTracker) read(id string) (uint64, error) {
+	b, err := ioutil.ReadFile(t.path(id))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, ErrCorruptState
+	}
+	return count, nil
+}
+
+func (t *FileTracker) write(id string, count uint64) error {
+	return atomicfile.WriteFile(t.dir, t.path(id), []byte(strconv.FormatUint(count, 10)+"\n"))
+}
@@ -0,0 +1,122 @@
+// Package keystate 记录每一个密钥已经生成的签名数，避免一次签名 (OTS) 的私钥被重复使用
+//
+// Lamport, WOTS, WOTS+ 以及 LM-OTS 的私钥只能签名一次，第二次签名会泄露足够多的私钥块，任何人都可以伪造签名
+// HORS 和 HORST 为 few-time 签名，每多签名一次安全性就下降一些，Policy 中可以设置警告以及拒绝的阈值
+// SPHINCS 以及 SLH-DSA 是无状态的，LMS 和 HSS 在私钥中保存自己的状态，都不限制签名次数
+//
+// 签名之前先调用 Reserve 记录这一次签名 (FileTracker 会先 fsync)，成功之后才能把签名交给调用方
+// 这样即使在签名之后崩溃，计数也只会偏大，不会出现同一个私钥签名两次但是只记录了一次的情况
+package keystate
+
+import (
+	"errors"
+
+	"github.com/junhaideng/sphincs/signature"
+)
+
+var (
+	ErrKeyReused     = errors.New("keystate: one-time key has already been used")
+	ErrLimitExceeded = errors.New("keystate: signature limit of the key has been reached")
+)
+
+// Limit 一个密钥的签名次数限制
+type Limit struct {
+	// Max 最多签名的次数，0 表示不限制
+	Max uint64
+	// Warn 签名次数超过 Warn 之后 Usage.Warning 为 true，0 表示不警告
+	Warn uint64
+}
+
+// Usage Reserve 之后密钥的使用情况
+type Usage struct {
+	// Signatures 包括这一次在内，已经生成的签名数
+	Signatures uint64 `json:"signatures"`
+	// Limit 最多签名的次数，0 表示不限制
+	Limit uint64 `json:"limit"`
+	// Warning few-time 签名的次数已经超过警告阈值
+	Warning bool `json:"warning"`
+}
+
+// Remaining 剩余可以签名的次数，不限制时返回 -1
+func (u Usage) Remaining() int64 {
+	if u.Limit == 0 {
+		return -1
+	}
+	if u.Signatures >= u.Limit {
+		return 0
+	}
+	return int64(u.Limit - u.Signatures)
+}
+
+// Policy 不同类型的签名算法使用的限制
+type Policy struct {
+	// FewTimeWarn HORS/HORST 签名次数超过该值之后警告，0 表示不警告
+	FewTimeWarn uint64
+	// FewTimeMax HORS/HORST 最多签名的次数，0 表示不限制
+	FewTimeMax uint64
+}
+
+// DefaultPolicy HORS/HORST 第二次签名开始警告，最多签名 8 次
+// 对于 t = 2^16, k = 32 的 HORST，r 次签名之后伪造的概率约为 (rk/t)^k，r = 8 时为 2^-256
+var DefaultPolicy = Policy{FewTimeWarn: 1, FewTimeMax: 8}
+
+// Limit 返回 s 的私钥的签名次数限制
+func (p Policy) Limit(s signature.Scheme) Limit {
+	switch s.Identifier().Alg {
+	case signature.AlgLamport, signature.AlgWinternitz, signature.AlgWOTSPlus, signature.AlgLMOTS:
+		return Limit{Max: 1}
+	case signature.AlgHors, signature.AlgHorst:
+		return Limit{Max: p.FewTimeMax, Warn: p.FewTimeWarn}
+	}
+	return Limit{}
+}
+
+// Tracker 记录每一个密钥已经生成的签名数，所有实现都需要可以在多个 goroutine 中同时使用
+type Tracker interface {
+	// Reserve 在签名之前调用，检查 limit 并将 id 的签名数加一
+	// 已经达到上限时返回 ErrKeyReused (Max 为 1) 或者 ErrLimitExceeded，此时不修改计数
+	// 返回 nil 时计数已经持久化，可以签名
+	Reserve(id string, limit Limit) (Usage, error)
+	// Usage 返回 id 当前的签名数，没有记录时为 0
+	Usage(id string) (uint64, error)
+}
+
+// check 在已经签名 count 次之后能否再签名一次
+func check(count uint64, limit Limit) (Usage, error) {
+	if limit.Max != 0 && count >= limit.Max {
+		u := Usage{Signatures: count, Limit: limit.Max}
+		if limit.Max == 1 {
+			return u, ErrKeyReused
+		}
+		return u, ErrLimitExceeded
+	}
+	count++
+	return Usage{
+		Signatures: count,
+		Limit:      limit.Max,
+		Warning:    limit.Warn != 0 && count > limit.Warn,
+	}, nil
+}
+
+// Current 返回 id 当前的使用情况，不修改计数
+func Current(t Tracker, id string, limit Limit) (Usage, error) {
+	count, err := t.Usage(id)
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Signatures: count, Limit: limit.Max, Warning: limit.Warn != 0 && count > limit.Warn}, nil
+}
+
+// Sign 先通过 t 记录这一次签名，再使用 s 签名
+// 签名失败时计数不会回滚，宁可少用一次密钥也不能重复使用
+func Sign(t Tracker, p Policy, s signature.Scheme, id string, message []byte, sk *signature.PrivateKey) (*signature.Sig, Usage, error) {
+	u, err := t.Reserve(id, p.Limit(s))
+	if err != nil {
+		return nil, u, err
+	}
+	sig, err := signature.SignMessage(s, message, sk)
+	if err != nil {
+		return nil, u, err
+	}
+	return sig, u, nil
+}
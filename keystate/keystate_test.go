@@ -0,0 +1,131 @@
+package keystate
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/junhaideng/sphincs/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTracker(t *testing.T, tracker Tracker) {
+	assert := assert.New(t)
+
+	// OTS 只能签名一次
+	u, err := tracker.Reserve("ots", Limit{Max: 1})
+	assert.Nil(err)
+	assert.Equal(Usage{Signatures: 1, Limit: 1}, u)
+	assert.Equal(int64(0), u.Remaining())
+	_, err = tracker.Reserve("ots", Limit{Max: 1})
+	assert.Equal(ErrKeyReused, err)
+	count, err := tracker.Usage("ots")
+	assert.Nil(err)
+	assert.Equal(uint64(1), count)
+
+	// few-time 签名
+	limit := Limit{Max: 3, Warn: 1}
+	u, err = tracker.Reserve("few", limit)
+	assert.Nil(err)
+	assert.False(u.Warning)
+	assert.Equal(int64(2), u.Remaining())
+	u, err = tracker.Reserve("few", limit)
+	assert.Nil(err)
+	assert.True(u.Warning)
+	_, err = tracker.Reserve("few", limit)
+	assert.Nil(err)
+	u, err = tracker.Reserve("few", limit)
+	assert.Equal(ErrLimitExceeded, err)
+	assert.Equal(uint64(3), u.Signatures)
+
+	// 不限制
+	for i := 0; i < 10; i++ {
+		u, err = tracker.Reserve("stateless", Limit{})
+		assert.Nil(err)
+	}
+	assert.Equal(uint64(10), u.Signatures)
+	assert.Equal(int64(-1), u.Remaining())
+
+	count, err = tracker.Usage("unknown")
+	assert.Nil(err)
+	assert.Equal(uint64(0), count)
+
+	// 并发时不能超过上限
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	success := 0
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tracker.Reserve("concurrent", Limit{Max: 5}); err == nil {
+				mu.Lock()
+				success++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(5, success)
+}
+
+func TestMemoryTracker(t *testing.T) {
+	testTracker(t, NewMemoryTracker())
+}
+
+func TestFileTracker(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	tracker, err := NewFileTracker(dir)
+	assert.Nil(err)
+	testTracker(t, tracker)
+
+	// 重新打开之后计数仍然存在
+	tracker, err = NewFileTracker(dir)
+	assert.Nil(err)
+	_, err = tracker.Reserve("ots", Limit{Max: 1})
+	assert.Equal(ErrKeyReused, err)
+
+	// 文件损坏时拒绝签名
+	assert.Nil(ioutil.WriteFile(tracker.path("corrupt"), []byte("garbage"), 0600))
+	_, err = tracker.Reserve("corrupt", Limit{})
+	assert.Equal(ErrCorruptState, err)
+}
+
+func TestPolicy(t *testing.T) {
+	assert := assert.New(t)
+	wots, err := signature.NewWOTSPlusSignature(4, signature.Size256, nil, make([]byte, 32*15))
+	assert.Nil(err)
+	hors, err := signature.NewHorsSignature(8, 32)
+	assert.Nil(err)
+	slh, err := signature.NewSLHDSA("SLH-DSA-SHA2-128f")
+	assert.Nil(err)
+
+	assert.Equal(Limit{Max: 1}, DefaultPolicy.Limit(wots.(signature.Scheme)))
+	assert.Equal(Limit{Max: 8, Warn: 1}, DefaultPolicy.Limit(hors.(signature.Scheme)))
+	assert.Equal(Limit{Max: 100}, Policy{FewTimeMax: 100}.Limit(hors.(signature.Scheme)))
+	assert.Equal(Limit{}, DefaultPolicy.Limit(slh))
+}
+
+func TestSign(t *testing.T) {
+	assert := assert.New(t)
+	s, err := signature.NewWOTSPlusSignature(4, signature.Size256, nil, make([]byte, 32*15))
+	assert.Nil(err)
+	scheme := s.(signature.Scheme)
+	sk, pk := signature.GenerateKeyPair(scheme)
+	tracker := NewMemoryTracker()
+
+	sig, u, err := Sign(tracker, DefaultPolicy, scheme, "key", []byte("hello world"), sk)
+	assert.Nil(err)
+	assert.Equal(uint64(1), u.Signatures)
+	assert.Nil(signature.VerifyMessage(scheme, []byte("hello world"), pk, sig))
+
+	// 第二次签名被拒绝
+	sig, _, err = Sign(tracker, DefaultPolicy, scheme, "key", []byte("sphincs"), sk)
+	assert.Equal(ErrKeyReused, err)
+	assert.Nil(sig)
+
+	u, err = Current(tracker, "key", DefaultPolicy.Limit(scheme))
+	assert.Nil(err)
+	assert.Equal(int64(0), u.Remaining())
+}
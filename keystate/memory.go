@@ -0,0 +1,31 @@
+package keystate
+
+import "sync"
+
+// MemoryTracker 计数只保存在内存中，进程重启之后丢失
+// 只适合密钥同样保存在内存中的情况，否则重启之后 OTS 私钥可能被再次使用
+type MemoryTracker struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func NewMemoryTracker() *MemoryTracker {
+	return &MemoryTracker{counts: map[string]uint64{}}
+}
+
+func (t *MemoryTracker) Reserve(id string, limit Limit) (Usage, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, err := check(t.counts[id], limit)
+	if err != nil {
+		return u, err
+	}
+	t.counts[id] = u.Signatures
+	return u, nil
+}
+
+func (t *MemoryTracker) Usage(id string) (uint64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[id], nil
+}
@@ -0,0 +1,112 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/junhaideng/sphincs/internal/atomicfile"
+)
+
+var (
+	ErrMasterKeySize = errors.New("keystore: master key must be 32 bytes")
+	ErrDecrypt       = errors.New("keystore: cannot decrypt key file")
+)
+
+// keyFileExt 密钥文件的后缀
+const keyFileExt = ".key"
+
+// FileStore 每一个密钥保存为 dir 下的 <id>.key
+// 文件内容为 nonce || AES-256-GCM(masterKey, nonce, JSON(Entry), id)
+// ID 作为附加数据参与认证，所以把一个文件重命名成另外一个 ID 之后无法解密
+// 文件先写入临时文件并 fsync，再 rename 并 fsync 目录 (见 atomicfile.WriteFile)，崩溃时不会留下损坏的文件或者丢失新的密钥
+type FileStore struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// NewFileStore dir 不存在时创建，权限为 0700
+// masterKey 为 32 bytes 的 AES-256 密钥，需要妥善保存，丢失之后所有密钥都无法解密
+func NewFileStore(dir string, masterKey []byte) (*FileStore, error) {
+	if len(masterKey) != 32 {
+		return nil, ErrMasterKeySize
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, aead: aead}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+keyFileExt)
+}
+
+func (s *FileStore) Put(privateKey, publicKey []byte) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(newEntry(id, privateKey, publicKey))
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := s.aead.Seal(nonce, nonce, plaintext, []byte(id))
+	if err := atomicfile.WriteFile(s.dir, s.path(id), data); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (s *FileStore) Get(id string) (*Entry, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	size := s.aead.NonceSize()
+	if len(data) < size {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := s.aead.Open(nil, data[:size], data[size:], []byte(id))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	e := &Entry{}
+	if err := json.Unmarshal(plaintext, e); err != nil || e.ID != id {
+		return nil, ErrDecrypt
+	}
+	return e, nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
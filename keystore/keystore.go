@@ -0,0 +1,75 @@
+// Package keystore 保存服务端生成的密钥，调用方只拿到不透明的 ID
+//
+// 密钥使用 keys 包中的 DER 编码保存 (私钥为 PKCS#8，公钥为 SubjectPublicKeyInfo)，store 本身不关心签名算法
+// MemoryStore 只保存在内存中，FileStore 每一个密钥一个文件，使用 AES-256-GCM 加密之后写入磁盘
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("keystore: key not found")
+	ErrInvalidID = errors.New("keystore: invalid key id")
+)
+
+// idSize ID 的随机字节数，编码为 hex 之后为 32 个字符
+const idSize = 16
+
+// Entry 保存的一对密钥
+type Entry struct {
+	ID string `json:"id"`
+	// PrivateKey PKCS#8 DER
+	PrivateKey []byte `json:"private_key"`
+	// PublicKey SubjectPublicKeyInfo DER
+	PublicKey []byte    `json:"public_key"`
+	Created   time.Time `json:"created"`
+}
+
+// Store 密钥存储，所有实现都需要可以在多个 goroutine 中同时使用
+// Get 返回的 Entry 是拷贝，修改不会影响保存的内容
+type Store interface {
+	// Put 保存一对密钥，返回新生成的 ID
+	Put(privateKey, publicKey []byte) (string, error)
+	// Get 不存在时返回 ErrNotFound
+	Get(id string) (*Entry, error)
+	// Delete 不存在时返回 ErrNotFound
+	Delete(id string) error
+}
+
+// newID 随机生成 ID，不包含任何和密钥相关的信息
+func newID() (string, error) {
+	b := make([]byte, idSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// checkID ID 为 newID 生成的格式，FileStore 中用作文件名，避免路径穿越
+func checkID(id string) error {
+	b, err := hex.DecodeString(id)
+	if err != nil || len(b) != idSize || hex.EncodeToString(b) != id {
+		return ErrInvalidID
+	}
+	return nil
+}
+
+func newEntry(id string, privateKey, publicKey []byte) *Entry {
+	return &Entry{
+		ID:         id,
+		PrivateKey: append([]byte{}, privateKey...),
+		PublicKey:  append([]byte{}, publicKey...),
+		Created:    time.Now().UTC(),
+	}
+}
+
+func (e *Entry) clone() *Entry {
+	c := *e
+	c.PrivateKey = append([]byte{}, e.PrivateKey...)
+	c.PublicKey = append([]byte{}, e.PublicKey...)
+	return &c
+}
@@ -0,0 +1,97 @@
+package keystore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, s Store) {
+	assert := assert.New(t)
+	sk, pk := []byte("private key"), []byte("public key")
+
+	id, err := s.Put(sk, pk)
+	assert.Nil(err)
+	assert.Nil(checkID(id))
+	id2, err := s.Put(sk, pk)
+	assert.Nil(err)
+	assert.NotEqual(id, id2)
+
+	e, err := s.Get(id)
+	assert.Nil(err)
+	assert.Equal(id, e.ID)
+	assert.Equal(sk, e.PrivateKey)
+	assert.Equal(pk, e.PublicKey)
+	assert.False(e.Created.IsZero())
+
+	// 修改返回值不影响保存的内容
+	e.PrivateKey[0] ^= 1
+	e, err = s.Get(id)
+	assert.Nil(err)
+	assert.Equal(sk, e.PrivateKey)
+
+	assert.Nil(s.Delete(id))
+	_, err = s.Get(id)
+	assert.Equal(ErrNotFound, err)
+	assert.Equal(ErrNotFound, s.Delete(id))
+
+	_, err = s.Get("00")
+	assert.NotNil(err)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	master := bytes.Repeat([]byte{1}, 32)
+	s, err := NewFileStore(dir, master)
+	assert.Nil(err)
+	testStore(t, s)
+
+	sk := []byte("private key")
+	id, err := s.Put(sk, []byte("public key"))
+	assert.Nil(err)
+
+	// 文件中不包含明文的私钥
+	data, err := ioutil.ReadFile(filepath.Join(dir, id+keyFileExt))
+	assert.Nil(err)
+	assert.False(bytes.Contains(data, sk))
+	info, err := os.Stat(filepath.Join(dir, id+keyFileExt))
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// 重新打开之后可以读取
+	s, err = NewFileStore(dir, master)
+	assert.Nil(err)
+	e, err := s.Get(id)
+	assert.Nil(err)
+	assert.Equal(sk, e.PrivateKey)
+
+	// 主密钥不对
+	other, err := NewFileStore(dir, bytes.Repeat([]byte{2}, 32))
+	assert.Nil(err)
+	_, err = other.Get(id)
+	assert.Equal(ErrDecrypt, err)
+
+	// 文件被重命名成另外一个 ID
+	id2, err := newID()
+	assert.Nil(err)
+	assert.Nil(os.Rename(filepath.Join(dir, id+keyFileExt), filepath.Join(dir, id2+keyFileExt)))
+	_, err = s.Get(id2)
+	assert.Equal(ErrDecrypt, err)
+
+	// 路径穿越
+	_, err = s.Get("../" + id2)
+	assert.Equal(ErrInvalidID, err)
+	assert.Equal(ErrInvalidID, s.Delete("../"+id2))
+
+	_, err = NewFileStore(dir, master[1:])
+	assert.Equal(ErrMasterKeySize, err)
+}
@@ -0,0 +1,44 @@
+package keystore
+
+import "sync"
+
+// MemoryStore 保存在内存中，进程退出之后密钥丢失
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*Entry{}}
+}
+
+func (s *MemoryStore) Put(privateKey, publicKey []byte) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.entries[id] = newEntry(id, privateKey, publicKey)
+	s.mu.Unlock()
+	return id, nil
+}
+
+func (s *MemoryStore) Get(id string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return e.clone(), nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, id)
+	return nil
+}
@@ -0,0 +1,445 @@
+package merkle
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/junhaideng/sphincs/hash"
+)
+
+var (
+	ErrLeavesExhausted = errors.New("merkle: all leaves have been used")
+	ErrInvalidBDSState = errors.New("merkle: invalid BDS state")
+)
+
+// bdsVersion 状态编码的版本
+const bdsVersion = 1
+
+// BDS 使用 BDS 算法 (Buchmann, Dahmen, Schneider, 2008) 依次计算每一个叶子节点的鉴权路径
+// 和 XMSS 参考实现中的 xmss_core_fast 一致，适用于有状态签名中按照 0, 1, 2, ... 的顺序使用叶子节点
+//
+// 记 H = height-1 为从叶子节点到根节点的层数，初始化时需要遍历一次所有叶子节点
+// 之后每次 Next 最多计算 (H-K)/2 + 1 个叶子节点以及 O(H) 次哈希，保存的节点数为 O(H) + 2^K - K - 1
+// K 越大，每次 Next 的计算越少，但是需要保存更多最上面几层的节点
+//
+// 状态可以通过 MarshalBinary 保存，重启之后使用 TreeHash.RestoreBDS 恢复，不需要重新遍历叶子节点
+type BDS struct {
+	t     *TreeHash
+	hash  *hash.Hasher
+	leaf  LeafFunc
+	k     int
+	index int
+	root  []byte
+	// 当前叶子节点的鉴权路径，从下到上
+	auth [][]byte
+	// 左节点的右子节点，之后用来计算新的左鉴权节点，一共 H/2 个
+	keep [][]byte
+	// 第 i 个用来计算第 i 层的下一个右鉴权节点，i < H-K
+	treehash []bdsTreeHash
+	// 所有 treehash 共用的栈
+	stack []bdsNode
+	// H-K 层及以上的右节点，这些节点只计算一次，一共 2^K - K - 1 个
+	retain [][]byte
+}
+
+type bdsTreeHash struct {
+	node      []byte
+	next      int // 下一个需要计算的叶子节点
+	usage     int // 在共用的栈中的节点数
+	completed bool
+}
+
+type bdsNode struct {
+	node  []byte
+	level int
+}
+
+// BDS 遍历一次叶子节点，返回从第 0 个叶子节点开始的 BDS 状态
+// k 为保存的最上面的层数，需要满足 0 <= k <= H 并且 H-k 为偶数，其中 H = height-1
+func (t *TreeHash) BDS(leaf LeafFunc, k int) (*BDS, error) {
+	b, err := t.newBDS(leaf, k)
+	if err != nil {
+		return nil, err
+	}
+	b.init(0)
+	return b, nil
+}
+
+// BDSAt 和 BDS 一样遍历一次叶子节点，但是返回从第 index 个叶子节点开始的状态
+// 用于只保存了下一个叶子节点的索引，没有保存 BDS 状态的情况，例如程序重启之后
+// 返回的状态和从第 0 个叶子节点开始调用 index 次 Next 得到的鉴权路径相同
+func (t *TreeHash) BDSAt(leaf LeafFunc, k, index int) (*BDS, error) {
+	b, err := t.newBDS(leaf, k)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= 1<<(t.height-1) {
+		return nil, errors.New("index out of range")
+	}
+	b.init(index)
+	return b, nil
+}
+
+func (t *TreeHash) newBDS(leaf LeafFunc, k int) (*BDS, error) {
+	h := t.height - 1
+	if k < 0 || k > h || (h-k)%2 != 0 {
+		return nil, errors.New("k should be in [0, height-1] and height-1-k should be even")
+	}
+	if h > 62 {
+		return nil, errors.New("height should not greater than 63")
+	}
+	if leaf == nil {
+		return nil, errors.New("leaf function is required")
+	}
+	return &BDS{
+		t:        t,
+		hash:     t.hash.Clone(),
+		leaf:     leaf,
+		k:        k,
+		auth:     make([][]byte, h),
+		keep:     make([][]byte, h/2),
+		treehash: make([]bdsTreeHash, h-k),
+		stack:    make([]bdsNode, 0, h+1),
+		retain:   make([][]byte, 1<<k-k-1),
+	}, nil
+}
+
+// Index 当前叶子节点的索引
+func (b *BDS) Index() int {
+	return b.index
+}
+
+// Root 根节点
+func (b *BDS) Root() []byte {
+	return b.root
+}
+
+// AuthenticationPath 当前叶子节点的鉴权路径，从下到上，和 Tree.AuthenticationPath(0, b.Index()) 相同
+func (b *BDS) AuthenticationPath() [][]byte {
+	return append(make([][]byte, 0, len(b.auth)), b.auth...)
+}
+
+// Next 计算下一个叶子节点的鉴权路径，当前已经是最后一个叶子节点时返回 ErrLeavesExhausted
+func (b *BDS) Next() error {
+	h := b.t.height - 1
+	if b.index >= 1<<h-1 {
+		return ErrLeavesExhausted
+	}
+	b.round()
+	b.update((h - b.k) / 2)
+	b.index++
+	return nil
+}
+
+// init 计算根节点以及第 index 个叶子节点的鉴权路径，遍历所有叶子节点时保存之后需要的节点
+// treehash 中为每一层下一个需要的右节点，直接计算完成，keep 中为之后计算左鉴权节点时需要的右子节点
+// 以及 H-K 层及以上的所有右节点 (retain)，index 为 0 时 keep 都为空，treehash 为每一层的第二个右节点
+func (b *BDS) init(index int) {
+	h := b.t.height - 1
+	b.index = index
+	// treehash 和 keep 为第 level 层中需要保存的节点的位置，-1 表示不需要
+	treehash := make([]int, h-b.k)
+	keep := make([]int, h)
+	for level := range treehash {
+		// 下一个满足 0 到 level 位都为 1 的叶子节点 r 使用完之后，第 level 层的鉴权节点为 (r+1)>>level + 1
+		r := index | (1<<(level+1) - 1)
+		treehash[level] = (r+1)>>level + 1
+		b.treehash[level].completed = true
+	}
+	for level := range keep {
+		keep[level] = -1
+		if level >= h-1 {
+			// 最上面一层的鉴权节点不会保存到 keep 中
+			continue
+		}
+		// 叶子节点 s 使用完之后 (0 到 level-1 位为 1，level 和 level+1 位为 0)，第 level 层的鉴权节点保存到 keep 中
+		// 直到叶子节点 s + 2^level 使用完之后才会用到，所以 index 在 (s, s + 2^level] 中时需要保存
+		period := 1 << (level + 2)
+		s := index&^(period-1) + 1<<level - 1
+		if s >= index {
+			s -= period
+		}
+		if s >= 0 && s+1<<level >= index {
+			keep[level] = (s + 1<<level) >> level
+		}
+	}
+	stack := make([]bdsNode, 0, h+1)
+	for i := 0; i < 1<<h; i++ {
+		node, level := b.newLeaf(i), 0
+		for {
+			// node 在第 level 层中的位置为 i>>level
+			// 一个节点可能同时是鉴权节点和需要保存的节点，所以分别判断
+			pos := i >> level
+			if level < h && pos == (index>>level)^1 {
+				b.auth[level] = node
+			}
+			if level < h-b.k && pos == treehash[level] {
+				b.treehash[level].node = node
+			}
+			if level >= h-b.k && level < h && pos&1 == 1 && pos >= 3 {
+				b.retain[b.retainIndex(level, pos)] = node
+			}
+			if level < h && pos == keep[level] {
+				b.keep[level>>1] = node
+			}
+			if len(stack) == 0 || stack[len(stack)-1].level != level {
+				break
+			}
+			node = b.combine(stack[len(stack)-1].node, node, level, pos>>1)
+			stack = stack[:len(stack)-1]
+			level++
+		}
+		stack = append(stack, bdsNode{node, level})
+	}
+	b.root = stack[0].node
+}
+
+// newLeaf LeafFunc 可能复用返回的缓冲区，BDS 中的节点需要保存下来，所以复制一份
+func (b *BDS) newLeaf(i int) []byte {
+	return append([]byte(nil), b.leaf(i)...)
+}
+
+// combine 计算父节点，结果需要保存下来，所以每次分配新的 slice，level 和 index 的含义见 NodeFunc
+func (b *BDS) combine(left, right []byte, level, index int) []byte {
+	return b.t.combine(b.hash, make([]byte, 0, b.t.n/8), left, right, level, index)
+}
+
+// retainIndex 第 level 层中位置为 pos (奇数，不小于 3) 的右节点在 retain 中的索引
+// 从 H-2 层开始往下保存，第 level 层有 2^(H-1-level) - 1 个
+func (b *BDS) retainIndex(level, pos int) int {
+	h := b.t.height - 1
+	return 1<<(h-1-level) + level - h + (pos-3)>>1
+}
+
+// round 当前叶子节点已经使用，更新鉴权路径
+// tau 为当前叶子节点的路径上第一个左节点所在的层，tau 层的鉴权节点变为左节点，下面的都变为右节点
+func (b *BDS) round() {
+	h := b.t.height - 1
+	tau := h
+	for i := 0; i < h; i++ {
+		if b.index>>i&1 == 0 {
+			tau = i
+			break
+		}
+	}
+
+	var left, right []byte
+	if tau > 0 {
+		// keep 之后可能被覆盖，先取出来
+		left, right = b.auth[tau-1], b.keep[(tau-1)>>1]
+	}
+	if tau < h-1 && b.index>>(tau+1)&1 == 0 {
+		b.keep[tau>>1] = b.auth[tau]
+	}
+	if tau == 0 {
+		b.auth[0] = b.newLeaf(b.index)
+		return
+	}
+
+	b.auth[tau] = b.combine(left, right, tau-1, b.index>>tau)
+	for i := 0; i < tau; i++ {
+		if i < h-b.k {
+			b.auth[i] = b.treehash[i].node
+		} else {
+			b.auth[i] = b.retain[b.retainIndex(i, b.index>>i+2)]
+		}
+	}
+	for i := 0; i < tau && i < h-b.k; i++ {
+		// 开始计算第 i 层之后需要的右节点
+		if start := b.index + 1 + 3<<i; start < 1<<h {
+			b.treehash[i] = bdsTreeHash{next: start}
+		}
+	}
+}
+
+// update 最多计算 updates 个叶子节点，每次选择栈中最低的节点所在层数最小的 treehash
+func (b *BDS) update(updates int) {
+	h := b.t.height - 1
+	for j := 0; j < updates; j++ {
+		low, level := h, h-b.k
+		for i := range b.treehash {
+			th := &b.treehash[i]
+			l := h
+			switch {
+			case th.completed:
+			case th.usage == 0:
+				l = i
+			default:
+				// 正在计算的 treehash 的节点一定在栈顶
+				for _, n := range b.stack[len(b.stack)-th.usage:] {
+					if n.level < l {
+						l = n.level
+					}
+				}
+			}
+			if l < low {
+				low, level = l, i
+			}
+		}
+		if level == h-b.k {
+			return
+		}
+		b.step(level)
+	}
+}
+
+// step 第 level 个 treehash 计算一个叶子节点，并和栈顶相同层数的节点合并
+func (b *BDS) step(level int) {
+	th := &b.treehash[level]
+	node, l := b.newLeaf(th.next), 0
+	for th.usage > 0 && b.stack[len(b.stack)-1].level == l {
+		node = b.combine(b.stack[len(b.stack)-1].node, node, l, th.next>>(l+1))
+		b.stack = b.stack[:len(b.stack)-1]
+		th.usage--
+		l++
+	}
+	if l == level {
+		th.node = node
+		th.completed = true
+		return
+	}
+	b.stack = append(b.stack, bdsNode{node, l})
+	th.usage++
+	th.next++
+}
+
+// MarshalBinary 编码当前的状态，不包括 TreeHash 中的参数以及叶子节点的生成函数
+//
+//	version || height || k || n/8 || index (8 bytes) || root || auth || keep || retain
+//	|| (completed || usage || next (8 bytes) || node) * (H-K)
+//	|| 栈中的节点数 || (level || node) * 栈中的节点数
+//
+// 没有计算出来的节点使用全 0 填充
+func (b *BDS) MarshalBinary() ([]byte, error) {
+	size := b.t.n / 8
+	out := []byte{bdsVersion, byte(b.t.height), byte(b.k), byte(size)}
+	out = appendUint64(out, b.index)
+	nodes := func(nodes ...[]byte) {
+		for _, n := range nodes {
+			if n == nil {
+				n = make([]byte, size)
+			}
+			out = append(out, n...)
+		}
+	}
+	nodes(b.root)
+	nodes(b.auth...)
+	nodes(b.keep...)
+	nodes(b.retain...)
+	for _, th := range b.treehash {
+		completed := byte(0)
+		if th.completed {
+			completed = 1
+		}
+		out = append(out, completed, byte(th.usage))
+		out = appendUint64(out, th.next)
+		nodes(th.node)
+	}
+	out = append(out, byte(len(b.stack)))
+	for _, n := range b.stack {
+		out = append(out, byte(n.level))
+		nodes(n.node)
+	}
+	return out, nil
+}
+
+// BDSStateSize MarshalBinary 返回值长度的上界，height 和 n 的含义和 NewTreeHash 相同
+// 用于把状态保存在固定长度的私钥中
+func BDSStateSize(height, n, k int) int {
+	h, size := height-1, n/8
+	nodes := 1 + h + h/2 + 1<<k - k - 1
+	return 4 + 8 + nodes*size + (h-k)*(2+8+size) + 1 + (h+1)*(1+size)
+}
+
+func appendUint64(b []byte, v int) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(v))
+	return append(b, buf[:]...)
+}
+
+// RestoreBDS 从 BDS.MarshalBinary 的结果中恢复状态，leaf 需要和保存状态时的相同
+// 状态中的参数和 t 不一致或者编码不正确时返回 ErrInvalidBDSState
+func (t *TreeHash) RestoreBDS(leaf LeafFunc, state []byte) (*BDS, error) {
+	size := t.n / 8
+	if len(state) < 4 || state[0] != bdsVersion || int(state[1]) != t.height || int(state[3]) != size {
+		return nil, ErrInvalidBDSState
+	}
+	b, err := t.newBDS(leaf, int(state[2]))
+	if err != nil {
+		if leaf == nil {
+			return nil, err
+		}
+		return nil, ErrInvalidBDSState
+	}
+	h := t.height - 1
+	r := bdsReader{data: state[4:]}
+	if b.index = r.uint64(); b.index >= 1<<h {
+		return nil, ErrInvalidBDSState
+	}
+	b.root = r.node(size)
+	for _, nodes := range [][][]byte{b.auth, b.keep, b.retain} {
+		for i := range nodes {
+			nodes[i] = r.node(size)
+		}
+	}
+	usage := 0
+	for i := range b.treehash {
+		th := &b.treehash[i]
+		th.completed = r.byte() == 1
+		th.usage = int(r.byte())
+		th.next = r.uint64()
+		th.node = r.node(size)
+		if !th.completed && th.next >= 1<<h {
+			return nil, ErrInvalidBDSState
+		}
+		usage += th.usage
+	}
+	num := int(r.byte())
+	if num > cap(b.stack) {
+		return nil, ErrInvalidBDSState
+	}
+	b.stack = b.stack[:num]
+	for i := range b.stack {
+		b.stack[i] = bdsNode{level: int(r.byte()), node: r.node(size)}
+		if b.stack[i].level >= h {
+			return nil, ErrInvalidBDSState
+		}
+	}
+	if r.err || len(r.data) != 0 || usage != len(b.stack) {
+		return nil, ErrInvalidBDSState
+	}
+	return b, nil
+}
+
+// bdsReader 按顺序读取状态，长度不够时设置 err
+type bdsReader struct {
+	data []byte
+	err  bool
+}
+
+func (r *bdsReader) next(n int) []byte {
+	if r.err || len(r.data) < n {
+		r.err = true
+		return make([]byte, n)
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *bdsReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *bdsReader) uint64() int {
+	v := binary.BigEndian.Uint64(r.next(8))
+	if v > 1<<62 {
+		r.err = true
+		return 0
+	}
+	return int(v)
+}
+
+func (r *bdsReader) node(size int) []byte {
+	return append([]byte(nil), r.next(size)...)
+}
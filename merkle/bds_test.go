@@ -0,0 +1,245 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBDS(t *testing.T) {
+	assert := assert.New(t)
+	for height := 1; height <= 8; height++ {
+		num := 1 << (height - 1)
+		sk := genBytes(num, 32)
+		mask := genBytes(2*(height-1), 32)
+		calls := 0
+		// 每次返回同一个缓冲区
+		buf := make([]byte, 0, 32)
+		leaf := func(i int) []byte {
+			calls++
+			buf = append(buf[:0], hash.Sha256(sk[i*32:(i+1)*32])...)
+			return buf
+		}
+
+		for _, m := range [][]byte{nil, mask} {
+			tree, err := NewTreeWithMask(height, 256, m)
+			assert.Nil(err)
+			if m == nil {
+				assert.Nil(tree.SetSk(sk))
+			} else {
+				assert.Nil(tree.SetSkWithMask(sk))
+			}
+			root, _ := tree.GetPk()
+			th, err := NewTreeHash(height, 256, hash.NewSha256Hasher(), m)
+			assert.Nil(err)
+
+			for k := (height - 1) % 2; k < height; k += 2 {
+				b, err := th.BDS(leaf, k)
+				assert.Nil(err)
+				assert.Equal(root, b.Root())
+				for i := 0; i < num; i++ {
+					assert.Equal(i, b.Index())
+					assert.Equal(tree.AuthenticationPath(0, i), b.AuthenticationPath(), "height %d, k %d, leaf %d", height, k, i)
+					calls = 0
+					err := b.Next()
+					if i == num-1 {
+						assert.Equal(ErrLeavesExhausted, err)
+						continue
+					}
+					assert.Nil(err)
+					// 每次最多计算 (H-K)/2 + 1 个叶子节点
+					assert.LessOrEqual(calls, (height-1-k)/2+1)
+				}
+			}
+		}
+	}
+}
+
+func TestBDSAt(t *testing.T) {
+	assert := assert.New(t)
+	for height := 1; height <= 8; height++ {
+		num := 1 << (height - 1)
+		sk := genBytes(num, 32)
+		mask := genBytes(2*(height-1), 32)
+		leaf := func(i int) []byte {
+			return hash.Sha256(sk[i*32 : (i+1)*32])
+		}
+		th, err := NewTreeHash(height, 256, hash.NewSha256Hasher(), mask)
+		assert.Nil(err)
+		for k := (height - 1) % 2; k < height; k += 2 {
+			for start := 0; start < num; start++ {
+				// 从第 start 个叶子节点开始，之后每一个叶子节点的鉴权路径都正确
+				b, err := th.BDSAt(leaf, k, start)
+				assert.Nil(err)
+				for i := start; i < num; i++ {
+					assert.Equal(i, b.Index())
+					_, path := th.AuthenticationPath(leaf, 0, i)
+					assert.Equal(path, b.AuthenticationPath(), "height %d, k %d, start %d, leaf %d", height, k, start, i)
+					if i < num-1 {
+						assert.Nil(b.Next())
+					}
+				}
+			}
+		}
+		_, err = th.BDSAt(leaf, (height-1)%2, num)
+		assert.NotNil(err)
+		_, err = th.BDSAt(leaf, (height-1)%2, -1)
+		assert.NotNil(err)
+	}
+}
+
+func TestTreeHashWithNode(t *testing.T) {
+	assert := assert.New(t)
+	const height = 6
+	num := 1 << (height - 1)
+	sk := genBytes(num, 32)
+	leaf := func(i int) []byte {
+		return hash.Sha256(sk[i*32 : (i+1)*32])
+	}
+	// 节点的哈希值包含它的层数以及位置，和 LMS 中一样
+	node := func(h *hash.Hasher, dst, left, right []byte, level, index int) []byte {
+		return h.Sum(dst, []byte{byte(level), byte(index)}, left, right)
+	}
+	// 直接按照定义计算每一层
+	layers := [][][]byte{make([][]byte, num)}
+	for i := range layers[0] {
+		layers[0][i] = leaf(i)
+	}
+	for level := 0; level < height-1; level++ {
+		next := make([][]byte, len(layers[level])/2)
+		for i := range next {
+			next[i] = node(hash.NewSha256Hasher(), nil, layers[level][2*i], layers[level][2*i+1], level, i)
+		}
+		layers = append(layers, next)
+	}
+
+	th, err := NewTreeHashWithNode(height, 256, hash.NewSha256Hasher(), node)
+	assert.Nil(err)
+	b, err := th.BDS(leaf, 1)
+	assert.Nil(err)
+	assert.Equal(layers[height-1][0], b.Root())
+	for i := 0; i < num; i++ {
+		path := make([][]byte, height-1)
+		for level := range path {
+			path[level] = layers[level][(i>>level)^1]
+		}
+		root, p := th.AuthenticationPath(leaf, 0, i)
+		assert.Equal(layers[height-1][0], root)
+		assert.Equal(path, p, i)
+		assert.Equal(path, b.AuthenticationPath(), i)
+		if i < num-1 {
+			assert.Nil(b.Next())
+		}
+	}
+
+	_, err = NewTreeHashWithNode(height, 256, hash.NewSha256Hasher(), nil)
+	assert.NotNil(err)
+}
+
+func TestBDSRestore(t *testing.T) {
+	assert := assert.New(t)
+	const height = 7
+	sk := genBytes(1<<(height-1), 32)
+	mask := genBytes(2*(height-1), 32)
+	leaf := func(i int) []byte {
+		return hash.Sha256(sk[i*32 : (i+1)*32])
+	}
+	th, err := NewTreeHash(height, 256, hash.NewSha256Hasher(), mask)
+	assert.Nil(err)
+
+	for _, k := range []int{0, 2, 4, 6} {
+		b, err := th.BDS(leaf, k)
+		assert.Nil(err)
+		other, err := th.BDS(leaf, k)
+		assert.Nil(err)
+		for i := 0; i < 1<<(height-1)-1; i++ {
+			// 每一个叶子节点之后都保存一次，恢复出来的状态和原来的状态一致
+			state, err := b.MarshalBinary()
+			assert.Nil(err)
+			assert.LessOrEqual(len(state), BDSStateSize(height, 256, k))
+			restored, err := th.RestoreBDS(leaf, state)
+			assert.Nil(err)
+			assert.Equal(b.Index(), restored.Index())
+			assert.Equal(b.Root(), restored.Root())
+			assert.Equal(b.AuthenticationPath(), restored.AuthenticationPath())
+
+			assert.Nil(restored.Next())
+			assert.Nil(other.Next())
+			assert.Equal(other.AuthenticationPath(), restored.AuthenticationPath(), "k %d, leaf %d", k, i+1)
+			b = restored
+		}
+	}
+}
+
+func TestBDSErr(t *testing.T) {
+	assert := assert.New(t)
+	leaf := func(i int) []byte {
+		return make([]byte, 32)
+	}
+	th, err := NewTreeHash(5, 256, hash.NewSha256Hasher(), nil)
+	assert.Nil(err)
+	for _, k := range []int{-2, 1, 3, 5, 6} {
+		_, err := th.BDS(leaf, k)
+		assert.NotNil(err, k)
+	}
+	_, err = th.BDS(nil, 2)
+	assert.NotNil(err)
+
+	b, err := th.BDS(leaf, 2)
+	assert.Nil(err)
+	assert.Nil(b.Next())
+	state, err := b.MarshalBinary()
+	assert.Nil(err)
+
+	_, err = th.RestoreBDS(nil, state)
+	assert.NotNil(err)
+	other, _ := NewTreeHash(7, 256, hash.NewSha256Hasher(), nil)
+	_, err = other.RestoreBDS(leaf, state)
+	assert.Equal(ErrInvalidBDSState, err)
+	for _, s := range [][]byte{
+		nil,
+		state[:len(state)-1],
+		append(append([]byte(nil), state...), 0),
+	} {
+		_, err = th.RestoreBDS(leaf, s)
+		assert.Equal(ErrInvalidBDSState, err)
+	}
+	// 版本以及 k 不正确
+	for _, i := range []int{0, 2} {
+		s := append([]byte(nil), state...)
+		s[i] = 3
+		_, err = th.RestoreBDS(leaf, s)
+		assert.Equal(ErrInvalidBDSState, err, i)
+	}
+}
+
+// BenchmarkBDS 依次计算所有叶子节点的鉴权路径，和每次使用 TreeHash 重新计算比较
+func BenchmarkBDS(b *testing.B) {
+	const height = 11
+	sk := genBytes(1<<(height-1), 32)
+	leaf := func(i int) []byte {
+		return hash.Sha256(sk[i*32 : (i+1)*32])
+	}
+	th, _ := NewTreeHash(height, 256, hash.NewSha256Hasher(), nil)
+	for _, k := range []int{2, 4, 6} {
+		b.Run(fmt.Sprintf("bds-k%d", k), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bds, _ := th.BDS(leaf, k)
+				for bds.Next() == nil {
+					_ = bds.AuthenticationPath()
+				}
+			}
+		})
+	}
+	b.Run("tree-hash", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 1<<(height-1); j++ {
+				_, _ = th.AuthenticationPath(leaf, 0, j)
+			}
+		}
+	})
+}
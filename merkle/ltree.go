@@ -28,28 +28,58 @@ func LTree(pk []byte, n int, hash hash.Hash) []byte {
 	return tree[0]
 }
 
-func h(hash hash.Hash, a, b []byte) []byte {
-	return hash(append(a, b...))
+func h(h hash.Hash, a, b []byte) []byte {
+	return hash.CombineAndHash(a, b, h)
 }
 
 // LTreeWithMask 计算 L-Tree 的根节点值
+// 每一层将相邻的两个节点和掩码异或之后进行哈希，如果该层节点数为奇数，
+// 最后一个节点直接提升到上一层
+// 第 j 层 (叶子节点为第 0 层) 使用的掩码为 mask 中的第 2j 和 2j+1 块
+// 一共需要 2 * ceil(log(l)) 个掩码，l 为公钥块的个数
 func LTreeWithMask(pk []byte, n int, hash hash.Hash, mask []byte) []byte {
 	size := n / 8
-	num := len(pk) * 8 / n
-	// 找到最接近的 2 的指数数字
-	value, power := common.NearestPowerOf2(num)
-	tree := make([][]byte, value)
+	l := len(pk) / size
+	tree := make([][]byte, l)
 	// 将 pk 拷贝过去
-	for i := 0; i < num; i++ {
+	for i := 0; i < l; i++ {
 		tree[i] = pk[i*size : (i+1)*size]
 	}
 
-	// 首先使用 mask 的前一部分的数据，还是后面的？
-	//
-	for j := power - 1; j >= 0; j-- {
-		for i := 0; i < value/2; i += 1 {
+	for j := 0; l > 1; j++ {
+		for i := 0; i < l/2; i++ {
 			tree[i] = h(hash, common.Xor(tree[2*i], mask[(2*j)*size:(2*j+1)*size]), common.Xor(tree[2*i+1], mask[(2*j+1)*size:(2*j+2)*size]))
 		}
+		if l&1 != 0 {
+			tree[l/2] = tree[l-1]
+			l = l/2 + 1
+		} else {
+			l /= 2
+		}
 	}
 	return tree[0]
 }
+
+// LTreeInPlace 和 LTreeWithMask 相同，不过使用 Hasher 直接在 pk 上计算，不分配内存
+// pk 的内容会被覆盖，返回值为 pk 的前 n bits，mask 为空时不使用掩码
+func LTreeInPlace(pk []byte, n int, h *hash.Hasher, mask []byte) []byte {
+	size := n / 8
+	l := len(pk) / size
+	for j := 0; l > 1; j++ {
+		var m []byte
+		if mask != nil {
+			m = mask[2*j*size : (2*j+2)*size]
+		}
+		// 第 i 个父节点写入第 i 个块，2i 和 2i+1 在此之前已经读取
+		for i := 0; i < l/2; i++ {
+			h.Combine(pk[i*size:i*size], pk[2*i*size:(2*i+1)*size], pk[(2*i+1)*size:(2*i+2)*size], m)
+		}
+		if l&1 != 0 {
+			copy(pk[l/2*size:], pk[(l-1)*size:l*size])
+			l = l/2 + 1
+		} else {
+			l /= 2
+		}
+	}
+	return pk[:size]
+}
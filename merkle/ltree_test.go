@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
 	"github.com/stretchr/testify/assert"
 )
@@ -49,6 +50,42 @@ func TestLTree4(t *testing.T) {
 	assert.Equal("b32aaaa44a979603301a530fe4c5e8e70abfb4075c2bd5ef0a7636041b97f9cf6ac007ce57b47a109cd361f893ba5a806d6e366724060902ad03977a69f58063", hex.EncodeToString(LTree(pk, n, hash.Sha512)))
 }
 
+func TestLTreeWithMask(t *testing.T) {
+	assert := assert.New(t)
+	n := 256
+	size := n / 8
+	// 5 个公钥块，需要 3 层，每层 2 个掩码
+	pk := genBytes(5, size)
+	mask := genBytes(6, size)
+	origin := make([]byte, len(pk))
+	copy(origin, pk)
+
+	node := func(a, b []byte, level int) []byte {
+		return hash.Sha256(append(common.Xor(a, mask[2*level*size:(2*level+1)*size]), common.Xor(b, mask[(2*level+1)*size:(2*level+2)*size])...))
+	}
+	block := func(i int) []byte {
+		return pk[i*size : (i+1)*size]
+	}
+	// level 0: (0,1) (2,3) 4
+	// level 1: (01, 23) 4
+	// level 2: (0123, 4)
+	expected := node(node(node(block(0), block(1), 0), node(block(2), block(3), 0), 1), block(4), 2)
+	assert.Equal(expected, LTreeWithMask(pk, n, hash.Sha256, mask))
+	// 不应该修改输入
+	assert.Equal(origin, pk)
+
+	// LTreeInPlace 直接覆盖 pk
+	assert.Equal(expected, LTreeInPlace(pk, n, hash.NewSha256Hasher(), mask))
+	for l := 1; l <= 9; l++ {
+		pk := genBytes(l, size)
+		expected := LTree(pk, n, hash.Sha256)
+		if l&(l-1) == 0 {
+			// 没有掩码时，块数为 2 的幂的情况和 LTree 相同
+			assert.Equal(expected, LTreeInPlace(pk, n, hash.NewSha256Hasher(), nil), l)
+		}
+	}
+}
+
 // bytes 中的每一个元素长度都是一致的
 func chainHash(bytes []byte, h hash.Hash) []byte {
 	return h(bytes)
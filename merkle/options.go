@@ -2,6 +2,7 @@ package merkle
 
 import (
 	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/suite"
 )
 
 type Option interface {
@@ -34,3 +35,23 @@ func WithHash(hash hash.Hash) Option {
 		t.hash = hash
 	})
 }
+
+// WithLeafHash 设置叶子节点使用的哈希函数
+// 如 SPHINCS 中叶子节点使用 F，其余节点使用 H
+func WithLeafHash(hash hash.Hash) Option {
+	return function(func(t *Tree) {
+		t.leafHash = hash
+	})
+}
+
+// WithHashSuite 使用哈希函数族中的 F 作为叶子节点的哈希函数，H 作为其余节点的哈希函数
+// 输出为 NewTree 中的 n bits，哈希函数族不支持 n 时 NewTree 返回错误
+func WithHashSuite(s suite.HashSuite) Option {
+	return function(func(t *Tree) {
+		t.suite = s
+		if s.Supports(t.n) {
+			t.leafHash = s.F(t.n).Hash()
+			t.hash = s.H(t.n).Hash()
+		}
+	})
+}
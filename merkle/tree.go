@@ -6,6 +6,7 @@ import (
 
 	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/suite"
 )
 
 // Node represents Merkle node
@@ -23,17 +24,22 @@ type Tree struct {
 	height int
 	total  int
 	hash   hash.Hash
-	n      int
+	// 叶子节点使用的哈希函数，为空时使用 hash
+	leafHash hash.Hash
+	// 通过 WithHashSuite 设置的哈希函数族
+	suite suite.HashSuite
+	n     int
 	// TODO: bytes pool, for tree.h function
 	// bytes length is n/8
 	mask []byte
 }
 
 // NewTree returns a tree with height h
-// n specifies hash function
-func NewTree(height int, n int) (*Tree, error) {
-	if n != 512 && n != 256 {
-		return nil, errors.New("n should be 256 or 512")
+// n specifies hash function, which can be replaced by options
+// n 为 256 或者 512 时默认使用 SHA-2，其他长度 (例如 128, 192) 必须通过 WithHash 指定哈希函数
+func NewTree(height int, n int, opts ...Option) (*Tree, error) {
+	if n <= 0 || n%8 != 0 {
+		return nil, errors.New("n should be a positive multiple of 8")
 	}
 	if height < 1 {
 		return nil, errors.New("height should not less than 1")
@@ -49,19 +55,31 @@ func NewTree(height int, n int) (*Tree, error) {
 		nodes:  nodes,
 		height: height,
 		total:  total,
-		hash:   hash.Sha256,
 		n:      n,
 	}
 
-	if n == 512 {
+	switch n {
+	case 256:
+		t.hash = hash.Sha256
+	case 512:
 		t.hash = hash.Sha512
 	}
 
+	for _, opt := range opts {
+		opt.apply(t)
+	}
+	if t.suite != nil && !t.suite.Supports(t.n) {
+		return nil, fmt.Errorf("hash suite %s does not support n = %d", t.suite.Name(), t.n)
+	}
+	if t.hash == nil {
+		return nil, errors.New("hash function is required when n is not 256 or 512")
+	}
+
 	return t, nil
 }
 
-func NewTreeWithMask(height, n int, mask []byte) (*Tree, error) {
-	t, err := NewTree(height, n)
+func NewTreeWithMask(height, n int, mask []byte, opts ...Option) (*Tree, error) {
+	t, err := NewTree(height, n, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +100,7 @@ func (t *Tree) SetSk(sk []byte) error {
 	// 设置叶子节点的私钥
 	for i := diff; i < t.total; i++ {
 		// leave are the hash value of secret key
-		t.nodes[i].value = t.hash(sk[(i-diff)*n : (i-diff+1)*n])
+		t.nodes[i].value = t.leaf(sk[(i-diff)*n : (i-diff+1)*n])
 	}
 
 	// compute root
@@ -101,38 +119,60 @@ func (t *Tree) SetSkWithMask(sk []byte) error {
 		return fmt.Errorf("sk should have %d bytes, but got %d", (1<<(t.height-1))*n, len(sk))
 	}
 
+	// leave are the hash value of secret key
+	leaves := make([]byte, 0, len(sk))
+	for i := 0; i < len(sk); i += n {
+		leaves = append(leaves, t.leaf(sk[i:i+n])...)
+	}
+	return t.SetLeavesWithMask(leaves)
+}
+
+// SetLeavesWithMask 直接设置叶子节点的值，不再进行哈希
+// 每一个叶子节点 n bits
+func (t *Tree) SetLeavesWithMask(leaves []byte) error {
+	n := t.n / 8
+	if len(leaves) != (1<<(t.height-1))*n {
+		return fmt.Errorf("leaves should have %d bytes, but got %d", (1<<(t.height-1))*n, len(leaves))
+	}
+
 	// 叶子节点第一个节点的索引值
 	diff := 1<<(t.height-1) - 1
 
-	// 设置叶子节点的私钥
 	for i := diff; i < t.total; i++ {
-		// leave are the hash value of secret key
-		t.nodes[i].value = t.hash(sk[(i-diff)*n : (i-diff+1)*n])
+		t.nodes[i].value = leaves[(i-diff)*n : (i-diff+1)*n]
 	}
 
 	// compute root
-	// mask 一共是 2 * height * n bits
+	// mask 一共是 2 * (height-1) * n bits
 	// 每一个 mask block 是 n bits
-	// 每一层使用一对 mask
+	// 每一层使用一对 mask，从下往上使用，即叶子节点的父节点使用第 0 对
 	// 左节点和 mask_i[0] 进行异或
 	// 右结点和 mask_i[1] 进行异或
 	xor := common.Xor
 	for i := diff - 1; i >= 0; i-- {
 		// 判断是第几层，从 0 开始，根节点为 0 层
 		layer := common.BitCount(uint64(i+1)) - 1
+		// 从下往上数的层数
+		level := t.height - 2 - layer
 		left := t.nodes[2*i+1].value
 		right := t.nodes[2*i+2].value
 
-		//fmt.Printf("[mask-tree.go] left: [node %x] [mask %x]\n, right: [node %x] [mask %x]\n", left, t.mask[(2*layer)*t.n/8:(2*layer+1)*t.n/8], right, t.mask[(2*layer+1)*t.n/8:(2*layer+2)*t.n/8])
-
 		t.nodes[i].value = t.h(
-			xor(left, t.mask[(2*layer)*t.n/8:(2*layer+1)*t.n/8]),
-			xor(right, t.mask[(2*layer+1)*t.n/8:(2*layer+2)*t.n/8]),
+			xor(left, t.mask[(2*level)*n:(2*level+1)*n]),
+			xor(right, t.mask[(2*level+1)*n:(2*level+2)*n]),
 		)
 	}
 	return nil
 }
 
+// leaf 计算叶子节点的哈希值
+func (t *Tree) leaf(sk []byte) []byte {
+	if t.leafHash != nil {
+		return t.leafHash(sk)
+	}
+	return t.hash(sk)
+}
+
 func (t *Tree) h(a []byte, b []byte) []byte {
 	return hash.CombineAndHash(a, b, t.hash)
 }
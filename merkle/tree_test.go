@@ -8,6 +8,7 @@ import (
 
 	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/suite"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -162,6 +163,36 @@ func TestComputeRoot(t *testing.T) {
 	assert.Equal(root, root_)
 }
 
+func TestTreeHashSuite(t *testing.T) {
+	assert := assert.New(t)
+	sk := make([]byte, 4*16)
+	for i := range sk {
+		sk[i] = byte(i)
+	}
+	for _, name := range suite.Names() {
+		st, _ := suite.ByName(name)
+		// n = 128 时没有默认的哈希函数
+		tree, err := NewTree(3, 128, WithHashSuite(st))
+		if !assert.Nil(err, name) {
+			continue
+		}
+		assert.Nil(tree.SetSk(sk))
+
+		// 叶子节点使用 F，其余节点使用 H
+		leaf := st.F(128).Clone().Sum(nil, sk[16:32])
+		got, _ := tree.GetLeaf(1)
+		assert.Equal(leaf, got, name)
+
+		path := tree.AuthenticationPath(0, 1)
+		root, _ := tree.GetPk()
+		assert.Equal(root, ComputeRootFromLeafWithMask(leaf, 1+1<<(tree.height-1)-1, path, st.H(128).Hash(), make([][]byte, 2*len(path))), name)
+	}
+
+	// SPHINCS-256 中的 F 和 H 最多为 256 bits
+	_, err := NewTree(3, 512, WithHashSuite(suite.SPHINCS256))
+	assert.NotNil(err)
+}
+
 func BenchmarkTreeAuthenticationPath(b *testing.B) {
 
 	tree, _ := NewTree(3, 256)
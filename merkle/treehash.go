@@ -0,0 +1,138 @@
+package merkle
+
+import (
+	"errors"
+
+	"github.com/junhaideng/sphincs/hash"
+)
+
+// LeafFunc 返回第 i 个叶子节点的值 (n bits)，TreeHash 按照 i = 0, 1, ... 的顺序依次调用
+// TreeHash 和 BDS 不会保存返回的 slice，所以 LeafFunc 可以每次都使用同一个缓冲区
+type LeafFunc func(i int) []byte
+
+// NodeFunc 计算 left 和 right 的父节点，结果追加到 dst 后面
+// level 为子节点从下往上数的层数，叶子节点为 0，index 为父节点在它所在的一层中的位置
+// 用于 LMS 这样内部节点的哈希值和位置有关的树，h 为 TreeHash 中哈希函数的副本，每次计算时只被一个 goroutine 使用
+type NodeFunc func(h *hash.Hasher, dst, left, right []byte, level, index int) []byte
+
+// TreeHash 使用 tree hash 算法 (Merkle, 1979) 计算 Merkle 树
+// 叶子节点由 LeafFunc 依次生成，栈中每一层最多保存一个节点，计算完一个父节点之后子节点就被丢弃
+// 所以除了需要返回的节点之外只使用 O(height) 的内存，而 Tree 需要保存全部 2^height - 1 个节点
+//
+// height 和 mask 的含义和 NewTreeWithMask 相同，树一共有 2^(height-1) 个叶子节点
+// 计算的结果和 Tree.SetLeavesWithMask 完全一致，mask 为空时和 Tree.SetSk 中的内部节点一致
+// 栈中的节点保存在预先分配的缓冲区中，计算内部节点时不分配内存
+type TreeHash struct {
+	height int
+	n      int
+	// 原型，每次计算时 Clone，所以 TreeHash 可以被多个 goroutine 同时使用
+	hash *hash.Hasher
+	mask []byte
+	// node 不为空时代替 hash.Combine 计算内部节点，见 NewTreeHashWithNode
+	node NodeFunc
+}
+
+// NewTreeHash h 为内部节点使用的哈希函数，mask 为空时不使用掩码
+func NewTreeHash(height, n int, h *hash.Hasher, mask []byte) (*TreeHash, error) {
+	if n <= 0 || n%8 != 0 {
+		return nil, errors.New("n should be a positive multiple of 8")
+	}
+	if height < 1 {
+		return nil, errors.New("height should not less than 1")
+	}
+	if h == nil {
+		return nil, errors.New("hash function is required")
+	}
+	if mask != nil && len(mask) < 2*(height-1)*n/8 {
+		return nil, errors.New("mask should have 2*(height-1) blocks")
+	}
+	return &TreeHash{height: height, n: n, hash: h, mask: mask}, nil
+}
+
+// NewTreeHashWithNode 内部节点由 node 计算，h 为传给 node 的哈希函数
+func NewTreeHashWithNode(height, n int, h *hash.Hasher, node NodeFunc) (*TreeHash, error) {
+	if node == nil {
+		return nil, errors.New("node function is required")
+	}
+	t, err := NewTreeHash(height, n, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	t.node = node
+	return t, nil
+}
+
+// Root 计算根节点
+func (t *TreeHash) Root(leaf LeafFunc) []byte {
+	root, _, _ := t.Compute(leaf, 0, nil)
+	return root
+}
+
+// AuthenticationPath 计算根节点以及第 index 个叶子节点的鉴权路径
+// h 和 index 的含义和 Tree.AuthenticationPath 相同，路径中的节点从下到上
+func (t *TreeHash) AuthenticationPath(leaf LeafFunc, h, index int) ([]byte, [][]byte) {
+	root, _, paths := t.Compute(leaf, h, []int{index})
+	return root, paths[0]
+}
+
+// Compute 遍历一次叶子节点，返回根节点，第 h 层 (根节点为 0 层) 的所有节点
+// 以及 indices 中每一个叶子节点到第 h 层的鉴权路径，例如 HORST 中需要的 k 条路径
+func (t *TreeHash) Compute(leaf LeafFunc, h int, indices []int) ([]byte, [][]byte, [][][]byte) {
+	size := t.n / 8
+	hasher := t.hash.Clone()
+	// stop 为第 h 层从下往上数的层数，叶子节点为 0
+	stop := t.height - 1 - h
+	layer := make([][]byte, 1<<h)
+	paths := make([][][]byte, len(indices))
+	for j := range paths {
+		paths[j] = make([][]byte, stop)
+	}
+	// record 保存需要返回的节点，level 从下往上数，pos 为节点在这一层中的位置
+	// node 所在的缓冲区之后会被覆盖，所以需要复制
+	record := func(node []byte, level, pos int) {
+		if level == stop {
+			layer[pos] = append([]byte(nil), node...)
+		}
+		if level >= stop {
+			return
+		}
+		for j, index := range indices {
+			if (index>>level)^1 == pos {
+				paths[j][level] = append([]byte(nil), node...)
+			}
+		}
+	}
+
+	// 栈中每一层最多只有一个节点，第 level 层的节点保存在 stack[level*size:] 中
+	// levels 为栈中节点的层数，从下到上递减
+	stack := make([]byte, t.height*size)
+	levels := make([]int, 0, t.height)
+	node := make([]byte, 0, size)
+	for i := 0; i < 1<<(t.height-1); i++ {
+		cur, level, pos := leaf(i), 0, i
+		record(cur, level, pos)
+		for len(levels) > 0 && levels[len(levels)-1] == level {
+			levels = levels[:len(levels)-1]
+			pos >>= 1
+			cur = t.combine(hasher, node[:0], stack[level*size:(level+1)*size], cur, level, pos)
+			level++
+			record(cur, level, pos)
+		}
+		copy(stack[level*size:], cur)
+		levels = append(levels, level)
+	}
+	return append([]byte(nil), stack[(t.height-1)*size:]...), layer, paths
+}
+
+// combine 计算两个子节点的父节点，追加到 dst 后面，level 和 index 的含义见 NodeFunc
+func (t *TreeHash) combine(h *hash.Hasher, dst, left, right []byte, level, index int) []byte {
+	if t.node != nil {
+		return t.node(h, dst, left, right, level, index)
+	}
+	if t.mask == nil {
+		return h.Combine(dst, left, right, nil)
+	}
+	// 和 SetLeavesWithMask 一样，从下往上第 level 层的子节点使用第 level 对掩码
+	size := t.n / 8
+	return h.Combine(dst, left, right, t.mask[2*level*size:2*(level+1)*size])
+}
@@ -0,0 +1,99 @@
+package merkle
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeHash(t *testing.T) {
+	assert := assert.New(t)
+	for height := 1; height <= 6; height++ {
+		num := 1 << (height - 1)
+		sk := genBytes(num, 32)
+		mask := genBytes(2*(height-1), 32)
+
+		for _, m := range [][]byte{nil, mask} {
+			tree, err := NewTreeWithMask(height, 256, m)
+			assert.Nil(err)
+			if m == nil {
+				assert.Nil(tree.SetSk(sk))
+			} else {
+				assert.Nil(tree.SetSkWithMask(sk))
+			}
+			root, _ := tree.GetPk()
+
+			th, err := NewTreeHash(height, 256, hash.NewSha256Hasher(), m)
+			assert.Nil(err)
+			// 叶子节点只能按顺序生成
+			next := 0
+			// 每次返回同一个缓冲区
+			buf := make([]byte, 0, 32)
+			leaf := func(i int) []byte {
+				assert.Equal(next, i)
+				next++
+				buf = append(buf[:0], hash.Sha256(sk[i*32:(i+1)*32])...)
+				return buf
+			}
+			assert.Equal(root, th.Root(leaf))
+
+			for h := 0; h < height; h++ {
+				indices := make([]int, 3)
+				for j := range indices {
+					indices[j] = rand.Intn(num)
+				}
+				next = 0
+				r, layer, paths := th.Compute(leaf, h, indices)
+				assert.Equal(root, r)
+				assert.Equal(tree.Layer(h), layer, h)
+				for j, index := range indices {
+					assert.Equal(tree.AuthenticationPath(h, index), paths[j], h)
+				}
+
+				next = 0
+				r, path := th.AuthenticationPath(leaf, h, indices[0])
+				assert.Equal(root, r)
+				assert.Equal(tree.AuthenticationPath(h, indices[0]), path)
+			}
+		}
+	}
+}
+
+func TestNewTreeHashErr(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewTreeHash(0, 256, hash.NewSha256Hasher(), nil)
+	assert.NotNil(err)
+	_, err = NewTreeHash(3, 7, hash.NewSha256Hasher(), nil)
+	assert.NotNil(err)
+	_, err = NewTreeHash(3, 256, nil, nil)
+	assert.NotNil(err)
+	_, err = NewTreeHash(3, 256, hash.NewSha256Hasher(), make([]byte, 32*3))
+	assert.NotNil(err)
+}
+
+// BenchmarkTreeHash 和 Tree 比较内存分配，高度为 HORST 中的 17
+func BenchmarkTreeHash(b *testing.B) {
+	const height = 17
+	sk := genBytes(1<<(height-1), 32)
+	mask := genBytes(2*(height-1), 32)
+	leaf := func(i int) []byte {
+		return hash.Sha256(sk[i*32 : (i+1)*32])
+	}
+	b.Run("tree", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			tree, _ := NewTreeWithMask(height, 256, mask)
+			_ = tree.SetSkWithMask(sk)
+			_ = tree.AuthenticationPath(0, 12345)
+		}
+	})
+	b.Run("tree-hash", func(b *testing.B) {
+		b.ReportAllocs()
+		th, _ := NewTreeHash(height, 256, hash.NewSha256Hasher(), mask)
+		for i := 0; i < b.N; i++ {
+			_, _ = th.AuthenticationPath(leaf, 0, 12345)
+		}
+	})
+}
@@ -25,26 +25,27 @@ func ComputeRoot(sk []byte, index int, path [][]byte, h hash.Hash) []byte {
 	return ret
 }
 
+// ComputeRootWithMask 通过鉴权路径和私钥，计算出根节点，叶子节点为 h(sk)
+// mask 从下往上使用，第 i 次哈希使用 mask[2i] 和 mask[2i+1]
 func ComputeRootWithMask(sk []byte, index int, path [][]byte, h hash.Hash, mask [][]byte) []byte {
+	return ComputeRootFromLeafWithMask(h(sk), index, path, h, mask)
+}
+
+// ComputeRootFromLeafWithMask 和 ComputeRootWithMask 一样，不过直接给出叶子节点的值
+// index 为叶子节点在树中的总索引，path 中的节点数据从下到上
+func ComputeRootFromLeafWithMask(leaf []byte, index int, path [][]byte, h hash.Hash, mask [][]byte) []byte {
 	if 2*len(path) != len(mask) {
 		panic("掩码长度应该是鉴权路径的两倍")
 	}
-	//ret := make([]byte, len(sk))
-	//copy(ret, h(sk))
-	ret := h(sk)
-	//fmt.Printf("ret: %x\n", ret)
-	//fmt.Println(index)
+	ret := leaf
 	xor := common.Xor
 	for i := 0; i < len(path); i++ {
 		// 奇数
 		if index&1 != 0 {
-			//fmt.Printf("[mask-utils.go], 奇数 left: [node %x] [mask %x], right: [node %x] [mask %x]\n", ret, mask[2*(len(path)-i-1)], path[i], mask[2*(len(path)-1-i)+1])
-			ret = hash.CombineAndHash(xor(ret, mask[2*(len(path)-i-1)]), xor(path[i], mask[2*(len(path)-1-i)+1]), h)
+			ret = hash.CombineAndHash(xor(ret, mask[2*i]), xor(path[i], mask[2*i+1]), h)
 		} else {
-			//fmt.Printf("[mask-utils.go], 偶数 left: [node %x] [mask %x], right: [node %x] [mask %x]\n", path[i], mask[2*(len(path)-i-1)], ret, mask[2*(len(path)-1-i)+1])
-			ret = hash.CombineAndHash(xor(path[i], mask[2*(len(path)-i-1)]), xor(ret, mask[2*(len(path)-1-i)+1]), h)
+			ret = hash.CombineAndHash(xor(path[i], mask[2*i]), xor(ret, mask[2*i+1]), h)
 		}
-		//fmt.Printf("compute: %d, %x\n", i, ret)
 		index = (index - 1) / 2
 	}
 
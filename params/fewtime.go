@@ -0,0 +1,58 @@
+package params
+
+import "math"
+
+const (
+	// maxTerms 最多计算的项数，超过之后按照期望值估计
+	maxTerms = 1 << 20
+	// negligible 比当前和小 2^64 倍并且已经开始下降的项忽略不计
+	negligible = 64
+)
+
+// fewTimeBits 返回 -log2(Σ Pr[r] (rk/t)^k)，其中 t = 2^tau
+// h 为 0 时 q 次签名都使用同一个密钥，即 -log2((qk/t)^k)
+// 结果不小于 0 (避免输出 -0)
+func fewTimeBits(q uint64, h, k, tau int) float64 {
+	if h == 0 {
+		return math.Max(0, -forgery(float64(q), k, tau))
+	}
+
+	// 每一个 HORST 密钥被使用次数的期望值
+	mean := float64(q) * math.Exp2(-float64(h))
+	if mean > maxTerms {
+		// 此时使用次数集中在期望值附近
+		return math.Max(0, -forgery(mean, k, tau))
+	}
+
+	// log2(1 - 2^-h)，h 较大时直接计算 1 - 2^-h 会等于 1
+	log1p := math.Log1p(-math.Exp2(-float64(h))) / math.Ln2
+	// logC 为 log2(C(q, r))
+	logC, sum, prev := 0.0, math.Inf(-1), math.Inf(-1)
+	for r := uint64(1); r <= q && r <= maxTerms; r++ {
+		logC += math.Log2(float64(q-r+1)) - math.Log2(float64(r))
+		term := logC - float64(h)*float64(r) + float64(q-r)*log1p + forgery(float64(r), k, tau)
+		sum = logAdd(sum, term)
+		// log2(Pr[r]) 和 log2((rk/t)^k) 都是 r 的凹函数，开始下降之后不会再上升
+		if term < prev && term < sum-negligible {
+			break
+		}
+		prev = term
+	}
+	return math.Max(0, -sum)
+}
+
+// forgery 已经签名 r 次的密钥被伪造的概率 log2((rk/t)^k)，不超过 0
+func forgery(r float64, k, tau int) float64 {
+	return float64(k) * math.Min(0, math.Log2(r*float64(k))-float64(tau))
+}
+
+// logAdd 返回 log2(2^a + 2^b)
+func logAdd(a, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	return a + math.Log2(1+math.Exp2(b-a))
+}
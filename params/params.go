@@ -0,0 +1,304 @@
+// Package params 估计 HORS, HORST 以及 SPHINCS 参数集合的安全性，密钥和签名的大小以及哈希函数的调用次数
+//
+// 安全性按照 SPHINCS 论文 (https://sphincs.cr.yp.to/sphincs-20150202.pdf) 第 5 节的方式计算，取以下几项的最小值
+//
+//	F/H    通用的第二原像攻击，经典 n bits，量子 (Grover) n/2 bits
+//	H_msg  消息摘要的碰撞，经典 m/2 bits，量子 (BHT) m/3 bits
+//	HORST  攻击者选择的 HORST 密钥已经签名 r 次时，伪造的概率为 (rk/t)^k
+//
+// SPHINCS 中一共 2^h 个 HORST 密钥，签名 q 次之后某一个密钥恰好被使用 r 次的概率为
+//
+//	Pr[r] = C(q, r) (1 - 2^-h)^(q-r) 2^(-hr)
+//
+// 所以伪造成功的概率为 Σ Pr[r] (rk/t)^k，量子攻击者使用 Grover 搜索消息，安全性减半
+// 单独使用 HORS 或者 HORST 时 q 次签名都使用同一个密钥，即 r = q
+package params
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/junhaideng/sphincs/signature"
+)
+
+var (
+	ErrUnknownScheme = errors.New("params: unknown scheme")
+	ErrSignatures    = errors.New("params: number of signatures should be a positive integer or 2^x with x <= 64")
+)
+
+// Scheme 可以估计的签名算法
+type Scheme string
+
+const (
+	HORS    Scheme = "hors"
+	HORST   Scheme = "horst"
+	SPHINCS Scheme = "sphincs"
+)
+
+// Params 和 NewSphincs 中的记号一致，只是 W 为 Winternitz 参数本身 (例如 16)，和 SphincsParams 相同
+// HORS 只使用 Tau, K 以及 N，HORST 只使用 N, Tau 以及 K
+// M 为 0 时等于 Tau*K，N 为 0 时 HORS 中等于 M
+type Params struct {
+	Scheme Scheme `json:"scheme"`
+	N      int    `json:"n"`
+	M      int    `json:"m"`
+	H      int    `json:"h"`
+	D      int    `json:"d"`
+	W      int    `json:"w"`
+	Tau    int    `json:"tau"`
+	K      int    `json:"k"`
+}
+
+// SPHINCS256 论文中的 SPHINCS-256
+var SPHINCS256 = Params{Scheme: SPHINCS, N: 256, M: 512, H: 60, D: 12, W: 16, Tau: 16, K: 32}
+
+// Bound 某一个部件的安全性 (bits)
+type Bound struct {
+	Name      string  `json:"name"`
+	Classical float64 `json:"classical"`
+	Quantum   float64 `json:"quantum"`
+}
+
+// HashCalls 按照本仓库中的实现估计的哈希函数调用次数，不包括生成私钥的 PRG
+// WOTS+ 的签名和校验取平均情况，每一条链计算 (w-1)/2 次
+type HashCalls struct {
+	KeyGen float64 `json:"keygen"`
+	Sign   float64 `json:"sign"`
+	Verify float64 `json:"verify"`
+}
+
+// Estimate 估计的结果，Classical 和 Quantum 为 Bounds 中的最小值
+type Estimate struct {
+	Params     Params    `json:"params"`
+	Signatures uint64    `json:"signatures"`
+	Classical  float64   `json:"classical"`
+	Quantum    float64   `json:"quantum"`
+	Bounds     []Bound   `json:"bounds"`
+	PublicKey  int       `json:"public_key"`
+	PrivateKey int       `json:"private_key"`
+	Signature  int       `json:"signature"`
+	HashCalls  HashCalls `json:"hash_calls"`
+}
+
+// Estimate 估计最多签名 signatures 次时的安全性
+// 参数不合法时返回 *signature.ParamsError
+func (p Params) Estimate(signatures uint64) (*Estimate, error) {
+	if signatures == 0 {
+		return nil, ErrSignatures
+	}
+	var (
+		e   *Estimate
+		err error
+	)
+	switch p.Scheme {
+	case HORS:
+		e, err = p.hors(signatures)
+	case HORST:
+		e, err = p.horst(signatures)
+	case SPHINCS:
+		e, err = p.sphincs(signatures)
+	default:
+		return nil, ErrUnknownScheme
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.Signatures = signatures
+	e.Classical, e.Quantum = math.Inf(1), math.Inf(1)
+	for _, b := range e.Bounds {
+		e.Classical = math.Min(e.Classical, b.Classical)
+		e.Quantum = math.Min(e.Quantum, b.Quantum)
+	}
+	return e, nil
+}
+
+func invalid(param, reason string) error {
+	return &signature.ParamsError{Param: param, Reason: reason}
+}
+
+// fewTime 检查 tau, k 以及 m，返回补全之后的参数
+func (p Params) fewTime() (Params, error) {
+	if p.Tau <= 0 || p.Tau > 32 {
+		return p, invalid("tau", "应该在 1 到 32 之间")
+	}
+	if p.K <= 0 {
+		return p, invalid("k", "应该是正整数")
+	}
+	if p.M == 0 {
+		p.M = p.Tau * p.K
+	}
+	if p.M != p.Tau*p.K {
+		return p, invalid("m", "应该等于 tau*k")
+	}
+	return p, nil
+}
+
+func checkN(n int) error {
+	if n <= 0 || n%8 != 0 {
+		return invalid("n", "应该是 8 的正整数倍")
+	}
+	return nil
+}
+
+// hors 公钥为 t 个私钥块的哈希值，没有掩码，所以是 t 个目标的原像攻击
+// 消息直接使用 n bits 的哈希函数计算摘要
+func (p Params) hors(q uint64) (*Estimate, error) {
+	p, err := p.fewTime()
+	if err != nil {
+		return nil, err
+	}
+	if p.N == 0 {
+		p.N = p.M
+	}
+	if err := checkN(p.N); err != nil {
+		return nil, err
+	}
+	if p.M > p.N {
+		return nil, invalid("tau*k", "不能超过 n")
+	}
+	n, t, size := float64(p.N), float64(uint64(1)<<p.Tau), p.N/8
+	few := fewTimeBits(q, 0, p.K, p.Tau)
+	return &Estimate{
+		Params: p,
+		Bounds: []Bound{
+			{Name: "F/H", Classical: n - float64(p.Tau), Quantum: (n - float64(p.Tau)) / 2},
+			{Name: "H_msg", Classical: n / 2, Quantum: n / 3},
+			{Name: "HORS", Classical: few, Quantum: few / 2},
+		},
+		PublicKey:  (1 << p.Tau) * size,
+		PrivateKey: (1 << p.Tau) * size,
+		Signature:  p.K * size,
+		HashCalls:  HashCalls{KeyGen: t, Sign: 1, Verify: 1 + float64(p.K)},
+	}, nil
+}
+
+// horst 待签名的消息已经是 tau*k bits 的摘要，摘要的安全性由调用方保证
+func (p Params) horst(q uint64) (*Estimate, error) {
+	p, err := p.fewTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkN(p.N); err != nil {
+		return nil, err
+	}
+	n, size, x := float64(p.N), p.N/8, layer(p.K, p.Tau)
+	few := fewTimeBits(q, 0, p.K, p.Tau)
+	tree := horstTree(p.Tau)
+	return &Estimate{
+		Params: p,
+		Bounds: []Bound{
+			{Name: "F/H", Classical: n, Quantum: n / 2},
+			{Name: "HORST", Classical: few, Quantum: few / 2},
+		},
+		PublicKey:  size,
+		PrivateKey: (1 << p.Tau) * size,
+		Signature:  (p.K + (p.Tau-x)*p.K + 1<<x) * size,
+		HashCalls:  HashCalls{KeyGen: tree, Sign: tree, Verify: horstVerify(p.K, p.Tau)},
+	}, nil
+}
+
+// sphincs 参数的要求和 SphincsParams.Validate 相同
+func (p Params) sphincs(q uint64) (*Estimate, error) {
+	sp := signature.SphincsParams{N: p.N, H: p.H, D: p.D, W: p.W, Tau: p.Tau, K: p.K}
+	sizes, err := sp.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if p.M == 0 {
+		p.M = sizes.M
+	}
+	if p.M != sizes.M {
+		return nil, invalid("m", "应该等于 tau*k")
+	}
+
+	n, m := float64(p.N), float64(p.M)
+	few := fewTimeBits(q, p.H, p.K, p.Tau)
+
+	// WOTS+ 计算公钥需要 l(w-1) 次 F，L-tree 需要 l-1 次 H
+	l, chain := float64(sizes.L), float64(p.W-1)
+	leaf := l*chain + l - 1
+	leaves := math.Exp2(float64(p.H / p.D))
+	subtree := leaves*leaf + leaves - 1
+	d := float64(p.D)
+	return &Estimate{
+		Params: p,
+		Bounds: []Bound{
+			{Name: "F/H", Classical: n, Quantum: n / 2},
+			{Name: "H_msg", Classical: m / 2, Quantum: m / 3},
+			{Name: "HORST", Classical: few, Quantum: few / 2},
+		},
+		PublicKey:  sizes.PublicKey,
+		PrivateKey: sizes.PrivateKey,
+		Signature:  sizes.Signature,
+		HashCalls: HashCalls{
+			KeyGen: subtree,
+			// R 和 H_msg，d 个大 node，一个 HORST 密钥，每一层重新生成 WOTS+ 公钥之后签名
+			Sign: 2 + d*subtree + horstTree(p.Tau) + d*(l*chain+l*chain/2),
+			// H_msg，HORST，每一层补全 WOTS+ 的链，L-tree 以及鉴权路径
+			Verify: 1 + horstVerify(p.K, p.Tau) + d*(l*chain/2+l-1+float64(p.H/p.D)),
+		},
+	}, nil
+}
+
+// layer HORST 签名中保存的层数，和 signature 中的计算方式相同
+// 选择 x 使得签名中的节点数 k(tau-x+1) + 2^x 最小
+func layer(k, tau int) int {
+	best, res := math.MaxInt64, 0
+	for x := 0; x <= tau; x++ {
+		if v := k*(tau-x+1) + 1<<x; v <= best {
+			best, res = v, x
+		}
+	}
+	return res
+}
+
+// horstTree 计算整棵 HORST 树，t 次 F 以及 t-1 次 H
+func horstTree(tau int) float64 {
+	return 2*math.Exp2(float64(tau)) - 1
+}
+
+// horstVerify 每一个私钥块到 x 层需要 1 + tau - x 次哈希，再由 x 层的 2^x 个节点计算根节点
+func horstVerify(k, tau int) float64 {
+	x := layer(k, tau)
+	return float64(k*(1+tau-x)) + math.Exp2(float64(x)) - 1
+}
+
+// DefaultSignatures 没有指定签名次数时使用，SPHINCS 和论文中一样为 2^50，HORS 和 HORST 为 1
+func (p Params) DefaultSignatures() uint64 {
+	if p.Scheme == SPHINCS {
+		return 1 << 50
+	}
+	return 1
+}
+
+// ParseSignatures 解析签名次数，可以是十进制整数或者 2^x，2^64 按照 2^64-1 处理
+func ParseSignatures(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "2^") {
+		x, err := strconv.Atoi(s[2:])
+		if err != nil || x < 0 || x > 64 {
+			return 0, ErrSignatures
+		}
+		if x == 64 {
+			return math.MaxUint64, nil
+		}
+		return 1 << uint(x), nil
+	}
+	q, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || q == 0 {
+		return 0, ErrSignatures
+	}
+	return q, nil
+}
+
+// String 例如 sphincs(n=256, m=512, h=60, d=12, w=16, tau=16, k=32)
+func (p Params) String() string {
+	switch p.Scheme {
+	case HORS, HORST:
+		return fmt.Sprintf("%s(n=%d, m=%d, tau=%d, k=%d)", p.Scheme, p.N, p.M, p.Tau, p.K)
+	}
+	return fmt.Sprintf("%s(n=%d, m=%d, h=%d, d=%d, w=%d, tau=%d, k=%d)", p.Scheme, p.N, p.M, p.H, p.D, p.W, p.Tau, p.K)
+}
@@ -0,0 +1,146 @@
+package params
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/junhaideng/sphincs/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSPHINCS256(t *testing.T) {
+	assert := assert.New(t)
+	e, err := SPHINCS256.Estimate(1 << 50)
+	assert.Nil(err)
+	// 论文中 SPHINCS-256 的安全性，量子攻击者为 2^128
+	assert.Equal(256.0, e.Classical)
+	assert.Equal(128.0, e.Quantum)
+
+	s, err := signature.NewSphincsWithParams(signature.SPHINCS256Params, nil)
+	assert.Nil(err)
+	sizes := s.Sizes()
+	assert.Equal(sizes.PublicKey, e.PublicKey)
+	assert.Equal(sizes.PrivateKey, e.PrivateKey)
+	assert.Equal(sizes.Signature, e.Signature)
+
+	// 签名次数越多 HORST 部分越弱
+	var last float64 = math.Inf(1)
+	for _, q := range []uint64{1, 1 << 30, 1 << 50, 1 << 60, math.MaxUint64} {
+		e, err := SPHINCS256.Estimate(q)
+		assert.Nil(err)
+		horst := e.Bounds[len(e.Bounds)-1]
+		assert.Equal("HORST", horst.Name)
+		assert.True(horst.Classical < last, q)
+		last = horst.Classical
+	}
+}
+
+// binomial 直接计算 Σ Pr[r] (rk/t)^k
+func binomial(q uint64, h, k, tau int) float64 {
+	p := math.Exp2(-float64(h))
+	sum, c := 0.0, 1.0
+	for r := uint64(1); r <= q; r++ {
+		c = c * float64(q-r+1) / float64(r)
+		pr := c * math.Pow(p, float64(r)) * math.Pow(1-p, float64(q-r))
+		sum += pr * math.Pow(math.Min(1, float64(r)*float64(k)/math.Exp2(float64(tau))), float64(k))
+	}
+	return -math.Log2(sum)
+}
+
+func TestFewTimeBits(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		q         uint64
+		h, k, tau int
+	}{
+		{1, 4, 4, 8},
+		{16, 4, 4, 8},
+		{100, 3, 8, 6},
+		{1000, 10, 16, 12},
+		{500, 2, 4, 4},
+	} {
+		assert.InDelta(binomial(c.q, c.h, c.k, c.tau), fewTimeBits(c.q, c.h, c.k, c.tau), 1e-6, c)
+	}
+
+	// 同一个密钥签名 r 次，(rk/t)^k
+	assert.Equal(192.0, fewTimeBits(1, 0, 16, 16))
+	assert.Equal(176.0, fewTimeBits(2, 0, 16, 16))
+	assert.Equal(0.0, fewTimeBits(1<<20, 0, 16, 16))
+}
+
+func TestHors(t *testing.T) {
+	assert := assert.New(t)
+	e, err := Params{Scheme: HORS, Tau: 16, K: 16}.Estimate(1)
+	assert.Nil(err)
+	assert.Equal(256, e.Params.N)
+	assert.Equal(128.0, e.Classical)
+	assert.Equal(HashCalls{KeyGen: 1 << 16, Sign: 1, Verify: 17}, e.HashCalls)
+
+	s, err := signature.NewHorsSignature(16, 16)
+	assert.Nil(err)
+	sk, pk := s.GenerateKey()
+	assert.Equal(len(pk), e.PublicKey)
+	assert.Equal(len(sk), e.PrivateKey)
+	assert.Equal(len(s.Sign([]byte("hello world"), sk)), e.Signature)
+}
+
+func TestHorst(t *testing.T) {
+	assert := assert.New(t)
+	e, err := Params{Scheme: HORST, N: 256, Tau: 8, K: 32}.Estimate(2)
+	assert.Nil(err)
+	assert.Equal(fewTimeBits(2, 0, 32, 8), e.Classical)
+
+	s, err := signature.NewHorstSignature(8, 32, make([]byte, 32), make([]byte, 2*32*8))
+	assert.Nil(err)
+	sk, pk := s.GenerateKey()
+	assert.Equal(len(pk), e.PublicKey)
+	assert.Equal(len(sk), e.PrivateKey)
+	assert.Equal(len(s.Sign(make([]byte, 32), sk)), e.Signature)
+}
+
+func TestEstimateError(t *testing.T) {
+	assert := assert.New(t)
+	var pe *signature.ParamsError
+
+	_, err := Params{Scheme: "xmss"}.Estimate(1)
+	assert.Equal(ErrUnknownScheme, err)
+	_, err = SPHINCS256.Estimate(0)
+	assert.Equal(ErrSignatures, err)
+
+	p := SPHINCS256
+	p.M = 256
+	_, err = p.Estimate(1)
+	assert.True(errors.As(err, &pe))
+	assert.Equal("m", pe.Param)
+
+	p = SPHINCS256
+	p.H = 61
+	_, err = p.Estimate(1)
+	assert.True(errors.As(err, &pe))
+	assert.Equal("h", pe.Param)
+
+	_, err = Params{Scheme: HORS, Tau: 16, K: 16, N: 128}.Estimate(1)
+	assert.True(errors.As(err, &pe))
+	_, err = Params{Scheme: HORST, Tau: 33, K: 16, N: 256}.Estimate(1)
+	assert.True(errors.As(err, &pe))
+}
+
+func TestParseSignatures(t *testing.T) {
+	assert := assert.New(t)
+	for s, want := range map[string]uint64{
+		"1":    1,
+		"2^50": 1 << 50,
+		"2^0":  1,
+		"2^64": math.MaxUint64,
+		" 100": 100,
+	} {
+		q, err := ParseSignatures(s)
+		assert.Nil(err, s)
+		assert.Equal(want, q, s)
+	}
+	for _, s := range []string{"", "0", "2^65", "2^x", "-1", "1e6"} {
+		_, err := ParseSignatures(s)
+		assert.Equal(ErrSignatures, err, s)
+	}
+}
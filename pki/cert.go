@@ -0,0 +1,257 @@
+// Package pki 使用 SPHINCS 或者 SLH-DSA 签发 X.509 证书以及 PKCS#10 证书请求
+//
+// 标准库中的 x509.CreateCertificate 只支持 RSA, ECDSA 以及 Ed25519
+// 这里使用 encoding/asn1 构造 TBSCertificate，再用 Signature.Sign 进行签名
+// 生成的证书可以使用 x509.ParseCertificate 解析 (公钥以及签名算法为 Unknown)，签名需要使用 CheckSignature 校验
+//
+// 签名算法的 OID 和公钥的 OID 相同，见 keys.AlgorithmIdentifier
+package pki
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"math/bits"
+	"time"
+
+	"github.com/junhaideng/sphincs/keys"
+	"github.com/junhaideng/sphincs/signature"
+)
+
+// Key 签名算法以及对应的公钥或者私钥 (GenerateKey 的返回值)
+type Key struct {
+	Scheme signature.Scheme
+	Key    []byte
+}
+
+var (
+	ErrInvalidCertificate = errors.New("pki: invalid certificate")
+	ErrSignature          = errors.New("pki: signature verification failed")
+	ErrAlgorithmMismatch  = errors.New("pki: signature algorithm does not match the issuer key")
+)
+
+var (
+	oidExtensionSubjectKeyId     = asn1.ObjectIdentifier{2, 5, 29, 14}
+	oidExtensionKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionSubjectAltName   = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtensionAuthorityKeyId   = asn1.ObjectIdentifier{2, 5, 29, 35}
+)
+
+const (
+	certificateVersion3 = 2 // v3
+	nameTypeDNS         = 2 // GeneralName 中的 dNSName
+	subjectKeyIdSize    = 20
+)
+
+// certificate RFC 5280 4.1
+type certificate struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificate struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           validity
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+type validity struct {
+	NotBefore, NotAfter time.Time
+}
+
+type basicConstraints struct {
+	IsCA       bool `asn1:"optional"`
+	MaxPathLen int  `asn1:"optional,default:-1"`
+}
+
+type authorityKeyId struct {
+	Id []byte `asn1:"optional,tag:0"`
+}
+
+// CreateCertificate 根据 template 签发证书，返回 DER 编码
+// 使用 template 中的 SerialNumber, Subject, NotBefore, NotAfter, KeyUsage,
+// BasicConstraintsValid, IsCA, MaxPathLen, MaxPathLenZero, SubjectKeyId 以及 DNSNames
+// parent 为空时生成自签名证书，此时 pub 应该是 priv 对应的公钥
+func CreateCertificate(template, parent *x509.Certificate, pub, priv Key) ([]byte, error) {
+	if template.SerialNumber == nil {
+		return nil, errors.New("pki: no SerialNumber given")
+	}
+	spki, err := keys.MarshalPKIXPublicKey(pub.Scheme, pub.Key)
+	if err != nil {
+		return nil, err
+	}
+	sigAlg, err := keys.AlgorithmIdentifier(priv.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := asn1.Marshal(template.Subject.ToRDNSequence())
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := subject
+	subjectKeyId := template.SubjectKeyId
+	if len(subjectKeyId) == 0 && template.IsCA {
+		subjectKeyId = keyId(pub.Key)
+	}
+	authorityKeyID := subjectKeyId
+	if parent != nil {
+		issuer = parent.RawSubject
+		authorityKeyID = parent.SubjectKeyId
+	}
+
+	extensions, err := buildExtensions(template, subjectKeyId, authorityKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	tbs, err := asn1.Marshal(tbsCertificate{
+		Version:            certificateVersion3,
+		SerialNumber:       template.SerialNumber,
+		SignatureAlgorithm: sigAlg,
+		Issuer:             asn1.RawValue{FullBytes: issuer},
+		Validity:           validity{template.NotBefore.UTC(), template.NotAfter.UTC()},
+		Subject:            asn1.RawValue{FullBytes: subject},
+		PublicKey:          asn1.RawValue{FullBytes: spki},
+		Extensions:         extensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sign(tbs, priv)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(certificate{asn1.RawValue{FullBytes: tbs}, sigAlg, sig})
+}
+
+// sign 使用 priv 对 tbs 签名，私钥的长度不对时返回 signature.ErrInvalidEncoding
+func sign(tbs []byte, priv Key) (asn1.BitString, error) {
+	if _, err := signature.NewPrivateKey(priv.Scheme, priv.Key); err != nil {
+		return asn1.BitString{}, err
+	}
+	sig := priv.Scheme.Sign(tbs, priv.Key)
+	return asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)}, nil
+}
+
+// keyId RFC 7093 方法 1，SHA-256 的前 160 bits
+func keyId(pk []byte) []byte {
+	sum := sha256.Sum256(pk)
+	return sum[:subjectKeyIdSize]
+}
+
+func buildExtensions(template *x509.Certificate, subjectKeyId, authorityKeyID []byte) ([]pkix.Extension, error) {
+	var res []pkix.Extension
+	add := func(id asn1.ObjectIdentifier, critical bool, v interface{}) error {
+		b, err := asn1.Marshal(v)
+		if err != nil {
+			return err
+		}
+		res = append(res, pkix.Extension{Id: id, Critical: critical, Value: b})
+		return nil
+	}
+
+	if template.KeyUsage != 0 {
+		if err := add(oidExtensionKeyUsage, true, keyUsageBits(template.KeyUsage)); err != nil {
+			return nil, err
+		}
+	}
+	if template.BasicConstraintsValid {
+		// 和标准库一致，MaxPathLen 为 0 并且 MaxPathLenZero 为 false 表示不限制
+		maxPathLen := template.MaxPathLen
+		if maxPathLen < 0 || (maxPathLen == 0 && !template.MaxPathLenZero) {
+			maxPathLen = -1
+		}
+		if err := add(oidExtensionBasicConstraints, true, basicConstraints{template.IsCA, maxPathLen}); err != nil {
+			return nil, err
+		}
+	}
+	if len(subjectKeyId) > 0 {
+		if err := add(oidExtensionSubjectKeyId, false, subjectKeyId); err != nil {
+			return nil, err
+		}
+	}
+	if len(authorityKeyID) > 0 {
+		if err := add(oidExtensionAuthorityKeyId, false, authorityKeyId{Id: authorityKeyID}); err != nil {
+			return nil, err
+		}
+	}
+	if len(template.DNSNames) > 0 {
+		if err := add(oidExtensionSubjectAltName, false, dnsNames(template.DNSNames)); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// dnsNames SubjectAltName 中的 dNSName
+func dnsNames(names []string) []asn1.RawValue {
+	res := make([]asn1.RawValue, len(names))
+	for i, name := range names {
+		res[i] = asn1.RawValue{Tag: nameTypeDNS, Class: asn1.ClassContextSpecific, Bytes: []byte(name)}
+	}
+	return res
+}
+
+// keyUsageBits 和标准库一致，KeyUsage 的第 i 位对应 BIT STRING 的第 i 个 bit
+func keyUsageBits(ku x509.KeyUsage) asn1.BitString {
+	b := []byte{bits.Reverse8(byte(ku)), bits.Reverse8(byte(ku >> 8))}
+	if b[1] == 0 {
+		b = b[:1]
+	}
+	last := b[len(b)-1]
+	return asn1.BitString{Bytes: b, BitLength: 8*len(b) - bits.TrailingZeros8(last)}
+}
+
+// signatureAlgorithm 从证书或者证书请求的 DER 中取出签名算法
+// x509.ParseCertificate 不认识这里的 OID，只会返回 UnknownSignatureAlgorithm
+func signatureAlgorithm(der []byte) (pkix.AlgorithmIdentifier, []byte, error) {
+	var c certificate
+	rest, err := asn1.Unmarshal(der, &c)
+	if err != nil || len(rest) != 0 || c.SignatureValue.BitLength%8 != 0 {
+		return pkix.AlgorithmIdentifier{}, nil, ErrInvalidCertificate
+	}
+	return c.SignatureAlgorithm, c.SignatureValue.Bytes, nil
+}
+
+// checkSignature 使用 spki 中的公钥校验 der 中的签名，签名算法必须和公钥的算法相同
+func checkSignature(der, signed, spki []byte) error {
+	sigAlg, sig, err := signatureAlgorithm(der)
+	if err != nil {
+		return err
+	}
+	s, pk, err := keys.ParsePKIXPublicKey(spki)
+	if err != nil {
+		return err
+	}
+	keyAlg, err := keys.AlgorithmIdentifier(s)
+	if err != nil {
+		return err
+	}
+	if !sigAlg.Algorithm.Equal(keyAlg.Algorithm) || !bytes.Equal(sigAlg.Parameters.FullBytes, keyAlg.Parameters.FullBytes) {
+		return ErrAlgorithmMismatch
+	}
+	if !s.Verify(signed, pk.Key, sig) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// CheckSignature 校验 cert 是否由 parent 中的公钥签发
+func CheckSignature(cert, parent *x509.Certificate) error {
+	if !bytes.Equal(cert.RawIssuer, parent.RawSubject) {
+		return ErrInvalidCertificate
+	}
+	return checkSignature(cert.Raw, cert.RawTBSCertificate, parent.RawSubjectPublicKeyInfo)
+}
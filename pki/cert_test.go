@@ -0,0 +1,189 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/junhaideng/sphincs/keys"
+	"github.com/junhaideng/sphincs/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func newKey(t *testing.T, s signature.Scheme) (Key, Key) {
+	sk, pk := s.GenerateKey()
+	return Key{s, pk}, Key{s, sk}
+}
+
+func newCert(t *testing.T, template, parent *x509.Certificate, pub, priv Key) *x509.Certificate {
+	der, err := CreateCertificate(template, parent, pub, priv)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return cert
+}
+
+func caTemplate(name string, serial int64, now time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+func TestCertificateChain(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	sphincs, err := signature.NewSphincs(256, 512, 8, 2, 4, 8, 64, nil)
+	assert.Nil(err)
+	slh, err := signature.NewSLHDSA("SLH-DSA-SHA2-128f")
+	assert.Nil(err)
+
+	// 自签名的根证书
+	rootPub, rootPriv := newKey(t, sphincs)
+	rootTemplate := caTemplate("root", 1, now)
+	rootTemplate.MaxPathLen = 1
+	root := newCert(t, rootTemplate, nil, rootPub, rootPriv)
+	assert.Nil(CheckSignature(root, root))
+	assert.Equal(x509.UnknownSignatureAlgorithm, root.SignatureAlgorithm)
+	assert.True(root.IsCA)
+	assert.Equal(1, root.MaxPathLen)
+	assert.Equal(x509.KeyUsageCertSign|x509.KeyUsageCRLSign, root.KeyUsage)
+	assert.Equal(root.SubjectKeyId, root.AuthorityKeyId)
+
+	// 根证书签发的中间证书，使用 SLH-DSA
+	interPub, interPriv := newKey(t, slh)
+	inter := newCert(t, caTemplate("intermediate", 2, now), root, interPub, rootPriv)
+	assert.Nil(CheckSignature(inter, root))
+	assert.Equal(root.SubjectKeyId, inter.AuthorityKeyId)
+
+	// 中间证书签发的叶子证书
+	leafPub, leafPriv := newKey(t, sphincs)
+	leaf := newCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		DNSNames:     []string{"example.com", "www.example.com"},
+	}, inter, leafPub, interPriv)
+	assert.Equal([]string{"example.com", "www.example.com"}, leaf.DNSNames)
+	assert.False(leaf.IsCA)
+
+	s, pk, err := keys.ParsePKIXPublicKey(leaf.RawSubjectPublicKeyInfo)
+	assert.Nil(err)
+	assert.Equal(sphincs.Identifier(), s.Identifier())
+	assert.Equal(leafPub.Key, pk.Key)
+
+	opts := VerifyOptions{
+		Intermediates: []*x509.Certificate{inter},
+		Roots:         []*x509.Certificate{root},
+		CurrentTime:   now,
+	}
+	chain, err := Verify(leaf, opts)
+	assert.Nil(err)
+	assert.Equal([]*x509.Certificate{leaf, inter, root}, chain)
+
+	// 没有中间证书
+	_, err = Verify(leaf, VerifyOptions{Roots: opts.Roots, CurrentTime: now})
+	assert.Equal(ErrUnknownAuthority, err)
+
+	// 过期
+	opts.CurrentTime = now.Add(2 * time.Hour)
+	_, err = Verify(leaf, opts)
+	assert.Equal(ErrExpired, err)
+	opts.CurrentTime = now
+
+	// 名称相同，但是公钥的算法和签名算法不一致
+	fake := newCert(t, caTemplate("root", 5, now), nil, interPub, interPriv)
+	assert.Equal(ErrAlgorithmMismatch, CheckSignature(inter, fake))
+	assert.Equal(ErrInvalidCertificate, CheckSignature(leaf, root))
+
+	// 修改 TBSCertificate 之后签名不正确
+	tampered := *leaf
+	tampered.RawTBSCertificate = append([]byte{}, leaf.RawTBSCertificate...)
+	tampered.RawTBSCertificate[len(tampered.RawTBSCertificate)-1] ^= 1
+	assert.Equal(ErrSignature, CheckSignature(&tampered, inter))
+
+	// 叶子证书不能签发证书
+	other := newCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "other"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+	}, leaf, leafPub, leafPriv)
+	opts.Intermediates = append(opts.Intermediates, leaf)
+	_, err = Verify(other, opts)
+	assert.Equal(ErrNotCA, err)
+}
+
+func TestPathLen(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	s, err := signature.NewSphincs(256, 512, 8, 2, 4, 8, 64, nil)
+	assert.Nil(err)
+
+	rootPub, rootPriv := newKey(t, s)
+	rootTemplate := caTemplate("root", 1, now)
+	rootTemplate.MaxPathLenZero = true
+	root := newCert(t, rootTemplate, nil, rootPub, rootPriv)
+	assert.Equal(0, root.MaxPathLen)
+	assert.True(root.MaxPathLenZero)
+
+	interPub, interPriv := newKey(t, s)
+	inter := newCert(t, caTemplate("intermediate", 2, now), root, interPub, rootPriv)
+	leafPub, _ := newKey(t, s)
+	leaf := newCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+	}, inter, leafPub, interPriv)
+
+	// 根证书可以直接签发叶子证书，但是不能再有中间证书
+	_, err = Verify(inter, VerifyOptions{Roots: []*x509.Certificate{root}, CurrentTime: now})
+	assert.Nil(err)
+	_, err = Verify(leaf, VerifyOptions{
+		Intermediates: []*x509.Certificate{inter},
+		Roots:         []*x509.Certificate{root},
+		CurrentTime:   now,
+	})
+	assert.Equal(ErrPathLen, err)
+}
+
+func TestCertificateRequest(t *testing.T) {
+	assert := assert.New(t)
+	s, err := signature.NewSLHDSA("SLH-DSA-SHA2-128f")
+	assert.Nil(err)
+	pub, priv := newKey(t, s)
+
+	der, err := CreateCertificateRequest(&x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "leaf", Organization: []string{"sphincs"}},
+		DNSNames: []string{"example.com"},
+	}, pub, priv)
+	assert.Nil(err)
+	csr, err := x509.ParseCertificateRequest(der)
+	assert.Nil(err)
+	assert.Equal("leaf", csr.Subject.CommonName)
+	assert.Equal([]string{"example.com"}, csr.DNSNames)
+	assert.Nil(CheckCertificateRequestSignature(csr))
+
+	// CSR 中的公钥和签名的私钥不对应
+	other, _ := newKey(t, s)
+	der, err = CreateCertificateRequest(&x509.CertificateRequest{}, other, priv)
+	assert.Nil(err)
+	csr, err = x509.ParseCertificateRequest(der)
+	assert.Nil(err)
+	assert.Equal(ErrSignature, CheckCertificateRequestSignature(csr))
+
+	// 私钥长度不对
+	_, err = CreateCertificateRequest(&x509.CertificateRequest{}, pub, Key{s, priv.Key[1:]})
+	assert.Equal(signature.ErrInvalidEncoding, err)
+}
@@ -0,0 +1,82 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+
+	"github.com/junhaideng/sphincs/keys"
+)
+
+// PKCS#9 extensionRequest，用来在 CSR 中携带 SubjectAltName
+var oidExtensionRequest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 14}
+
+// tbsCertificateRequest RFC 2986 4.1
+type tbsCertificateRequest struct {
+	Version       int
+	Subject       asn1.RawValue
+	PublicKey     asn1.RawValue
+	RawAttributes []asn1.RawValue `asn1:"tag:0"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// CreateCertificateRequest 生成 PKCS#10 证书请求并使用 priv 签名，返回 DER 编码
+// 使用 template 中的 Subject 以及 DNSNames，DNSNames 放在 extensionRequest 属性中
+func CreateCertificateRequest(template *x509.CertificateRequest, pub, priv Key) ([]byte, error) {
+	spki, err := keys.MarshalPKIXPublicKey(pub.Scheme, pub.Key)
+	if err != nil {
+		return nil, err
+	}
+	sigAlg, err := keys.AlgorithmIdentifier(priv.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := asn1.Marshal(template.Subject.ToRDNSequence())
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []asn1.RawValue{}
+	if len(template.DNSNames) > 0 {
+		extensions, err := buildExtensions(&x509.Certificate{DNSNames: template.DNSNames}, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		value, err := asn1.Marshal(extensions)
+		if err != nil {
+			return nil, err
+		}
+		b, err := asn1.Marshal(attribute{oidExtensionRequest, []asn1.RawValue{{FullBytes: value}}})
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, asn1.RawValue{FullBytes: b})
+	}
+
+	tbs, err := asn1.Marshal(tbsCertificateRequest{
+		Subject:       asn1.RawValue{FullBytes: subject},
+		PublicKey:     asn1.RawValue{FullBytes: spki},
+		RawAttributes: attributes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sign(tbs, priv)
+	if err != nil {
+		return nil, err
+	}
+	// CertificationRequest 和 Certificate 的结构相同
+	return asn1.Marshal(certificate{asn1.RawValue{FullBytes: tbs}, sigAlg, sig})
+}
+
+// CheckCertificateRequestSignature 使用 csr 中的公钥校验 csr 的签名
+func CheckCertificateRequestSignature(csr *x509.CertificateRequest) error {
+	if csr.Version != 0 {
+		return errors.New("pki: unsupported certificate request version")
+	}
+	return checkSignature(csr.Raw, csr.RawTBSCertificateRequest, csr.RawSubjectPublicKeyInfo)
+}
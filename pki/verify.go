@@ -0,0 +1,131 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+var (
+	ErrExpired            = errors.New("pki: certificate has expired or is not yet valid")
+	ErrNotCA              = errors.New("pki: issuer is not a certificate authority")
+	ErrPathLen            = errors.New("pki: too many intermediates for path length constraint")
+	ErrUnknownAuthority   = errors.New("pki: certificate signed by unknown authority")
+	ErrUnhandledCritical  = errors.New("pki: unhandled critical extension")
+	ErrCertSignNotAllowed = errors.New("pki: issuer key usage does not allow certificate signing")
+)
+
+// maxChainLength 证书链的最大长度，避免相互签发的证书导致无限循环
+const maxChainLength = 16
+
+// VerifyOptions 和 x509.VerifyOptions 类似
+type VerifyOptions struct {
+	// Intermediates 可以用来构建证书链的中间证书
+	Intermediates []*x509.Certificate
+	// Roots 信任的根证书
+	Roots []*x509.Certificate
+	// CurrentTime 为空时使用 time.Now()
+	CurrentTime time.Time
+}
+
+// Verify 构建从 leaf 到 opts.Roots 中某一个根证书的证书链并校验，返回的证书链第一个为 leaf，最后一个为根证书
+// 每一个证书都需要在有效期内，签发者需要是 CA (BasicConstraints 中 cA 为 true)
+// 签发者的 KeyUsage 不为空时必须包含 KeyUsageCertSign，并且满足 MaxPathLen 的限制
+// x509.Certificate.Verify 无法校验 SPHINCS 的签名，证书链需要使用这个函数校验
+func Verify(leaf *x509.Certificate, opts VerifyOptions) ([]*x509.Certificate, error) {
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if err := checkCertificate(leaf, now); err != nil {
+		return nil, err
+	}
+	chain, err := buildChain([]*x509.Certificate{leaf}, opts, now)
+	if err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// buildChain 深度优先搜索签发者，返回第一条合法的证书链
+// 所有候选证书都失败时返回最后一个错误，优先于 ErrUnknownAuthority
+func buildChain(chain []*x509.Certificate, opts VerifyOptions, now time.Time) ([]*x509.Certificate, error) {
+	err := ErrUnknownAuthority
+
+	for _, root := range opts.Roots {
+		if e := checkIssuer(chain, root, now); e != nil {
+			if e != ErrUnknownAuthority {
+				err = e
+			}
+			continue
+		}
+		return append(chain, root), nil
+	}
+
+	if len(chain) >= maxChainLength {
+		return nil, err
+	}
+	for _, parent := range opts.Intermediates {
+		if contains(chain, parent) {
+			continue
+		}
+		if e := checkIssuer(chain, parent, now); e != nil {
+			if e != ErrUnknownAuthority {
+				err = e
+			}
+			continue
+		}
+		res, e := buildChain(append(chain, parent), opts, now)
+		if e == nil {
+			return res, nil
+		}
+		if e != ErrUnknownAuthority {
+			err = e
+		}
+	}
+	return nil, err
+}
+
+// checkIssuer 检查 parent 能否作为 chain 中最后一个证书的签发者
+// 名称不匹配时返回 ErrUnknownAuthority
+func checkIssuer(chain []*x509.Certificate, parent *x509.Certificate, now time.Time) error {
+	cert := chain[len(chain)-1]
+	if !bytes.Equal(cert.RawIssuer, parent.RawSubject) {
+		return ErrUnknownAuthority
+	}
+	if err := checkCertificate(parent, now); err != nil {
+		return err
+	}
+	if !parent.BasicConstraintsValid || !parent.IsCA {
+		return ErrNotCA
+	}
+	if parent.KeyUsage != 0 && parent.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return ErrCertSignNotAllowed
+	}
+	// chain 中除了 leaf 以外都是 parent 下面的中间证书，x509 解析时没有 pathLen 的 MaxPathLen 为 -1
+	if parent.MaxPathLen >= 0 && len(chain)-1 > parent.MaxPathLen {
+		return ErrPathLen
+	}
+	return CheckSignature(cert, parent)
+}
+
+// checkCertificate 检查有效期以及是否有无法处理的关键扩展
+func checkCertificate(cert *x509.Certificate, now time.Time) error {
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return ErrExpired
+	}
+	if len(cert.UnhandledCriticalExtensions) > 0 {
+		return ErrUnhandledCritical
+	}
+	return nil
+}
+
+func contains(chain []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range chain {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package rand
+
+import "github.com/junhaideng/sphincs/hash"
+
+// ChaCha 使用 ChaCha 流密码的密钥流作为随机数
+// 种子直接作为 256 bits 的密钥，nonce 为 0，计数器从 0 开始
+// SPHINCS-256 中的 G_λ 即为 ChaCha12，见论文 表1
+type ChaCha struct {
+	key     []byte
+	rounds  int
+	counter uint64
+	// 当前分组中还未使用的密钥流
+	block  [64]byte
+	offset int
+}
+
+// NewChaCha12 返回 SPHINCS-256 中使用的伪随机数生成器 G_λ
+// seed 的长度应该为 32 bytes
+func NewChaCha12(seed []byte) Rander {
+	r := &ChaCha{rounds: 12}
+	r.Seed(seed)
+	return r
+}
+
+// NewChaCha20 返回使用 20 轮 ChaCha 的伪随机数生成器，比 ChaCha12 有更大的安全余量
+// seed 的长度应该为 32 bytes
+func NewChaCha20(seed []byte) Rander {
+	r := &ChaCha{rounds: 20}
+	r.Seed(seed)
+	return r
+}
+
+// Seed 重新设置密钥，同时重置密钥流的位置
+func (r *ChaCha) Seed(seed []byte) {
+	if len(seed) != 32 {
+		panic("ChaCha 的种子应该为 32 bytes")
+	}
+	r.key = append(r.key[:0], seed...)
+	r.counter = 0
+	r.offset = len(r.block)
+}
+
+func (r *ChaCha) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if r.offset == len(r.block) {
+			hash.ChaChaBlock(r.block[:], r.key, 0, r.counter, r.rounds)
+			r.counter++
+			r.offset = 0
+		}
+		c := copy(p[n:], r.block[r.offset:])
+		r.offset += c
+		n += c
+	}
+	return n, nil
+}
@@ -0,0 +1,40 @@
+package rand
+
+import (
+	"testing"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaCha12(t *testing.T) {
+	assert := assert.New(t)
+	seed := make([]byte, 32)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i)
+	}
+
+	// 一次性读取的结果应该和分块读取的一致
+	expected := make([]byte, 64*3)
+	for i := 0; i < 3; i++ {
+		hash.ChaChaBlock(expected[i*64:], seed, 0, uint64(i), 12)
+	}
+	r := NewChaCha12(seed)
+	out := make([]byte, len(expected))
+	n, err := r.Read(out)
+	assert.Nil(err)
+	assert.Equal(len(out), n)
+	assert.Equal(expected, out)
+
+	r.Seed(seed)
+	for i := 0; i < len(out); i += 7 {
+		end := i + 7
+		if end > len(out) {
+			end = len(out)
+		}
+		_, _ = r.Read(out[i:end])
+	}
+	assert.Equal(expected, out)
+
+	assert.Panics(func() { NewChaCha12(seed[:16]) })
+}
@@ -0,0 +1,63 @@
+package rand
+
+import "crypto/aes"
+
+// CTRDRBG 为 NIST SP 800-90A 中的 AES-256 CTR_DRBG (无 derivation function，无预测抗性)
+// 和 NIST PQC KAT 中 rng.c 的 randombytes 一致，可以用来复现参考实现的 KAT
+// 注意每一次 Read 对应一次 generate，之后都会更新内部状态，所以分块读取的结果和一次性读取的结果不同
+type CTRDRBG struct {
+	key [32]byte
+	v   [16]byte
+}
+
+// NewCTRDRBG 返回 AES-256 CTR_DRBG，seed 即 entropy_input，应该为 48 bytes
+func NewCTRDRBG(seed []byte) Rander {
+	d := &CTRDRBG{}
+	d.Seed(seed)
+	return d
+}
+
+// Seed 即 randombytes_init，personalization string 为空
+func (d *CTRDRBG) Seed(seed []byte) {
+	if len(seed) != 48 {
+		panic("CTR_DRBG 的种子应该为 48 bytes")
+	}
+	*d = CTRDRBG{}
+	d.update(seed)
+}
+
+func (d *CTRDRBG) increment() {
+	for i := len(d.v) - 1; i >= 0; i-- {
+		d.v[i]++
+		if d.v[i] != 0 {
+			break
+		}
+	}
+}
+
+func (d *CTRDRBG) update(provided []byte) {
+	block, _ := aes.NewCipher(d.key[:])
+	var tmp [48]byte
+	for i := 0; i < 3; i++ {
+		d.increment()
+		block.Encrypt(tmp[16*i:], d.v[:])
+	}
+	for i := range provided {
+		tmp[i] ^= provided[i]
+	}
+	copy(d.key[:], tmp[:32])
+	copy(d.v[:], tmp[32:])
+}
+
+// Read 对应一次 randombytes 调用
+func (d *CTRDRBG) Read(p []byte) (int, error) {
+	block, _ := aes.NewCipher(d.key[:])
+	var buf [16]byte
+	for i := 0; i < len(p); i += 16 {
+		d.increment()
+		block.Encrypt(buf[:], d.v[:])
+		copy(p[i:], buf[:])
+	}
+	d.update(nil)
+	return len(p), nil
+}
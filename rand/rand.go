@@ -4,6 +4,8 @@ import (
 	"hash/fnv"
 	"io"
 	"math/rand"
+
+	"github.com/junhaideng/sphincs/hash"
 )
 
 // Rander 为随机数生成器接口
@@ -18,6 +20,9 @@ type Rander interface {
 
 // Rand 实现 Rander 接口
 // 意在提供一个简单的随机数生成器，只作为演示使用
+//
+// Deprecated: 种子被压缩成 64 bits 之后交给 math/rand，最多只有 64 bits 的熵，不能用于生成密钥
+// 请使用 New 或者 NewChaCha12, NewChaCha20, NewShake256, NewCTRDRBG
 type Rand struct {
 	*rand.Rand
 }
@@ -33,8 +38,24 @@ func (r *Rand) Seed(p []byte) {
 	r.Rand = rand.New(source)
 }
 
+// New 返回默认的伪随机数生成器，即 SPHINCS-256 中的 G_λ (ChaCha12)
+// seed 为 32 bytes 时直接作为密钥，否则使用 SHA-256(seed) 作为密钥，保留种子中最多 256 bits 的熵
 func New(seed []byte) Rander {
-	r := &Rand{}
-	r.Seed(seed)
-	return r
+	return &keyed{Rander: NewChaCha12(chachaKey(seed))}
+}
+
+// keyed 和 New 一样，Seed 可以接受任意长度的种子
+type keyed struct {
+	Rander
+}
+
+func (r *keyed) Seed(seed []byte) {
+	r.Rander.Seed(chachaKey(seed))
+}
+
+func chachaKey(seed []byte) []byte {
+	if len(seed) == 32 {
+		return seed
+	}
+	return hash.Sha256(seed)
 }
@@ -0,0 +1,77 @@
+package rand
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/sha3"
+)
+
+func seedBytes(n int) []byte {
+	seed := make([]byte, n)
+	for i := 0; i < n; i++ {
+		seed[i] = byte(i)
+	}
+	return seed
+}
+
+func read(r Rander, n int) []byte {
+	out := make([]byte, n)
+	_, _ = r.Read(out)
+	return out
+}
+
+func TestChaCha20(t *testing.T) {
+	assert := assert.New(t)
+	seed := seedBytes(32)
+	// nonce 为 0 时，和 RFC 8439 中的 ChaCha20 的密钥流一致
+	c, err := chacha20.NewUnauthenticatedCipher(seed, make([]byte, chacha20.NonceSize))
+	assert.Nil(err)
+	expected := make([]byte, 300)
+	c.XORKeyStream(expected, expected)
+	assert.Equal(expected, read(NewChaCha20(seed), len(expected)))
+	assert.Panics(func() { NewChaCha20(seed[:31]) })
+}
+
+func TestShake256(t *testing.T) {
+	assert := assert.New(t)
+	seed := []byte("sphincs")
+	expected := make([]byte, 300)
+	sha3.ShakeSum256(expected, seed)
+
+	r := NewShake256(seed)
+	out := read(r, 100)
+	out = append(out, read(r, 200)...)
+	assert.Equal(expected, out)
+
+	r.Seed(seed)
+	assert.Equal(expected[:10], read(r, 10))
+}
+
+func TestCTRDRBG(t *testing.T) {
+	assert := assert.New(t)
+	// 所有 NIST PQC KAT 文件中 count = 0 的 seed
+	r := NewCTRDRBG(seedBytes(48))
+	assert.Equal("061550234d158c5ec95595fe04ef7a25767f2e24cc2bc479d09d86dc9abcfde7056a8c266f9ef97ed08541dbd2e1ffa1", hex.EncodeToString(read(r, 48)))
+	assert.Panics(func() { NewCTRDRBG(seedBytes(32)) })
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	// 32 bytes 的种子直接作为 ChaCha12 的密钥
+	seed := seedBytes(32)
+	assert.Equal(read(NewChaCha12(seed), 100), read(New(seed), 100))
+
+	// 其余长度的种子先进行哈希
+	seed = []byte("hello")
+	assert.Equal(read(NewChaCha12(hash.Sha256(seed)), 100), read(New(seed), 100))
+	r := New(nil)
+	r.Seed(seed)
+	assert.Equal(read(NewChaCha12(hash.Sha256(seed)), 100), read(r, 100))
+
+	// 不同的种子得到不同的输出
+	assert.NotEqual(read(New([]byte("hello")), 32), read(New([]byte("hellp")), 32))
+}
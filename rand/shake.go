@@ -0,0 +1,25 @@
+package rand
+
+import "golang.org/x/crypto/sha3"
+
+// Shake256 使用 SHAKE256(seed) 的输出作为随机数，种子的长度任意
+type Shake256 struct {
+	h sha3.ShakeHash
+}
+
+// NewShake256 返回基于 SHAKE256 的伪随机数生成器
+func NewShake256(seed []byte) Rander {
+	r := &Shake256{}
+	r.Seed(seed)
+	return r
+}
+
+// Seed 重新吸收种子，同时重置输出的位置
+func (r *Shake256) Seed(seed []byte) {
+	r.h = sha3.NewShake256()
+	r.h.Write(seed)
+}
+
+func (r *Shake256) Read(p []byte) (n int, err error) {
+	return r.h.Read(p)
+}
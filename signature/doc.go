@@ -9,6 +9,18 @@ package signature
 //   wots+        256*67           256*67         256*67
 //   hors         2^16*256         2^16*256       32*256
 //   horst        2^16*256         256            (32+(16-6)*32+2^6)*256
-//   sphincs      (1+1+134)*256    (1+134)*256    40992 bytes + h bits
-// 注意，在 sphincs-256 中 h 为 60 ，实际编程的时候需要将 h 转成 []byte，占 8 bytes
-// 事实上，任意的 h 保存在 σ 中的时候，都是 8 bytes
+//   sphincs      (1+32+1)*256     (32+1)*256     41000 bytes
+// 注意，在 sphincs-256 中 h 为 60 ，保存在 σ 中的时候占 ceil(h/8) = 8 bytes
+// sphincs 的密钥和签名格式与参考实现一致，见 sphincs.go
+// 其他参数集合 (例如 n=128, w=16, tau=15, h=66) 见 SphincsParams，Validate 返回各个大小以及参数不合法的原因
+//
+// slh-dsa 为 FIPS 205 中标准化之后的 SPHINCS+，支持 12 个参数集，见 slhdsa.go
+// 以 SLH-DSA-SHA2-128f 为例，sk 为 4*128 bits，pk 为 2*128 bits，σ 为 17088 bytes
+//
+// lm-ots, lms 以及 hss 为 RFC 8554 中的有状态签名，见 lmots.go, lms.go 以及 hss.go
+// 私钥每次签名之后都会被更新，同一个私钥状态不能签名两次
+//
+// GenerateKey, Sign 返回的是裸的 []byte，GenerateKeyPair, SignMessage 返回带有算法和参数标识的
+// PublicKey, PrivateKey 以及 Sig，编码格式以及解析时的检查见 key.go
+//
+// SPHINCS 的密钥可以通过 Sphincs.NewPrivateKey, NewPublicKey 转换成 crypto.Signer 以及 crypto.PublicKey，见 signer.go
@@ -0,0 +1,81 @@
+package signature
+
+// FORS (Forest Of Random Subsets)，SLH-DSA 中的少次签名，见 FIPS 205 第 8 节
+// 和 HORST 只有一棵 2^tau 个叶子的树不同，FORS 使用 k 棵高度为 a 的树
+// 每一棵树只负责消息中的 a bits，公钥为 k 个根节点的哈希值
+// 签名为 k 个 (私钥块, 鉴权路径)
+// 叶子节点的全局索引为 i*2^a + j，i 为树的索引
+
+// forsSkGen 生成索引为 idx 的私钥块，追加到 dst 后面
+func (s *SLHDSA) forsSkGen(dst, skSeed, pkSeed []byte, a *adrs, idx uint32) []byte {
+	skAdrs := *a
+	skAdrs.setTypeAndClear(adrsForsPrf)
+	skAdrs.setKeyPair(a.keyPair())
+	skAdrs.setTreeIndex(idx)
+	return s.hash.prf(dst, pkSeed, skSeed, &skAdrs)
+}
+
+// forsNode 计算高度为 z，索引为 i 的节点
+func (s *SLHDSA) forsNode(skSeed []byte, i, z uint32, pkSeed []byte, a *adrs) []byte {
+	if z == 0 {
+		sk := s.forsSkGen(nil, skSeed, pkSeed, a, i)
+		a.setTreeHeight(0)
+		a.setTreeIndex(i)
+		return s.hash.f(sk[:0], pkSeed, a, sk)
+	}
+	left := s.forsNode(skSeed, 2*i, z-1, pkSeed, a)
+	right := s.forsNode(skSeed, 2*i+1, z-1, pkSeed, a)
+	a.setTreeHeight(z)
+	a.setTreeIndex(i)
+	return s.hash.h(left[:0], pkSeed, a, left, right)
+}
+
+// forsSign 对 k*a bits 的消息 md 进行签名
+func (s *SLHDSA) forsSign(md, skSeed, pkSeed []byte, a *adrs) []byte {
+	indices := base2b(md, s.a, s.k)
+	signature := make([]byte, 0, s.k*(s.a+1)*s.n)
+	for i := 0; i < s.k; i++ {
+		offset := uint32(i) << s.a
+		signature = s.forsSkGen(signature, skSeed, pkSeed, a, offset+indices[i])
+		// 鉴权路径，从叶子节点开始
+		for j := 0; j < s.a; j++ {
+			sibling := indices[i]>>j ^ 1
+			signature = append(signature, s.forsNode(skSeed, offset>>j+sibling, uint32(j), pkSeed, a)...)
+		}
+	}
+	return signature
+}
+
+// forsPkFromSig 根据签名计算出 FORS 的公钥
+func (s *SLHDSA) forsPkFromSig(signature, md, pkSeed []byte, a *adrs) []byte {
+	indices := base2b(md, s.a, s.k)
+	roots := make([]byte, 0, s.k*s.n)
+	size := (s.a + 1) * s.n
+	for i := 0; i < s.k; i++ {
+		sig := signature[i*size : (i+1)*size]
+		auth := sig[s.n:]
+
+		a.setTreeHeight(0)
+		a.setTreeIndex(uint32(i)<<s.a + indices[i])
+		// 第 i 棵树的根节点直接写入 roots
+		node := s.hash.f(roots[i*s.n:i*s.n], pkSeed, a, sig[:s.n])
+
+		for j := 0; j < s.a; j++ {
+			a.setTreeHeight(uint32(j + 1))
+			block := auth[j*s.n : (j+1)*s.n]
+			if indices[i]>>j&1 == 0 {
+				a.setTreeIndex(a.treeIndex() / 2)
+				node = s.hash.h(node[:0], pkSeed, a, node, block)
+			} else {
+				a.setTreeIndex((a.treeIndex() - 1) / 2)
+				node = s.hash.h(node[:0], pkSeed, a, block, node)
+			}
+		}
+		roots = roots[:(i+1)*s.n]
+	}
+
+	pkAdrs := *a
+	pkAdrs.setTypeAndClear(adrsForsRoots)
+	pkAdrs.setKeyPair(a.keyPair())
+	return s.hash.t(nil, pkSeed, &pkAdrs, roots)
+}
@@ -0,0 +1,43 @@
+//go:build go1.18
+// +build go1.18
+
+package signature
+
+import (
+	"testing"
+)
+
+// fuzzVerify 对 name 对应的签名算法进行模糊测试，任意输入都不能 panic
+// 并且 Verify 和 VerifyErr 的结果需要一致
+// 运行方式: go test -run '^$' -fuzz FuzzSphincsVerify ./signature
+func fuzzVerify(f *testing.F, name string) {
+	var c verifyCase
+	for _, v := range newVerifyCases(f) {
+		if v.name == name {
+			c = v
+		}
+	}
+	f.Add(c.msg, c.pk, c.sig)
+	f.Add(c.msg, c.pk, c.sig[:len(c.sig)/2])
+	f.Add(c.msg, c.pk[:len(c.pk)/2], c.sig)
+	f.Add([]byte{}, []byte{}, []byte{})
+
+	v := c.s.(Verifier)
+	f.Fuzz(func(t *testing.T, msg, pk, sig []byte) {
+		err := v.VerifyErr(msg, pk, sig)
+		if ok := c.s.Verify(msg, pk, sig); ok != (err == nil) {
+			t.Fatalf("Verify returns %t but VerifyErr returns %v", ok, err)
+		}
+	})
+}
+
+func FuzzLamportVerify(f *testing.F)    { fuzzVerify(f, "lamport") }
+func FuzzWinternitzVerify(f *testing.F) { fuzzVerify(f, "wots") }
+func FuzzWOTSPlusVerify(f *testing.F)   { fuzzVerify(f, "wots+") }
+func FuzzHorsVerify(f *testing.F)       { fuzzVerify(f, "hors") }
+func FuzzHorstVerify(f *testing.F)      { fuzzVerify(f, "horst") }
+func FuzzSphincsVerify(f *testing.F)    { fuzzVerify(f, "sphincs") }
+func FuzzSLHDSAVerify(f *testing.F)     { fuzzVerify(f, "slh-dsa") }
+func FuzzLMOTSVerify(f *testing.F)      { fuzzVerify(f, "lm-ots") }
+func FuzzLMSVerify(f *testing.F)        { fuzzVerify(f, "lms") }
+func FuzzHSSVerify(f *testing.F)        { fuzzVerify(f, "hss") }
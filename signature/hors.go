@@ -1,8 +1,8 @@
 package signature
 
 import (
-	"crypto/rand"
 	"errors"
+	"io"
 
 	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
@@ -18,12 +18,14 @@ type Hors struct {
 	// base = log2(t)
 	base int
 	k    int
-	hash hash.Hash
+	hash *hash.Hasher
+	r    io.Reader
 }
 
 // NewHorsSignature return Hors signature algorithm
 // where t is exponential, and t * k = 256 or t * k = 512
-func NewHorsSignature(t, k int) (Signature, error) {
+// the secret key is read from crypto/rand unless WithRand is given
+func NewHorsSignature(t, k int, opts ...Option) (Signature, error) {
 	if !(t > 0 && t%8 == 0 && t <= 64) {
 		return nil, errors.New("t should a positive integer which is divisible by 8 and not greater than 64")
 	}
@@ -38,37 +40,34 @@ func NewHorsSignature(t, k int) (Signature, error) {
 		t:    1 << t,
 		base: t,
 		k:    k,
-		hash: hash.Sha256,
-	}
-	if n == Size512 {
-		h.hash = hash.Sha512
+		hash: sha2(n),
+		r:    NewOptions(opts...).Rand,
 	}
 	return h, nil
 }
 
 func (h *Hors) GenerateKey() ([]byte, []byte) {
 	size := int(h.n) / 8 // n bits => n/8 byte
-	sk := make([]byte, 0, h.t*size)
-	pk := make([]byte, 0, h.t*size)
-
-	r := make([]byte, size)
+	sk := make([]byte, h.PrivateKeySize())
+	pk := make([]byte, 0, h.PublicKeySize())
+	if _, err := io.ReadFull(h.r, sk); err != nil {
+		panic(err)
+	}
 
+	hasher := h.hash.Clone()
 	for i := 0; i < h.t; i++ {
-		rand.Read(r)
-		sk = append(sk, r...)
-		pk = append(pk, h.hash(r)...)
+		pk = hasher.Sum(pk, sk[i*size:(i+1)*size])
 	}
 
 	return sk, pk
 }
 
 func (h *Hors) Sign(message []byte, sk []byte) []byte {
-	digest := h.hash(message)
+	digest := h.hash.Clone().Sum(nil, message)
 	// split digest to k substring, each log2(t) bits
 	index := h.split(digest)
 
-	// signature length is k * n bits
-	signature := make([]byte, 0, h.k*int(h.n)/8)
+	signature := make([]byte, 0, h.SignatureSize())
 
 	size := uint64(h.n) / 8
 	// signature has k secret keys
@@ -80,18 +79,37 @@ func (h *Hors) Sign(message []byte, sk []byte) []byte {
 }
 
 func (h *Hors) Verify(message []byte, pk []byte, signature []byte) bool {
-	digest := h.hash(message)
-	index := h.split(digest)
+	return h.VerifyErr(message, pk, signature) == nil
+}
 
+// VerifyErr pk is t blocks and signature is k blocks, each block n bits
+func (h *Hors) VerifyErr(message []byte, pk []byte, signature []byte) error {
 	size := uint64(h.n) / 8
+	if uint64(len(pk)) != uint64(h.t)*size {
+		return ErrInvalidPublicKey
+	}
+	if uint64(len(signature)) != uint64(h.k)*size {
+		return ErrInvalidSignature
+	}
+
+	hasher := h.hash.Clone()
+	digest := hasher.Sum(nil, message)
+	index := h.split(digest)
+	buf := make([]byte, 0, size)
+
 	var i uint64
 	for i = 0; i < uint64(h.k); i++ {
 		j := index[i]
-		if !common.Equal(h.hash(signature[i*size:(i+1)*size]), pk[j*size:(j+1)*size]) {
-			return false
+		// when t = 2^64, h.t overflows and j may exceed pk
+		if j >= uint64(len(pk))/size {
+			return ErrVerifyFailed
+		}
+		buf = hasher.Sum(buf[:0], signature[i*size:(i+1)*size])
+		if !common.Equal(buf, pk[j*size:(j+1)*size]) {
+			return ErrVerifyFailed
 		}
 	}
-	return true
+	return nil
 }
 
 // log2(t) should be divided by 8
@@ -106,3 +124,23 @@ func (h *Hors) split(digest []byte) []uint64 {
 	}
 	return res
 }
+
+// Identifier 参数为 tau = log2(t), k
+func (h *Hors) Identifier() Identifier {
+	return Identifier{Alg: AlgHors, Params: []uint32{uint32(h.base), uint32(h.k)}}
+}
+
+// PublicKeySize t 个私钥块的哈希值
+func (h *Hors) PublicKeySize() int {
+	return h.t * int(h.n) / 8
+}
+
+// PrivateKeySize t 个 n bits 的私钥块
+func (h *Hors) PrivateKeySize() int {
+	return h.t * int(h.n) / 8
+}
+
+// SignatureSize k 个私钥块
+func (h *Hors) SignatureSize() int {
+	return h.k * int(h.n) / 8
+}
@@ -2,12 +2,13 @@ package signature
 
 import (
 	"errors"
+	"io"
 	"math"
 
 	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
 	"github.com/junhaideng/sphincs/merkle"
-	"github.com/junhaideng/sphincs/rand"
+	"github.com/junhaideng/sphincs/suite"
 )
 
 func calc(k, t int) int {
@@ -23,6 +24,9 @@ func calc(k, t int) int {
 	return res
 }
 
+// maxTau tau 的最大值，此时私钥一共有 2^32 个块
+const maxTau = 32
+
 // Horst .
 // 注意：形成的树的高度实际上为 1 + h.tau !!!!
 type Horst struct {
@@ -31,29 +35,47 @@ type Horst struct {
 	// t should be a power of 2
 	t int
 	// tau = log2(t)
-	tau  int
-	x    int
-	k    int
-	hash hash.Hash
+	tau int
+	x   int
+	k   int
+	// 树中非叶子节点使用的哈希函数
+	hash *hash.Hasher
+	// 叶子节点使用的哈希函数，即对私钥块进行哈希
+	f    *hash.Hasher
 	seed []byte
 	mask []byte
-	r    rand.Rander
-	tree *merkle.Tree
+	r    io.Reader
+	// WithHashSuite 设置的哈希函数族，为空时使用 SHA-2
+	suite suite.HashSuite
 }
 
 // 为了方便 SHPINCS 调用
-func newHorst(tau, k int, n int, mask []byte) (*Horst, error) {
+// f 和 h 分别为叶子节点和其余节点使用的哈希函数，为空时根据 n 选择 SHA-2
+// tau 不需要是 8 的倍数，摘要值按 bit 拆分，见 split
+func newHorst(tau, k int, n int, mask []byte, f, h *hash.Hasher) (*Horst, error) {
 
-	if !(tau > 0 && tau%8 == 0 && tau <= 64) {
-		return nil, errors.New("t should a positive integer which is divisible by 8 and not greater than 64")
+	if !(tau > 0 && tau <= maxTau) {
+		return nil, errors.New("tau should be a positive integer not greater than 32")
+	}
+	if k <= 0 {
+		return nil, errors.New("k should be a positive integer")
 	}
 
-	// tau +1 的高度，叶子节点可以容纳 2 ^ tau 个私钥块
-	tree, err := merkle.NewTreeWithMask(tau+1, n, mask)
-	if err != nil {
-		return nil, err
+	if h == nil {
+		if n != 256 && n != 512 {
+			return nil, common.ErrSizeNotSupport
+		}
+		h = sha2(Size(n))
+	}
+	if f == nil {
+		f = h
 	}
-	h := &Horst{
+
+	if n <= 0 || n%8 != 0 {
+		return nil, common.ErrSizeNotSupport
+	}
+	// 树不会保存下来，GenerateKey 和 Sign 中通过 merkle.TreeHash 计算
+	return &Horst{
 		n: Size(n),
 		// 这才是真正的 t
 		t: 1 << tau,
@@ -61,28 +83,34 @@ func newHorst(tau, k int, n int, mask []byte) (*Horst, error) {
 		tau:  tau,
 		k:    k,
 		x:    calc(k, tau),
-		hash: hash.Sha256,
-		mask: mask, // TODO 其实可以不进行保存
-		tree: tree,
-	}
-
-	if n == 512 {
-		h.hash = hash.Sha512
-	}
-	return h, nil
+		hash: h,
+		f:    f,
+		mask: mask,
+	}, nil
 }
 
 // NewHorstSignature return Horst signature algorithm
-// where tau is exponential, and tau * k = 256 or tau * k = 512
+// where tau is exponential, 1 <= tau <= 32
 // tau 就是树的高度
 // tau * k 是消息摘要的长度，并不是中间哈希值进行哈希得到的摘要长度
 // 在 SPHINCS-256 中 tau*k = 512 = m, n = 256
-func NewHorstSignature(tau, k int, seed, mask []byte) (Signature, error) {
+// 私钥由 seed 初始化的 ChaCha12 生成，也可以通过 WithRand 设置熵源，此时 seed 只用来确定 n
+// 通过 WithHashSuite 设置哈希函数族时，叶子节点使用 F，其余节点使用 H，n 可以是哈希函数族支持的任意大小
+func NewHorstSignature(tau, k int, seed, mask []byte, opts ...Option) (Signature, error) {
 
 	n := Size(len(seed) * 8)
 
-	if n != Size256 && n != Size512 {
-		return nil, errors.New("seed size should be 256 or 512")
+	st := applyOptions(opts).Suite
+	var f, hh *hash.Hasher
+	if st == nil {
+		if n != Size256 && n != Size512 {
+			return nil, errors.New("seed size should be 256 or 512")
+		}
+	} else {
+		if !st.Supports(int(n)) {
+			return nil, common.ErrSizeNotSupport
+		}
+		f, hh = st.F(int(n)), st.H(int(n))
 	}
 
 	// mask 一共 2n * logt bits
@@ -91,93 +119,134 @@ func NewHorstSignature(tau, k int, seed, mask []byte) (Signature, error) {
 		return nil, common.ErrSizeNotMatch
 	}
 
-	h, err := newHorst(tau, k, int(n), mask)
+	h, err := newHorst(tau, k, int(n), mask, f, hh)
 	if err != nil {
 		return nil, err
 	}
 
 	h.seed = seed
-	h.r = rand.New(seed)
+	h.r = seededRand(seed, opts)
+	h.suite = st
 
 	return h, nil
 }
 
 func (h *Horst) GenerateKey() ([]byte, []byte) {
-	size := int(h.n) / 8 // n bits => n/8 byte
-	sk := make([]byte, 0, h.t*size)
-	//pk := make([]byte, 0, h.t*size)
+	sk := h.secretKey()
+	return sk, h.treeHash().Root(h.leaves(sk))
+}
 
-	r := make([]byte, size)
+// secretKey 从 h.r 中读取 t 个 n bits 的私钥块
+func (h *Horst) secretKey() []byte {
+	sk := make([]byte, h.PrivateKeySize())
+	if _, err := io.ReadFull(h.r, sk); err != nil {
+		panic(err)
+	}
+	return sk
+}
 
-	for i := 0; i < h.t; i++ {
-		h.r.Read(r)
-		sk = append(sk, r...)
-		//pk = append(pk, h.hash(r)...)
+// leaves 叶子节点为私钥块的哈希值 F(sk_i)，每次返回同一个缓冲区
+func (h *Horst) leaves(sk []byte) merkle.LeafFunc {
+	size := int(h.n) / 8
+	f := h.f.Clone()
+	buf := make([]byte, 0, size)
+	return func(i int) []byte {
+		buf = f.Sum(buf[:0], sk[i*size:(i+1)*size])
+		return buf
 	}
-	err := h.tree.SetSkWithMask(sk)
+}
+
+// treeHash tau+1 的高度，叶子节点可以容纳 2^tau 个私钥块
+// 只保存栈中的 O(tau) 个节点，不需要像 merkle.Tree 一样保存全部 2^(tau+1) - 1 个节点
+func (h *Horst) treeHash() *merkle.TreeHash {
+	th, err := merkle.NewTreeHash(h.tau+1, int(h.n), h.hash, h.mask)
 	if err != nil {
 		panic(err)
 	}
-	PK, _ := h.tree.GetPk()
-	return sk, PK
+	return th
 }
 
 // Sign 对消息进行签名
 // 这里的 message 其实是已经经历过哈希处理的
+// 签名的格式和 SPHINCS-256 参考实现一致:
+// x 层的所有节点，然后是 k 个 (私钥块, 到 x 层的鉴权路径)
 func (h *Horst) Sign(message []byte, sk []byte) []byte {
+	signature, _ := h.sign(message, sk, h.leaves(sk))
+	return signature
+}
+
+// sign 遍历一次叶子节点，同时得到签名和公钥 (根节点)
+// leaf 返回第 i 个叶子节点，SPHINCS 中叶子节点可以提前并行计算
+func (h *Horst) sign(message []byte, sk []byte, leaf merkle.LeafFunc) ([]byte, []byte) {
 	// split message to k substring, each log2(t) bits
 	index := h.split(message)
+	indices := make([]int, h.k)
+	for i := range indices {
+		indices[i] = int(index[i])
+	}
+	// 和算法描述不同的是，这里的根节点为 0 层
+	root, layer, paths := h.treeHash().Compute(leaf, h.x, indices)
 
 	// k 个密钥块，k 个对应的 auth path，τ-x 层的所有节点
 	// 每一个单独的 block 都是 n bits
-	// 构建的 merkle tree 高度实际为 tau + 1
-	signature := make([]byte, 0, (h.k+(h.tau-h.x)*h.k+1<<h.x)*int(h.n)/8)
+	signature := make([]byte, 0, h.SignatureSize())
+
+	// 包含 x 层的所有节点，一共有 2^x 个
+	signature = append(signature, common.Flatten(layer)...)
 
 	size := uint64(h.n) / 8
 	// signature has k secret keys
 	for i := 0; i < h.k; i++ {
 		j := index[i]
-		sk_ := sk[j*size : (j+1)*size]
 		// σi= (skMi,AuthMi)
-		signature = append(signature, sk_...)
-		// 到达 x 层，和算法描述不同的是，这里的根节点为 0 层
-		auth := h.tree.AuthenticationPath(h.x, int(j))
-		signature = append(signature, common.Flatten(auth)...)
-
-		// 这个结果应该是 x 层的
-		//x := merkle.ComputeRootWithMask(sk_, int(j), auth, h.hash, common.Ravel(h.mask[len(h.mask)-(h.tau-h.x)*int(h.n)/8*2:], int(h.n)/8))
-		//fmt.Printf("layer x node: %x\n", x)
+		signature = append(signature, sk[j*size:(j+1)*size]...)
+		signature = append(signature, common.Flatten(paths[i])...)
 	}
 
-	// 包含 x 层的所有节点，一共有 2^x 个
-	signature = append(signature, common.Flatten(h.tree.Layer(h.x))...)
-	//fmt.Printf("layer x: %x\n", common.Flatten(h.tree.Layer(h.x)))
-	return signature
+	return signature, root
 }
 
 // Verify .
 func (h *Horst) Verify(message []byte, pk []byte, signature []byte) bool {
+	return h.VerifyErr(message, pk, signature) == nil
+}
+
+// VerifyErr pk 为 n bits 的根节点
+// message 为 tau*k bits 的摘要值，长度不够时返回 ErrVerifyFailed
+func (h *Horst) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	if len(pk) != h.PublicKeySize() {
+		return ErrInvalidPublicKey
+	}
+	if len(signature) != h.SignatureSize() {
+		return ErrInvalidSignature
+	}
 	ltree, flag := h.verify(message, signature)
-	if !flag {
-		return false
+	if !flag || !common.Equal(pk, ltree) {
+		return ErrVerifyFailed
 	}
+	return nil
+}
 
-	return common.Equal(pk, ltree)
+// SignatureSize x 层的 2^x 个节点，k 个私钥块以及到 x 层的鉴权路径
+func (h *Horst) SignatureSize() int {
+	return (h.k + (h.tau-h.x)*h.k + 1<<h.x) * int(h.n) / 8
 }
 
-// log2(t) should be divided by 8
-// ensured by constructor
+// split 将摘要值分成 k 块，每一块 log2(t) bits
+// 摘要值看作低位在前的比特串 (见 common.ReadBits)，tau 是 8 的倍数时等价于每一块按照小端序转换成整数
 func (h *Horst) split(digest []byte) []uint64 {
-	// digest has n bits, split into k substrings, each log2(t) bits
-	res := make([]uint64, h.k*h.tau/8)
-
-	by := h.tau / 8 // each substring length
+	res := make([]uint64, h.k)
 	for i := 0; i < h.k; i++ {
-		res[i] = common.ToInt(digest[by*i : (i+1)*by])
+		res[i] = common.ReadBits(digest, uint64(i*h.tau), uint64(h.tau))
 	}
 	return res
 }
 
+// digestSize 摘要值至少需要的字节数，即 ceil(tau*k/8)
+func (h *Horst) digestSize() int {
+	return (h.tau*h.k + BitSize - 1) / BitSize
+}
+
 // 索引为 index，上面相距 d 层的父结点索引
 func getIndex(index, d int) int {
 	for i := 0; i < d; i++ {
@@ -188,47 +257,72 @@ func getIndex(index, d int) int {
 
 // verify 实现 SPHINCS 中的函数签名，校验之后返回 pk
 // mask 通过构造函数传入
+// 签名的长度不对或者 message 不足 tau*k bits 时返回 false
 func (h *Horst) verify(message []byte, signature []byte) ([]byte, bool) {
+	if len(signature) != h.SignatureSize() || len(message) < h.digestSize() {
+		return nil, false
+	}
 	index := h.split(message)
 	n := int(h.n)
-	size := (1 + h.tau - h.x) * n / 8
 	// x 层的节点值
-	// DONE：nodes 和 layer x 节点已经对应
-	nodes := signature[size*h.k:]
+	nodes := signature[:(1<<h.x)*n/8]
+	size := (1 + h.tau - h.x) * n / 8
+	parts := signature[len(nodes):]
 	start := 1<<h.tau - 1
-	// signature 一共有 k + 1 份，最后一份是 x 层的所有节点
+	// 叶子节点到 x 层使用的掩码，从下往上
+	mask := common.Ravel(h.mask[:(h.tau-h.x)*n/8*2], n/8)
+	f, hh := h.f.Clone(), h.hash.Clone().Hash()
 	for i := 0; i < h.k; i++ {
 		j := int(index[i])
-		part := signature[i*size : (i+1)*size]
+		part := parts[i*size : (i+1)*size]
 
 		// 签名部分[0] 即私钥部分，每一个私钥 n bits 即 n / 8 byte
-		sk := part[:int(h.n)/8]
+		sk := part[:n/8]
 
-		// 私钥对应的鉴权路径部分, h.tau-h.x-1 个节点值
-		// 即，每一个路径都是 (h.tau-h.x-1) * n bits
-		auth := common.Ravel(part[int(h.n)/8:], n/8)
+		// 私钥对应的鉴权路径部分, h.tau-h.x 个节点值
+		auth := common.Ravel(part[n/8:], n/8)
 
 		// data 对应的 x 层的节点值
-		// path 的长度为 h.tau-h.x ，mask 只需要取对应的即可
-		// 注意计算的顺序，需要先用后一部分的mask和path的前面的计算
-		// 因为path中的数据，先取下面层级的，然后取上面的
-		mask := h.mask[len(h.mask)-(h.tau-h.x)*int(h.n)/8*2:]
-		data := merkle.ComputeRootWithMask(sk, start+j, auth, h.hash, common.Ravel(mask, int(h.n)/8))
+		data := merkle.ComputeRootFromLeafWithMask(f.Sum(nil, sk), start+j, auth, hh, mask)
 
 		// 私钥在叶子节点的位置为 1 << h.tau - 1 + j (总索引)
-		// 叶子节点和 h.x 层相差了 h.tau-h.x+1 距离
-		nodeIndex := getIndex(1<<h.tau-1+j, h.tau-h.x) - 1<<h.x + 1
+		// 叶子节点和 h.x 层相差了 h.tau-h.x 层
+		nodeIndex := getIndex(start+j, h.tau-h.x) - 1<<h.x + 1
 
 		// 每一个 node 都是 n/8 bytes
 		node := nodes[nodeIndex*n/8 : (nodeIndex+1)*n/8]
-		// 计算出来的 data 应该
 		if !common.Equal(data, node) {
 			return nil, false
 		}
 	}
 
-	// 计算根节点
-	ltree := merkle.LTreeWithMask(nodes, int(h.n), h.hash, h.mask)
+	// 从 x 层计算根节点，使用剩下的掩码
+	ltree := merkle.LTreeWithMask(nodes, n, hh, h.mask[(h.tau-h.x)*n/8*2:])
 
 	return ltree, true
 }
+
+// Mask 返回构造时传入的掩码，校验签名时需要使用同样的掩码
+func (h *Horst) Mask() []byte {
+	return h.mask
+}
+
+// HashSuite 返回 WithHashSuite 设置的哈希函数族，没有设置时为空
+func (h *Horst) HashSuite() suite.HashSuite {
+	return h.suite
+}
+
+// Identifier 参数为 tau, k, n，设置了哈希函数族时最后为它的编号，掩码不包含在标识中
+func (h *Horst) Identifier() Identifier {
+	return Identifier{Alg: AlgHorst, Params: withSuite([]uint32{uint32(h.tau), uint32(h.k), uint32(h.n)}, h.suite)}
+}
+
+// PublicKeySize n bits 的根节点
+func (h *Horst) PublicKeySize() int {
+	return int(h.n) / 8
+}
+
+// PrivateKeySize t 个 n bits 的私钥块
+func (h *Horst) PrivateKeySize() int {
+	return h.t * int(h.n) / 8
+}
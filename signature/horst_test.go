@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/suite"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,6 +47,28 @@ func TestHorstSignature(t *testing.T) {
 
 }
 
+func TestHorstHashSuite(t *testing.T) {
+	assert := assert.New(t)
+	// n = 128, tau = 8, k = 16，消息为 tau*k = 128 bits
+	seed := make([]byte, 128/8)
+	mask := make([]byte, 2*128*8/8)
+	msg := hash.Sha256([]byte("horst signature"))
+	for _, name := range suite.Names() {
+		st, _ := suite.ByName(name)
+		horst, err := NewHorstSignature(8, 16, seed, mask, WithHashSuite(st))
+		if !assert.Nil(err, name) {
+			continue
+		}
+		sk, pk := horst.GenerateKey()
+		sign := horst.Sign(msg, sk)
+		assert.True(horst.Verify(msg, pk, sign), name)
+		assert.False(horst.Verify(hash.Sha256(msg), pk, sign), name)
+	}
+
+	_, err := NewHorstSignature(8, 16, seed, mask)
+	assert.NotNil(err)
+}
+
 func TestGetIndex(t *testing.T) {
 	assert := assert.New(t)
 
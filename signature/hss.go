@@ -0,0 +1,296 @@
+package signature
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/merkle"
+)
+
+// HSS，RFC 8554 第 6 节中的多层 LMS
+// 第 i 层的 LMS 对第 i+1 层的公钥进行签名，最底层的 LMS 对消息进行签名
+//
+// pk = u32str(L) || pub[0]
+// sk = u32str(L) || prv[0] || ... || prv[L-1]，每一层的私钥格式见 lms.go
+// σ  = u32str(L-1) || sig[0] || pub[1] || ... || sig[L-2] || pub[L-1] || sig[L-1]
+//
+// 私钥的格式 RFC 中没有定义，最底层的 q 为下一次签名使用的叶子节点，上面各层的 q 为当前正在使用的叶子节点
+// 最底层用完之后，上一层的 q 加一，并重新生成下面的各层
+// 上层对下层公钥的签名不保存在私钥中，每次签名时重新计算，所以其中的 C 由 SEED 确定性地生成
+//
+// HSS 在内存中缓存最近一次签名时每一层的 BDS 状态 (见 merkle.BDS) 以及上层对下层公钥的签名
+// 下一次签名时鉴权路径只需要向前推进一个叶子节点，不需要重新计算整棵树
+// 缓存和私钥不一致时 (例如使用了另一个私钥，或者程序重启) 遍历一次叶子节点重新建立，只使用 O(h) 的内存
+
+// HSSLevel HSS 中一层使用的参数
+type HSSLevel struct {
+	LMS   LMSType
+	LMOTS LMOTSType
+}
+
+// hssMaxLevels RFC 8554 中 L 的最大值
+const hssMaxLevels = 8
+
+type HSS struct {
+	levels []HSSLevel
+	r      io.Reader
+	// mu 保护 cache，cache[i] 为第 i 层的缓存
+	mu    sync.Mutex
+	cache []hssCache
+}
+
+// hssCache 一层 LMS 私钥的 BDS 状态，bds.Index() 为当前使用的叶子节点
+type hssCache struct {
+	key *lmsKey
+	bds *merkle.BDS
+	// sig 为这一层对下一层公钥 pub 的签名，只在上面的各层中使用
+	pub []byte
+	sig []byte
+}
+
+// NewHSS 返回 HSS 签名算法，levels 从上往下依次为每一层的参数，层数为 1 到 8
+// 私钥是有状态的，每次签名之后 sk 会被更新，调用者需要保存更新之后的 sk
+func NewHSS(levels []HSSLevel, opts ...Option) (*HSS, error) {
+	if len(levels) < 1 || len(levels) > hssMaxLevels {
+		return nil, common.ErrSizeNotSupport
+	}
+	for _, l := range levels {
+		if _, err := l.LMS.height(); err != nil {
+			return nil, err
+		}
+		if _, err := l.LMOTS.params(); err != nil {
+			return nil, err
+		}
+	}
+	return &HSS{levels: levels, r: NewOptions(opts...).Rand, cache: make([]hssCache, len(levels))}, nil
+}
+
+// PrivateKeySize u32str(L) || 每一层的 LMS 私钥
+func (h *HSS) PrivateKeySize() int {
+	return 4 + len(h.levels)*lmsPrivateKeySize
+}
+
+func (h *HSS) GenerateKey() ([]byte, []byte) {
+	sk := make([]byte, 0, h.PrivateKeySize())
+	sk = append(sk, u32str(uint32(len(h.levels)))...)
+	var top *lmsKey
+	for i, l := range h.levels {
+		k, err := newLMSKey(l.LMS, l.LMOTS, h.r)
+		if err != nil {
+			panic(err)
+		}
+		if i == 0 {
+			top = k
+		}
+		sk = append(sk, k.bytes()...)
+	}
+	// 计算公钥时需要遍历一次最顶层的叶子节点，同时建立它的 BDS 状态
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := h.bds(0, top)
+	return sk, append(u32str(uint32(len(h.levels))), top.publicKey(b.Root())...)
+}
+
+// hssBDSK BDS 中保存的最上面的层数，需要 h-k 为偶数，这里取最小值，只保存 O(h) 个节点
+func hssBDSK(h int) int {
+	return h % 2
+}
+
+// bds 返回第 i 层的 key 在 key.q 处的 BDS 状态，调用者需要持有 mu
+// 缓存的是同一个密钥的上一个叶子节点时向前推进一步，否则遍历一次叶子节点重新建立
+func (h *HSS) bds(i int, key *lmsKey) *merkle.BDS {
+	c := &h.cache[i]
+	q := int(key.q)
+	if c.key != nil && c.key.equal(key) {
+		if c.bds.Index() == q {
+			return c.bds
+		}
+		if c.bds.Index() == q-1 && c.bds.Next() == nil {
+			c.sig = nil
+			return c.bds
+		}
+	}
+	// key 中的 I 和 SEED 指向 sk，之后可能被调用者修改，所以复制一份
+	k := *key
+	k.id = append([]byte(nil), key.id...)
+	k.seed = append([]byte(nil), key.seed...)
+	b, err := k.treeHash().BDSAt(k.leaf, hssBDSK(k.h), q)
+	if err != nil {
+		// q 已经检查过，不会越界
+		panic(err)
+	}
+	*c = hssCache{key: &k, bds: b}
+	return b
+}
+
+// parseKeys 解析私钥中每一层的 LMS 私钥
+func (h *HSS) parseKeys(sk []byte) ([]*lmsKey, error) {
+	if len(sk) != h.PrivateKeySize() || binary.BigEndian.Uint32(sk) != uint32(len(h.levels)) {
+		return nil, common.ErrSizeNotMatch
+	}
+	keys := make([]*lmsKey, len(h.levels))
+	for i := range keys {
+		k, err := parseLMSKey(sk[4+i*lmsPrivateKeySize : 4+(i+1)*lmsPrivateKeySize])
+		if err != nil {
+			return nil, err
+		}
+		if k.t != h.levels[i].LMS || k.ots != h.levels[i].LMOTS {
+			return nil, ErrTypeNotSupport
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+// advance 最底层用完之后，找到最近的还有剩余叶子节点的一层，将其 q 加一，并重新生成下面的各层
+func (h *HSS) advance(keys []*lmsKey) error {
+	i := len(keys) - 2
+	for ; i >= 0; i-- {
+		if keys[i].q+1 < 1<<keys[i].h {
+			break
+		}
+	}
+	if i < 0 {
+		return ErrKeyExhausted
+	}
+	keys[i].q++
+	for j := i + 1; j < len(keys); j++ {
+		k, err := newLMSKey(h.levels[j].LMS, h.levels[j].LMOTS, h.r)
+		if err != nil {
+			return err
+		}
+		keys[j] = k
+	}
+	return nil
+}
+
+// Sign 对消息进行签名，签名之后 sk 会被更新
+// 如果所有的一次性密钥都已经使用过，panic ErrKeyExhausted
+func (h *HSS) Sign(message []byte, sk []byte) []byte {
+	keys, err := h.parseKeys(sk)
+	if err != nil {
+		panic(err)
+	}
+	bottom := keys[len(keys)-1]
+	if bottom.q >= 1<<bottom.h {
+		if err := h.advance(keys); err != nil {
+			panic(err)
+		}
+		bottom = keys[len(keys)-1]
+	}
+	q := bottom.q
+	c := make([]byte, lmsM)
+	readRand(h.r, c)
+
+	// 先更新状态，再进行签名，最底层的 q 在私钥的最后
+	for i, k := range keys {
+		copy(sk[4+i*lmsPrivateKeySize:], k.bytes())
+	}
+	binary.BigEndian.PutUint32(sk[len(sk)-4:], q+1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sig := u32str(uint32(len(keys) - 1))
+	for i := 0; i < len(keys)-1; i++ {
+		h.bds(i, keys[i])
+		pub := keys[i+1].publicKey(h.bds(i+1, keys[i+1]).Root())
+		// 这一层的叶子节点以及下一层的公钥都没有变化时，签名也不会变化
+		cache := &h.cache[i]
+		if cache.sig == nil || !common.Equal(cache.pub, pub) {
+			path := cache.bds.AuthenticationPath()
+			cache.pub, cache.sig = pub, keys[i].sign(pub, keys[i].q, keys[i].randomizer(keys[i].q), path)
+		}
+		sig = append(sig, cache.sig...)
+		sig = append(sig, pub...)
+	}
+	return append(sig, bottom.sign(message, q, c, h.bds(len(keys)-1, bottom).AuthenticationPath())...)
+}
+
+// lmsSignatureLen 根据签名中的类型计算 LMS 签名的长度
+func lmsSignatureLen(sig []byte) (int, bool) {
+	if len(sig) < 8 {
+		return 0, false
+	}
+	p, err := LMOTSType(binary.BigEndian.Uint32(sig[4:])).params()
+	if err != nil || len(sig) < 8+p.signatureSize() {
+		return 0, false
+	}
+	t := LMSType(binary.BigEndian.Uint32(sig[4+p.signatureSize():]))
+	if _, err := t.height(); err != nil {
+		return 0, false
+	}
+	return lmsSignatureSize(t, LMOTSType(binary.BigEndian.Uint32(sig[4:]))), true
+}
+
+// Verify RFC 8554 算法 8
+func (h *HSS) Verify(message []byte, pk []byte, signature []byte) bool {
+	return h.VerifyErr(message, pk, signature) == nil
+}
+
+// VerifyErr 签名的层数或者某一层的长度不对时返回 ErrInvalidSignature
+func (h *HSS) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	if len(pk) != 4+lmsPublicKeySize {
+		return ErrInvalidPublicKey
+	}
+	levels := binary.BigEndian.Uint32(pk)
+	if levels != uint32(len(h.levels)) {
+		return ErrInvalidPublicKey
+	}
+	if len(signature) < 4 || binary.BigEndian.Uint32(signature) != levels-1 {
+		return ErrInvalidSignature
+	}
+	key := pk[4:]
+	signature = signature[4:]
+	for i := uint32(0); i < levels; i++ {
+		size, ok := lmsSignatureLen(signature)
+		if !ok || len(signature) < size {
+			return ErrInvalidSignature
+		}
+		sig := signature[:size]
+		signature = signature[size:]
+		if i == levels-1 {
+			if len(signature) != 0 {
+				return ErrInvalidSignature
+			}
+			if !lmsVerify(message, key, sig) {
+				return ErrVerifyFailed
+			}
+			return nil
+		}
+		if len(signature) < lmsPublicKeySize {
+			return ErrInvalidSignature
+		}
+		pub := signature[:lmsPublicKeySize]
+		signature = signature[lmsPublicKeySize:]
+		if !lmsVerify(pub, key, sig) {
+			return ErrVerifyFailed
+		}
+		key = pub
+	}
+	return ErrInvalidSignature
+}
+
+// Identifier 参数为层数 L，然后是每一层的 LMS 类型以及 LM-OTS 类型
+func (h *HSS) Identifier() Identifier {
+	params := []uint32{uint32(len(h.levels))}
+	for _, l := range h.levels {
+		params = append(params, uint32(l.LMS), uint32(l.LMOTS))
+	}
+	return Identifier{Alg: AlgHSS, Params: params}
+}
+
+// PublicKeySize u32str(L) || 最顶层的 LMS 公钥
+func (h *HSS) PublicKeySize() int {
+	return 4 + lmsPublicKeySize
+}
+
+// SignatureSize u32str(L-1) || 每一层的 LMS 签名 || 下面 L-1 层的 LMS 公钥
+func (h *HSS) SignatureSize() int {
+	sig := 4 + (len(h.levels)-1)*lmsPublicKeySize
+	for _, l := range h.levels {
+		sig += lmsSignatureSize(l.LMS, l.LMOTS)
+	}
+	return sig
+}
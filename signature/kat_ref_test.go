@@ -0,0 +1,127 @@
+//go:build kat
+// +build kat
+
+package signature
+
+// 和参考实现的输出以及 RFC 中的测试向量进行比较，需要 testdata 中的文件
+// go test -tags kat ./signature
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/junhaideng/sphincs/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+type katEntry map[string]string
+
+// readRsp 解析 PQCgenKAT_sign 生成的 .rsp 文件
+func readRsp(path string) ([]katEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var res []katEntry
+	var cur katEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		kv := strings.SplitN(line, " = ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if kv[0] == "count" {
+			cur = katEntry{}
+			res = append(res, cur)
+		}
+		if cur != nil {
+			cur[kv[0]] = kv[1]
+		}
+	}
+	return res, scanner.Err()
+}
+
+func katBytes(t *testing.T, e katEntry, key string) []byte {
+	b, err := hex.DecodeString(e[key])
+	if err != nil {
+		t.Fatalf("count %s: invalid %s: %v", e["count"], key, err)
+	}
+	return b
+}
+
+// TestSphincs256KAT 使用参考实现 (SUPERCOP crypto_sign/sphincs256/ref) 生成的 KAT 进行校验
+// 密钥生成使用的随机数来自于 seed 初始化的 DRBG，签名为 sm 的前 41000 bytes
+// testdata/sphincs256 中没有 PQCgenKAT_sign 生成的 .rsp 文件时测试失败
+func TestSphincs256KAT(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "sphincs256", "*.rsp"))
+	if len(files) == 0 {
+		t.Fatal("no SPHINCS-256 KAT file in testdata/sphincs256, generate one with PQCgenKAT_sign and the reference implementation")
+	}
+	for _, file := range files {
+		entries, err := readRsp(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			sphincs, err := NewSphincs(256, 512, 60, 12, 4, 16, 32, nil, WithRand(rand.NewCTRDRBG(katBytes(t, e, "seed"))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sk, pk := sphincs.GenerateKey()
+			assert.Equal(t, katBytes(t, e, "pk"), pk, "%s count %s: pk", file, e["count"])
+			assert.Equal(t, katBytes(t, e, "sk"), sk, "%s count %s: sk", file, e["count"])
+
+			msg := katBytes(t, e, "msg")
+			sig := sphincs.Sign(msg, sk)
+			sm := katBytes(t, e, "sm")
+			assert.Equal(t, sm, append(sig, msg...), "%s count %s: sm", file, e["count"])
+			assert.True(t, sphincs.Verify(msg, pk, sm[:len(sig)]), "%s count %s: verify", file, e["count"])
+		}
+	}
+}
+
+// TestHSSAppendixF 使用 RFC 8554 附录 F 中的 Test Case 1 和 Test Case 2 进行校验
+// 测试向量以 {"pk": "...", "msg": "...", "sig": "..."} 的格式保存在 testdata/rfc8554 中，缺少时测试失败
+func TestHSSAppendixF(t *testing.T) {
+	for _, name := range []string{"test-case-1.json", "test-case-2.json"} {
+		file := filepath.Join("testdata", "rfc8554", name)
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("RFC 8554 Appendix F vector missing: %v", err)
+		}
+		var v struct {
+			PK  hexBytes `json:"pk"`
+			Msg hexBytes `json:"msg"`
+			Sig hexBytes `json:"sig"`
+		}
+		if err := json.Unmarshal(b, &v); err != nil {
+			t.Fatal(err)
+		}
+		// 层数以及每一层的参数从公钥和签名中读取
+		levels := make([]HSSLevel, binary.BigEndian.Uint32(v.PK))
+		key, sig := []byte(v.PK[4:]), []byte(v.Sig[4:])
+		for i := range levels {
+			levels[i] = HSSLevel{LMSType(binary.BigEndian.Uint32(key)), LMOTSType(binary.BigEndian.Uint32(key[4:]))}
+			size, ok := lmsSignatureLen(sig)
+			assert.True(t, ok, file)
+			if i < len(levels)-1 {
+				key = sig[size : size+lmsPublicKeySize]
+				sig = sig[size+lmsPublicKeySize:]
+			}
+		}
+		hss, err := NewHSS(levels)
+		assert.Nil(t, err, file)
+		assert.True(t, hss.Verify(v.Msg, v.PK, v.Sig), file)
+		assert.False(t, hss.Verify(append(v.Msg, 0), v.PK, v.Sig), file)
+	}
+}
@@ -0,0 +1,39 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/junhaideng/sphincs/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSphincs256Regression 固定随机数之后，密钥以及签名的哈希值
+// 这里的值由本实现计算得到，不是参考实现的输出，只用于保证重构之后输出不发生变化
+// 和参考实现的比较见 kat_ref_test.go 中的 TestSphincs256KAT
+func TestSphincs256Regression(t *testing.T) {
+	assert := assert.New(t)
+	entropy := make([]byte, 48)
+	for i := 0; i < len(entropy); i++ {
+		entropy[i] = byte(i)
+	}
+	sphincs, err := NewSphincs(256, 512, 60, 12, 4, 16, 32, nil, WithRand(rand.NewCTRDRBG(entropy)))
+	assert.Nil(err)
+
+	sk, pk := sphincs.GenerateKey()
+	assert.Equal(1088, len(sk))
+	assert.Equal(1056, len(pk))
+
+	msg := []byte("SPHINCS-256")
+	sig := sphincs.Sign(msg, sk)
+	assert.Equal(41000, len(sig))
+	assert.True(sphincs.Verify(msg, pk, sig))
+
+	sum := func(b []byte) string {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:])
+	}
+	assert.Equal("be8076e93bfaaf31b9d0eb84288543f6d2cdc26fd674925f0e553fe91800fcdc", sum(pk))
+	assert.Equal("ccea72ad486cddbc4fcd05fad388f8ffa4b026bf353b54f8ed23ce670c91468e", sum(sig))
+}
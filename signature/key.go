@@ -0,0 +1,386 @@
+package signature
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/suite"
+)
+
+// 带头部的密钥和签名
+// GenerateKey, Sign 返回的都是裸的 []byte，无法区分是哪一个算法、哪一组参数生成的
+// PublicKey, PrivateKey 以及 Sig 在前面加上算法和参数的标识，解析的时候检查标识和长度
+//
+// 编码格式，所有整数都是大端序
+//   u8(version) || u8(kind) || u8(alg) || u8(len(params)) || u32(params[0]) || ... || u32(len(data)) || data
+// kind 区分公钥，私钥和签名，避免把签名当作公钥解析
+
+// Algorithm 签名算法的标识
+type Algorithm uint8
+
+const (
+	AlgLamport Algorithm = iota + 1
+	AlgWinternitz
+	AlgWOTSPlus
+	AlgHors
+	AlgHorst
+	AlgSphincs
+	AlgSLHDSA
+	AlgLMOTS
+	AlgLMS
+	AlgHSS
+)
+
+var algorithmNames = map[Algorithm]string{
+	AlgLamport:    "lamport",
+	AlgWinternitz: "wots",
+	AlgWOTSPlus:   "wots+",
+	AlgHors:       "hors",
+	AlgHorst:      "horst",
+	AlgSphincs:    "sphincs",
+	AlgSLHDSA:     "slh-dsa",
+	AlgLMOTS:      "lm-ots",
+	AlgLMS:        "lms",
+	AlgHSS:        "hss",
+}
+
+// algorithmParams 每一个算法参数的个数，HSS 的参数个数和层数有关，见 Identifier.valid
+var algorithmParams = map[Algorithm]int{
+	AlgLamport:    1, // n
+	AlgWinternitz: 2, // w, n
+	AlgWOTSPlus:   2, // w, n
+	AlgHors:       2, // tau, k
+	AlgHorst:      3, // tau, k, n
+	AlgSphincs:    6, // n, h, d, w, tau, k
+	AlgSLHDSA:     1, // 参数集在 SLHDSAParameterSets 中的下标
+	AlgLMOTS:      1, // LM-OTS 类型
+	AlgLMS:        2, // LMS 类型, LM-OTS 类型
+}
+
+// suiteAlgorithms 可以通过 WithHashSuite 设置哈希函数族的算法
+// 使用非默认的哈希函数族时，在参数的最后加上哈希函数族的编号 (suite.ID)
+var suiteAlgorithms = map[Algorithm]bool{
+	AlgWOTSPlus: true,
+	AlgHorst:    true,
+	AlgSphincs:  true,
+}
+
+// withSuite s 不为空时在 params 后面加上它的编号，未注册的哈希函数族编号为 0，无法编码
+func withSuite(params []uint32, s suite.HashSuite) []uint32 {
+	if s == nil {
+		return params
+	}
+	return append(params, suite.ID(s))
+}
+
+func (a Algorithm) String() string {
+	if name, ok := algorithmNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Algorithm(%d)", uint8(a))
+}
+
+// Identifier 签名算法以及参数，写入密钥和签名的头部
+type Identifier struct {
+	Alg    Algorithm
+	Params []uint32
+}
+
+// Equal 算法和参数都相同
+func (id Identifier) Equal(other Identifier) bool {
+	if id.Alg != other.Alg || len(id.Params) != len(other.Params) {
+		return false
+	}
+	for i := range id.Params {
+		if id.Params[i] != other.Params[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String 例如 sphincs(256,60,12,16,16,32)
+func (id Identifier) String() string {
+	params := make([]string, len(id.Params))
+	for i, p := range id.Params {
+		params[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s(%s)", id.Alg, strings.Join(params, ","))
+}
+
+// valid 算法是否已知，参数个数是否正确，哈希函数族的编号是否已经注册
+func (id Identifier) valid() bool {
+	if id.Alg == AlgHSS {
+		return len(id.Params) > 0 && len(id.Params) == 1+2*int(id.Params[0])
+	}
+	n, ok := algorithmParams[id.Alg]
+	if ok && suiteAlgorithms[id.Alg] && len(id.Params) == n+1 {
+		_, err := suite.ByID(id.Params[n])
+		return err == nil
+	}
+	return ok && len(id.Params) == n
+}
+
+// Scheme 可以生成带头部的密钥和签名的签名算法，signature 包中的所有签名算法都实现了该接口
+type Scheme interface {
+	Signature
+	// Identifier 返回算法以及参数的标识
+	Identifier() Identifier
+	// PublicKeySize, PrivateKeySize 以及 SignatureSize 由参数计算得到 (bytes)
+	// GenerateKey 和 Sign 的输出长度一定等于这里的值
+	PublicKeySize() int
+	PrivateKeySize() int
+	SignatureSize() int
+}
+
+// 解析密钥和签名时可能返回的错误
+var (
+	ErrInvalidEncoding = errors.New("invalid key or signature encoding")
+	ErrParamsMismatch  = errors.New("algorithm or parameters do not match")
+)
+
+const encodingVersion = 1
+
+type keyKind uint8
+
+const (
+	kindPublicKey keyKind = iota + 1
+	kindPrivateKey
+	kindSignature
+)
+
+// PublicKey 带有算法标识的公钥
+type PublicKey struct {
+	ID  Identifier
+	Key []byte
+}
+
+// PrivateKey 带有算法标识的私钥
+// LMS 和 HSS 的私钥是有状态的，SignMessage 会更新 Key，需要重新保存
+type PrivateKey struct {
+	ID  Identifier
+	Key []byte
+}
+
+// Sig 带有算法标识的签名
+type Sig struct {
+	ID    Identifier
+	Value []byte
+}
+
+func (k *PublicKey) MarshalBinary() ([]byte, error) {
+	return marshal(kindPublicKey, k.ID, k.Key)
+}
+
+// UnmarshalBinary 只检查编码本身，参数是否和某一个签名算法一致见 ParsePublicKey
+func (k *PublicKey) UnmarshalBinary(b []byte) error {
+	id, data, err := unmarshal(kindPublicKey, b)
+	if err != nil {
+		return err
+	}
+	k.ID, k.Key = id, data
+	return nil
+}
+
+func (k *PrivateKey) MarshalBinary() ([]byte, error) {
+	return marshal(kindPrivateKey, k.ID, k.Key)
+}
+
+// UnmarshalBinary 只检查编码本身，参数是否和某一个签名算法一致见 ParsePrivateKey
+func (k *PrivateKey) UnmarshalBinary(b []byte) error {
+	id, data, err := unmarshal(kindPrivateKey, b)
+	if err != nil {
+		return err
+	}
+	k.ID, k.Key = id, data
+	return nil
+}
+
+func (s *Sig) MarshalBinary() ([]byte, error) {
+	return marshal(kindSignature, s.ID, s.Value)
+}
+
+// UnmarshalBinary 只检查编码本身，参数是否和某一个签名算法一致见 ParseSig
+func (s *Sig) UnmarshalBinary(b []byte) error {
+	id, data, err := unmarshal(kindSignature, b)
+	if err != nil {
+		return err
+	}
+	s.ID, s.Value = id, data
+	return nil
+}
+
+func marshal(kind keyKind, id Identifier, data []byte) ([]byte, error) {
+	if !id.valid() || len(id.Params) > 0xff || uint64(len(data)) > 0xffffffff {
+		return nil, ErrInvalidEncoding
+	}
+	b := make([]byte, 0, 4+4*len(id.Params)+4+len(data))
+	b = append(b, encodingVersion, byte(kind), byte(id.Alg), byte(len(id.Params)))
+	for _, p := range id.Params {
+		b = append(b, u32str(p)...)
+	}
+	b = append(b, u32str(uint32(len(data)))...)
+	return append(b, data...), nil
+}
+
+// unmarshal 严格检查每一个长度，多出或者缺少任何字节都返回 ErrInvalidEncoding
+// 返回的 data 是 b 的拷贝
+func unmarshal(kind keyKind, b []byte) (Identifier, []byte, error) {
+	var id Identifier
+	if len(b) < 4 || b[0] != encodingVersion || keyKind(b[1]) != kind {
+		return id, nil, ErrInvalidEncoding
+	}
+	id.Alg = Algorithm(b[2])
+	count := int(b[3])
+	b = b[4:]
+	if len(b) < 4*count+4 {
+		return id, nil, ErrInvalidEncoding
+	}
+	id.Params = make([]uint32, count)
+	for i := range id.Params {
+		id.Params[i] = binary.BigEndian.Uint32(b[4*i:])
+	}
+	b = b[4*count:]
+	if !id.valid() || uint64(binary.BigEndian.Uint32(b)) != uint64(len(b)-4) {
+		return id, nil, ErrInvalidEncoding
+	}
+	return id, append([]byte{}, b[4:]...), nil
+}
+
+// check 标识和 s 的一致，并且长度和 s 的参数对应
+func check(s Scheme, id Identifier, size, expected int) error {
+	if !id.Equal(s.Identifier()) {
+		return ErrParamsMismatch
+	}
+	if size != expected {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
+
+// NewPublicKey 为 s 生成的 pk 加上标识，长度不对时返回 ErrInvalidEncoding
+func NewPublicKey(s Scheme, pk []byte) (*PublicKey, error) {
+	if len(pk) != s.PublicKeySize() {
+		return nil, ErrInvalidEncoding
+	}
+	return &PublicKey{ID: s.Identifier(), Key: pk}, nil
+}
+
+// NewPrivateKey 为 s 生成的 sk 加上标识，长度不对时返回 ErrInvalidEncoding
+func NewPrivateKey(s Scheme, sk []byte) (*PrivateKey, error) {
+	if len(sk) != s.PrivateKeySize() {
+		return nil, ErrInvalidEncoding
+	}
+	return &PrivateKey{ID: s.Identifier(), Key: sk}, nil
+}
+
+// GenerateKeyPair 生成带有 s 的标识的密钥对
+func GenerateKeyPair(s Scheme) (*PrivateKey, *PublicKey) {
+	sk, pk := s.GenerateKey()
+	id := s.Identifier()
+	return &PrivateKey{ID: id, Key: sk}, &PublicKey{ID: id, Key: pk}
+}
+
+// ParsePublicKey 解析 PublicKey.MarshalBinary 的结果
+// 算法或者参数和 s 不同时返回 ErrParamsMismatch，编码或者长度不对时返回 ErrInvalidEncoding
+func ParsePublicKey(s Scheme, b []byte) (*PublicKey, error) {
+	k := &PublicKey{}
+	if err := k.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	if err := check(s, k.ID, len(k.Key), s.PublicKeySize()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ParsePrivateKey 解析 PrivateKey.MarshalBinary 的结果，错误同 ParsePublicKey
+func ParsePrivateKey(s Scheme, b []byte) (*PrivateKey, error) {
+	k := &PrivateKey{}
+	if err := k.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	if err := check(s, k.ID, len(k.Key), s.PrivateKeySize()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ParseSig 解析 Sig.MarshalBinary 的结果，错误同 ParsePublicKey
+func ParseSig(s Scheme, b []byte) (*Sig, error) {
+	sig := &Sig{}
+	if err := sig.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	if err := check(s, sig.ID, len(sig.Value), s.SignatureSize()); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// signErrors Sign 中因为输入或者状态而 panic 的错误，SignMessage 将其作为返回值
+var signErrors = []error{
+	common.ErrSizeNotMatch,
+	ErrKeyExhausted,
+	ErrTypeNotSupport,
+	ErrContextTooLong,
+	ErrRandRead,
+}
+
+// signError r 是 signErrors 中的错误 (或者包装了其中的错误) 时返回该错误
+func signError(r interface{}) (error, bool) {
+	e, ok := r.(error)
+	if !ok {
+		return nil, false
+	}
+	for _, target := range signErrors {
+		if errors.Is(e, target) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// SignMessage 和 s.Sign 一样，但是先检查 sk 是否由 s 生成
+// Sign 中 panic 的 signErrors (例如 ErrKeyExhausted) 作为返回值，其他的 panic (例如 runtime.Error) 继续抛出
+func SignMessage(s Scheme, message []byte, sk *PrivateKey) (sig *Sig, err error) {
+	if err := check(s, sk.ID, len(sk.Key), s.PrivateKeySize()); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := signError(r)
+			if !ok {
+				panic(r)
+			}
+			sig, err = nil, e
+		}
+	}()
+	if e, ok := s.(ErrSigner); ok {
+		value, err := e.SignErr(message, sk.Key)
+		if err != nil {
+			return nil, err
+		}
+		return &Sig{ID: sk.ID, Value: value}, nil
+	}
+	return &Sig{ID: sk.ID, Value: s.Sign(message, sk.Key)}, nil
+}
+
+// VerifyMessage 检查 pk 和 sig 是否由 s 生成，然后校验签名
+// 参数不一致时返回 ErrParamsMismatch，其余错误见 Verifier
+func VerifyMessage(s Scheme, message []byte, pk *PublicKey, sig *Sig) error {
+	id := s.Identifier()
+	if !pk.ID.Equal(id) || !sig.ID.Equal(id) {
+		return ErrParamsMismatch
+	}
+	if v, ok := s.(Verifier); ok {
+		return v.VerifyErr(message, pk.Key, sig.Value)
+	}
+	if !s.Verify(message, pk.Key, sig.Value) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
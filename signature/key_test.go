@@ -0,0 +1,314 @@
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyEncoding(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range newVerifyCases(t) {
+		s, ok := c.s.(Scheme)
+		if !assert.True(ok, c.name) {
+			continue
+		}
+		assert.Equal(s.PublicKeySize(), len(c.pk), c.name)
+		assert.Equal(s.SignatureSize(), len(c.sig), c.name)
+
+		sk, pk := GenerateKeyPair(s)
+		assert.Equal(s.PrivateKeySize(), len(sk.Key), c.name)
+		sig, err := SignMessage(s, c.msg, sk)
+		if !assert.Nil(err, c.name) {
+			continue
+		}
+		assert.Nil(VerifyMessage(s, c.msg, pk, sig), c.name)
+
+		pkb, err := pk.MarshalBinary()
+		assert.Nil(err)
+		skb, err := sk.MarshalBinary()
+		assert.Nil(err)
+		sigb, err := sig.MarshalBinary()
+		assert.Nil(err)
+
+		pk2, err := ParsePublicKey(s, pkb)
+		assert.Nil(err, c.name)
+		assert.Equal(pk, pk2, c.name)
+		sk2, err := ParsePrivateKey(s, skb)
+		assert.Nil(err, c.name)
+		assert.Equal(sk, sk2, c.name)
+		sig2, err := ParseSig(s, sigb)
+		assert.Nil(err, c.name)
+		assert.Equal(sig, sig2, c.name)
+
+		// 截断，多出字节，以及把签名当作公钥解析
+		_, err = ParsePublicKey(s, pkb[:len(pkb)-1])
+		assert.Equal(ErrInvalidEncoding, err, c.name)
+		_, err = ParseSig(s, append(sigb, 0))
+		assert.Equal(ErrInvalidEncoding, err, c.name)
+		_, err = ParsePublicKey(s, sigb)
+		assert.Equal(ErrInvalidEncoding, err, c.name)
+		_, err = ParsePrivateKey(s, nil)
+		assert.Equal(ErrInvalidEncoding, err, c.name)
+
+		// 头部正确但是长度和参数不对应
+		short := &PublicKey{ID: pk.ID, Key: pk.Key[1:]}
+		b, _ := short.MarshalBinary()
+		_, err = ParsePublicKey(s, b)
+		assert.Equal(ErrInvalidEncoding, err, c.name)
+	}
+}
+
+func TestKeyParamsMismatch(t *testing.T) {
+	assert := assert.New(t)
+	s1, err := NewSphincs(256, 512, 8, 2, 4, 8, 64, nil)
+	assert.Nil(err)
+	s2, err := NewSphincs(256, 256, 8, 2, 4, 8, 32, nil)
+	assert.Nil(err)
+	assert.Equal("sphincs(256,8,2,16,8,64)", s1.Identifier().String())
+
+	sk, pk := GenerateKeyPair(s1)
+	sig, err := SignMessage(s1, []byte("hello"), sk)
+	assert.Nil(err)
+
+	// 公钥的长度相同，但是 k 不同
+	assert.Equal(s1.PublicKeySize(), s2.PublicKeySize())
+	b, _ := pk.MarshalBinary()
+	_, err = ParsePublicKey(s2, b)
+	assert.Equal(ErrParamsMismatch, err)
+	_, err = SignMessage(s2, []byte("hello"), sk)
+	assert.Equal(ErrParamsMismatch, err)
+	assert.Equal(ErrParamsMismatch, VerifyMessage(s2, []byte("hello"), pk, sig))
+
+	// 不同的算法
+	lamport, _ := NewLamportSignature(Size256)
+	_, err = ParsePublicKey(lamport.(Scheme), b)
+	assert.Equal(ErrParamsMismatch, err)
+
+	// 未知的算法或者参数个数不对
+	_, err = (&PublicKey{ID: Identifier{Alg: 0xff}}).MarshalBinary()
+	assert.Equal(ErrInvalidEncoding, err)
+	_, err = (&PublicKey{ID: Identifier{Alg: AlgLamport, Params: []uint32{256, 1}}}).MarshalBinary()
+	assert.Equal(ErrInvalidEncoding, err)
+	_, err = (&PublicKey{ID: Identifier{Alg: AlgHSS, Params: []uint32{2, 5, 3}}}).MarshalBinary()
+	assert.Equal(ErrInvalidEncoding, err)
+}
+
+// customSuite 没有注册的哈希函数族
+type customSuite struct {
+	suite.HashSuite
+}
+
+func TestKeyHashSuiteMismatch(t *testing.T) {
+	assert := assert.New(t)
+	p := SphincsParams{N: 128, H: 12, D: 4, W: 16, Tau: 8, K: 16}
+	def, err := NewSphincsWithParams(p, nil)
+	assert.Nil(err)
+	s1, err := NewSphincsWithParams(p, nil, WithHashSuite(suite.SHA2))
+	assert.Nil(err)
+	s2, err := NewSphincsWithParams(p, nil, WithHashSuite(suite.BLAKE3))
+	assert.Nil(err)
+	same, err := NewSphincsWithParams(p, nil, WithHashSuite(suite.SPHINCS256))
+	assert.Nil(err)
+
+	// 默认的哈希函数族不写入标识
+	assert.Equal("sphincs(128,12,4,16,8,16)", def.Identifier().String())
+	assert.Equal(def.Identifier(), same.Identifier())
+	assert.Equal(fmt.Sprintf("sphincs(128,12,4,16,8,16,%d)", suite.ID(suite.SHA2)), s1.Identifier().String())
+
+	// 只有哈希函数族不同
+	sk, pk := GenerateKeyPair(s1)
+	sig, err := SignMessage(s1, []byte("hello"), sk)
+	assert.Nil(err)
+	for _, s := range []Scheme{def, s2} {
+		b, _ := pk.MarshalBinary()
+		_, err = ParsePublicKey(s, b)
+		assert.Equal(ErrParamsMismatch, err)
+		_, err = SignMessage(s, []byte("hello"), sk)
+		assert.Equal(ErrParamsMismatch, err)
+		assert.Equal(ErrParamsMismatch, VerifyMessage(s, []byte("hello"), pk, sig))
+	}
+	b, err := sk.MarshalBinary()
+	assert.Nil(err)
+	sk2, err := ParsePrivateKey(s1, b)
+	assert.Nil(err)
+	assert.Equal(sk, sk2)
+
+	// WOTS+ 和 HORST 没有设置哈希函数族时使用 SHA-2，和 suite.SHA2 不同
+	mask := make([]byte, 32*15)
+	wots, err := NewWOTSPlusSignature(4, Size256, nil, mask)
+	assert.Nil(err)
+	wotsSHA2, err := NewWOTSPlusSignature(4, Size256, nil, mask, WithHashSuite(suite.SHA2))
+	assert.Nil(err)
+	assert.False(wots.(Scheme).Identifier().Equal(wotsSHA2.(Scheme).Identifier()))
+	horst, err := NewHorstSignature(8, 32, make([]byte, 32), make([]byte, 2*32*8))
+	assert.Nil(err)
+	horstSHA2, err := NewHorstSignature(8, 32, make([]byte, 32), make([]byte, 2*32*8), WithHashSuite(suite.SHA2))
+	assert.Nil(err)
+	assert.False(horst.(Scheme).Identifier().Equal(horstSHA2.(Scheme).Identifier()))
+
+	// 没有注册的哈希函数族无法编码
+	custom, err := NewSphincsWithParams(p, nil, WithHashSuite(customSuite{suite.SHA2}))
+	assert.Nil(err)
+	_, err = (&PublicKey{ID: custom.Identifier(), Key: pk.Key}).MarshalBinary()
+	assert.Equal(ErrInvalidEncoding, err)
+	_, err = (&PublicKey{ID: Identifier{Alg: AlgSphincs, Params: append(def.Identifier().Params, 0xff)}}).MarshalBinary()
+	assert.Equal(ErrInvalidEncoding, err)
+}
+
+func TestSignMessageExhausted(t *testing.T) {
+	assert := assert.New(t)
+	lms, err := NewLMS(LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8)
+	assert.Nil(err)
+	sk, pk := GenerateKeyPair(lms)
+	for i := 0; i < 32; i++ {
+		sig, err := SignMessage(lms, []byte("hello"), sk)
+		assert.Nil(err)
+		assert.Nil(VerifyMessage(lms, []byte("hello"), pk, sig))
+	}
+	_, err = SignMessage(lms, []byte("hello"), sk)
+	assert.Equal(ErrKeyExhausted, err)
+}
+
+// panicScheme Sign 中 panic v
+type panicScheme struct {
+	Scheme
+	v interface{}
+}
+
+func (p panicScheme) Sign(message []byte, sk []byte) []byte {
+	panic(p.v)
+}
+
+func TestSignMessagePanic(t *testing.T) {
+	assert := assert.New(t)
+	lamport, err := NewLamportSignature(Size256)
+	assert.Nil(err)
+	sk, _ := GenerateKeyPair(lamport.(Scheme))
+
+	// 只有 signErrors 作为返回值
+	wrapped := fmt.Errorf("%w: short read", ErrRandRead)
+	_, err = SignMessage(panicScheme{lamport.(Scheme), wrapped}, nil, sk)
+	assert.Equal(wrapped, err)
+	_, err = SignMessage(panicScheme{lamport.(Scheme), common.ErrSizeNotMatch}, nil, sk)
+	assert.Equal(common.ErrSizeNotMatch, err)
+
+	// 程序错误 (runtime.Error) 继续 panic，不会被当作签名失败
+	rerr := runtimeError()
+	assert.PanicsWithError(rerr.Error(), func() { SignMessage(panicScheme{lamport.(Scheme), rerr}, nil, sk) })
+	other := errors.New("other")
+	assert.PanicsWithValue(other, func() { SignMessage(panicScheme{lamport.(Scheme), other}, nil, sk) })
+
+	// 熵源读取失败
+	lms, err := NewLMS(LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8, WithRand(strings.NewReader("")))
+	assert.Nil(err)
+	lmsSk, err := NewPrivateKey(lms, mustLMSKey(t))
+	assert.Nil(err)
+	_, err = SignMessage(lms, []byte("hello"), lmsSk)
+	assert.True(errors.Is(err, ErrRandRead), "%v", err)
+}
+
+// runtimeError 返回数组越界时的 runtime.Error
+func runtimeError() (err error) {
+	defer func() {
+		err = recover().(runtime.Error)
+	}()
+	var index []int
+	_ = index[len(index)]
+	return nil
+}
+
+// mustLMSKey 使用 crypto/rand 生成 LMS_SHA256_M32_H5 的私钥
+func mustLMSKey(t *testing.T) []byte {
+	lms, err := NewLMS(LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, _ := lms.GenerateKey()
+	return sk
+}
+
+// checkSizes GenerateKey 和 Sign 的输出长度和 PublicKeySize, PrivateKeySize, SignatureSize 一致
+func checkSizes(t *testing.T, name string, s Scheme, msg []byte) {
+	sk, pk := s.GenerateKey()
+	assert.Equal(t, s.PublicKeySize(), len(pk), name)
+	assert.Equal(t, s.PrivateKeySize(), len(sk), name)
+	sig := s.Sign(msg, sk)
+	assert.Equal(t, s.SignatureSize(), len(sig), name)
+	assert.True(t, s.Verify(msg, pk, sig), name)
+}
+
+func TestSizes(t *testing.T) {
+	assert := assert.New(t)
+	msg := []byte("hello world")
+	scheme := func(s Signature, err error) Scheme {
+		assert.Nil(err)
+		return s.(Scheme)
+	}
+
+	for _, n := range []Size{Size256, Size512} {
+		checkSizes(t, fmt.Sprint("lamport ", n), scheme(NewLamportSignature(n)), msg)
+		for _, w := range []int{1, 2, 4, 8} {
+			checkSizes(t, fmt.Sprint("wots ", w, n), scheme(NewWinternitzSignature(w, n)), msg)
+			mask := make([]byte, (1<<w-1)*int(n)/8)
+			checkSizes(t, fmt.Sprint("wots+ ", w, n), scheme(NewWOTSPlusSignature(w, n, nil, mask)), msg)
+		}
+		for _, tau := range []int{8, 16} {
+			// HORST 的输入为 tau*k bits 的摘要值
+			k := int(n) / tau
+			seed, mask := make([]byte, n/8), make([]byte, 2*int(n)/8*tau)
+			checkSizes(t, fmt.Sprint("horst ", tau, k), scheme(NewHorstSignature(tau, k, seed, mask)), make([]byte, tau*k/8))
+		}
+	}
+	// 只生成 t 不超过 2^16 的 HORS 密钥
+	for _, c := range [][2]int{{8, 32}, {8, 64}, {16, 16}, {16, 32}} {
+		checkSizes(t, fmt.Sprint("hors ", c), scheme(NewHorsSignature(c[0], c[1])), msg)
+	}
+
+	for _, p := range []SphincsParams{
+		{N: 256, H: 8, D: 2, W: 16, Tau: 8, K: 64},
+		{N: 128, H: 9, D: 3, W: 4, Tau: 6, K: 20},
+		{N: 192, H: 10, D: 5, W: 256, Tau: 5, K: 7},
+	} {
+		s, err := NewSphincsWithParams(p, nil)
+		assert.Nil(err)
+		checkSizes(t, fmt.Sprintf("sphincs %+v", p), s, msg)
+	}
+	if !testing.Short() {
+		s, err := NewSphincsWithParams(SPHINCS256Params, nil)
+		assert.Nil(err)
+		checkSizes(t, "sphincs-256", s, msg)
+	}
+
+	for _, name := range SLHDSAParameterSets() {
+		if testing.Short() && strings.HasSuffix(name, "s") {
+			continue
+		}
+		s, err := NewSLHDSA(name)
+		assert.Nil(err)
+		checkSizes(t, name, s, msg)
+	}
+
+	for _, ots := range []LMOTSType{LMOTS_SHA256_N32_W1, LMOTS_SHA256_N32_W2, LMOTS_SHA256_N32_W4, LMOTS_SHA256_N32_W8} {
+		o, err := NewLMOTS(ots)
+		assert.Nil(err)
+		checkSizes(t, fmt.Sprint("lm-ots ", ots), o, msg)
+		l, err := NewLMS(LMS_SHA256_M32_H5, ots)
+		assert.Nil(err)
+		checkSizes(t, fmt.Sprint("lms ", ots), l, msg)
+	}
+	for _, levels := range [][]HSSLevel{
+		{{LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8}},
+		{{LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W4}, {LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8}},
+	} {
+		h, err := NewHSS(levels)
+		assert.Nil(err)
+		checkSizes(t, fmt.Sprint("hss ", levels), h, msg)
+	}
+}
@@ -1,7 +1,7 @@
 package signature
 
 import (
-	"crypto/rand"
+	"io"
 
 	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
@@ -11,19 +11,17 @@ import (
 // reference: https://en.wikipedia.org/wiki/Lamport_signature
 type Lamport struct {
 	n    Size
-	hash hash.Hash
+	hash *hash.Hasher
+	r    io.Reader
 }
 
 // NewLamportSignature returns lamport signature algorithm
-func NewLamportSignature(n Size) (Signature, error) {
+// the secret key is read from crypto/rand unless WithRand is given
+func NewLamportSignature(n Size, opts ...Option) (Signature, error) {
 	if n != Size256 && n != Size512 {
 		return nil, common.ErrSizeNotSupport
 	}
-	lp := &Lamport{n: n, hash: hash.Sha256}
-	if n == Size512 {
-		lp.hash = hash.Sha512
-	}
-	return lp, nil
+	return &Lamport{n: n, hash: sha2(n), r: NewOptions(opts...).Rand}, nil
 }
 
 func (l *Lamport) GenerateKey() ([]byte, []byte) {
@@ -31,17 +29,16 @@ func (l *Lamport) GenerateKey() ([]byte, []byte) {
 
 	// n * n * 2 bits <=> n * n * 2 / 8 byte
 	size := n * 2 * n / 8
-	private := make([]byte, 0, size)
+	private := make([]byte, size)
 	public := make([]byte, 0, size)
+	if _, err := io.ReadFull(l.r, private); err != nil {
+		panic(err)
+	}
 
-	// each sk n bits, n/8 bytes
-	sk := make([]byte, n/8)
-
-	// n pairs
+	// n pairs, each sk n bits, n/8 bytes
+	h := l.hash.Clone()
 	for i := 0; i < n*2; i++ {
-		rand.Read(sk)
-		private = append(private, sk...)
-		public = append(public, l.hash(sk)...)
+		public = h.Sum(public, private[i*n/8:(i+1)*n/8])
 	}
 	return private, public
 }
@@ -49,7 +46,7 @@ func (l *Lamport) GenerateKey() ([]byte, []byte) {
 func (l *Lamport) Sign(message []byte, sk []byte) []byte {
 	n := int(l.n)
 	// hash message to n bits
-	digest := l.hash(message)
+	digest := l.hash.Clone().Sum(nil, message)
 
 	res := make([]byte, 0, n*n/8)
 
@@ -75,9 +72,22 @@ func (l *Lamport) Sign(message []byte, sk []byte) []byte {
 }
 
 func (l *Lamport) Verify(message []byte, pk []byte, signature []byte) bool {
+	return l.VerifyErr(message, pk, signature) == nil
+}
+
+// VerifyErr pk is 2n blocks and signature is n blocks, each block n bits
+func (l *Lamport) VerifyErr(message []byte, pk []byte, signature []byte) error {
 	n := int(l.n)
+	if len(pk) != 2*n*n/8 {
+		return ErrInvalidPublicKey
+	}
+	if len(signature) != n*n/8 {
+		return ErrInvalidSignature
+	}
 	// hash message to n bits
-	digest := l.hash(message)
+	h := l.hash.Clone()
+	digest := h.Sum(nil, message)
+	buf := make([]byte, 0, n/8)
 
 	// hash => 32 bytes -> 256 bits
 	for i := 0; i < len(digest); i++ {
@@ -97,12 +107,33 @@ func (l *Lamport) Verify(message []byte, pk []byte, signature []byte) bool {
 				p = pk[start+n/8 : start+n/8*2]
 			}
 			sk := signature[index*n/8 : (index+1)*n/8]
-			if !common.Equal(p, l.hash(sk)) {
-				return false
+			buf = h.Sum(buf[:0], sk)
+			if !common.Equal(p, buf) {
+				return ErrVerifyFailed
 			}
 		}
 	}
 
 	// each n/8 bytes is a sk, there are total n sk
-	return true
+	return nil
+}
+
+// Identifier 参数为 n
+func (l *Lamport) Identifier() Identifier {
+	return Identifier{Alg: AlgLamport, Params: []uint32{uint32(l.n)}}
+}
+
+// PublicKeySize 2n 个 n bits 的哈希值
+func (l *Lamport) PublicKeySize() int {
+	return 2 * int(l.n) * int(l.n) / 8
+}
+
+// PrivateKeySize 2n 个 n bits 的私钥块
+func (l *Lamport) PrivateKeySize() int {
+	return 2 * int(l.n) * int(l.n) / 8
+}
+
+// SignatureSize 每一个 bit 公开一个私钥块，一共 n 个
+func (l *Lamport) SignatureSize() int {
+	return int(l.n) * int(l.n) / 8
 }
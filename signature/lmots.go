@@ -0,0 +1,274 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/hash"
+)
+
+// LM-OTS，RFC 8554 第 4 节中的一次性签名，和 Winternitz 类似
+// 每一次哈希都带上了 I (密钥对的标识)，q (叶子节点的索引) 以及在链上的位置，用于区分不同的调用
+//
+// 私钥按照 RFC 8554 附录 A 的方式由 SEED 生成
+// x_q[i] = H(I || u32str(q) || u16str(i) || u8str(0xff) || SEED)
+//
+// 所有的整数都是大端序
+// pk = u32str(type) || I || u32str(q) || K
+// sk = u32str(type) || I || u32str(q) || SEED
+// σ  = u32str(type) || C || y[0] || ... || y[p-1]
+
+// LMOTSType LM-OTS 的参数，见 RFC 8554 表1
+type LMOTSType uint32
+
+const (
+	LMOTS_SHA256_N32_W1 LMOTSType = 1
+	LMOTS_SHA256_N32_W2 LMOTSType = 2
+	LMOTS_SHA256_N32_W4 LMOTSType = 3
+	LMOTS_SHA256_N32_W8 LMOTSType = 4
+)
+
+// 不同用途的哈希使用的标识
+const (
+	dPBLC = 0x8080
+	dMESG = 0x8181
+	dLEAF = 0x8282
+	dINTR = 0x8383
+)
+
+// lmsIDSize I 的长度
+const lmsIDSize = 16
+
+var ErrTypeNotSupport = errors.New("typecode is not supported")
+
+type lmotsParams struct {
+	n  int // 哈希值的长度
+	w  int // Winternitz 参数，每一个数字 w bits
+	p  int // 签名块的个数
+	ls int // 校验和左移的位数
+}
+
+var lmotsParamSets = map[LMOTSType]lmotsParams{
+	LMOTS_SHA256_N32_W1: {n: 32, w: 1, p: 265, ls: 7},
+	LMOTS_SHA256_N32_W2: {n: 32, w: 2, p: 133, ls: 6},
+	LMOTS_SHA256_N32_W4: {n: 32, w: 4, p: 67, ls: 4},
+	LMOTS_SHA256_N32_W8: {n: 32, w: 8, p: 34, ls: 0},
+}
+
+func (t LMOTSType) params() (lmotsParams, error) {
+	p, ok := lmotsParamSets[t]
+	if !ok {
+		return p, ErrTypeNotSupport
+	}
+	return p, nil
+}
+
+// lmotsSignatureSize LM-OTS 签名的长度
+func (p lmotsParams) signatureSize() int {
+	return 4 + p.n*(p.p+1)
+}
+
+// lmsHash 计算 SHA-256(data[0] || data[1] || ...)
+func lmsHash(data ...[]byte) []byte {
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func u32str(x uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, x)
+	return b
+}
+
+func u16str(x uint16) []byte {
+	return []byte{byte(x >> 8), byte(x)}
+}
+
+// coef 将 S 看作 w bits 一个的数字，返回第 i 个
+func coef(s []byte, i, w int) int {
+	return (1<<w - 1) & int(s[i*w/8]>>(8-(w*(i%(8/w))+w)))
+}
+
+// lmotsDigits Q || Cksm(Q) 对应的 p 个数字
+func (p lmotsParams) digits(q []byte) []int {
+	sum := 0
+	for i := 0; i < p.n*8/p.w; i++ {
+		sum += 1<<p.w - 1 - coef(q, i, p.w)
+	}
+	s := append(append([]byte{}, q...), u16str(uint16(sum<<p.ls))...)
+	res := make([]int, p.p)
+	for i := range res {
+		res[i] = coef(s, i, p.w)
+	}
+	return res
+}
+
+// lmotsPrefix I || u32str(q) || u16str(i) || u8str(j)，最后一个 byte 由调用者设置
+func lmotsPrefix(id []byte, q uint32, i int) [lmsIDSize + 7]byte {
+	var prefix [lmsIDSize + 7]byte
+	copy(prefix[:lmsIDSize], id)
+	binary.BigEndian.PutUint32(prefix[lmsIDSize:], q)
+	binary.BigEndian.PutUint16(prefix[lmsIDSize+4:], uint16(i))
+	return prefix
+}
+
+// chain 从第 start 步开始，计算到第 end 步，结果写回 x
+// h 为 SHA-256，同一次签名或者校验中的所有链复用同一个 h
+func (p lmotsParams) chain(h *hash.Hasher, x, id []byte, q uint32, i, start, end int) {
+	prefix := lmotsPrefix(id, q, i)
+	for j := start; j < end; j++ {
+		prefix[lmsIDSize+6] = byte(j)
+		h.Sum(x[:0], prefix[:], x)
+	}
+}
+
+// sk 由 SEED 生成第 i 个私钥块，追加到 dst 后面
+func (p lmotsParams) sk(h *hash.Hasher, dst, id []byte, q uint32, i int, seed []byte) []byte {
+	prefix := lmotsPrefix(id, q, i)
+	prefix[lmsIDSize+6] = 0xff
+	return h.Sum(dst, prefix[:], seed)
+}
+
+// publicKey 计算公钥中的 K
+func (p lmotsParams) publicKey(id []byte, q uint32, seed []byte) []byte {
+	h := hash.NewSha256Hasher()
+	y := make([]byte, 0, p.p*p.n)
+	for i := 0; i < p.p; i++ {
+		y = p.sk(h, y, id, q, i, seed)
+		p.chain(h, y[i*p.n:], id, q, i, 0, 1<<p.w-1)
+	}
+	return lmsHash(id, u32str(q), u16str(dPBLC), y)
+}
+
+// sign 对消息进行签名，c 为 n bytes 的随机数
+func (p lmotsParams) sign(t LMOTSType, message, id []byte, q uint32, seed, c []byte) []byte {
+	sig := make([]byte, 0, p.signatureSize())
+	sig = append(sig, u32str(uint32(t))...)
+	sig = append(sig, c...)
+	digits := p.digits(lmsHash(id, u32str(q), u16str(dMESG), c, message))
+	h := hash.NewSha256Hasher()
+	for i, a := range digits {
+		sig = p.sk(h, sig, id, q, i, seed)
+		p.chain(h, sig[len(sig)-p.n:], id, q, i, 0, a)
+	}
+	return sig
+}
+
+// publicKeyFromSig RFC 8554 算法 4b，根据签名计算出 Kc
+// 签名的类型和长度不对时返回 false
+func lmotsPublicKeyFromSig(t LMOTSType, message, sig, id []byte, q uint32) ([]byte, bool) {
+	if len(sig) < 4 || LMOTSType(binary.BigEndian.Uint32(sig)) != t {
+		return nil, false
+	}
+	p, err := t.params()
+	if err != nil || len(sig) != p.signatureSize() {
+		return nil, false
+	}
+	c := sig[4 : 4+p.n]
+	y := sig[4+p.n:]
+	digits := p.digits(lmsHash(id, u32str(q), u16str(dMESG), c, message))
+	h := hash.NewSha256Hasher()
+	z := make([]byte, 0, p.p*p.n)
+	for i, a := range digits {
+		z = append(z, y[i*p.n:(i+1)*p.n]...)
+		p.chain(h, z[i*p.n:], id, q, i, a, 1<<p.w-1)
+	}
+	return lmsHash(id, u32str(q), u16str(dPBLC), z), true
+}
+
+// LMOTS 单独使用的 LM-OTS 一次性签名
+type LMOTS struct {
+	t LMOTSType
+	p lmotsParams
+	r io.Reader
+}
+
+// NewLMOTS 返回 LM-OTS 一次性签名算法，每一个私钥只能签名一次
+func NewLMOTS(t LMOTSType, opts ...Option) (*LMOTS, error) {
+	p, err := t.params()
+	if err != nil {
+		return nil, err
+	}
+	return &LMOTS{t: t, p: p, r: NewOptions(opts...).Rand}, nil
+}
+
+func (l *LMOTS) GenerateKey() ([]byte, []byte) {
+	tmp := make([]byte, lmsIDSize+l.p.n)
+	if _, err := io.ReadFull(l.r, tmp); err != nil {
+		panic(err)
+	}
+	id := tmp[:lmsIDSize]
+	seed := tmp[lmsIDSize:]
+
+	sk := make([]byte, 0, 8+lmsIDSize+l.p.n)
+	sk = append(sk, u32str(uint32(l.t))...)
+	sk = append(sk, id...)
+	sk = append(sk, u32str(0)...)
+	sk = append(sk, seed...)
+
+	pk := make([]byte, 0, 8+lmsIDSize+l.p.n)
+	pk = append(pk, sk[:8+lmsIDSize]...)
+	pk = append(pk, l.p.publicKey(id, 0, seed)...)
+	return sk, pk
+}
+
+func (l *LMOTS) Sign(message []byte, sk []byte) []byte {
+	if len(sk) != 8+lmsIDSize+l.p.n || LMOTSType(binary.BigEndian.Uint32(sk)) != l.t {
+		panic(common.ErrSizeNotMatch)
+	}
+	id := sk[4 : 4+lmsIDSize]
+	q := binary.BigEndian.Uint32(sk[4+lmsIDSize:])
+	seed := sk[8+lmsIDSize:]
+
+	c := make([]byte, l.p.n)
+	readRand(l.r, c)
+	return l.p.sign(l.t, message, id, q, seed, c)
+}
+
+// Verify RFC 8554 算法 4a
+func (l *LMOTS) Verify(message []byte, pk []byte, signature []byte) bool {
+	return l.VerifyErr(message, pk, signature) == nil
+}
+
+// VerifyErr 公钥或者签名的类型不对时，同样认为长度不对
+func (l *LMOTS) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	if len(pk) != 8+lmsIDSize+l.p.n || LMOTSType(binary.BigEndian.Uint32(pk)) != l.t {
+		return ErrInvalidPublicKey
+	}
+	id := pk[4 : 4+lmsIDSize]
+	q := binary.BigEndian.Uint32(pk[4+lmsIDSize:])
+	kc, ok := lmotsPublicKeyFromSig(l.t, message, signature, id, q)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	if !common.Equal(kc, pk[8+lmsIDSize:]) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
+
+// Identifier 参数为 LM-OTS 类型
+func (l *LMOTS) Identifier() Identifier {
+	return Identifier{Alg: AlgLMOTS, Params: []uint32{uint32(l.t)}}
+}
+
+// PublicKeySize u32str(type) || I || u32str(q) || K
+func (l *LMOTS) PublicKeySize() int {
+	return 8 + lmsIDSize + l.p.n
+}
+
+// PrivateKeySize u32str(type) || I || u32str(q) || SEED
+func (l *LMOTS) PrivateKeySize() int {
+	return 8 + lmsIDSize + l.p.n
+}
+
+// SignatureSize u32str(type) || C || y[0] || ... || y[p-1]
+func (l *LMOTS) SignatureSize() int {
+	return l.p.signatureSize()
+}
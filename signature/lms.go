@@ -0,0 +1,305 @@
+package signature
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/merkle"
+)
+
+// LMS，RFC 8554 第 5 节中的有状态签名
+// 一棵高度为 h 的 Merkle 树，2^h 个叶子节点为 LM-OTS 公钥的哈希值
+// 树中的节点按照 1, 2, ..., 2^(h+1)-1 编号，根节点为 T[1]，第 q 个叶子节点为 T[2^h+q]
+//
+// pk = u32str(lms_type) || u32str(ots_type) || I || T[1]
+// sk = u32str(lms_type) || u32str(ots_type) || I || SEED || u32str(q)
+// σ  = u32str(q) || LM-OTS 签名 || u32str(lms_type) || path[0] || ... || path[h-1]
+// 私钥的格式 RFC 中没有定义，这里的 q 为下一次签名使用的叶子节点，每次签名之后加一
+
+// LMSType LMS 的参数，见 RFC 8554 表2
+type LMSType uint32
+
+const (
+	LMS_SHA256_M32_H5  LMSType = 5
+	LMS_SHA256_M32_H10 LMSType = 6
+	LMS_SHA256_M32_H15 LMSType = 7
+	LMS_SHA256_M32_H20 LMSType = 8
+	LMS_SHA256_M32_H25 LMSType = 9
+)
+
+var ErrKeyExhausted = errors.New("all one-time keys have been used")
+
+// lmsM LMS 中哈希值的长度
+const lmsM = 32
+
+var lmsHeights = map[LMSType]int{
+	LMS_SHA256_M32_H5:  5,
+	LMS_SHA256_M32_H10: 10,
+	LMS_SHA256_M32_H15: 15,
+	LMS_SHA256_M32_H20: 20,
+	LMS_SHA256_M32_H25: 25,
+}
+
+func (t LMSType) height() (int, error) {
+	h, ok := lmsHeights[t]
+	if !ok {
+		return 0, ErrTypeNotSupport
+	}
+	return h, nil
+}
+
+const (
+	lmsPublicKeySize  = 8 + lmsIDSize + lmsM
+	lmsPrivateKeySize = 8 + lmsIDSize + lmsM + 4
+)
+
+// lmsSignatureSize LMS 签名的长度
+func lmsSignatureSize(t LMSType, ots LMOTSType) int {
+	h, _ := t.height()
+	p, _ := ots.params()
+	return 8 + p.signatureSize() + h*lmsM
+}
+
+// lmsKey 解析之后的 LMS 私钥
+type lmsKey struct {
+	t    LMSType
+	ots  LMOTSType
+	h    int
+	p    lmotsParams
+	id   []byte
+	seed []byte
+	q    uint32
+}
+
+// newLMSKey 使用 r 中的随机数生成 I 以及 SEED
+func newLMSKey(t LMSType, ots LMOTSType, r io.Reader) (*lmsKey, error) {
+	tmp := make([]byte, lmsIDSize+lmsM)
+	if _, err := io.ReadFull(r, tmp); err != nil {
+		return nil, err
+	}
+	k := &lmsKey{t: t, ots: ots, id: tmp[:lmsIDSize], seed: tmp[lmsIDSize:]}
+	return k, k.init()
+}
+
+func parseLMSKey(b []byte) (*lmsKey, error) {
+	if len(b) != lmsPrivateKeySize {
+		return nil, common.ErrSizeNotMatch
+	}
+	k := &lmsKey{
+		t:    LMSType(binary.BigEndian.Uint32(b)),
+		ots:  LMOTSType(binary.BigEndian.Uint32(b[4:])),
+		id:   b[8 : 8+lmsIDSize],
+		seed: b[8+lmsIDSize : 8+lmsIDSize+lmsM],
+		q:    binary.BigEndian.Uint32(b[8+lmsIDSize+lmsM:]),
+	}
+	return k, k.init()
+}
+
+func (k *lmsKey) init() error {
+	var err error
+	if k.h, err = k.t.height(); err != nil {
+		return err
+	}
+	k.p, err = k.ots.params()
+	return err
+}
+
+// equal 类型，I 以及 SEED 都相同，不比较 q
+func (k *lmsKey) equal(o *lmsKey) bool {
+	return k.t == o.t && k.ots == o.ots && common.Equal(k.id, o.id) && common.Equal(k.seed, o.seed)
+}
+
+func (k *lmsKey) bytes() []byte {
+	b := make([]byte, 0, lmsPrivateKeySize)
+	b = append(b, u32str(uint32(k.t))...)
+	b = append(b, u32str(uint32(k.ots))...)
+	b = append(b, k.id...)
+	b = append(b, k.seed...)
+	return append(b, u32str(k.q)...)
+}
+
+// leaf 第 q 个叶子节点 T[2^h+q]
+func (k *lmsKey) leaf(q int) []byte {
+	r := uint32(1)<<k.h + uint32(q)
+	return lmsHash(k.id, u32str(r), u16str(dLEAF), k.p.publicKey(k.id, uint32(q), k.seed))
+}
+
+// treeHash 按照 RFC 中的编号计算内部节点，不保存整棵树，只使用 O(h) 的内存
+// 从下往上第 level 层中位置为 index 的父节点的编号为 2^(h-level-1) + index
+func (k *lmsKey) treeHash() *merkle.TreeHash {
+	h, id := k.h, k.id
+	t, err := merkle.NewTreeHashWithNode(h+1, 8*lmsM, hash.NewSha256Hasher(),
+		func(s *hash.Hasher, dst, left, right []byte, level, index int) []byte {
+			r := uint32(1)<<(h-level-1) + uint32(index)
+			return s.Sum(dst, id, u32str(r), u16str(dINTR), left, right)
+		})
+	if err != nil {
+		// h 和 m 都是固定的，不会出错
+		panic(err)
+	}
+	return t
+}
+
+// publicKey root 为根节点 T[1]
+func (k *lmsKey) publicKey(root []byte) []byte {
+	pk := make([]byte, 0, lmsPublicKeySize)
+	pk = append(pk, u32str(uint32(k.t))...)
+	pk = append(pk, u32str(uint32(k.ots))...)
+	pk = append(pk, k.id...)
+	return append(pk, root...)
+}
+
+// randomizer 确定性地生成第 q 个 LM-OTS 签名中的 C
+// 在 HSS 中，上层对下层公钥的签名每次都需要重新计算，必须保持一致
+func (k *lmsKey) randomizer(q uint32) []byte {
+	return lmsHash(k.id, u32str(q), u16str(0xfffd), []byte{0xff}, k.seed)
+}
+
+// sign 使用第 q 个 LM-OTS 密钥对消息进行签名，c 为 LM-OTS 签名中的随机数，path 为第 q 个叶子节点的鉴权路径
+func (k *lmsKey) sign(message []byte, q uint32, c []byte, path [][]byte) []byte {
+	sig := make([]byte, 0, lmsSignatureSize(k.t, k.ots))
+	sig = append(sig, u32str(q)...)
+	sig = append(sig, k.p.sign(k.ots, message, k.id, q, k.seed, c)...)
+	sig = append(sig, u32str(uint32(k.t))...)
+	for _, node := range path {
+		sig = append(sig, node...)
+	}
+	return sig
+}
+
+// lmsVerify RFC 8554 算法 6，LMS 的类型从公钥中读取
+func lmsVerify(message, pk, sig []byte) bool {
+	if len(pk) != lmsPublicKeySize || len(sig) < 8 {
+		return false
+	}
+	t := LMSType(binary.BigEndian.Uint32(pk))
+	ots := LMOTSType(binary.BigEndian.Uint32(pk[4:]))
+	h, err := t.height()
+	if err != nil {
+		return false
+	}
+	p, err := ots.params()
+	if err != nil || len(sig) != lmsSignatureSize(t, ots) {
+		return false
+	}
+	id := pk[8 : 8+lmsIDSize]
+
+	q := binary.BigEndian.Uint32(sig)
+	if q >= 1<<h {
+		return false
+	}
+	otsSig := sig[4 : 4+p.signatureSize()]
+	rest := sig[4+p.signatureSize():]
+	if LMSType(binary.BigEndian.Uint32(rest)) != t {
+		return false
+	}
+	path := rest[4:]
+
+	kc, ok := lmotsPublicKeyFromSig(ots, message, otsSig, id, q)
+	if !ok {
+		return false
+	}
+	node := uint32(1)<<h + q
+	tmp := lmsHash(id, u32str(node), u16str(dLEAF), kc)
+	for i := 0; node > 1; i++ {
+		block := path[i*lmsM : (i+1)*lmsM]
+		if node&1 == 1 {
+			tmp = lmsHash(id, u32str(node/2), u16str(dINTR), block, tmp)
+		} else {
+			tmp = lmsHash(id, u32str(node/2), u16str(dINTR), tmp, block)
+		}
+		node /= 2
+	}
+	return common.Equal(tmp, pk[8+lmsIDSize:])
+}
+
+// LMS 单层的 LMS 签名
+type LMS struct {
+	t   LMSType
+	ots LMOTSType
+	r   io.Reader
+}
+
+// NewLMS 返回 LMS 签名算法
+// 私钥是有状态的，每次签名之后 sk 中的 q 会加一，调用者需要保存更新之后的 sk
+func NewLMS(t LMSType, ots LMOTSType, opts ...Option) (*LMS, error) {
+	if _, err := t.height(); err != nil {
+		return nil, err
+	}
+	if _, err := ots.params(); err != nil {
+		return nil, err
+	}
+	return &LMS{t: t, ots: ots, r: NewOptions(opts...).Rand}, nil
+}
+
+func (l *LMS) GenerateKey() ([]byte, []byte) {
+	k, err := newLMSKey(l.t, l.ots, l.r)
+	if err != nil {
+		panic(err)
+	}
+	return k.bytes(), k.publicKey(k.treeHash().Root(k.leaf))
+}
+
+// Sign 使用 sk 中的 q 对消息进行签名，签名之后 sk 中的 q 加一
+// 如果所有的一次性密钥都已经使用过，panic ErrKeyExhausted
+func (l *LMS) Sign(message []byte, sk []byte) []byte {
+	k, err := parseLMSKey(sk)
+	if err != nil {
+		panic(err)
+	}
+	if k.t != l.t || k.ots != l.ots {
+		panic(ErrTypeNotSupport)
+	}
+	if k.q >= 1<<k.h {
+		panic(ErrKeyExhausted)
+	}
+	q := k.q
+	binary.BigEndian.PutUint32(sk[lmsPrivateKeySize-4:], q+1)
+
+	c := make([]byte, lmsM)
+	readRand(l.r, c)
+	_, path := k.treeHash().AuthenticationPath(k.leaf, 0, int(q))
+	return k.sign(message, q, c, path)
+}
+
+func (l *LMS) Verify(message []byte, pk []byte, signature []byte) bool {
+	return l.VerifyErr(message, pk, signature) == nil
+}
+
+// VerifyErr 公钥或者签名的类型不对时，同样认为长度不对
+func (l *LMS) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	if len(pk) != lmsPublicKeySize || LMSType(binary.BigEndian.Uint32(pk)) != l.t {
+		return ErrInvalidPublicKey
+	}
+	if _, err := LMOTSType(binary.BigEndian.Uint32(pk[4:])).params(); err != nil {
+		return ErrInvalidPublicKey
+	}
+	if size, ok := lmsSignatureLen(signature); !ok || size != len(signature) {
+		return ErrInvalidSignature
+	}
+	if !lmsVerify(message, pk, signature) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
+
+// Identifier 参数为 LMS 类型以及 LM-OTS 类型
+func (l *LMS) Identifier() Identifier {
+	return Identifier{Alg: AlgLMS, Params: []uint32{uint32(l.t), uint32(l.ots)}}
+}
+
+func (l *LMS) PublicKeySize() int {
+	return lmsPublicKeySize
+}
+
+func (l *LMS) PrivateKeySize() int {
+	return lmsPrivateKeySize
+}
+
+// SignatureSize u32str(q) || LM-OTS 签名 || u32str(type) || h 个节点的鉴权路径
+func (l *LMS) SignatureSize() int {
+	return lmsSignatureSize(l.t, l.ots)
+}
@@ -0,0 +1,157 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/stretchr/testify/assert"
+)
+
+var lmotsTypes = []LMOTSType{LMOTS_SHA256_N32_W1, LMOTS_SHA256_N32_W2, LMOTS_SHA256_N32_W4, LMOTS_SHA256_N32_W8}
+
+func TestLMOTS(t *testing.T) {
+	assert := assert.New(t)
+	// RFC 8554 表1 中签名的长度
+	sizes := []int{8516, 4292, 2180, 1124}
+	msg := []byte("hello world")
+	for i, typ := range lmotsTypes {
+		ots, err := NewLMOTS(typ)
+		assert.Nil(err)
+		sk, pk := ots.GenerateKey()
+		sig := ots.Sign(msg, sk)
+		assert.Equal(sizes[i], len(sig))
+		assert.True(ots.Verify(msg, pk, sig))
+		assert.False(ots.Verify([]byte("hello world!"), pk, sig))
+
+		sig[len(sig)-1] ^= 1
+		assert.False(ots.Verify(msg, pk, sig))
+	}
+	_, err := NewLMOTS(5)
+	assert.Equal(ErrTypeNotSupport, err)
+}
+
+func TestLMS(t *testing.T) {
+	assert := assert.New(t)
+	msg := []byte("hello world")
+	for _, typ := range lmotsTypes {
+		lms, err := NewLMS(LMS_SHA256_M32_H5, typ)
+		assert.Nil(err)
+		sk, pk := lms.GenerateKey()
+		assert.Equal(56, len(pk))
+
+		sig := lms.Sign(msg, sk)
+		p, _ := typ.params()
+		assert.Equal(8+p.signatureSize()+5*32, len(sig))
+		assert.True(lms.Verify(msg, pk, sig))
+		assert.False(lms.Verify([]byte("hello world!"), pk, sig))
+
+		// 鉴权路径被修改
+		sig[len(sig)-1] ^= 1
+		assert.False(lms.Verify(msg, pk, sig))
+		sig[len(sig)-1] ^= 1
+		// q 被修改
+		sig[3] ^= 1
+		assert.False(lms.Verify(msg, pk, sig))
+	}
+
+	if !testing.Short() {
+		lms, _ := NewLMS(LMS_SHA256_M32_H10, LMOTS_SHA256_N32_W1)
+		sk, pk := lms.GenerateKey()
+		assert.True(lms.Verify(msg, pk, lms.Sign(msg, sk)))
+	}
+
+	// 按照 RFC 8554 5.4 节计算的签名大小
+	assert.Equal(1292, lmsSignatureSize(LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8))
+	assert.Equal(2988, lmsSignatureSize(LMS_SHA256_M32_H25, LMOTS_SHA256_N32_W4))
+
+	_, err := NewLMS(4, LMOTS_SHA256_N32_W8)
+	assert.Equal(ErrTypeNotSupport, err)
+}
+
+func TestLMSStatefulKey(t *testing.T) {
+	assert := assert.New(t)
+	lms, _ := NewLMS(LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W2)
+	sk, pk := lms.GenerateKey()
+	msg := []byte("hello world")
+	for i := 0; i < 32; i++ {
+		assert.Equal(uint32(i), binary.BigEndian.Uint32(sk[len(sk)-4:]))
+		sig := lms.Sign(msg, sk)
+		assert.Equal(uint32(i), binary.BigEndian.Uint32(sig))
+		assert.True(lms.Verify(msg, pk, sig))
+	}
+	assert.PanicsWithValue(ErrKeyExhausted, func() { lms.Sign(msg, sk) })
+}
+
+func TestHSS(t *testing.T) {
+	assert := assert.New(t)
+	hss, err := NewHSS([]HSSLevel{
+		{LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W4},
+		{LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W2},
+	})
+	assert.Nil(err)
+	sk, pk := hss.GenerateKey()
+	assert.Equal(60, len(pk))
+	assert.Equal(4+2*60, len(sk))
+
+	msg := []byte("hello world")
+	sig := hss.Sign(msg, sk)
+	assert.True(hss.Verify(msg, pk, sig))
+	assert.False(hss.Verify([]byte("hello world!"), pk, sig))
+	sig[len(sig)-1] ^= 1
+	assert.False(hss.Verify(msg, pk, sig))
+	sig[len(sig)-1] ^= 1
+	assert.False(hss.Verify(msg, pk, sig[:len(sig)-1]))
+	assert.False(hss.Verify(msg, pk, append(sig, 0)))
+
+	// 上层对下层公钥的签名每次都相同
+	next := hss.Sign(msg, sk)
+	assert.True(hss.Verify(msg, pk, next))
+	size, _ := lmsSignatureLen(sig[4:])
+	assert.Equal(sig[:4+size+lmsPublicKeySize], next[:4+size+lmsPublicKeySize])
+
+	// 最底层用完之后，上一层使用下一个叶子节点，并重新生成最底层
+	bottom := sk[4+lmsPrivateKeySize:]
+	binary.BigEndian.PutUint32(bottom[lmsPrivateKeySize-4:], 32)
+	sig = hss.Sign(msg, sk)
+	assert.True(hss.Verify(msg, pk, sig))
+	assert.Equal(uint32(1), binary.BigEndian.Uint32(sig[4:]))
+	assert.Equal(uint32(1), binary.BigEndian.Uint32(sk[4+lmsPrivateKeySize-4:]))
+	assert.Equal(uint32(1), binary.BigEndian.Uint32(bottom[lmsPrivateKeySize-4:]))
+
+	// 所有的叶子节点都已经使用过
+	binary.BigEndian.PutUint32(sk[4+lmsPrivateKeySize-4:], 31)
+	binary.BigEndian.PutUint32(bottom[lmsPrivateKeySize-4:], 32)
+	assert.PanicsWithValue(ErrKeyExhausted, func() { hss.Sign(msg, sk) })
+
+	_, err = NewHSS(nil)
+	assert.Equal(common.ErrSizeNotSupport, err)
+}
+
+func TestHSSCache(t *testing.T) {
+	assert := assert.New(t)
+	levels := []HSSLevel{
+		{LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8},
+		{LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W8},
+	}
+	hss, _ := NewHSS(levels)
+	sk, pk := hss.GenerateKey()
+	msg := []byte("hello world")
+	// 最底层签名中的 C 是随机的，重新计算时使用相同的 C
+	bottom := lmsSignatureSize(levels[1].LMS, levels[1].LMOTS)
+	for i := 0; i < 40; i++ {
+		prev := append([]byte(nil), sk...)
+		sig := hss.Sign(msg, sk)
+		assert.True(hss.Verify(msg, pk, sig), i)
+		if i == 32 {
+			// 最底层重新生成，新的私钥由熵源生成，无法比较
+			continue
+		}
+		// 没有缓存时 (例如程序重启之后) 重新计算，结果和使用缓存的相同
+		c := sig[len(sig)-bottom+8 : len(sig)-bottom+8+lmsM]
+		other, _ := NewHSS(levels, WithRand(bytes.NewReader(c)))
+		assert.Equal(sig, other.Sign(msg, prev), i)
+		assert.Equal(sk, prev, i)
+	}
+}
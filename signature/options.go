@@ -0,0 +1,95 @@
+package signature
+
+import (
+	crand "crypto/rand"
+	"io"
+
+	"github.com/junhaideng/sphincs/rand"
+	"github.com/junhaideng/sphincs/suite"
+)
+
+// Options 所有签名算法的构造函数共用的可选参数
+type Options struct {
+	// Rand 生成密钥以及签名中随机数时使用的熵源，默认为 crypto/rand
+	Rand io.Reader
+	// Workers SPHINCS 生成密钥和签名时最多使用的 goroutine 数，0 表示使用 GOMAXPROCS，1 表示串行计算
+	Workers int
+	// Suite SPHINCS，WOTS+ 以及 HORST 使用的哈希函数族，默认值见各个构造函数
+	Suite suite.HashSuite
+	// Deterministic SLH-DSA 是否使用确定性签名，默认为随机化的签名 (hedged)
+	Deterministic bool
+}
+
+type Option interface {
+	apply(o *Options)
+}
+
+type function func(o *Options)
+
+func (f function) apply(o *Options) {
+	f(o)
+}
+
+// WithRand 设置熵源，例如测试中使用确定性的 io.Reader，或者生产环境中使用硬件随机数发生器
+func WithRand(r io.Reader) Option {
+	return function(func(o *Options) {
+		o.Rand = r
+	})
+}
+
+// WithWorkers 设置 SPHINCS 生成密钥和签名时使用的 worker 数，结果和串行计算完全相同
+func WithWorkers(n int) Option {
+	return function(func(o *Options) {
+		o.Workers = n
+	})
+}
+
+// WithHashSuite 设置哈希函数族 (F, H, H_msg, PRF, PRG)，见 suite 包
+// 使用不同哈希函数族生成的密钥和签名互不兼容
+func WithHashSuite(s suite.HashSuite) Option {
+	return function(func(o *Options) {
+		o.Suite = s
+	})
+}
+
+// WithDeterministic 设置 SLH-DSA 是否使用确定性签名，此时 opt_rand 为 PK.seed，签名不读取熵源
+// 只能在构造时设置，之后不能改变，所以同一个实例可以被多个 goroutine 同时使用
+func WithDeterministic(deterministic bool) Option {
+	return function(func(o *Options) {
+		o.Deterministic = deterministic
+	})
+}
+
+// NewOptions 返回应用 opts 之后的参数，未设置的参数使用默认值
+// 其他包中的签名算法 (例如 xmss) 可以使用相同的 Option
+func NewOptions(opts ...Option) Options {
+	o := applyOptions(opts)
+	if o.Rand == nil {
+		o.Rand = crand.Reader
+	}
+	return o
+}
+
+func applyOptions(opts []Option) Options {
+	var o Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&o)
+		}
+	}
+	return o
+}
+
+// seededRand 用于 WOTS+，HORST 以及 SPHINCS 这类传入种子的构造函数
+// 优先使用 WithRand 设置的熵源，其次使用 seed 初始化的 ChaCha12 (见 rand.New)，seed 为空时使用 crypto/rand
+// 设置了 WithHashSuite 时使用哈希函数族中的 PRG 代替 ChaCha12
+func seededRand(seed []byte, opts []Option) io.Reader {
+	o := applyOptions(opts)
+	if o.Rand == nil && len(seed) != 0 {
+		if o.Suite != nil {
+			return o.Suite.PRG(seed)
+		}
+		return rand.New(seed)
+	}
+	return NewOptions(opts...).Rand
+}
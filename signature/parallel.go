@@ -0,0 +1,53 @@
+package signature
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parallel 使用最多 workers 个 goroutine 计算 f(0), ..., f(n-1)，全部完成之后返回
+// 每一个 f(i) 只写入属于自己的位置，所以结果和调用顺序无关
+// workers 不超过 1 时在当前 goroutine 中按顺序计算
+// f 中的 panic 在调用方的 goroutine 中重新抛出，SignMessage 中的 recover 仍然可以捕获
+func parallel(workers, n int, f func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	var (
+		next    int64 = -1
+		wg      sync.WaitGroup
+		once    sync.Once
+		failure interface{}
+		failed  int32
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { failure = r })
+					atomic.StoreInt32(&failed, 1)
+				}
+			}()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+	if failure != nil {
+		panic(failure)
+	}
+}
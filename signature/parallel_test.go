@@ -0,0 +1,34 @@
+package signature
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallel(t *testing.T) {
+	assert := assert.New(t)
+	for _, workers := range []int{0, 1, 4, 100} {
+		res := make([]int, 37)
+		parallel(workers, len(res), func(i int) {
+			res[i] = i * i
+		})
+		for i := range res {
+			assert.Equal(i*i, res[i], workers)
+		}
+	}
+	parallel(4, 0, func(i int) {
+		t.Fatal("n 为 0 时不应该调用 f")
+	})
+
+	// panic 在调用方的 goroutine 中重新抛出
+	for _, workers := range []int{1, 4} {
+		assert.PanicsWithValue("boom", func() {
+			parallel(workers, 10, func(i int) {
+				if i == 5 {
+					panic("boom")
+				}
+			})
+		}, workers)
+	}
+}
@@ -1,6 +1,14 @@
 package signature
 
-import "github.com/junhaideng/sphincs/common"
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/suite"
+)
 
 type Size = common.Size
 
@@ -16,3 +24,47 @@ type Signature interface {
 	// Verify check if the signature is valid
 	Verify(message []byte, pk []byte, signature []byte) bool
 }
+
+// sha2 根据 n 选择 SHA-256 或者 SHA-512，返回的 Hasher 作为原型，每次使用之前 Clone
+func sha2(n Size) *hash.Hasher {
+	if n == Size512 {
+		return hash.NewSha512Hasher()
+	}
+	return hash.NewSha256Hasher()
+}
+
+// messageHasher 使用哈希函数族中的 H_msg 计算 n bits 的消息摘要，R 为空
+func messageHasher(s suite.HashSuite, n int) *hash.Hasher {
+	return hash.FromHash(func(message []byte) []byte {
+		return s.HMsg(nil, message, n/8)
+	}, n/8)
+}
+
+// 校验签名时可能返回的错误
+var (
+	ErrInvalidPublicKey = errors.New("public key size is invalid")
+	ErrInvalidSignature = errors.New("signature size is invalid")
+	ErrVerifyFailed     = errors.New("signature verification failed")
+)
+
+// ErrRandRead 签名时无法从熵源读取随机数，原来的错误包含在错误信息中
+var ErrRandRead = errors.New("cannot read from the random source")
+
+// readRand 签名时读取随机数，失败时 panic 一个 errors.Is(err, ErrRandRead) 的错误，见 SignMessage
+func readRand(r io.Reader, b []byte) {
+	if _, err := io.ReadFull(r, b); err != nil {
+		panic(fmt.Errorf("%w: %v", ErrRandRead, err))
+	}
+}
+
+// Verifier 和 Signature 中的 Verify 一样，但是返回校验失败的原因
+// 公钥或者签名的长度不对时分别返回 ErrInvalidPublicKey 以及 ErrInvalidSignature
+// 长度正确但是签名无效时返回 ErrVerifyFailed，任何输入都不会 panic
+type Verifier interface {
+	VerifyErr(message []byte, pk []byte, signature []byte) error
+}
+
+// ErrSigner 和 Signature 中的 Sign 一样，但是私钥的长度不对时返回 common.ErrSizeNotMatch 而不是 panic
+type ErrSigner interface {
+	SignErr(message []byte, sk []byte) ([]byte, error)
+}
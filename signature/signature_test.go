@@ -0,0 +1,151 @@
+package signature
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifyCase 一个已经签过名的实例，用于校验各种非法输入
+type verifyCase struct {
+	name string
+	s    Signature
+	msg  []byte
+	pk   []byte
+	sig  []byte
+}
+
+// newVerifyCases 为每一种签名算法生成一组密钥以及签名，参数尽量小，保证测试足够快
+func newVerifyCases(t testing.TB) []verifyCase {
+	var cases []verifyCase
+	add := func(name string, s Signature, err error, msg []byte) {
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		sk, pk := s.GenerateKey()
+		cases = append(cases, verifyCase{name, s, msg, pk, s.Sign(msg, sk)})
+	}
+	msg := []byte("hello world")
+
+	s, err := NewLamportSignature(Size256)
+	add("lamport", s, err, msg)
+	s, err = NewWinternitzSignature(4, Size256)
+	add("wots", s, err, msg)
+	s, err = NewWOTSPlusSignature(4, Size256, make([]byte, 32), make([]byte, 32*15))
+	add("wots+", s, err, msg)
+	s, err = NewHorsSignature(16, 16)
+	add("hors", s, err, msg)
+	// HORST 的输入为 tau*k bits 的摘要值
+	s, err = NewHorstSignature(8, 32, make([]byte, 32), make([]byte, 2*32*8))
+	add("horst", s, err, hash.Sha256(msg))
+	sphincs, err := NewSphincs(256, 512, 8, 2, 4, 8, 64, make([]byte, 32))
+	add("sphincs", sphincs, err, msg)
+	slh, err := NewSLHDSA("SLH-DSA-SHA2-128f")
+	add("slh-dsa", slh, err, msg)
+	ots, err := NewLMOTS(LMOTS_SHA256_N32_W4)
+	add("lm-ots", ots, err, msg)
+	lms, err := NewLMS(LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W4)
+	add("lms", lms, err, msg)
+	hss, err := NewHSS([]HSSLevel{{LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W4}, {LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W4}})
+	add("hss", hss, err, msg)
+	return cases
+}
+
+func TestVerifyErr(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range newVerifyCases(t) {
+		v, ok := c.s.(Verifier)
+		if !assert.True(ok, c.name) {
+			continue
+		}
+		assert.Nil(v.VerifyErr(c.msg, c.pk, c.sig), c.name)
+
+		assert.Equal(ErrInvalidPublicKey, v.VerifyErr(c.msg, c.pk[:len(c.pk)-1], c.sig), c.name)
+		assert.Equal(ErrInvalidPublicKey, v.VerifyErr(c.msg, nil, c.sig), c.name)
+		assert.Equal(ErrInvalidSignature, v.VerifyErr(c.msg, c.pk, c.sig[:len(c.sig)-1]), c.name)
+		assert.Equal(ErrInvalidSignature, v.VerifyErr(c.msg, c.pk, append(c.sig, 0)), c.name)
+		assert.Equal(ErrInvalidSignature, v.VerifyErr(c.msg, c.pk, nil), c.name)
+		assert.False(c.s.Verify(c.msg, c.pk, nil), c.name)
+
+		sig := append([]byte{}, c.sig...)
+		sig[len(sig)-1] ^= 1
+		assert.Equal(ErrVerifyFailed, v.VerifyErr(c.msg, c.pk, sig), c.name)
+		assert.False(c.s.Verify(c.msg, c.pk, sig), c.name)
+	}
+}
+
+func TestConstructorErr(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewSphincs(256, 512, 60, 0, 4, 16, 32, nil)
+	assert.NotNil(err)
+	_, err = NewSphincs(256, 512, 60, 7, 4, 16, 32, nil)
+	assert.NotNil(err)
+	_, err = NewSphincs(256, 512, 72, 12, 4, 16, 32, nil)
+	assert.NotNil(err)
+	_, err = NewSphincs(512, 512, 60, 12, 4, 16, 32, nil)
+	assert.NotNil(err)
+	_, err = NewSphincs(256, 512, 60, 12, 9, 16, 32, nil)
+	assert.NotNil(err)
+	_, err = NewSphincs(256, 512, 60, 12, 0, 16, 32, nil)
+	assert.NotNil(err)
+	_, err = NewSphincs(256, 512, 60, 12, 4, 16, 31, nil)
+	assert.NotNil(err)
+	_, err = NewSphincs(256, 1024, 60, 12, 4, 32, 32, nil)
+	assert.NotNil(err)
+
+	_, err = NewWinternitzSignature(0, Size256)
+	assert.NotNil(err)
+	_, err = NewWOTSPlusSignature(0, Size256, nil, nil)
+	assert.NotNil(err)
+	_, err = NewHorstSignature(16, 0, make([]byte, 32), make([]byte, 2*32*16))
+	assert.NotNil(err)
+}
+
+func TestWithRand(t *testing.T) {
+	assert := assert.New(t)
+	// 每次都返回同一个确定性的熵源，两次生成的密钥应该相同
+	entropy := func() Option {
+		return WithRand(bytes.NewReader(make([]byte, 1<<16)))
+	}
+	mask := make([]byte, 32*15)
+	constructors := map[string]func(opt Option) (Signature, error){
+		"lamport": func(opt Option) (Signature, error) { return NewLamportSignature(Size256, opt) },
+		"wots":    func(opt Option) (Signature, error) { return NewWinternitzSignature(4, Size256, opt) },
+		"wots+":   func(opt Option) (Signature, error) { return NewWOTSPlusSignature(4, Size256, nil, mask, opt) },
+		"hors":    func(opt Option) (Signature, error) { return NewHorsSignature(8, 32, opt) },
+		"slh-dsa": func(opt Option) (Signature, error) { return NewSLHDSA("SLH-DSA-SHA2-128f", opt) },
+		"lm-ots":  func(opt Option) (Signature, error) { return NewLMOTS(LMOTS_SHA256_N32_W8, opt) },
+		"lms": func(opt Option) (Signature, error) {
+			return NewLMS(LMS_SHA256_M32_H5, LMOTS_SHA256_N32_W1, opt)
+		},
+	}
+	for name, newSignature := range constructors {
+		s1, err := newSignature(entropy())
+		assert.Nil(err, name)
+		s2, err := newSignature(entropy())
+		assert.Nil(err, name)
+		sk1, pk1 := s1.GenerateKey()
+		sk2, pk2 := s2.GenerateKey()
+		assert.Equal(sk1, sk2, name)
+		assert.Equal(pk1, pk2, name)
+
+		// 默认使用 crypto/rand
+		s3, err := newSignature(nil)
+		assert.Nil(err, name)
+		sk3, _ := s3.GenerateKey()
+		assert.NotEqual(sk1, sk3, name)
+	}
+
+	// 同时设置了 seed 和 WithRand 时，使用 WithRand
+	s1, _ := NewSphincs(256, 512, 8, 2, 4, 8, 64, []byte("seed"), entropy())
+	s2, _ := NewSphincs(256, 512, 8, 2, 4, 8, 64, nil, entropy())
+	sk1, _ := s1.GenerateKey()
+	sk2, _ := s2.GenerateKey()
+	assert.Equal(sk1, sk2)
+	// seed 为空时使用 crypto/rand
+	s3, _ := NewSphincs(256, 512, 8, 2, 4, 8, 64, nil)
+	sk3, _ := s3.GenerateKey()
+	assert.NotEqual(sk1, sk3)
+}
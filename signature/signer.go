@@ -0,0 +1,141 @@
+package signature
+
+import (
+	"crypto"
+	"errors"
+	"io"
+
+	"github.com/junhaideng/sphincs/common"
+)
+
+// SPHINCS 的密钥实现标准库中的 crypto.Signer 以及 crypto.PublicKey
+// 可以直接用在使用 crypto.Signer 的代码中 (例如 TLS 工具，JOSE，CA)
+//
+// Sign 的 opts 决定签名的模式:
+//   opts 为 nil 或者 opts.HashFunc() 为 0 时为 pure 模式，digest 就是消息本身，和 Sphincs.Sign 的结果相同
+//   否则为 pre-hash 模式，digest 为 opts.HashFunc() 的哈希值，签名的消息和 SLH-DSA 的 HashSign 一致
+//   M' = 1 || 0 || OID(PH) || digest
+// SPHINCS 的签名是确定性的，Sign 中的 rand 不会被使用
+
+var ErrDigestSize = errors.New("digest size does not match the hash function")
+
+// signerPreHash 标准库中的哈希函数对应的 slhPreHash
+var signerPreHash = map[crypto.Hash]string{
+	crypto.SHA224:     "SHA2-224",
+	crypto.SHA256:     "SHA2-256",
+	crypto.SHA384:     "SHA2-384",
+	crypto.SHA512:     "SHA2-512",
+	crypto.SHA512_224: "SHA2-512/224",
+	crypto.SHA512_256: "SHA2-512/256",
+	crypto.SHA3_224:   "SHA3-224",
+	crypto.SHA3_256:   "SHA3-256",
+	crypto.SHA3_384:   "SHA3-384",
+	crypto.SHA3_512:   "SHA3-512",
+}
+
+// SphincsPublicKey SPHINCS 的公钥，pk = (Q, PK1)
+type SphincsPublicKey struct {
+	s   *Sphincs
+	key []byte
+}
+
+// SphincsPrivateKey SPHINCS 的私钥，sk = (SK1, Q, SK2)
+type SphincsPrivateKey struct {
+	s   *Sphincs
+	key []byte
+	pub *SphincsPublicKey
+}
+
+// NewPublicKey 将 GenerateKey 返回的 pk 转换成 crypto.PublicKey，长度不对时返回 ErrInvalidPublicKey
+func (s *Sphincs) NewPublicKey(pk []byte) (*SphincsPublicKey, error) {
+	if len(pk) != s.PublicKeySize() {
+		return nil, ErrInvalidPublicKey
+	}
+	return &SphincsPublicKey{s: s, key: append([]byte{}, pk...)}, nil
+}
+
+// NewPrivateKey 将 GenerateKey 返回的 sk 转换成 crypto.Signer
+// 公钥由 sk 重新计算，需要计算一次最顶层的树
+func (s *Sphincs) NewPrivateKey(sk []byte) (*SphincsPrivateKey, error) {
+	if len(sk) != s.PrivateKeySize() {
+		return nil, common.ErrSizeNotMatch
+	}
+	return &SphincsPrivateKey{
+		s:   s,
+		key: append([]byte{}, sk...),
+		pub: &SphincsPublicKey{s: s, key: s.publicKey(sk)},
+	}, nil
+}
+
+// GenerateSigner 生成一对新的密钥，和 GenerateKey 一样
+func (s *Sphincs) GenerateSigner() *SphincsPrivateKey {
+	sk, pk := s.GenerateKey()
+	return &SphincsPrivateKey{s: s, key: sk, pub: &SphincsPublicKey{s: s, key: pk}}
+}
+
+// Bytes 返回 pk 的拷贝
+func (k *SphincsPublicKey) Bytes() []byte {
+	return append([]byte{}, k.key...)
+}
+
+// Equal 参数和公钥都相同
+func (k *SphincsPublicKey) Equal(x crypto.PublicKey) bool {
+	o, ok := x.(*SphincsPublicKey)
+	if !ok {
+		return false
+	}
+	return k.s.Identifier().Equal(o.s.Identifier()) && common.Equal(k.key, o.key)
+}
+
+// Verify 校验 SphincsPrivateKey.Sign 生成的签名，opts 和签名时的一致，错误见 Verifier
+func (k *SphincsPublicKey) Verify(digest, signature []byte, opts crypto.SignerOpts) error {
+	m, err := signerMessage(digest, opts)
+	if err != nil {
+		return err
+	}
+	return k.s.VerifyErr(m, k.key, signature)
+}
+
+// Bytes 返回 sk 的拷贝
+func (k *SphincsPrivateKey) Bytes() []byte {
+	return append([]byte{}, k.key...)
+}
+
+func (k *SphincsPrivateKey) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Equal 参数和私钥都相同
+func (k *SphincsPrivateKey) Equal(x crypto.PrivateKey) bool {
+	o, ok := x.(*SphincsPrivateKey)
+	if !ok {
+		return false
+	}
+	return k.s.Identifier().Equal(o.s.Identifier()) && common.Equal(k.key, o.key)
+}
+
+// Sign 实现 crypto.Signer，模式见文件开头的说明
+// pre-hash 模式下 digest 的长度不对时返回 ErrDigestSize，不支持的哈希函数返回 ErrPreHashNotSupport
+func (k *SphincsPrivateKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	m, err := signerMessage(digest, opts)
+	if err != nil {
+		return nil, err
+	}
+	return k.s.SignErr(m, k.key)
+}
+
+// signerMessage 根据 opts 返回实际签名的消息
+func signerMessage(digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts == nil || opts.HashFunc() == 0 {
+		return digest, nil
+	}
+	h := opts.HashFunc()
+	name, ok := signerPreHash[h]
+	if !ok {
+		return nil, ErrPreHashNotSupport
+	}
+	if len(digest) != h.Size() {
+		return nil, ErrDigestSize
+	}
+	return slhMessage(1, nil, slhPreHash[name].oid(), digest)
+}
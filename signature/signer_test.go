@@ -0,0 +1,69 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/junhaideng/sphincs/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSphincsSigner(t *testing.T) {
+	assert := assert.New(t)
+	s, err := NewSphincs(256, 512, 8, 2, 4, 8, 64, []byte("seed"))
+	assert.Nil(err)
+
+	var signer crypto.Signer = s.GenerateSigner()
+	pub := signer.Public().(*SphincsPublicKey)
+	message := []byte("hello world")
+
+	// pure 模式和 Sphincs.Sign 一致
+	sig, err := signer.Sign(nil, message, crypto.Hash(0))
+	assert.Nil(err)
+	assert.True(s.Verify(message, pub.Bytes(), sig))
+	assert.Nil(pub.Verify(message, sig, nil))
+
+	// pre-hash 模式
+	digest := sha256.Sum256(message)
+	sig, err = signer.Sign(nil, digest[:], crypto.SHA256)
+	assert.Nil(err)
+	assert.Nil(pub.Verify(digest[:], sig, crypto.SHA256))
+	assert.Equal(ErrVerifyFailed, pub.Verify(digest[:], sig, nil))
+	assert.False(s.Verify(digest[:], pub.Bytes(), sig))
+
+	_, err = signer.Sign(nil, message, crypto.SHA256)
+	assert.Equal(ErrDigestSize, err)
+	_, err = signer.Sign(nil, make([]byte, 16), crypto.MD5)
+	assert.Equal(ErrPreHashNotSupport, err)
+
+	// 从 sk 中恢复公钥
+	sk := signer.(*SphincsPrivateKey)
+	restored, err := s.NewPrivateKey(sk.Bytes())
+	assert.Nil(err)
+	assert.True(restored.Equal(sk))
+	assert.True(pub.Equal(restored.Public()))
+
+	pk, err := s.NewPublicKey(pub.Bytes())
+	assert.Nil(err)
+	assert.True(pk.Equal(pub))
+	_, err = s.NewPublicKey(pub.Bytes()[1:])
+	assert.Equal(ErrInvalidPublicKey, err)
+	_, err = s.NewPrivateKey(nil)
+	assert.NotNil(err)
+
+	// 参数不同时不相等
+	other, err := NewSphincs(256, 256, 8, 2, 4, 8, 32, nil)
+	assert.Nil(err)
+	pk, err = other.NewPublicKey(pub.Bytes())
+	assert.Nil(err)
+	assert.False(pk.Equal(pub))
+	assert.False(pub.Equal(crypto.PublicKey(nil)))
+
+	// 只有哈希函数族不同时不相等
+	other, err = NewSphincs(256, 512, 8, 2, 4, 8, 64, nil, WithHashSuite(suite.SHA2))
+	assert.Nil(err)
+	pk, err = other.NewPublicKey(pub.Bytes())
+	assert.Nil(err)
+	assert.False(pk.Equal(pub))
+}
@@ -0,0 +1,412 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/junhaideng/sphincs/common"
+	"golang.org/x/crypto/sha3"
+)
+
+// SLH-DSA，即 FIPS 205 中标准化之后的 SPHINCS+
+// 和 SPHINCS-256 相比:
+// 1. 少次签名使用 FORS 替换了 HORST
+// 2. 不再使用掩码，所有的哈希都是 tweakable hash，使用 PK.seed 以及地址 ADRS 进行区分
+// 3. 签名使用的 FORS 密钥对的位置由消息摘要决定
+//
+// 密钥和签名的格式和 FIPS 205 保持一致
+// sk = (SK.seed, SK.prf, PK.seed, PK.root)
+// pk = (PK.seed, PK.root)
+// σ  = (R, SIG_FORS, SIG_HT)
+// 其中 SIG_HT 为 d 个 (WOTS+ 签名, 鉴权路径)，从最底层开始
+
+// slhParams SLH-DSA 的参数集，见 FIPS 205 表2
+type slhParams struct {
+	name string
+	n    int // 哈希值的长度，单位 bytes
+	h    int // hyper tree 的高度
+	d    int // hyper tree 的层数
+	hp   int // 每一棵 XMSS 树的高度 h' = h/d
+	a    int // FORS 中每一棵树的高度
+	k    int // FORS 中树的个数
+	lgw  int // Winternitz 参数，w = 2^lgw
+	m    int // 消息摘要的长度，单位 bytes
+	sha2 bool
+}
+
+var slhParamSets = []slhParams{
+	{name: "SLH-DSA-SHA2-128s", n: 16, h: 63, d: 7, hp: 9, a: 12, k: 14, lgw: 4, m: 30, sha2: true},
+	{name: "SLH-DSA-SHAKE-128s", n: 16, h: 63, d: 7, hp: 9, a: 12, k: 14, lgw: 4, m: 30},
+	{name: "SLH-DSA-SHA2-128f", n: 16, h: 66, d: 22, hp: 3, a: 6, k: 33, lgw: 4, m: 34, sha2: true},
+	{name: "SLH-DSA-SHAKE-128f", n: 16, h: 66, d: 22, hp: 3, a: 6, k: 33, lgw: 4, m: 34},
+	{name: "SLH-DSA-SHA2-192s", n: 24, h: 63, d: 7, hp: 9, a: 14, k: 17, lgw: 4, m: 39, sha2: true},
+	{name: "SLH-DSA-SHAKE-192s", n: 24, h: 63, d: 7, hp: 9, a: 14, k: 17, lgw: 4, m: 39},
+	{name: "SLH-DSA-SHA2-192f", n: 24, h: 66, d: 22, hp: 3, a: 8, k: 33, lgw: 4, m: 42, sha2: true},
+	{name: "SLH-DSA-SHAKE-192f", n: 24, h: 66, d: 22, hp: 3, a: 8, k: 33, lgw: 4, m: 42},
+	{name: "SLH-DSA-SHA2-256s", n: 32, h: 64, d: 8, hp: 8, a: 14, k: 22, lgw: 4, m: 47, sha2: true},
+	{name: "SLH-DSA-SHAKE-256s", n: 32, h: 64, d: 8, hp: 8, a: 14, k: 22, lgw: 4, m: 47},
+	{name: "SLH-DSA-SHA2-256f", n: 32, h: 68, d: 17, hp: 4, a: 9, k: 35, lgw: 4, m: 49, sha2: true},
+	{name: "SLH-DSA-SHAKE-256f", n: 32, h: 68, d: 17, hp: 4, a: 9, k: 35, lgw: 4, m: 49},
+}
+
+var ErrContextTooLong = errors.New("context should not be longer than 255 bytes")
+var ErrPreHashNotSupport = errors.New("pre-hash function is not supported")
+
+// SLHDSAParameterSets 返回支持的所有参数集的名称
+func SLHDSAParameterSets() []string {
+	res := make([]string, len(slhParamSets))
+	for i, p := range slhParamSets {
+		res[i] = p.name
+	}
+	return res
+}
+
+// SLHDSA .
+type SLHDSA struct {
+	slhParams
+	// WOTS+ 中的签名块数 len = len1 + len2
+	len1 int
+	len2 int
+	len  int
+	hash slhHash
+	// 用于生成密钥以及签名中的 opt_rand
+	r io.Reader
+	// 为 true 时 opt_rand = PK.seed，签名是确定性的，只能通过 WithDeterministic 在构造时设置
+	deterministic bool
+}
+
+// NewSLHDSA 根据参数集的名称创建签名算法，例如 SLH-DSA-SHA2-128s
+// 默认使用随机化的签名 (hedged)，随机数来自 crypto/rand，可以通过 WithRand 设置
+// WithDeterministic(true) 时使用确定性签名
+func NewSLHDSA(name string, opts ...Option) (*SLHDSA, error) {
+	for _, p := range slhParamSets {
+		if p.name != name {
+			continue
+		}
+		o := NewOptions(opts...)
+		s := &SLHDSA{
+			slhParams:     p,
+			r:             o.Rand,
+			deterministic: o.Deterministic,
+		}
+		w := 1 << p.lgw
+		s.len1 = (8*p.n + p.lgw - 1) / p.lgw
+		// len2 = floor(log2(len1 * (w-1)) / lg(w)) + 1
+		s.len2 = (bits.Len(uint(s.len1*(w-1)))-1)/p.lgw + 1
+		s.len = s.len1 + s.len2
+		if p.sha2 {
+			s.hash = newSlhSha2(p.n, p.m)
+		} else {
+			s.hash = newSlhShake(p.n, p.m)
+		}
+		return s, nil
+	}
+	return nil, common.ErrSizeNotSupport
+}
+
+// local 返回使用单独 hasher 的副本，哈希函数的状态以及缓冲区都保存在 hasher 中
+// 每次生成密钥，签名以及校验都使用一个新的副本，所以同一个 SLHDSA 可以被多个 goroutine 同时使用
+func (s *SLHDSA) local() *SLHDSA {
+	c := *s
+	c.hash = s.hash.clone()
+	return &c
+}
+
+// Name 返回参数集的名称
+func (s *SLHDSA) Name() string {
+	return s.name
+}
+
+// Deterministic 是否使用确定性签名，见 WithDeterministic
+func (s *SLHDSA) Deterministic() bool {
+	return s.deterministic
+}
+
+// SignatureSize R || SIG_FORS || SIG_HT，见 FIPS 205 表 2
+func (s *SLHDSA) SignatureSize() int {
+	return (1 + s.k*(1+s.a) + s.h + s.d*s.len) * s.n
+}
+
+// GenerateKey 生成密钥对，sk 为 4n bytes，pk 为 2n bytes
+func (s *SLHDSA) GenerateKey() ([]byte, []byte) {
+	seed := make([]byte, 3*s.n)
+	if _, err := io.ReadFull(s.r, seed); err != nil {
+		panic(err)
+	}
+	return s.keyGenInternal(seed[:s.n], seed[s.n:2*s.n], seed[2*s.n:])
+}
+
+// Sign 使用空的 context 对消息进行签名，即 FIPS 205 中的 slh_sign
+func (s *SLHDSA) Sign(message []byte, sk []byte) []byte {
+	signature, err := s.SignWithContext(message, nil, sk)
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+// Verify 使用空的 context 校验签名，即 FIPS 205 中的 slh_verify
+func (s *SLHDSA) Verify(message []byte, pk []byte, signature []byte) bool {
+	return s.VerifyWithContext(message, nil, pk, signature)
+}
+
+// VerifyErr 和 Verify 一样，但是返回校验失败的原因
+func (s *SLHDSA) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	if len(pk) != 2*s.n {
+		return ErrInvalidPublicKey
+	}
+	if len(signature) != s.SignatureSize() {
+		return ErrInvalidSignature
+	}
+	if !s.Verify(message, pk, signature) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
+
+// SignWithContext 对消息进行签名，ctx 最多 255 bytes
+// M' = 0 || len(ctx) || ctx || M
+func (s *SLHDSA) SignWithContext(message, ctx, sk []byte) ([]byte, error) {
+	m, err := slhMessage(0, ctx, nil, message)
+	if err != nil {
+		return nil, err
+	}
+	return s.sign(m, sk)
+}
+
+// VerifyWithContext 校验 SignWithContext 生成的签名
+func (s *SLHDSA) VerifyWithContext(message, ctx, pk, signature []byte) bool {
+	m, err := slhMessage(0, ctx, nil, message)
+	if err != nil {
+		return false
+	}
+	return s.verifyInternal(m, pk, signature)
+}
+
+// HashSign 即 FIPS 205 中的 hash_slh_sign，先使用 ph 对消息进行哈希
+// ph 为哈希函数的名称，例如 SHA2-256，SHAKE-128，见 slhPreHash
+// M' = 1 || len(ctx) || ctx || OID || PH(M)
+func (s *SLHDSA) HashSign(message, ctx []byte, ph string, sk []byte) ([]byte, error) {
+	p, ok := slhPreHash[ph]
+	if !ok {
+		return nil, ErrPreHashNotSupport
+	}
+	m, err := slhMessage(1, ctx, p.oid(), p.sum(message))
+	if err != nil {
+		return nil, err
+	}
+	return s.sign(m, sk)
+}
+
+// HashVerify 校验 HashSign 生成的签名
+func (s *SLHDSA) HashVerify(message, ctx []byte, ph string, pk, signature []byte) bool {
+	p, ok := slhPreHash[ph]
+	if !ok {
+		return false
+	}
+	m, err := slhMessage(1, ctx, p.oid(), p.sum(message))
+	if err != nil {
+		return false
+	}
+	return s.verifyInternal(m, pk, signature)
+}
+
+// sign 根据是否确定性签名选择 opt_rand
+func (s *SLHDSA) sign(m, sk []byte) ([]byte, error) {
+	if len(sk) != 4*s.n {
+		return nil, common.ErrSizeNotMatch
+	}
+	addrnd := sk[2*s.n : 3*s.n]
+	if !s.deterministic {
+		addrnd = make([]byte, s.n)
+		if _, err := io.ReadFull(s.r, addrnd); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRandRead, err)
+		}
+	}
+	return s.signInternal(m, sk, addrnd), nil
+}
+
+// slhMessage 构造 M' = domain || len(ctx) || ctx || oid || message
+func slhMessage(domain byte, ctx, oid, message []byte) ([]byte, error) {
+	if len(ctx) > 255 {
+		return nil, ErrContextTooLong
+	}
+	m := make([]byte, 0, 2+len(ctx)+len(oid)+len(message))
+	m = append(m, domain, byte(len(ctx)))
+	m = append(m, ctx...)
+	m = append(m, oid...)
+	m = append(m, message...)
+	return m, nil
+}
+
+// keyGenInternal 即 FIPS 205 中的 slh_keygen_internal
+func (s *SLHDSA) keyGenInternal(skSeed, skPrf, pkSeed []byte) ([]byte, []byte) {
+	var a adrs
+	a.setLayer(uint32(s.d - 1))
+	root := s.local().xmssNode(skSeed, 0, s.hp, pkSeed, &a)
+
+	sk := make([]byte, 0, 4*s.n)
+	sk = append(sk, skSeed...)
+	sk = append(sk, skPrf...)
+	sk = append(sk, pkSeed...)
+	sk = append(sk, root...)
+	pk := make([]byte, 2*s.n)
+	copy(pk, sk[2*s.n:])
+	return sk, pk
+}
+
+// digest 将消息摘要拆分成 FORS 签名的消息 md，以及 hyper tree 中的位置 (idx_tree, idx_leaf)
+func (s *SLHDSA) digest(r, pkSeed, pkRoot, m []byte) ([]byte, uint64, uint32) {
+	digest := s.hash.hMsg(r, pkSeed, pkRoot, m)
+	mdSize := (s.k*s.a + 7) / 8
+	treeSize := (s.h - s.hp + 7) / 8
+	leafSize := (s.hp + 7) / 8
+
+	md := digest[:mdSize]
+	tree := toInt(digest[mdSize : mdSize+treeSize])
+	if s.h-s.hp < 64 {
+		tree &= 1<<(s.h-s.hp) - 1
+	}
+	leaf := toInt(digest[mdSize+treeSize : mdSize+treeSize+leafSize])
+	leaf &= 1<<s.hp - 1
+	return md, tree, uint32(leaf)
+}
+
+// signInternal 即 FIPS 205 中的 slh_sign_internal
+// addrnd 为 n bytes 的随机数，确定性签名时为 PK.seed
+func (s *SLHDSA) signInternal(m, sk, addrnd []byte) []byte {
+	s = s.local()
+	skSeed := sk[:s.n]
+	skPrf := sk[s.n : 2*s.n]
+	pkSeed := sk[2*s.n : 3*s.n]
+	pkRoot := sk[3*s.n:]
+
+	signature := make([]byte, 0, s.SignatureSize())
+	r := s.hash.prfMsg(skPrf, addrnd, m)
+	signature = append(signature, r...)
+
+	md, tree, leaf := s.digest(r, pkSeed, pkRoot, m)
+
+	var a adrs
+	a.setTree(tree)
+	a.setTypeAndClear(adrsForsTree)
+	a.setKeyPair(leaf)
+	sigFors := s.forsSign(md, skSeed, pkSeed, &a)
+	signature = append(signature, sigFors...)
+
+	// FORS 的公钥由 hyper tree 进行签名
+	pkFors := s.forsPkFromSig(sigFors, md, pkSeed, &a)
+	return append(signature, s.htSign(pkFors, skSeed, pkSeed, tree, leaf)...)
+}
+
+// verifyInternal 即 FIPS 205 中的 slh_verify_internal
+func (s *SLHDSA) verifyInternal(m, pk, signature []byte) bool {
+	if len(pk) != s.PublicKeySize() || len(signature) != s.SignatureSize() {
+		return false
+	}
+	s = s.local()
+	pkSeed := pk[:s.n]
+	pkRoot := pk[s.n:]
+
+	r := signature[:s.n]
+	forsSize := s.k * (1 + s.a) * s.n
+	sigFors := signature[s.n : s.n+forsSize]
+	sigHt := signature[s.n+forsSize:]
+
+	md, tree, leaf := s.digest(r, pkSeed, pkRoot, m)
+
+	var a adrs
+	a.setTree(tree)
+	a.setTypeAndClear(adrsForsTree)
+	a.setKeyPair(leaf)
+	pkFors := s.forsPkFromSig(sigFors, md, pkSeed, &a)
+	return s.htVerify(pkFors, sigHt, pkSeed, tree, leaf, pkRoot)
+}
+
+// toInt 将 bytes 按照大端序转换成整数
+func toInt(b []byte) uint64 {
+	var res uint64
+	for _, v := range b {
+		res = res<<8 | uint64(v)
+	}
+	return res
+}
+
+// base2b 将 x 按照大端序每 b bits 拆分成一个整数，一共 outLen 个
+func base2b(x []byte, b, outLen int) []uint32 {
+	res := make([]uint32, outLen)
+	in := 0
+	n := 0
+	var total uint64
+	for i := 0; i < outLen; i++ {
+		for n < b {
+			total = total<<8 | uint64(x[in])
+			in++
+			n += 8
+		}
+		n -= b
+		res[i] = uint32(total>>n) & (1<<b - 1)
+		total &= 1<<n - 1
+	}
+	return res
+}
+
+// slhPreHashFunc HashSLH-DSA 中可以使用的哈希函数
+// 最后一个 byte 为 OID 2.16.840.1.101.3.4.2.x 中的 x
+type slhPreHashFunc struct {
+	id  byte
+	sum func([]byte) []byte
+}
+
+// oid 返回 DER 编码之后的 OID
+func (p slhPreHashFunc) oid() []byte {
+	return []byte{0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, p.id}
+}
+
+var slhPreHash = map[string]slhPreHashFunc{
+	"SHA2-224":     {0x04, func(b []byte) []byte { s := sha256.Sum224(b); return s[:] }},
+	"SHA2-256":     {0x01, func(b []byte) []byte { s := sha256.Sum256(b); return s[:] }},
+	"SHA2-384":     {0x02, func(b []byte) []byte { s := sha512.Sum384(b); return s[:] }},
+	"SHA2-512":     {0x03, func(b []byte) []byte { s := sha512.Sum512(b); return s[:] }},
+	"SHA2-512/224": {0x05, func(b []byte) []byte { s := sha512.Sum512_224(b); return s[:] }},
+	"SHA2-512/256": {0x06, func(b []byte) []byte { s := sha512.Sum512_256(b); return s[:] }},
+	"SHA3-224":     {0x07, func(b []byte) []byte { s := sha3.Sum224(b); return s[:] }},
+	"SHA3-256":     {0x08, func(b []byte) []byte { s := sha3.Sum256(b); return s[:] }},
+	"SHA3-384":     {0x09, func(b []byte) []byte { s := sha3.Sum384(b); return s[:] }},
+	"SHA3-512":     {0x0a, func(b []byte) []byte { s := sha3.Sum512(b); return s[:] }},
+	"SHAKE-128": {0x0b, func(b []byte) []byte {
+		s := make([]byte, 32)
+		sha3.ShakeSum128(s, b)
+		return s
+	}},
+	"SHAKE-256": {0x0c, func(b []byte) []byte {
+		s := make([]byte, 64)
+		sha3.ShakeSum256(s, b)
+		return s
+	}},
+}
+
+// Identifier 参数为参数集在 SLHDSAParameterSets 中的下标
+func (s *SLHDSA) Identifier() Identifier {
+	var index uint32
+	for i, p := range slhParamSets {
+		if p.name == s.name {
+			index = uint32(i)
+		}
+	}
+	return Identifier{Alg: AlgSLHDSA, Params: []uint32{index}}
+}
+
+// PublicKeySize PK.seed || PK.root
+func (s *SLHDSA) PublicKeySize() int {
+	return 2 * s.n
+}
+
+// PrivateKeySize SK.seed || SK.prf || PK.seed || PK.root
+func (s *SLHDSA) PrivateKeySize() int {
+	return 4 * s.n
+}
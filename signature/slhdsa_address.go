@@ -0,0 +1,84 @@
+package signature
+
+import "encoding/binary"
+
+// SLH-DSA 中的地址 ADRS，一共 32 bytes，见 FIPS 205 4.2
+//
+//	layer address      4 bytes
+//	tree address       12 bytes
+//	type               4 bytes
+//	后面 12 bytes 的含义由 type 决定:
+//	  key pair address    4 bytes
+//	  chain address / tree height   4 bytes
+//	  hash address / tree index     4 bytes
+//
+// 所有字段都是大端序
+type adrs [32]byte
+
+// ADRS 中的 type 字段
+const (
+	adrsWotsHash  = 0
+	adrsWotsPk    = 1
+	adrsTree      = 2
+	adrsForsTree  = 3
+	adrsForsRoots = 4
+	adrsWotsPrf   = 5
+	adrsForsPrf   = 6
+)
+
+func (a *adrs) setLayer(layer uint32) {
+	binary.BigEndian.PutUint32(a[0:4], layer)
+}
+
+// setTree tree address 有 12 bytes，但是树的索引不会超过 64 bits
+func (a *adrs) setTree(tree uint64) {
+	binary.BigEndian.PutUint32(a[4:8], 0)
+	binary.BigEndian.PutUint64(a[8:16], tree)
+}
+
+// setTypeAndClear 设置 type，同时将后面 12 bytes 清零
+func (a *adrs) setTypeAndClear(t uint32) {
+	binary.BigEndian.PutUint32(a[16:20], t)
+	for i := 20; i < len(a); i++ {
+		a[i] = 0
+	}
+}
+
+func (a *adrs) setKeyPair(i uint32) {
+	binary.BigEndian.PutUint32(a[20:24], i)
+}
+
+func (a *adrs) keyPair() uint32 {
+	return binary.BigEndian.Uint32(a[20:24])
+}
+
+func (a *adrs) setChain(i uint32) {
+	binary.BigEndian.PutUint32(a[24:28], i)
+}
+
+func (a *adrs) setTreeHeight(z uint32) {
+	binary.BigEndian.PutUint32(a[24:28], z)
+}
+
+func (a *adrs) setHash(i uint32) {
+	binary.BigEndian.PutUint32(a[28:32], i)
+}
+
+func (a *adrs) setTreeIndex(i uint32) {
+	binary.BigEndian.PutUint32(a[28:32], i)
+}
+
+func (a *adrs) treeIndex() uint32 {
+	return binary.BigEndian.Uint32(a[28:32])
+}
+
+// putCompressed 将 SHA2 参数集使用的 22 bytes 压缩地址 ADRSc 写入 c 中，见 FIPS 205 11.2
+// layer 和 type 各取最低的 1 byte，tree address 取最低的 8 bytes，c 至少为 22 bytes
+func (a *adrs) putCompressed(c []byte) []byte {
+	c = c[:22]
+	c[0] = a[3]
+	copy(c[1:9], a[8:16])
+	c[9] = a[19]
+	copy(c[10:], a[20:32])
+	return c
+}
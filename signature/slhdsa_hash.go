@@ -0,0 +1,178 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	stdhash "hash"
+
+	"github.com/junhaideng/sphincs/hash"
+	"golang.org/x/crypto/sha3"
+)
+
+// slhHash SLH-DSA 中使用的哈希函数族，见 FIPS 205 11.1 以及 11.2
+// F, H, T_l 都是 tweakable hash，通过 PK.seed 和 ADRS 进行区分
+// prf, f, h, t 的结果追加到 dst 后面，读取完所有输入之后才写入 dst，所以 dst 可以和输入重叠
+// 实现中保存了哈希函数的状态以及缓冲区，不能被多个 goroutine 同时使用，见 SLHDSA.local
+type slhHash interface {
+	// prfMsg 生成签名中的随机数 R
+	prfMsg(skPrf, optRand, msg []byte) []byte
+	// hMsg 计算消息摘要，输出 m bytes
+	hMsg(r, pkSeed, pkRoot, msg []byte) []byte
+	// prf 生成 WOTS+ 以及 FORS 的私钥
+	prf(dst, pkSeed, skSeed []byte, a *adrs) []byte
+	// f 对一个 n bytes 的块进行哈希
+	f(dst, pkSeed []byte, a *adrs, m []byte) []byte
+	// h 对两个 n bytes 的块 left || right 进行哈希
+	h(dst, pkSeed []byte, a *adrs, left, right []byte) []byte
+	// t 对任意多个 n bytes 的块进行哈希
+	t(dst, pkSeed []byte, a *adrs, m []byte) []byte
+	// clone 返回使用单独的哈希函数状态以及缓冲区的副本
+	clone() slhHash
+}
+
+// slhShake SHAKE 参数集，所有函数都基于 SHAKE256
+type slhShake struct {
+	n int
+	m int
+	// 输出 n bytes 的 SHAKE256
+	hasher *hash.Hasher
+}
+
+func newSlhShake(n, m int) *slhShake {
+	return &slhShake{n: n, m: m, hasher: hash.NewShakeHasher(sha3.NewShake256, n)}
+}
+
+func shake256(out int, data ...[]byte) []byte {
+	s := sha3.NewShake256()
+	for _, d := range data {
+		s.Write(d)
+	}
+	res := make([]byte, out)
+	s.Read(res)
+	return res
+}
+
+func (s *slhShake) clone() slhHash {
+	return &slhShake{n: s.n, m: s.m, hasher: s.hasher.Clone()}
+}
+
+func (s *slhShake) prfMsg(skPrf, optRand, msg []byte) []byte {
+	return shake256(s.n, skPrf, optRand, msg)
+}
+
+func (s *slhShake) hMsg(r, pkSeed, pkRoot, msg []byte) []byte {
+	return shake256(s.m, r, pkSeed, pkRoot, msg)
+}
+
+func (s *slhShake) prf(dst, pkSeed, skSeed []byte, a *adrs) []byte {
+	return s.hasher.Sum(dst, pkSeed, a[:], skSeed)
+}
+
+func (s *slhShake) f(dst, pkSeed []byte, a *adrs, m []byte) []byte {
+	return s.hasher.Sum(dst, pkSeed, a[:], m)
+}
+
+func (s *slhShake) h(dst, pkSeed []byte, a *adrs, left, right []byte) []byte {
+	return s.hasher.Sum(dst, pkSeed, a[:], left, right)
+}
+
+func (s *slhShake) t(dst, pkSeed []byte, a *adrs, m []byte) []byte {
+	return s.hasher.Sum(dst, pkSeed, a[:], m)
+}
+
+// slhSha2 SHA2 参数集
+// n = 16 时所有函数使用 SHA-256
+// n = 24, 32 时 F 和 PRF 使用 SHA-256，其余使用 SHA-512
+// PK.seed 填充到一个分组的大小，地址使用压缩之后的 ADRSc
+type slhSha2 struct {
+	n int
+	m int
+	// small 为 F 和 PRF 使用的 SHA-256，large 为 H 和 T 使用的哈希函数，输出都截取前 n bytes
+	small, large *hash.Hasher
+	// padding 填充 PK.seed 使用的 0，addr 保存 ADRSc
+	padding []byte
+	addr    [22]byte
+}
+
+func newSlhSha2(n, m int) *slhSha2 {
+	s := &slhSha2{n: n, m: m, padding: make([]byte, sha512.BlockSize)}
+	newHash, _ := s.big()
+	s.small = hash.NewTruncatedHasher(sha256.New, n)
+	s.large = hash.NewTruncatedHasher(newHash, n)
+	return s
+}
+
+func (s *slhSha2) clone() slhHash {
+	return &slhSha2{n: s.n, m: s.m, small: s.small.Clone(), large: s.large.Clone(), padding: s.padding}
+}
+
+// big 返回 H, T, H_msg, PRF_msg 使用的哈希函数以及分组大小
+func (s *slhSha2) big() (func() stdhash.Hash, int) {
+	if s.n == 16 {
+		return sha256.New, sha256.BlockSize
+	}
+	return sha512.New, sha512.BlockSize
+}
+
+// tweak 计算 Hash(PK.seed || toByte(0, block-n) || ADRSc || m1 || m2)
+func (s *slhSha2) tweak(dst []byte, h *hash.Hasher, block int, pkSeed []byte, a *adrs, m1, m2 []byte) []byte {
+	return h.Sum(dst, pkSeed, s.padding[:block-len(pkSeed)], a.putCompressed(s.addr[:]), m1, m2)
+}
+
+func (s *slhSha2) prfMsg(skPrf, optRand, msg []byte) []byte {
+	newHash, _ := s.big()
+	mac := hmac.New(newHash, skPrf)
+	mac.Write(optRand)
+	mac.Write(msg)
+	return mac.Sum(nil)[:s.n]
+}
+
+// hMsg MGF1-SHA-X(R || PK.seed || SHA-X(R || PK.seed || PK.root || M), m)
+func (s *slhSha2) hMsg(r, pkSeed, pkRoot, msg []byte) []byte {
+	newHash, _ := s.big()
+	d := newHash()
+	d.Write(r)
+	d.Write(pkSeed)
+	d.Write(pkRoot)
+	d.Write(msg)
+
+	seed := make([]byte, 0, len(r)+len(pkSeed)+d.Size()+4)
+	seed = append(seed, r...)
+	seed = append(seed, pkSeed...)
+	seed = d.Sum(seed)
+	return mgf1(newHash, seed, s.m)
+}
+
+func (s *slhSha2) prf(dst, pkSeed, skSeed []byte, a *adrs) []byte {
+	return s.tweak(dst, s.small, sha256.BlockSize, pkSeed, a, skSeed, nil)
+}
+
+func (s *slhSha2) f(dst, pkSeed []byte, a *adrs, m []byte) []byte {
+	return s.tweak(dst, s.small, sha256.BlockSize, pkSeed, a, m, nil)
+}
+
+func (s *slhSha2) h(dst, pkSeed []byte, a *adrs, left, right []byte) []byte {
+	_, block := s.big()
+	return s.tweak(dst, s.large, block, pkSeed, a, left, right)
+}
+
+func (s *slhSha2) t(dst, pkSeed []byte, a *adrs, m []byte) []byte {
+	_, block := s.big()
+	return s.tweak(dst, s.large, block, pkSeed, a, m, nil)
+}
+
+// mgf1 见 RFC 8017 B.2.1
+func mgf1(newHash func() stdhash.Hash, seed []byte, length int) []byte {
+	res := make([]byte, 0, length+newHash().Size())
+	counter := make([]byte, 4)
+	for i := uint32(0); len(res) < length; i++ {
+		binary.BigEndian.PutUint32(counter, i)
+		d := newHash()
+		d.Write(seed)
+		d.Write(counter)
+		res = d.Sum(res)
+	}
+	return res[:length]
+}
@@ -0,0 +1,176 @@
+package signature
+
+import "github.com/junhaideng/sphincs/common"
+
+// SLH-DSA 中的 WOTS+，XMSS 以及 hyper tree，见 FIPS 205 第 5，6，7 节
+// 和 SPHINCS-256 不同的是，链上的每一步都使用 F(PK.seed, ADRS, ·)，不再异或掩码
+// 最底层的 XMSS 树层数为 0，根节点所在的层数为 d-1
+
+// chain 从第 i 步开始，计算 steps 次 F，结果写回 x
+func (s *SLHDSA) chain(x []byte, i, steps uint32, pkSeed []byte, a *adrs) {
+	for j := i; j < i+steps; j++ {
+		a.setHash(j)
+		s.hash.f(x[:0], pkSeed, a, x)
+	}
+}
+
+// wotsDigits 将 n bytes 的消息转换成 len 个 base w 的数字，包括校验和
+func (s *SLHDSA) wotsDigits(m []byte) []uint32 {
+	digits := base2b(m, s.lgw, s.len1)
+	w := uint32(1) << s.lgw
+
+	var csum uint32
+	for _, v := range digits {
+		csum += w - 1 - v
+	}
+	// 左移使得校验和的最高位对齐到 byte
+	csum <<= uint(8-s.len2*s.lgw%8) % 8
+	size := (s.len2*s.lgw + 7) / 8
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(csum)
+		csum >>= 8
+	}
+	return append(digits, base2b(b, s.lgw, s.len2)...)
+}
+
+// wotsSk 生成第 i 条链的私钥，追加到 dst 后面
+func (s *SLHDSA) wotsSk(dst, skSeed, pkSeed []byte, a *adrs, i uint32) []byte {
+	skAdrs := *a
+	skAdrs.setTypeAndClear(adrsWotsPrf)
+	skAdrs.setKeyPair(a.keyPair())
+	skAdrs.setChain(i)
+	return s.hash.prf(dst, pkSeed, skSeed, &skAdrs)
+}
+
+// wotsPk 将 len 条链的末端压缩成 WOTS+ 公钥
+func (s *SLHDSA) wotsPk(tmp, pkSeed []byte, a *adrs) []byte {
+	pkAdrs := *a
+	pkAdrs.setTypeAndClear(adrsWotsPk)
+	pkAdrs.setKeyPair(a.keyPair())
+	return s.hash.t(nil, pkSeed, &pkAdrs, tmp)
+}
+
+// wotsPkGen 生成 WOTS+ 公钥
+func (s *SLHDSA) wotsPkGen(skSeed, pkSeed []byte, a *adrs) []byte {
+	w := uint32(1) << s.lgw
+	tmp := make([]byte, 0, s.len*s.n)
+	for i := 0; i < s.len; i++ {
+		tmp = s.wotsSk(tmp, skSeed, pkSeed, a, uint32(i))
+		a.setChain(uint32(i))
+		s.chain(tmp[i*s.n:], 0, w-1, pkSeed, a)
+	}
+	return s.wotsPk(tmp, pkSeed, a)
+}
+
+// wotsSign 对 n bytes 的消息进行签名
+func (s *SLHDSA) wotsSign(m, skSeed, pkSeed []byte, a *adrs) []byte {
+	digits := s.wotsDigits(m)
+	signature := make([]byte, 0, s.len*s.n)
+	for i, v := range digits {
+		signature = s.wotsSk(signature, skSeed, pkSeed, a, uint32(i))
+		a.setChain(uint32(i))
+		s.chain(signature[i*s.n:], 0, v, pkSeed, a)
+	}
+	return signature
+}
+
+// wotsPkFromSig 根据签名计算出 WOTS+ 公钥
+func (s *SLHDSA) wotsPkFromSig(signature, m, pkSeed []byte, a *adrs) []byte {
+	w := uint32(1) << s.lgw
+	digits := s.wotsDigits(m)
+	tmp := make([]byte, 0, s.len*s.n)
+	for i, v := range digits {
+		a.setChain(uint32(i))
+		tmp = append(tmp, signature[i*s.n:(i+1)*s.n]...)
+		s.chain(tmp[i*s.n:], v, w-1-v, pkSeed, a)
+	}
+	return s.wotsPk(tmp, pkSeed, a)
+}
+
+// xmssNode 计算 XMSS 树中高度为 z，索引为 i 的节点
+func (s *SLHDSA) xmssNode(skSeed []byte, i uint32, z int, pkSeed []byte, a *adrs) []byte {
+	if z == 0 {
+		a.setTypeAndClear(adrsWotsHash)
+		a.setKeyPair(i)
+		return s.wotsPkGen(skSeed, pkSeed, a)
+	}
+	left := s.xmssNode(skSeed, 2*i, z-1, pkSeed, a)
+	right := s.xmssNode(skSeed, 2*i+1, z-1, pkSeed, a)
+	a.setTypeAndClear(adrsTree)
+	a.setTreeHeight(uint32(z))
+	a.setTreeIndex(i)
+	return s.hash.h(left[:0], pkSeed, a, left, right)
+}
+
+// xmssSign 使用索引为 idx 的 WOTS+ 密钥对 m 进行签名，后面附上鉴权路径
+func (s *SLHDSA) xmssSign(m, skSeed []byte, idx uint32, pkSeed []byte, a *adrs) []byte {
+	auth := make([]byte, 0, s.hp*s.n)
+	for j := 0; j < s.hp; j++ {
+		sibling := idx>>j ^ 1
+		auth = append(auth, s.xmssNode(skSeed, sibling, j, pkSeed, a)...)
+	}
+	a.setTypeAndClear(adrsWotsHash)
+	a.setKeyPair(idx)
+	return append(s.wotsSign(m, skSeed, pkSeed, a), auth...)
+}
+
+// xmssPkFromSig 根据签名计算出 XMSS 树的根节点
+func (s *SLHDSA) xmssPkFromSig(idx uint32, signature, m, pkSeed []byte, a *adrs) []byte {
+	a.setTypeAndClear(adrsWotsHash)
+	a.setKeyPair(idx)
+	node := s.wotsPkFromSig(signature[:s.len*s.n], m, pkSeed, a)
+	auth := signature[s.len*s.n:]
+
+	a.setTypeAndClear(adrsTree)
+	a.setTreeIndex(idx)
+	for k := 0; k < s.hp; k++ {
+		a.setTreeHeight(uint32(k + 1))
+		block := auth[k*s.n : (k+1)*s.n]
+		if idx>>k&1 == 0 {
+			a.setTreeIndex(a.treeIndex() / 2)
+			node = s.hash.h(node[:0], pkSeed, a, node, block)
+		} else {
+			a.setTreeIndex((a.treeIndex() - 1) / 2)
+			node = s.hash.h(node[:0], pkSeed, a, block, node)
+		}
+	}
+	return node
+}
+
+// htSign 使用 hyper tree 对 m 进行签名，从第 0 层开始
+func (s *SLHDSA) htSign(m, skSeed, pkSeed []byte, tree uint64, leaf uint32) []byte {
+	size := (s.hp + s.len) * s.n
+	signature := make([]byte, 0, s.d*size)
+	var a adrs
+	for j := 0; j < s.d; j++ {
+		if j > 0 {
+			leaf = uint32(tree & (1<<s.hp - 1))
+			tree >>= s.hp
+		}
+		a.setLayer(uint32(j))
+		a.setTree(tree)
+		sig := s.xmssSign(m, skSeed, leaf, pkSeed, &a)
+		signature = append(signature, sig...)
+		if j < s.d-1 {
+			m = s.xmssPkFromSig(leaf, sig, m, pkSeed, &a)
+		}
+	}
+	return signature
+}
+
+// htVerify 校验 hyper tree 签名，最后得到的根节点应该和 PK.root 一致
+func (s *SLHDSA) htVerify(m, signature, pkSeed []byte, tree uint64, leaf uint32, pkRoot []byte) bool {
+	size := (s.hp + s.len) * s.n
+	var a adrs
+	for j := 0; j < s.d; j++ {
+		if j > 0 {
+			leaf = uint32(tree & (1<<s.hp - 1))
+			tree >>= s.hp
+		}
+		a.setLayer(uint32(j))
+		a.setTree(tree)
+		m = s.xmssPkFromSig(leaf, signature[j*size:(j+1)*size], m, pkSeed, &a)
+	}
+	return common.Equal(m, pkRoot)
+}
@@ -0,0 +1,219 @@
+package signature
+
+import (
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testdata/slhdsa 中的向量来自 NIST ACVP-Server v1.1.0.38
+// gen-val/json-files/SLH-DSA-{keyGen,sigGen,sigVer}-FIPS205
+// 为了控制大小，每一组只保留了少量用例，并且将 prompt 和 expectedResults 合并到了一起
+
+type hexBytes []byte
+
+func (h *hexBytes) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(s)
+	*h = v
+	return err
+}
+
+type acvpGroup struct {
+	TgID          int    `json:"tgId"`
+	ParameterSet  string `json:"parameterSet"`
+	Deterministic bool   `json:"deterministic"`
+	SigInterface  string `json:"signatureInterface"`
+	PreHash       string `json:"preHash"`
+	Tests         []struct {
+		TcID       int      `json:"tcId"`
+		SkSeed     hexBytes `json:"skSeed"`
+		SkPrf      hexBytes `json:"skPrf"`
+		PkSeed     hexBytes `json:"pkSeed"`
+		Sk         hexBytes `json:"sk"`
+		Pk         hexBytes `json:"pk"`
+		Message    hexBytes `json:"message"`
+		Context    hexBytes `json:"context"`
+		HashAlg    string   `json:"hashAlg"`
+		AddRand    hexBytes `json:"additionalRandomness"`
+		Signature  hexBytes `json:"signature"`
+		TestPassed bool     `json:"testPassed"`
+	} `json:"tests"`
+}
+
+func readACVP(t *testing.T, name string) []acvpGroup {
+	f, err := os.Open(filepath.Join("testdata", "slhdsa", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		TestGroups []acvpGroup `json:"testGroups"`
+	}
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v.TestGroups
+}
+
+// 参数集 s 的签名较慢，-short 时跳过
+func newACVPSLHDSA(t *testing.T, name string, opts ...Option) *SLHDSA {
+	if testing.Short() && strings.HasSuffix(name, "s") {
+		t.Skip("skip small parameter set in short mode")
+	}
+	s, err := NewSLHDSA(name, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSLHDSAKeyGenACVP(t *testing.T) {
+	for _, g := range readACVP(t, "keyGen.json.gz") {
+		g := g
+		t.Run(g.ParameterSet, func(t *testing.T) {
+			s := newACVPSLHDSA(t, g.ParameterSet)
+			for _, c := range g.Tests {
+				sk, pk := s.keyGenInternal(c.SkSeed, c.SkPrf, c.PkSeed)
+				assert.Equal(t, []byte(c.Sk), sk, "tcId %d", c.TcID)
+				assert.Equal(t, []byte(c.Pk), pk, "tcId %d", c.TcID)
+			}
+		})
+	}
+}
+
+func TestSLHDSASigGenACVP(t *testing.T) {
+	for _, g := range readACVP(t, "sigGen.json.gz") {
+		g := g
+		t.Run(g.ParameterSet, func(t *testing.T) {
+			s := newACVPSLHDSA(t, g.ParameterSet, WithDeterministic(g.Deterministic))
+			for _, c := range g.Tests {
+				if !g.Deterministic {
+					s.r = strings.NewReader(string(c.AddRand))
+				}
+				var sig []byte
+				var err error
+				switch {
+				case g.SigInterface == "internal":
+					addrnd := c.Sk[2*s.n : 3*s.n]
+					if !g.Deterministic {
+						addrnd = c.AddRand
+					}
+					sig = s.signInternal(c.Message, c.Sk, addrnd)
+				case g.PreHash == "preHash":
+					sig, err = s.HashSign(c.Message, c.Context, c.HashAlg, c.Sk)
+				default:
+					sig, err = s.SignWithContext(c.Message, c.Context, c.Sk)
+				}
+				assert.Nil(t, err, "tgId %d tcId %d", g.TgID, c.TcID)
+				assert.Equal(t, hex.EncodeToString(c.Signature), hex.EncodeToString(sig), "tgId %d tcId %d", g.TgID, c.TcID)
+			}
+		})
+	}
+}
+
+func TestSLHDSASigVerACVP(t *testing.T) {
+	for _, g := range readACVP(t, "sigVer.json.gz") {
+		g := g
+		t.Run(g.ParameterSet, func(t *testing.T) {
+			s := newACVPSLHDSA(t, g.ParameterSet)
+			for _, c := range g.Tests {
+				var ok bool
+				switch {
+				case g.SigInterface == "internal":
+					ok = s.verifyInternal(c.Message, c.Pk, c.Signature)
+				case g.PreHash == "preHash":
+					ok = s.HashVerify(c.Message, c.Context, c.HashAlg, c.Pk, c.Signature)
+				default:
+					ok = s.VerifyWithContext(c.Message, c.Context, c.Pk, c.Signature)
+				}
+				assert.Equal(t, c.TestPassed, ok, "tgId %d tcId %d", g.TgID, c.TcID)
+			}
+		})
+	}
+}
+
+func TestSLHDSASignature(t *testing.T) {
+	assert := assert.New(t)
+
+	var s Signature
+	s, err := NewSLHDSA("SLH-DSA-SHAKE-128f")
+	assert.Nil(err)
+	sk, pk := s.GenerateKey()
+	assert.Equal(64, len(sk))
+	assert.Equal(32, len(pk))
+
+	msg := []byte("hello world")
+	sig := s.Sign(msg, sk)
+	assert.Equal(17088, len(sig))
+	assert.True(s.Verify(msg, pk, sig))
+	assert.False(s.Verify([]byte("hello world!"), pk, sig))
+
+	sig[len(sig)-1] ^= 1
+	assert.False(s.Verify(msg, pk, sig))
+	assert.False(s.Verify(msg, pk, sig[:len(sig)-1]))
+
+	_, err = NewSLHDSA("SLH-DSA-SHA2-512f")
+	assert.NotNil(err)
+}
+
+func TestSLHDSAContext(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewSLHDSA("SLH-DSA-SHA2-128f")
+	assert.Nil(err)
+	sk, pk := s.GenerateKey()
+	msg := []byte("hello world")
+
+	sig, err := s.SignWithContext(msg, []byte("context"), sk)
+	assert.Nil(err)
+	assert.True(s.VerifyWithContext(msg, []byte("context"), pk, sig))
+	assert.False(s.VerifyWithContext(msg, []byte("other"), pk, sig))
+	assert.False(s.Verify(msg, pk, sig))
+
+	_, err = s.SignWithContext(msg, make([]byte, 256), sk)
+	assert.Equal(ErrContextTooLong, err)
+	_, err = s.HashSign(msg, nil, "MD5", sk)
+	assert.Equal(ErrPreHashNotSupport, err)
+
+	// 确定性签名对同一个消息的签名是相同的，并且可以用默认的实例校验
+	assert.False(s.Deterministic())
+	det, err := NewSLHDSA("SLH-DSA-SHA2-128f", WithDeterministic(true))
+	assert.Nil(err)
+	assert.True(det.Deterministic())
+	sig = det.Sign(msg, sk)
+	assert.Equal(sig, det.Sign(msg, sk))
+	assert.True(s.Verify(msg, pk, sig))
+}
+
+func TestSLHDSAParameterSets(t *testing.T) {
+	assert := assert.New(t)
+
+	// FIPS 205 表2 中的签名大小
+	sizes := map[string]int{
+		"SLH-DSA-SHA2-128s": 7856, "SLH-DSA-SHA2-128f": 17088,
+		"SLH-DSA-SHA2-192s": 16224, "SLH-DSA-SHA2-192f": 35664,
+		"SLH-DSA-SHA2-256s": 29792, "SLH-DSA-SHA2-256f": 49856,
+	}
+	names := SLHDSAParameterSets()
+	assert.Equal(12, len(names))
+	for _, name := range names {
+		s, err := NewSLHDSA(name)
+		assert.Nil(err)
+		assert.Equal(name, s.Name())
+		assert.Equal(sizes[strings.Replace(name, "SHAKE", "SHA2", 1)], s.SignatureSize(), name)
+	}
+}
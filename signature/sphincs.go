@@ -1,14 +1,17 @@
 package signature
 
 import (
-	"encoding/binary"
 	"errors"
+	"io"
 	"math"
+	"math/bits"
+	"runtime"
+	"sync"
 
 	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
 	"github.com/junhaideng/sphincs/merkle"
-	"github.com/junhaideng/sphincs/rand"
+	"github.com/junhaideng/sphincs/suite"
 )
 
 // SPHINCS-256
@@ -19,18 +22,31 @@ import (
 // w: 16 = 2^4
 // tau: 16 -> t = 2^16
 // k: 32
-// 签名大小 41000 bytes
-// pk 1056 bytes
-// sk 1088 bytes
-// TODO: 论文中声明的签名大小 p 取的是 32？ 2*h 都大于 2*tau了
-// 论文不一定正确 ):
+// 签名大小 41000 bytes (SignatureSize)
+// pk 1056 bytes (PublicKeySize)
+// sk 1088 bytes (PrivateKeySize)
+// 掩码的个数 p = max{w-1, 2(h/d+ceil(log l)), 2 tau} = 32
+// 每一层的 binary hash tree 使用同一组掩码，所以这里是 h/d 而不是 h
+//
+// 密钥和签名的格式和参考实现保持一致
+// sk = (SK1, Q, SK2)
+// pk = (Q, PK1)
+// σ  = (R1, i, σH, σW,0, Auth_{A_0}, ..., σ_{W,d-1}, Auth_{A_{d-1}})
+// 其中 i 占 ceil(h/8) bytes，小端序
+//
+// 其他参数集合见 SphincsParams，n 不是 256 时 F 和 H 的输出截取为 n bits (见 hash.NewF)
+// 哈希函数默认为论文中的 suite.SPHINCS256，可以通过 WithHashSuite 替换
+// 索引 i 以及 HORST 的摘要值都按 bit 拆分 (见 common.ReadBits)，所以 w 和 tau 不需要整除 8
 
 // Sphincs .
+// 结构体中只保存参数，掩码在签名时从 sk 中读取，校验时从 pk 中读取
+// 所以同一个实例可以在多个 goroutine 中同时对不同的密钥进行签名和校验
 // 注意！！！！
 // 这里的 d 算层数的时候，根节点的层数为 d-1
 // WOTS+ 的叶子节点层数记为 0
 // HORST 所在的位置层数记为 d
 // 这里为了简单，不设置缓存，仅提供方法思路
+// 不同层以及同一个大 node 中的 WOTS+ 密钥对互相独立，生成密钥和签名时通过 WithWorkers 并行计算
 // YOU CAN DO:
 // 1. 设置缓存，避免重复计算 (在计算过程中，很多部分可能是重复的，同一棵 HORST 下的私钥，可能对应同一棵 WOTS 节点，那么这两个密钥对很大部分内容的计算都是一致的)
 type Sphincs struct {
@@ -43,402 +59,420 @@ type Sphincs struct {
 	// t = 2^tau 保存 tau 更加方便
 	k    uint64 // 在 HORST 签名中公开的私钥元素个数
 	seed []byte
-	r    rand.Rander
-	p    uint64 // 掩码的个数
-	mask [][]byte
+	// 生成密钥时使用，mu 保证并发调用 GenerateKey 时顺序读取
+	mu sync.Mutex
+	r  io.Reader
+	p  uint64 // 掩码的个数
 	//
-	ltree     uint64 // l-tree 需要使用的掩码部分
-	l         uint64 // l wots+ 中的签名块数
-	signature Signature
+	ltree uint64 // l-tree 需要使用的掩码部分
+	l     uint64 // l wots+ 中的签名块数
+	// suite 哈希函数族，F 和 H 由其生成，输出为 n bits
+	suite   suite.HashSuite
+	hashF   *hash.Hasher
+	hashH   *hash.Hasher
+	lengths SphincsSizes
+	// workers 见 WithWorkers，0 时使用 GOMAXPROCS
+	workers int
 }
 
 // NewSphincs 创建一个新的签名算法
-func NewSphincs(n, m, h, d, w, tau, k uint64, seed []byte) (*Sphincs, error) {
+// 这里的 w 为 Winternitz 参数的指数，例如 SPHINCS-256 中 w = 4，需要满足 tau*k = m
+// 其余要求见 SphincsParams.Validate
+// seed 不为空时用于初始化生成密钥的随机数生成器 G_λ (ChaCha12)，见 rand.New
+// seed 为空时使用 crypto/rand，也可以通过 WithRand 设置熵源
+func NewSphincs(n, m, h, d, w, tau, k uint64, seed []byte, opts ...Option) (*Sphincs, error) {
 	//
 	if tau*k != m {
 		return nil, errors.New("tau *k 应该等于 m")
 	}
-
-	if h > 64 {
-		// h 如果过大，签名的时候截取 h bit 时，无法保存到一个整数中
-		panic("h 过大")
+	if w == 0 || w > 8 {
+		return nil, &ParamsError{Param: "w", Reason: "指数应该在 1 到 8 之间"}
+	}
+	// 避免转换成 int 之后溢出
+	for _, v := range []uint64{n, h, d, tau, k} {
+		if v > math.MaxInt32 {
+			return nil, common.ErrSizeNotSupport
+		}
 	}
+	p := SphincsParams{N: int(n), H: int(h), D: int(d), W: 1 << w, Tau: int(tau), K: int(k)}
+	return NewSphincsWithParams(p, seed, opts...)
+}
 
-	if h%d != 0 {
-		panic("h 应该时 d 的倍数")
+// NewSphincsWithParams 使用任意合法的参数集合创建签名算法，例如 n = 128, w = 16, tau = 15, h = 66
+// 参数不合法时返回 *ParamsError
+// WithWorkers 设置生成密钥和签名时使用的 goroutine 数，默认为 GOMAXPROCS
+// WithHashSuite 设置哈希函数族，默认为 suite.SPHINCS256，使用其他哈希函数族时标识 (Identifier) 中包含它的编号
+func NewSphincsWithParams(p SphincsParams, seed []byte, opts ...Option) (*Sphincs, error) {
+	sizes, err := p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	o := applyOptions(opts)
+	st := o.Suite
+	if st == nil {
+		st = suite.SPHINCS256
 	}
-	if n != 256 && m != 512 {
-		// 因为需要使用的哈希函数生成的哈希值长度不同
-		// 这里仅仅采用了 256 和 512 的
-		panic("暂未实现该参数")
+	if !st.Supports(p.N) {
+		return nil, &ParamsError{Param: "n", Reason: "哈希函数族 " + st.Name() + " 不支持"}
 	}
 	sphincs := &Sphincs{
-		n:    n,
-		m:    m,
-		h:    h,
-		d:    d,
-		w:    w,
-		tau:  tau,
-		k:    k,
-		seed: seed,
-		r:    rand.New(seed),
+		n:       uint64(p.N),
+		m:       uint64(sizes.M),
+		h:       uint64(p.H),
+		d:       uint64(p.D),
+		w:       uint64(bits.Len(uint(p.W)) - 1),
+		tau:     uint64(p.Tau),
+		k:       uint64(p.K),
+		seed:    seed,
+		r:       seededRand(seed, opts),
+		p:       uint64(sizes.P),
+		ltree:   2 * uint64(ceilLog2(sizes.L)),
+		l:       uint64(sizes.L),
+		suite:   st,
+		hashF:   st.F(p.N),
+		hashH:   st.H(p.N),
+		lengths: sizes,
+		workers: o.Workers,
 	}
-	sphincs.calculateP()
-
 	return sphincs, nil
 }
 
-func (s *Sphincs) GenerateKey() ([]byte, []byte) {
-	// 首先取两个私钥值
-	// SK_1
-	// for pseudorandom key generation
-	sk1 := make([]byte, s.n/8)
+// Params 返回参数集合
+func (s *Sphincs) Params() SphincsParams {
+	return SphincsParams{N: int(s.n), H: int(s.h), D: int(s.d), W: 1 << s.w, Tau: int(s.tau), K: int(s.k)}
+}
+
+// Sizes 返回密钥和签名的大小
+func (s *Sphincs) Sizes() SphincsSizes {
+	return s.lengths
+}
 
+func (s *Sphincs) GenerateKey() ([]byte, []byte) {
+	size := s.n / 8
+	// sk = (SK1, Q, SK2)，一次性从随机数生成器中读取
+	// SK_1 for pseudorandom key generation
+	// Q 为 p 个掩码，每一份大小为 n/8
 	// SK_2
 	// 1. unpredictable index in `sign`
 	// 2. pseudorandom values to randomize the message hash in sign
-	sk2 := make([]byte, s.n/8)
-
-	s.r.Read(sk1)
-	s.r.Read(sk2)
-
-	// 生成 p 个掩码，每一份大小为 n/8
-	for i := uint64(0); i < s.p; i++ {
-		s.mask[i] = make([]byte, s.n/8)
-		s.r.Read(s.mask[i])
+	sk := make([]byte, (2+s.p)*size)
+	s.mu.Lock()
+	_, err := io.ReadFull(s.r, sk)
+	s.mu.Unlock()
+	if err != nil {
+		panic(err)
 	}
+	return sk, s.publicKey(sk)
+}
+
+// publicKey 由 sk 计算 pk = (Q, PK1)
+func (s *Sphincs) publicKey(sk []byte) []byte {
+	size := s.n / 8
+	mask := sk[size : (1+s.p)*size]
 
 	// 生成根节点
 	// 注意了，在 SPHINCS 中，根节点的层数为 s.d-1，最下面的 WOTS+ 密钥对层为 0
 	// 最底层的 HORST 密钥对层记为 d 层
-	layer := s.d - 1
-
-	// 在 SPHINCS virtual structure 中的一个节点
-	// 并不是说只有一个 node，而是很多个 node
-	// 这些 node 组成一个 binary hash tree
-	// 根节点是 WOTS+ pk 构成的 l-tree 的根节点
-	// 然后这些所有的根节点通过 binary hash tree
-	// 得到 virtual structure 中的一个节点
-	leaf := make([][]byte, 1<<(s.h/s.d)-1)
-	leaf_ := make([][]byte, 1<<(s.h/s.d)-1)
-	for i := uint64(0); i < 1<<(s.h/s.d); i++ {
-		address := s.address(layer, 0, i)
-		seed := hash.FuncAlpha(address, sk1)
-
-		// 获取到 WOTS+ l 个 pk 块构成的 L-Tree 的根节点
-		wots, err := NewWOTSPlusSignature(int(s.w), Size(s.n), seed, common.Flatten(s.getMask(WOTS_Mask)))
-		if err != nil {
-			panic(err)
-		}
-
-		_, pk := wots.GenerateKey()
-		leaf = append(leaf, hash.Sha256(merkle.LTreeWithMask(pk, int(s.n), hash.F, common.Flatten(s.getMask(LTREE_Mask)))))
-		leaf_ = append(leaf_, merkle.LTreeWithMask(pk, int(s.n), hash.F, common.Flatten(s.getMask(LTREE_Mask))))
-		//fmt.Printf("1. leaf: %x\n", merkle.LTreeWithMask(pk, int(s.n), hash.F, common.Flatten(s.getMask(LTREE_Mask))))
-	}
-
-	// 得到所有的叶子 node 之后，计算得到 binary hash tree 的根节点
-	// 其实也是一个 L-Tree ，只不过比较特殊罢了
-	// 这里我们只需要计算根节点，就不使用 Merkel Tree 结构计算了
-	// 不过也是可以的，不过比较重，因为会需要消耗更多的资源
-	// 一般用于 Authentication Path 的计算
-	// 使用 L-Tree 需要上面的 leaf 求 hash
-
-	// 这里使用的掩码是 Q_{L-Tree} 后面的 2h 个
 	// root 即论文中的 PK1
+	root := s.subtree(sk[:size], mask, s.d-1, 0)
 
-	mask := s.getMask(TREE_Mask)
-	root := merkle.LTreeWithMask(common.Flatten(leaf), int(s.n), hash.Sha256, common.Flatten(mask[len(mask)-int(2*(s.h/s.d)):]))
-
-	//fmt.Printf("root: %x\n", root)
-	// sk = (SK_1, SK_2, Q)
-	sk := make([]byte, 0, (s.n+s.n+s.n*s.p)/8) // (2+p) * n bits
-
-	sk = append(sk, sk1...)
-	sk = append(sk, sk2...)
-	sk = append(sk, common.Flatten(s.mask)...)
-
-	// pk = (PK1, Q)
-	pk := make([]byte, 0, (s.n+s.n*s.p)/8) // (1+p) * n bits
+	// pk = (Q, PK1)
+	pk := make([]byte, 0, (1+s.p)*size) // (1+p) * n bits
+	pk = append(pk, mask...)
 	pk = append(pk, root...)
-	pk = append(pk, common.Flatten(s.mask)...)
-
-	return sk, pk
+	return pk
 }
 
+// Sign sk 的长度不等于 PrivateKeySize 时 panic(common.ErrSizeNotMatch)，不希望 panic 时使用 SignErr
 func (s *Sphincs) Sign(message []byte, sk []byte) []byte {
-	// 我们可以首先计算出签名的大小
-	// signature = (i, R1, σH, σW,0, Auth_{A_0}, ..., σ_{W,d-1}, Auth_{A_{d-1}}
-	// i 为 8 bytes，R1 为 n/8 bytes
-	// σH 大小为 (h.k+(h.tau-h.x)*h.k+1<<h.x)*int(h.n)/8 bytes
-	// σw =
-	signature := make([]byte, 0, 0)
+	signature, err := s.SignErr(message, sk)
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
 
-	// 取出 sk1
-	sk1 := sk[0 : s.n/8]
+// SignErr 和 Sign 一样，但是 sk 的长度不对时返回 common.ErrSizeNotMatch
+func (s *Sphincs) SignErr(message []byte, sk []byte) ([]byte, error) {
+	if len(sk) != s.PrivateKeySize() {
+		return nil, common.ErrSizeNotMatch
+	}
+	return s.sign(message, sk), nil
+}
 
-	// 1. 对于任意长度的消息，计算 randomized message digest
-	// 首先计算出伪随机数 R= (R1, R2) = {0,1}^512
-	r := hash.Func(message, sk1)
+// sign sk 的长度已经检查过
+func (s *Sphincs) sign(message []byte, sk []byte) []byte {
+	size := s.n / 8
+	// 取出 sk1 和 sk2
+	sk1 := sk[:size]
+	sk2 := sk[uint64(len(sk))-size:]
+	// 掩码 Q
+	mask := sk[size : (1+s.p)*size]
 
-	// 随机摘要值
-	d := hash.HashMessage(r[:s.n/8], message)
+	// 1. 对于任意长度的消息，计算伪随机数 R = F(M, SK2) = {0,1}^512
+	r := s.suite.PRF(sk2, message, 64)
 
 	// 2. 截取 h bits 的值，来选择一个 HORST 密钥对
-	index, bytes := common.Chop(r[s.n/8:s.n/4], s.h)
-
-	// 3. 计算出 HORST 地址
-	bits := (s.d - 1) * s.h / s.d
-	address := s.address(s.d, common.Cut(index, s.h, 0, bits), common.Cut(index, s.h, bits, s.h/s.d))
-
-	// 4. 计算出随机数种子
-	seed := hash.FuncAlpha(address, sk1)
-
-	// 5. 生成 (σ,pkH)
-	horst, err := NewHorstSignature(int(s.tau), int(s.k), seed, common.Flatten(s.getMask(HORST_Mask)))
-
-	if err != nil {
-		panic(err)
+	// 和参考实现一致，取 R 的前 ceil(h/8) bytes 作为小端序的索引，多余的高位置 0，R1 取 R[16:16+n/8]
+	// h 可能超过 64，所以索引保持为字节串，按 bit 读取每一层的位置
+	index := s.index(r)
+	r1 := r[16 : 16+size]
+
+	// 3. 计算 d 层中每一个大 node 的所有叶子节点以及 HORST 树的叶子节点
+	// 这些计算只依赖于索引 i，互相独立，放在同一个 worker pool 中
+	leafBits := s.h / s.d
+	num := 1 << leafBits
+	trees := make([]uint64, s.d)
+	for j := range trees {
+		// 第 j 层中，大 node 的索引为 i 的高 (d-1-j)h/d bits
+		trees[j] = common.ReadBits(index, uint64(j+1)*leafBits, s.h-uint64(j+1)*leafBits)
 	}
-	s.signature = horst
-	sk, pkH := horst.GenerateKey()
-	//fmt.Printf("pkH: \n%x\n", pkH)
-	// HORST 签名
-	sigma := horst.Sign(d, sk)
-
-	//fmt.Printf("d: %x\n", d)
-	//fmt.Printf("signature: \n%x\n", sigma)
-
-	// signature = (i, R1, σH, σW,0, Auth_{A_0}, ..., σ_{W,d-1}, Auth_{A_{d-1}}
-	// i
-	// TODO 在这里实现的时候 bytes 大小实际上已经被确定了，并不是严格的 h bits
-	//  并且在实现的时候不可能出现最小单位为比特的变量
-	// 因为我们需要考虑到 h 不是 8 的倍数，在实际编程的时候并不方便
-	signature = append(signature, bytes...)
-	// R1
-	signature = append(signature, r[:s.n/8]...)
-
-	// σH
-	signature = append(signature, sigma...)
-
-	// 计算 0 到 d-1 层的 σ 以及对应的 鉴权路径
-	for j := uint64(0); j < s.d; j++ {
-		// 计算 address
-		tmp := (s.d - 1 - j) * s.h / s.d
-		address := s.address(j, common.Cut(index, s.h, 0, tmp), common.Cut(index, s.h, tmp, s.h/s.d))
-		seed := hash.FuncAlpha(address, sk1)
-		wots, err := NewWOTSPlusSignature(int(s.w), Size(s.n), seed, common.Flatten(s.getMask(WOTS_Mask)))
-		if err != nil {
-			panic(err)
-		}
-		//sk, p_ := wots.GenerateKey()
-		sk, _ := wots.GenerateKey()
-		//fmt.Printf("1. wots pk, index: %d, key: %x\n", j, p_)
-		//pkW := merkle.LTreeWithMask(p_, int(s.n), hash.F, common.Flatten(s.getMask(LTREE_Mask)))
-		//fmt.Printf("1. wots 公钥的L-Tree根节点, index: %d, key: %x\n", j, pkW)
-
-		// 对 pkH 进行签名
-		sign := wots.Sign(pkH, sk)
-
-		sks := make([][]byte, 1<<(s.h/s.d))
-		// 将这一个大 node 计算出来
-		// 层数为 j，index 为 i(0, (d-1-j)h/d)
-		// 这一个大 node 一共 1<<h/d-1 个 WOTS 密钥对
-		// 类似 GenerateKey 中的
-		for i := uint64(0); i < 1<<(s.h/s.d); i++ {
-			//if j == s.d-1 {
-			//	fmt.Println("----", common.Cut(index, s.h, 0, tmp), common.Cut(index, s.h, tmp, s.h/s.d), i)
-			//}
-			address := s.address(j, common.Cut(index, s.h, 0, tmp), i)
-			seed := hash.FuncAlpha(address, sk1)
-			wots, err := NewWOTSPlusSignature(int(s.w), Size(s.n), seed, common.Flatten(s.getMask(WOTS_Mask)))
-			if err != nil {
-				panic(err)
+	horst := s.horst(sk1, mask, trees[0], common.ReadBits(index, 0, leafBits))
+	skH := horst.secretKey()
+	// HORST 的叶子节点很多，每 horstChunk 个作为一个任务，写入同一块内存
+	t := 1 << s.tau
+	chunks := (t + horstChunk - 1) / horstChunk
+	horstLeaves := make([]byte, t*int(size))
+	leaves := make([][]byte, int(s.d)*num)
+	parallel(s.concurrency(), chunks+len(leaves), func(i int) {
+		if i < chunks {
+			// 每个任务使用单独的 Hasher
+			leaf := horst.leaves(skH)
+			for j := i * horstChunk; j < t && j < (i+1)*horstChunk; j++ {
+				copy(horstLeaves[j*int(size):], leaf(j))
 			}
-			_, pk := wots.GenerateKey()
-			// 对 pk 求 L-Tree 的根节点
-			root := merkle.LTreeWithMask(pk, int(s.n), hash.F, common.Flatten(s.getMask(LTREE_Mask)))
-			sks[i] = root
-			//if j == s.d-1 {
-			//	fmt.Printf("2. leaf: %x\n", root)
-			//}
+			return
 		}
-		// TODO 这里的 mask 还需要在处理一下，因为 TREE_Mask 是整棵树的掩码 done
-		// 需要计算每 layer 使用的, 当前层数为 j
-		tree, err := merkle.NewTreeWithMask(int(s.h/s.d)+1, int(s.n), common.Flatten(s.getMask(TREE_Mask)[2*j*(s.h/s.d):(2*j+2)*(s.h/s.d)]))
-		if err != nil {
-			panic(err)
-		}
-
-		//fmt.Printf("index: %d, TREE_MASK: %x\n\n", j, s.getMask(TREE_Mask)[2*j*(s.h/s.d):(2*j+2)*(s.h/s.d)])
-
-		err = tree.SetSkWithMask(common.Flatten(sks))
-		if err != nil {
-			panic(err)
+		i -= chunks
+		layer := uint64(i / num)
+		leaves[i] = s.leaf(sk1, mask, layer, trees[layer], uint64(i%num))
+	})
+	// 每一层只需要根节点以及一条鉴权路径，不需要保存整棵树
+	roots := make([][]byte, s.d)
+	auths := make([][][]byte, s.d)
+	parallel(s.concurrency(), len(roots), func(j int) {
+		leaf := int(common.ReadBits(index, uint64(j)*leafBits, leafBits))
+		roots[j], auths[j] = s.treeHash(mask).AuthenticationPath(precomputed(leaves[j*num:(j+1)*num]), 0, leaf)
+	})
+
+	// 4. 随机摘要值 D = H(R1, PK || M)
+	// 最顶层的大 node 的根节点即 PK1
+	d := s.suite.HMsg(r1, s.messageWithPk(mask, roots[s.d-1], message), 64)
+
+	// signature = (R1, i, σH, σW,0, Auth_{A_0}, ..., σ_{W,d-1}, Auth_{A_{d-1}}
+	signature := make([]byte, 0, s.SignatureSize())
+	signature = append(signature, r1...)
+	signature = append(signature, index...)
+
+	// 5. 使用选中的 HORST 密钥对 D 进行签名，同时得到 HORST 公钥
+	sigH, pkH := horst.sign(d, skH, precomputed(common.Ravel(horstLeaves, int(size))))
+	signature = append(signature, sigH...)
+
+	// 6. 第 j 层的 WOTS+ 对 HORST 公钥 (j = 0) 或者下一层的根节点进行签名
+	sigmas := make([][]byte, s.d)
+	parallel(s.concurrency(), len(sigmas), func(j int) {
+		msg := pkH
+		if j > 0 {
+			msg = roots[j-1]
 		}
-		// 添加到签名中
-		// σw
-		signature = append(signature, sign...)
+		wots := s.wots(sk1, mask, uint64(j), trees[j], common.ReadBits(index, uint64(j)*leafBits, leafBits))
+		skW, _ := wots.GenerateKey()
+		sigmas[j] = wots.sign(msg, skW)
+	})
+	for j := uint64(0); j < s.d; j++ {
+		signature = append(signature, sigmas[j]...)
 		// authentication path
-		auth := tree.AuthenticationPath(0, int(common.Cut(index, s.h, tmp, s.h/s.d)))
-
-		// 有关鉴权路径
-		//fmt.Printf("1. index: %d, pk: %x, auth: %x\n\n", j, hash.Sha256(sk), auth)
-		signature = append(signature, common.Flatten(auth)...)
-
-		pkH, _ = tree.GetPk()
-		//fmt.Printf("1. pkH: %x\n", pkH)
-		//if j == s.d-1 {
-		//	fmt.Printf("2. root d-1: %x\n", pkH)
-		//}
-
-		//start := 1<<(s.h/s.d) - 1
-		//pkH_ := merkle.ComputeRootWithMask(pkW, start+int(common.Cut(index, s.h, tmp, s.h/s.d)), auth, hash.Sha256, s.getMask(TREE_Mask)[2*j*(s.h/s.d):(2*j+2)*(s.h/s.d)])
-		//
-		////fmt.Printf("1. index: %d, pkH: %x\n", j, pkH)
-		//fmt.Println(common.Equal(pkH_, pkH))
+		signature = append(signature, common.Flatten(auths[j])...)
 	}
 
 	return signature
 }
 
 func (s *Sphincs) Verify(message []byte, pk []byte, signature []byte) bool {
+	return s.VerifyErr(message, pk, signature) == nil
+}
+
+// VerifyErr pk 和签名的大小见 PublicKeySize 以及 SignatureSize
+func (s *Sphincs) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	size := s.n / 8
+	if len(pk) != s.PublicKeySize() {
+		return ErrInvalidPublicKey
+	}
+	if len(signature) != s.SignatureSize() {
+		return ErrInvalidSignature
+	}
+	// 掩码以及根节点
+	mask := pk[:s.p*size]
+	root := pk[s.p*size:]
+
+	h, err := newHorst(int(s.tau), int(s.k), int(s.n), s.getMask(mask, HORST_Mask), s.hashF, s.hashH)
+	if err != nil {
+		panic(err)
+	}
+	wots, err := newWOTSPlus(int(s.w), int(s.n), s.getMask(mask, WOTS_Mask), s.hashF)
+	if err != nil {
+		panic(err)
+	}
+
 	// i 的大小
-	iSize := uint64(8)
-	// R1 的大小
-	r1Size := s.n / 8
-	x := uint64(calc(int(s.k), int(s.tau)))
+	iSize := (s.h + 7) / 8
 	// horst 签名大小
-	horstSize := (s.k + (s.tau-x)*s.k + 1<<x) * s.n / 8
+	horstSize := uint64(h.SignatureSize())
 	// wots+ 签名大小
-	wotsSize := s.l * s.n / 8
+	wotsSize := uint64(wots.SignatureSize())
 	// 鉴权路径大小
-	authSize := (s.h / s.d) * s.n / 8
-	//println(iSize, r1Size, x, horstSize, wotsSize, authSize)
+	authSize := (s.h / s.d) * size
 
+	r1 := signature[:size]
 	// 选择 horst 密钥的索引值
-	index := binary.BigEndian.Uint64(signature[:iSize])
-	//println(index)
-
-	// 掩码
-	//mask := pk[s.n/8:]
+	index := signature[size : size+iSize]
 
 	// 1. 对于任意长度的消息，计算 randomized message digest
+	d := s.suite.HMsg(r1, s.messageWithPk(mask, root, message), 64)
 
-	// 随机摘要值
-	d := hash.HashMessage(signature[iSize:iSize+r1Size], message)
-	//fmt.Printf("d: %x\n", d)
-
-	horstMask := pk[s.n/8 : s.n/8+s.tau*2*s.n/8]
-
-	//fmt.Printf("pk: %x\n mask: %x\n", pk, common.Flatten(s.getMask(HORST_Mask)))
-	//fmt.Println("----", common.Equal(common.Flatten(s.getMask(HORST_Mask)), mask))
-
-	h, err := newHorst(int(s.tau), int(s.k), int(s.n), horstMask)
-
-	if err != nil {
-		panic(err)
-	}
 	// 首先校验 HORST 签名
-	pkH, flag := h.verify(d, signature[iSize+r1Size:iSize+r1Size+horstSize])
-
-	//fmt.Printf("pkH: \n%x\n", pkH)
-
+	pkH, flag := h.verify(d, signature[size+iSize:size+iSize+horstSize])
 	if !flag {
-		return false
+		return ErrVerifyFailed
 	}
 
-	wotsMask := pk[s.n/8 : s.n/8+(1<<s.w-1)*s.n/8]
-	wots, err := newWOTSPlus(int(s.w), int(s.n), wotsMask)
-	// 目前来说上面的对上了
 	// 接下来需要对 WOTS+ 进行校验了
-
 	// (σW,0, Auth_{A_0}, ..., σ_{W,d-1}, Auth_{A_{d-1})
-	sigmaAndAuth := signature[iSize+r1Size+horstSize:]
+	sigmaAndAuth := signature[size+iSize+horstSize:]
 	partSize := wotsSize + authSize
-	lTreeMask := pk[s.n/8 : (s.n/8 + s.ltree*s.n/8)]
-	// s.ltree : s.ltree+2*s.h
-	treeMask := pk[s.n/8+s.ltree*s.n/8 : s.n/8+(2*s.h+s.ltree)*s.n/8]
+	lTreeMask := s.getMask(mask, LTREE_Mask)
+	treeMask := common.Ravel(s.getMask(mask, TREE_Mask), int(size))
+	hh := s.hashH.Clone()
 
-	start := 1<<(s.h/s.d) - 1
+	leafBits := s.h / s.d
+	start := 1<<leafBits - 1
 	// pkH 用来计算 wots 的公钥
 	for i := uint64(0); i < s.d; i++ {
-		tmp := (s.d - 1 - i) * s.h / s.d
-
 		part := sigmaAndAuth[i*partSize : (i+1)*partSize]
 
 		// pkH 被签名，返回值为公钥
-		wotsPk := wots.verify(pkH, part[:wotsSize])
-		//fmt.Printf("2. wots pk, index: %d, key: %x\n", i, wotsPk)
+		wotsPk, _ := wots.verify(pkH, part[:wotsSize])
 		// L-Tree 根节点
-		pkW := merkle.LTreeWithMask(wotsPk, int(s.n), hash.F, lTreeMask)
-		// 大 Node 的根节点
-		//fmt.Printf("2. wots 公钥的L-Tree根节点, index: %d, key: %x\n", i, pkW)
+		pkW := merkle.LTreeInPlace(wotsPk, int(s.n), hh, lTreeMask)
 
 		// 计算出大 Node 的根节点
-		j := int(common.Cut(index, s.h, tmp, s.h/s.d))
+		j := common.ReadBits(index, i*leafBits, leafBits)
+		pkH = merkle.ComputeRootFromLeafWithMask(pkW, start+int(j), common.Ravel(part[wotsSize:], int(size)), hh.Hash(), treeMask)
+	}
 
-		mask := common.Ravel(treeMask[2*i*(s.h/s.d)*s.n/8:(2*i+2)*(s.h/s.d)*s.n/8], int(s.n/8))
-		// 大 Node 的根节点
-		pkH = merkle.ComputeRootWithMask(pkW, start+j, common.Ravel(part[wotsSize:], int(s.n/8)), hash.Sha256, mask)
+	if !common.Equal(pkH, root) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
 
-		// 这个已经 ok 了，可以对应上去
-		//fmt.Printf("2. index: %d, pkH: %x\n", i, pkH)
-		//fmt.Printf("2.index: %d, key: %x\n auth: %x\n\n", i, pkW, common.Ravel(part[wotsSize:], int(s.n/8)))
+// subtree 计算第 layer 层中索引为 index 的大 node
+// 在 SPHINCS virtual structure 中的一个节点
+// 并不是说只有一个 node，而是很多个 node
+// 这些 node 组成一个 binary hash tree
+// 叶子节点是 WOTS+ pk 构成的 l-tree 的根节点
+// mask 为 sk 中的 p 个掩码
+// 叶子节点由 WithWorkers 设置的 worker 并行计算，返回 binary hash tree 的根节点
+func (s *Sphincs) subtree(sk1, mask []byte, layer, index uint64) []byte {
+	leaves := make([][]byte, 1<<(s.h/s.d))
+	parallel(s.concurrency(), len(leaves), func(i int) {
+		leaves[i] = s.leaf(sk1, mask, layer, index, uint64(i))
+	})
+	return s.treeHash(mask).Root(precomputed(leaves))
+}
 
-		//fmt.Printf("index: %d, TREE_MASK: %x\n\n", i, s.getMask(TREE_Mask)[2*i*(s.h/s.d):(2*i+2)*(s.h/s.d)])
-	}
+// leaf 大 node 中第 keyIdx 个叶子节点，即 WOTS+ l 个 pk 块构成的 L-Tree 的根节点
+func (s *Sphincs) leaf(sk1, mask []byte, layer, index, keyIdx uint64) []byte {
+	// pk 只在这里使用，可以直接在 pk 上计算 L-Tree
+	_, pk := s.wots(sk1, mask, layer, index, keyIdx).GenerateKey()
+	return merkle.LTreeInPlace(pk, int(s.n), s.hashH.Clone(), s.getMask(mask, LTREE_Mask))
+}
 
-	pk_ := pk[:s.n/8]
-	//fmt.Printf("pkH: %x\n", pkH)
-	//fmt.Printf("pk: %x\n", pk_)
-	return common.Equal(pkH, pk_)
+// treeHash 由 2^(h/d) 个叶子节点计算大 node 中的 binary hash tree
+func (s *Sphincs) treeHash(mask []byte) *merkle.TreeHash {
+	// 每一层使用的都是 Q_{L-Tree} 后面的 2h/d 个掩码
+	th, err := merkle.NewTreeHash(int(s.h/s.d)+1, int(s.n), s.hashH, s.getMask(mask, TREE_Mask))
+	if err != nil {
+		panic(err)
+	}
+	return th
 }
 
-// 注意了，这里我们要求 address 的 bit 长度必须是 8 的倍数
-// 否则不好计算哈
-// bit length of address = ceil(log(d+1)) + (d-1)(h/d) + h/d = ceil(log(d+1)) + h
-// SPHINCS-256 中，我们有 length = ceil(log( 12 + 1 )) + 60 = 64 bits
-// layer 为 key pair 所在的层数，根节点为 0
-// index 为 所在节点在层数中的索引值
-// idx 为密钥对的索引
-// TODO 实现，下面的实现方式并不是论文中提到的
-//  因为位数如果不确定的话，不太好处理，针对 256 我们可以单独处理
-func (s *Sphincs) address(layer, index, keyIdx uint64) []byte {
-	// length := uint64(math.Ceil(math.Log2(float64(s.d+1)))) + s.h
-	// if length%8 != 0 {
-	// 	panic("address 的 bit 长度应该是 8 的倍数")
-	// }
-	res := make([]byte, 8)
-	binary.BigEndian.PutUint64(res, layer^index^keyIdx)
-	return res
+// horstChunk 签名时每个任务计算的 HORST 叶子节点个数
+const horstChunk = 256
+
+// precomputed 叶子节点已经并行计算好，TreeHash 只需要依次读取
+func precomputed(leaves [][]byte) merkle.LeafFunc {
+	return func(i int) []byte {
+		return leaves[i]
+	}
 }
 
-// 掩码的个数
-func (s *Sphincs) calculateP() {
-	num1 := 1<<s.w - 1
+// wots 返回地址为 (layer, index, keyIdx) 的 WOTS+ 密钥对
+// 私钥由 G(Fα(A, SK1)) 展开得到
+func (s *Sphincs) wots(sk1, mask []byte, layer, index, keyIdx uint64) *WOTSPlus {
+	wots, err := newWOTSPlus(int(s.w), int(s.n), s.getMask(mask, WOTS_Mask), s.hashF)
+	if err != nil {
+		panic(err)
+	}
+	wots.r = s.prg(sk1, s.address(layer, index, keyIdx))
+	return wots
+}
 
-	l1 := int(s.n / s.w)
+// horst 返回地址为 (d, index, keyIdx) 的 HORST 密钥对
+func (s *Sphincs) horst(sk1, mask []byte, index, keyIdx uint64) *Horst {
+	horst, err := newHorst(int(s.tau), int(s.k), int(s.n), s.getMask(mask, HORST_Mask), s.hashF, s.hashH)
+	if err != nil {
+		panic(err)
+	}
+	horst.r = s.prg(sk1, s.address(s.d, index, keyIdx))
+	return horst
+}
 
-	l2_ := l2(l1, int(s.w))
-	ltree := uint64(math.Ceil(math.Log2(float64(l1 + l2_))))
-	num2 := 2 * (s.h + ltree)
+// prg 返回 G(Fα(A, SK1))，种子为 256 bits
+func (s *Sphincs) prg(sk1, address []byte) io.Reader {
+	return s.suite.PRG(s.suite.PRF(sk1, address, 32))
+}
 
-	num3 := 2 * s.tau
+// messageWithPk 返回 PK || M，参考实现中 H_msg 的输入包含了公钥
+func (s *Sphincs) messageWithPk(mask, root, message []byte) []byte {
+	res := make([]byte, 0, len(mask)+len(root)+len(message))
+	res = append(res, mask...)
+	res = append(res, root...)
+	return append(res, message...)
+}
 
-	max := uint64(num1)
-	if num2 > max {
-		max = num2
+// concurrency 生成密钥和签名时使用的 worker 数
+func (s *Sphincs) concurrency() int {
+	if s.workers > 0 {
+		return s.workers
 	}
-	if num3 > max {
-		max = num3
+	return runtime.GOMAXPROCS(0)
+}
+
+// index 从 R 中截取 h bits 的索引，按照小端序保存为 ceil(h/8) bytes
+func (s *Sphincs) index(r []byte) []byte {
+	res := make([]byte, (s.h+7)/8)
+	copy(res, r)
+	if s.h%8 != 0 {
+		res[len(res)-1] &= 1<<(s.h%8) - 1
 	}
-	s.p = max
-	s.ltree = ltree * 2
-	s.l = uint64(l1 + l2_)
+	return res
+}
 
-	// 初始化掩码
-	s.mask = make([][]byte, max)
+// address 计算密钥对的地址，用于 Fα 生成该密钥对的随机数种子
+// bit length of address = ceil(log(d+1)) + (d-1)(h/d) + h/d = ceil(log(d+1)) + h
+// SPHINCS-256 中，我们有 length = ceil(log( 12 + 1 )) + 60 = 64 bits
+// layer 为 key pair 所在的层数，WOTS+ 最底层为 0，HORST 为 d
+// index 为所在节点在层数中的索引值，占 (d-1)(h/d) bits
+// keyIdx 为密钥对在节点中的索引，占 h/d bits
+// 和参考实现一样，layer 占据最低位，然后依次是 index 和 keyIdx，按照小端序保存
+// 当 length 不是 8 的倍数时，最后一个字节的高位补 0
+func (s *Sphincs) address(layer, index, keyIdx uint64) []byte {
+	return common.Pack(
+		[]uint64{layer, index, keyIdx},
+		[]uint64{uint64(bits.Len64(s.d)), (s.d - 1) * s.h / s.d, s.h / s.d},
+	)
 }
 
 // 获取对应的掩码
@@ -451,17 +485,18 @@ const (
 	TREE_Mask  maskType = 4
 )
 
-func (s *Sphincs) getMask(typ maskType) [][]byte {
-	mask := s.mask
+// mask 为 sk 或者 pk 中的 p 个掩码，返回其中对应的部分
+func (s *Sphincs) getMask(mask []byte, typ maskType) []byte {
+	size := s.n / 8
 	switch typ {
 	case WOTS_Mask:
-		return mask[:(1<<s.w - 1)]
+		return mask[:(1<<s.w-1)*size]
 	case HORST_Mask:
-		return mask[:2*s.tau]
+		return mask[:2*s.tau*size]
 	case LTREE_Mask:
-		return mask[:s.ltree]
+		return mask[:s.ltree*size]
 	case TREE_Mask:
-		return mask[s.ltree : s.ltree+2*s.h]
+		return mask[s.ltree*size : (s.ltree+2*s.h/s.d)*size]
 	default:
 		panic("没有该掩码类型")
 	}
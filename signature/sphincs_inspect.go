@@ -0,0 +1,82 @@
+package signature
+
+import "github.com/junhaideng/sphincs/common"
+
+// SphincsSignature 签名 σ = (R1, i, σH, σW,0, Auth_{A_0}, ..., σ_{W,d-1}, Auth_{A_{d-1}}) 拆分之后的各个部分
+// 所有字段都是签名的切片，修改会影响原来的签名
+type SphincsSignature struct {
+	R1 []byte
+	// Index 为 ceil(h/8) bytes 的小端序索引 i
+	Index []byte
+	Horst HorstSignature
+	// Layers 从最底层 (签名 HORST 公钥) 到最顶层，一共 d 层
+	Layers []SphincsLayer
+}
+
+// HorstSignature HORST 签名的各个部分
+type HorstSignature struct {
+	// Top 为 merkle tree 中第 x 层的 2^x 个节点
+	Top [][]byte
+	// Blocks 为 k 个私钥块以及到第 x 层的鉴权路径
+	Blocks []HorstBlock
+}
+
+// HorstBlock σi = (sk_Mi, Auth_Mi)
+type HorstBlock struct {
+	SK   []byte
+	Auth [][]byte
+}
+
+// SphincsLayer hyper tree 中一层的 WOTS+ 签名以及鉴权路径
+type SphincsLayer struct {
+	// Tree 为大 node 在这一层中的索引，Leaf 为 WOTS+ 密钥对在大 node 中的索引
+	Tree, Leaf uint64
+	// Sigma 为 l 个 WOTS+ 签名块
+	Sigma [][]byte
+	// Auth 为 h/d 个节点的鉴权路径
+	Auth [][]byte
+}
+
+// ParseSignature 按照参数将签名拆分成各个部分，只检查签名的长度，不进行校验
+// 长度不对时返回 ErrInvalidSignature
+func (s *Sphincs) ParseSignature(signature []byte) (*SphincsSignature, error) {
+	if len(signature) != s.SignatureSize() {
+		return nil, ErrInvalidSignature
+	}
+	size := s.n / 8
+	iSize := (s.h + 7) / 8
+	x := uint64(calc(int(s.k), int(s.tau)))
+	leafBits := s.h / s.d
+
+	res := &SphincsSignature{
+		R1:    signature[:size],
+		Index: signature[size : size+iSize],
+	}
+	rest := signature[size+iSize:]
+	next := func(n uint64) []byte {
+		b := rest[:n]
+		rest = rest[n:]
+		return b
+	}
+
+	res.Horst.Top = common.Ravel(next((1<<x)*size), int(size))
+	res.Horst.Blocks = make([]HorstBlock, s.k)
+	for i := range res.Horst.Blocks {
+		res.Horst.Blocks[i] = HorstBlock{
+			SK:   next(size),
+			Auth: common.Ravel(next((s.tau-x)*size), int(size)),
+		}
+	}
+
+	res.Layers = make([]SphincsLayer, s.d)
+	for j := range res.Layers {
+		layer := uint64(j)
+		res.Layers[j] = SphincsLayer{
+			Tree:  common.ReadBits(res.Index, (layer+1)*leafBits, s.h-(layer+1)*leafBits),
+			Leaf:  common.ReadBits(res.Index, layer*leafBits, leafBits),
+			Sigma: common.Ravel(next(s.l*size), int(size)),
+			Auth:  common.Ravel(next(leafBits*size), int(size)),
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,153 @@
+package signature
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/junhaideng/sphincs/suite"
+)
+
+// SphincsParams SPHINCS 的参数集合
+// 和论文中的记号一致，只是 W 为 Winternitz 参数本身 (例如 16)，而不是指数
+type SphincsParams struct {
+	N   int // 哈希值的长度 (bits)，F 和 H 的输出长度
+	H   int // hyper tree 的高度
+	D   int // hyper tree 的层数
+	W   int // Winternitz 参数，2 的幂，每个数字 log2(W) bits
+	Tau int // t = 2^Tau 为 HORST 中私钥的块数
+	K   int // HORST 签名中公开的私钥块数，摘要值长度 m = Tau*K
+}
+
+// SPHINCS256Params 论文中的 SPHINCS-256
+var SPHINCS256Params = SphincsParams{N: 256, H: 60, D: 12, W: 16, Tau: 16, K: 32}
+
+// SphincsSizes 由参数推导出来的各个长度
+type SphincsSizes struct {
+	M  int // 消息摘要的长度 (bits)，等于 Tau*K
+	L1 int // WOTS+ 中消息部分的块数
+	L2 int // WOTS+ 中校验和部分的块数
+	L  int // WOTS+ 签名的块数 L1 + L2
+	X  int // HORST 签名中保存的层数，见 calc
+	P  int // 掩码的个数
+
+	PublicKey  int // 公钥大小 (bytes)
+	PrivateKey int // 私钥大小 (bytes)
+	Signature  int // 签名大小 (bytes)
+}
+
+// ParamsError 说明参数组合不合法的原因
+type ParamsError struct {
+	Param  string // 不合法的参数，例如 "n"，"h/d"
+	Reason string
+}
+
+func (e *ParamsError) Error() string {
+	return fmt.Sprintf("sphincs: invalid %s: %s", e.Param, e.Reason)
+}
+
+// Validate 检查参数组合是否可以使用，返回密钥和签名的大小
+// 不合法时返回 *ParamsError，说明是哪一个参数以及原因
+func (p SphincsParams) Validate() (SphincsSizes, error) {
+	var sizes SphincsSizes
+	invalid := func(param, reason string) (SphincsSizes, error) {
+		return SphincsSizes{}, &ParamsError{Param: param, Reason: reason}
+	}
+
+	if p.N <= 0 || p.N%8 != 0 || p.N > 256 {
+		// F, H 基于 512 bits 的 ChaCha12 置换，输出最多 256 bits
+		return invalid("n", "应该是 8 的倍数，并且不超过 256")
+	}
+	if p.W < 2 || p.W > 256 || p.W&(p.W-1) != 0 {
+		return invalid("w", "应该是 2 的幂，并且在 2 到 256 之间")
+	}
+	if p.D <= 0 {
+		return invalid("d", "应该是正整数")
+	}
+	if p.H <= 0 || p.H%p.D != 0 {
+		return invalid("h", "应该是 d 的正整数倍")
+	}
+	if p.H > 128 {
+		// 索引取自 R 的前 16 bytes，R1 取自 R[16:]
+		return invalid("h", "不能超过 128")
+	}
+	if p.H/p.D > 32 {
+		// 每一个大 node 中有 2^(h/d) 个 WOTS+ 密钥对
+		return invalid("h/d", "不能超过 32")
+	}
+	if (p.D-1)*(p.H/p.D) > 64 {
+		// 地址中大 node 的索引占 (d-1)h/d bits，需要保存在一个 uint64 中
+		return invalid("(d-1)h/d", "不能超过 64")
+	}
+	if p.Tau <= 0 || p.Tau > maxTau {
+		return invalid("tau", "应该在 1 到 32 之间")
+	}
+	if p.K <= 0 {
+		return invalid("k", "应该是正整数")
+	}
+	if p.Tau*p.K > 512 {
+		// H_msg 使用 BLAKE-512，摘要值只有 512 bits
+		return invalid("tau*k", "不能超过 512")
+	}
+
+	logw := bits.Len(uint(p.W)) - 1
+	sizes.M = p.Tau * p.K
+	sizes.L1 = (p.N + logw - 1) / logw
+	sizes.L2 = l2(sizes.L1, logw)
+	sizes.L = sizes.L1 + sizes.L2
+	sizes.X = calc(p.K, p.Tau)
+
+	// 掩码的个数 p = max{w-1, 2(h/d+ceil(log l)), 2 tau}
+	sizes.P = p.W - 1
+	if v := 2 * (p.H/p.D + ceilLog2(sizes.L)); v > sizes.P {
+		sizes.P = v
+	}
+	if v := 2 * p.Tau; v > sizes.P {
+		sizes.P = v
+	}
+
+	size := p.N / 8
+	horst := p.K + (p.Tau-sizes.X)*p.K + 1<<sizes.X
+	sizes.PublicKey = (1 + sizes.P) * size
+	sizes.PrivateKey = (2 + sizes.P) * size
+	sizes.Signature = (p.H+7)/8 + (1+horst+p.D*sizes.L+p.H)*size
+	return sizes, nil
+}
+
+// ceilLog2 返回 ceil(log2(x))，x > 0
+func ceilLog2(x int) int {
+	return bits.Len(uint(x - 1))
+}
+
+// HashSuite 返回使用的哈希函数族，默认为 suite.SPHINCS256
+func (s *Sphincs) HashSuite() suite.HashSuite {
+	return s.suite
+}
+
+// Identifier 参数为 n, h, d, w, tau, k，其中 w 为 Winternitz 参数本身
+// 哈希函数族不是 suite.SPHINCS256 时最后为它的编号
+func (s *Sphincs) Identifier() Identifier {
+	p := s.Params()
+	var st suite.HashSuite
+	if s.suite != suite.SPHINCS256 {
+		st = s.suite
+	}
+	return Identifier{Alg: AlgSphincs, Params: withSuite([]uint32{
+		uint32(p.N), uint32(p.H), uint32(p.D), uint32(p.W), uint32(p.Tau), uint32(p.K),
+	}, st)}
+}
+
+// PublicKeySize pk = (Q, PK1)，(1+p) 个 n bits 的块
+func (s *Sphincs) PublicKeySize() int {
+	return s.lengths.PublicKey
+}
+
+// PrivateKeySize sk = (SK1, Q, SK2)，(2+p) 个 n bits 的块
+func (s *Sphincs) PrivateKeySize() int {
+	return s.lengths.PrivateKey
+}
+
+// SignatureSize σ = (R1, i, σH, σW,0, Auth_{A_0}, ..., σ_{W,d-1}, Auth_{A_{d-1}})
+// SPHINCS-256 为 32 + 8 + 13312 + 12*(67+5)*32 = 41000 bytes，见 SphincsParams.Validate
+func (s *Sphincs) SignatureSize() int {
+	return s.lengths.Signature
+}
@@ -1,9 +1,13 @@
 package signature
 
 import (
+	"encoding/binary"
 	"math/rand"
+	"sync"
 	"testing"
 
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/suite"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,7 +18,7 @@ func TestSphincs(t *testing.T) {
 		seed[i] = byte(rand.Intn(128))
 	}
 	// w 为 4，和论文中稍有不太，这里采用的是指数
-	// 仅支持 n = 256 ，m = 512
+	// 其他参数集合见 TestSphincsWithParams
 	sphincs, err := NewSphincs(256, 512, 60, 12, 4, 16, 32, seed)
 	assert.Nil(err)
 
@@ -37,6 +41,150 @@ func TestSphincs(t *testing.T) {
 
 }
 
+func TestSphincsParams(t *testing.T) {
+	assert := assert.New(t)
+
+	sizes, err := SPHINCS256Params.Validate()
+	assert.Nil(err)
+	assert.Equal(SphincsSizes{
+		M: 512, L1: 64, L2: 3, L: 67, X: 6, P: 32,
+		PublicKey: 1056, PrivateKey: 1088, Signature: 41000,
+	}, sizes)
+
+	cases := []struct {
+		params SphincsParams
+		param  string
+	}{
+		{SphincsParams{N: 512, H: 60, D: 12, W: 16, Tau: 16, K: 32}, "n"},
+		{SphincsParams{N: 100, H: 60, D: 12, W: 16, Tau: 16, K: 32}, "n"},
+		{SphincsParams{N: 256, H: 60, D: 12, W: 12, Tau: 16, K: 32}, "w"},
+		{SphincsParams{N: 256, H: 60, D: 12, W: 512, Tau: 16, K: 32}, "w"},
+		{SphincsParams{N: 256, H: 60, D: 0, W: 16, Tau: 16, K: 32}, "d"},
+		{SphincsParams{N: 256, H: 60, D: 7, W: 16, Tau: 16, K: 32}, "h"},
+		{SphincsParams{N: 256, H: 132, D: 66, W: 16, Tau: 16, K: 32}, "h"},
+		{SphincsParams{N: 256, H: 66, D: 2, W: 16, Tau: 16, K: 32}, "h/d"},
+		{SphincsParams{N: 256, H: 72, D: 12, W: 16, Tau: 16, K: 32}, "(d-1)h/d"},
+		{SphincsParams{N: 256, H: 60, D: 12, W: 16, Tau: 33, K: 8}, "tau"},
+		{SphincsParams{N: 256, H: 60, D: 12, W: 16, Tau: 16, K: 0}, "k"},
+		{SphincsParams{N: 256, H: 60, D: 12, W: 16, Tau: 16, K: 33}, "tau*k"},
+	}
+	for _, c := range cases {
+		_, err := c.params.Validate()
+		e, ok := err.(*ParamsError)
+		if assert.True(ok, "%+v", c.params) {
+			assert.Equal(c.param, e.Param, "%+v", c.params)
+			assert.NotEmpty(e.Reason)
+		}
+		_, err = NewSphincsWithParams(c.params, nil)
+		assert.NotNil(err)
+	}
+}
+
+func TestSphincsWithParams(t *testing.T) {
+	assert := assert.New(t)
+	for _, p := range []SphincsParams{
+		// h 超过 64，w 的指数为 4，tau 不是 8 的倍数
+		{N: 128, H: 66, D: 33, W: 16, Tau: 15, K: 10},
+		// w 的指数为 3，不是 8 的因数，数字会跨越字节，tau 同样不是 8 的倍数
+		{N: 192, H: 12, D: 4, W: 8, Tau: 12, K: 20},
+	} {
+		s, err := NewSphincsWithParams(p, []byte("seed"))
+		if !assert.Nil(err, "%+v", p) {
+			continue
+		}
+		sizes := s.Sizes()
+		assert.Equal(p, s.Params())
+
+		sk, pk := s.GenerateKey()
+		assert.Equal(sizes.PrivateKey, len(sk))
+		assert.Equal(sizes.PublicKey, len(pk))
+
+		message := []byte("hello world")
+		sig := s.Sign(message, sk)
+		assert.Equal(sizes.Signature, len(sig))
+		assert.Nil(s.VerifyErr(message, pk, sig), "%+v", p)
+		assert.Equal(ErrVerifyFailed, s.VerifyErr([]byte("hello"), pk, sig))
+		// 长度不对的私钥 (例如公钥) 不能用来签名
+		assert.PanicsWithValue(common.ErrSizeNotMatch, func() { s.Sign(message, pk) }, "%+v", p)
+		assert.PanicsWithValue(common.ErrSizeNotMatch, func() { s.Sign(message, sk[1:]) }, "%+v", p)
+		_, err = s.SignErr(message, pk)
+		assert.Equal(common.ErrSizeNotMatch, err)
+		sig2, err := s.SignErr(message, sk)
+		assert.Nil(err)
+		assert.Equal(sig, sig2)
+
+		// 索引中高于 h 的 bit 被置 0
+		if p.H%8 != 0 {
+			iSize := (p.H + 7) / 8
+			assert.Zero(sig[p.N/8+iSize-1] >> (p.H % 8))
+		}
+	}
+}
+
+func TestSphincsAddress(t *testing.T) {
+	assert := assert.New(t)
+	sphincs, err := NewSphincs(256, 512, 60, 12, 4, 16, 32, make([]byte, 32))
+	assert.Nil(err)
+
+	// 参考实现: level | subtree << 4 | subleaf << 59，小端序
+	address := sphincs.address(12, 0x123456789abcd, 17)
+	assert.Equal(8, len(address))
+	assert.Equal(uint64(12)|0x123456789abcd<<4|17<<59, binary.LittleEndian.Uint64(address))
+
+	// 之前 layer^index^keyIdx 的方式下，下面的密钥对地址是相同的
+	seen := make(map[string]bool)
+	for _, v := range [][3]uint64{{0, 1, 0}, {1, 0, 0}, {0, 0, 1}, {1, 1, 1}, {12, 0, 0}, {0, 12, 0}} {
+		a := string(sphincs.address(v[0], v[1], v[2]))
+		assert.False(seen[a], "address collision: %v", v)
+		seen[a] = true
+	}
+}
+
+// TestSphincsConcurrent 同一个实例在多个 goroutine 中同时使用不同的密钥进行签名和校验
+// 使用 go test -race 运行可以检查是否存在数据竞争
+func TestSphincsConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	sphincs, err := NewSphincs(256, 512, 8, 2, 4, 8, 64, make([]byte, 32))
+	assert.Nil(err)
+
+	const keys = 4
+	sks := make([][]byte, keys)
+	pks := make([][]byte, keys)
+	var wg sync.WaitGroup
+	for i := 0; i < keys; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sks[i], pks[i] = sphincs.GenerateKey()
+		}(i)
+	}
+	wg.Wait()
+
+	// 签名是确定性的，先依次计算出期望的结果
+	msgs := [][]byte{[]byte("hello world"), []byte("sphincs")}
+	expected := make([][][]byte, keys)
+	for i := 0; i < keys; i++ {
+		for _, msg := range msgs {
+			expected[i] = append(expected[i], sphincs.Sign(msg, sks[i]))
+		}
+	}
+
+	for i := 0; i < keys; i++ {
+		for j := range msgs {
+			wg.Add(1)
+			go func(i, j int) {
+				defer wg.Done()
+				sig := sphincs.Sign(msgs[j], sks[i])
+				assert.Equal(expected[i][j], sig)
+				assert.True(sphincs.Verify(msgs[j], pks[i], sig))
+				assert.False(sphincs.Verify(msgs[1-j], pks[i], sig))
+				assert.False(sphincs.Verify(msgs[j], pks[(i+1)%keys], sig))
+			}(i, j)
+		}
+	}
+	wg.Wait()
+}
+
 type sphincsArgs struct {
 	n, m, h, d, w, tau, k uint64
 	seed                  []byte
@@ -85,7 +233,7 @@ func BenchmarkSphincs(b *testing.B) {
 			}
 		})
 
-		sigma := sphincs.Sign(msg, pk)
+		sigma := sphincs.Sign(msg, sk)
 		b.Logf("sigma: %d\n", len(sigma))
 
 		b.Run("verify", func(b *testing.B) {
@@ -95,3 +243,150 @@ func BenchmarkSphincs(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkSphincsParallel 默认的 worker 数为 GOMAXPROCS，通过 -cpu 1,2,4,8 比较不同 CPU 数时的速度
+func BenchmarkSphincsParallel(b *testing.B) {
+	sphincs, err := NewSphincsWithParams(SPHINCS256Params, make([]byte, 32))
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := make([]byte, 512)
+	b.Run("key-gen", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = sphincs.GenerateKey()
+		}
+	})
+	sk, _ := sphincs.GenerateKey()
+	b.Run("msg-sign", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = sphincs.Sign(msg, sk)
+		}
+	})
+}
+
+func TestSphincsParseSignature(t *testing.T) {
+	assert := assert.New(t)
+	s, err := NewSphincs(256, 512, 8, 2, 4, 8, 64, nil)
+	assert.Nil(err)
+	sk, _ := s.GenerateKey()
+	sig := s.Sign([]byte("hello world"), sk)
+
+	parsed, err := s.ParseSignature(sig)
+	assert.Nil(err)
+	assert.Len(parsed.Index, 1)
+	assert.Len(parsed.Horst.Blocks, 64)
+	assert.Len(parsed.Layers, 2)
+
+	// 每一层的索引由 i 拆分得到，最顶层只有一个大 node
+	i := uint64(parsed.Index[0])
+	assert.Equal(i&0xf, parsed.Layers[0].Leaf)
+	assert.Equal(i>>4, parsed.Layers[0].Tree)
+	assert.Equal(i>>4, parsed.Layers[1].Leaf)
+	assert.Equal(uint64(0), parsed.Layers[1].Tree)
+
+	// 所有部分按顺序拼接起来就是原来的签名
+	res := append([]byte{}, parsed.R1...)
+	res = append(res, parsed.Index...)
+	res = append(res, common.Flatten(parsed.Horst.Top)...)
+	for _, b := range parsed.Horst.Blocks {
+		assert.Len(b.Auth, 8-int(calc(64, 8)))
+		res = append(res, b.SK...)
+		res = append(res, common.Flatten(b.Auth)...)
+	}
+	for _, layer := range parsed.Layers {
+		assert.Len(layer.Sigma, 67)
+		assert.Len(layer.Auth, 4)
+		res = append(res, common.Flatten(layer.Sigma)...)
+		res = append(res, common.Flatten(layer.Auth)...)
+	}
+	assert.Equal(sig, res)
+
+	_, err = s.ParseSignature(sig[1:])
+	assert.Equal(ErrInvalidSignature, err)
+}
+
+func TestSphincsWorkers(t *testing.T) {
+	assert := assert.New(t)
+	seed := make([]byte, 32)
+	message := []byte("hello world")
+
+	// 串行计算的结果作为参照
+	serial, err := NewSphincs(256, 512, 12, 3, 4, 8, 64, seed, WithWorkers(1))
+	assert.Nil(err)
+	sk, pk := serial.GenerateKey()
+	sig := serial.Sign(message, sk)
+
+	for _, workers := range []int{0, 2, 3, 16} {
+		s, err := NewSphincs(256, 512, 12, 3, 4, 8, 64, seed, WithWorkers(workers))
+		assert.Nil(err)
+		sk2, pk2 := s.GenerateKey()
+		assert.Equal(sk, sk2, workers)
+		assert.Equal(pk, pk2, workers)
+		assert.Equal(sig, s.Sign(message, sk), workers)
+		assert.True(s.Verify(message, pk, sig), workers)
+	}
+}
+
+func TestSphincsHashSuite(t *testing.T) {
+	assert := assert.New(t)
+	p := SphincsParams{N: 128, H: 12, D: 4, W: 16, Tau: 8, K: 16}
+	message := []byte("hello world")
+
+	def, err := NewSphincsWithParams(p, []byte("seed"))
+	assert.Nil(err)
+	_, defPk := def.GenerateKey()
+
+	for _, name := range suite.Names() {
+		st, _ := suite.ByName(name)
+		s, err := NewSphincsWithParams(p, []byte("seed"), WithHashSuite(st))
+		if !assert.Nil(err, name) {
+			continue
+		}
+		sk, pk := s.GenerateKey()
+		sig := s.Sign(message, sk)
+		assert.Equal(s.Sizes().Signature, len(sig), name)
+		assert.Nil(s.VerifyErr(message, pk, sig), name)
+		assert.Equal(ErrVerifyFailed, s.VerifyErr([]byte("hello"), pk, sig), name)
+
+		// 默认的哈希函数族为 SPHINCS-256，其他哈希函数族生成的密钥不同，签名也不能通过校验
+		if st == suite.SPHINCS256 {
+			assert.Equal(defPk, pk)
+		} else {
+			assert.NotEqual(defPk, pk, name)
+			assert.False(def.Verify(message, pk, sig), name)
+		}
+	}
+
+	// SPHINCS-256 中的 F 和 H 最多为 256 bits
+	_, err = NewSphincsWithParams(SphincsParams{N: 512, H: 12, D: 4, W: 16, Tau: 8, K: 64}, nil, WithHashSuite(suite.SPHINCS256))
+	assert.NotNil(err)
+}
+
+// go test -run ^$ -bench BenchmarkSphincsSuites ./signature
+func BenchmarkSphincsSuites(b *testing.B) {
+	msg := make([]byte, 512)
+	for _, name := range suite.Names() {
+		st, _ := suite.ByName(name)
+		sphincs, err := NewSphincsWithParams(SPHINCS256Params, make([]byte, 32), WithHashSuite(st))
+		if err != nil {
+			b.Fatal(err)
+		}
+		sk, pk := sphincs.GenerateKey()
+		sigma := sphincs.Sign(msg, sk)
+		b.Run(name+"/key-gen", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = sphincs.GenerateKey()
+			}
+		})
+		b.Run(name+"/msg-sign", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = sphincs.Sign(msg, sk)
+			}
+		})
+		b.Run(name+"/verify", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = sphincs.Verify(msg, pk, sigma)
+			}
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package signature
 
 import (
-	"crypto/rand"
 	"errors"
+	"io"
 	"math"
 
 	"github.com/junhaideng/sphincs/common"
@@ -14,15 +14,17 @@ import (
 type Winternitz struct {
 	n    Size
 	w    int
-	hash hash.Hash
+	hash *hash.Hasher
 	l1   int
 	l2   int
+	r    io.Reader
 }
 
 // NewWinternitzSignature return winternitz one time signature algorithm
 // w should be a divisor of 8
-func NewWinternitzSignature(w int, n Size) (Signature, error) {
-	if 8%w != 0 {
+// the secret key is read from crypto/rand unless WithRand is given
+func NewWinternitzSignature(w int, n Size, opts ...Option) (Signature, error) {
+	if w <= 0 || 8%w != 0 {
 		return nil, errors.New("w should be a divisor of 8")
 	}
 	if n != Size256 && n != Size512 {
@@ -36,13 +38,10 @@ func NewWinternitzSignature(w int, n Size) (Signature, error) {
 	win := &Winternitz{
 		n:    n,
 		w:    w,
-		hash: hash.Sha256,
+		hash: sha2(n),
 		l1:   l1,
 		l2:   l2_,
-	}
-
-	if n == Size512 {
-		win.hash = hash.Sha512
+		r:    NewOptions(opts...).Rand,
 	}
 	return win, nil
 }
@@ -53,24 +52,24 @@ func (w *Winternitz) GenerateKey() ([]byte, []byte) {
 	// generate l1+l2 keys
 	l := w.l1 + w.l2
 
-	private := make([]byte, 0, n/8*l)
+	private := make([]byte, n/8*l)
 	public := make([]byte, 0, n/8*l)
+	if _, err := io.ReadFull(w.r, private); err != nil {
+		panic(err)
+	}
 
-	// each secret key n bits, n/8 bytes
-	sk := make([]byte, n/8)
-
-	// key generation's iteration
+	// key generation's iteration, each secret key n bits, n/8 bytes
+	h := w.hash.Clone()
 	for i := 0; i < l; i++ {
-		rand.Read(sk)
-		private = append(private, sk...)
-		public = append(public, hash.HashTimes(sk, 1<<w.w-1, w.hash)...)
+		public = h.Chain(public, private[i*n/8:(i+1)*n/8], 0, 1<<w.w-1, nil)
 	}
 
 	return private, public
 }
 
 func (w *Winternitz) Sign(message []byte, sk []byte) []byte {
-	digest := w.hash(message)
+	h := w.hash.Clone()
+	digest := h.Sum(nil, message)
 
 	// w bits as an integer, so after hash the message
 	// there will be l1 integers
@@ -84,27 +83,42 @@ func (w *Winternitz) Sign(message []byte, sk []byte) []byte {
 
 	n := int(w.n)
 	for i := 0; i < l; i++ {
-		res = append(res, hash.HashTimes(sk[i*n/8:(i+1)*n/8], 1<<w.w-1-int(block[i]), w.hash)...)
+		res = h.Chain(res, sk[i*n/8:(i+1)*n/8], 0, 1<<w.w-1-int(block[i]), nil)
 	}
 	return res
 }
 
 func (w *Winternitz) Verify(message []byte, pk []byte, signature []byte) bool {
-	digest := w.hash(message)
+	return w.VerifyErr(message, pk, signature) == nil
+}
+
+// VerifyErr both pk and signature are l1+l2 blocks, each block n bits
+func (w *Winternitz) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	l := w.l1 + w.l2
+	n := int(w.n)
+	if len(pk) != l*n/8 {
+		return ErrInvalidPublicKey
+	}
+	if len(signature) != l*n/8 {
+		return ErrInvalidSignature
+	}
+
+	h := w.hash.Clone()
+	digest := h.Sum(nil, message)
 	block := w.baseW(digest, w.l1)
 
 	block = append(block, w.checksum(block)...)
 
-	l := w.l1 + w.l2
-	n := int(w.n)
+	buf := make([]byte, 0, n/8)
 	for i := 0; i < l; i++ {
 		s := signature[i*n/8 : (i+1)*n/8]
 		p := pk[i*n/8 : (i+1)*n/8]
-		if !common.Equal(p, hash.HashTimes(s, int(block[i]), w.hash)) {
-			return false
+		buf = h.Chain(buf[:0], s, 0, int(block[i]), nil)
+		if !common.Equal(p, buf) {
+			return ErrVerifyFailed
 		}
 	}
-	return true
+	return nil
 }
 
 // sphincs l2 calculation
@@ -173,3 +187,23 @@ func (w *Winternitz) checksum(input []byte) []byte {
 	// convert checksum to tau w
 	return w.baseW(b, w.l2)
 }
+
+// Identifier 参数为 w, n
+func (w *Winternitz) Identifier() Identifier {
+	return Identifier{Alg: AlgWinternitz, Params: []uint32{uint32(w.w), uint32(w.n)}}
+}
+
+// PublicKeySize l1+l2 个 n bits 的链的终点
+func (w *Winternitz) PublicKeySize() int {
+	return (w.l1 + w.l2) * int(w.n) / 8
+}
+
+// PrivateKeySize l1+l2 个 n bits 的链的起点
+func (w *Winternitz) PrivateKeySize() int {
+	return (w.l1 + w.l2) * int(w.n) / 8
+}
+
+// SignatureSize 每一条链上的一个节点
+func (w *Winternitz) SignatureSize() int {
+	return (w.l1 + w.l2) * int(w.n) / 8
+}
@@ -5,7 +5,6 @@ import (
 	"testing"
 
 	"github.com/junhaideng/sphincs/common"
-	"github.com/junhaideng/sphincs/hash"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,13 +23,10 @@ func newWinternitzSignature(w int, n Size) (*Winternitz, error) {
 	win := &Winternitz{
 		n:    n,
 		w:    w,
-		hash: hash.Sha256,
+		hash: sha2(n),
 		l1:   l1,
 		l2:   l2_,
 	}
-	if n == Size512 {
-		win.hash = hash.Sha512
-	}
 	return win, nil
 }
 
@@ -2,34 +2,45 @@ package signature
 
 import (
 	"errors"
+	"io"
 
 	"github.com/junhaideng/sphincs/common"
 	"github.com/junhaideng/sphincs/hash"
-	"github.com/junhaideng/sphincs/rand"
+	"github.com/junhaideng/sphincs/suite"
 )
 
 // WOTSPlus 签名
 // 在 WOTS+ 中，为了适应 SPHINCS，创建的时候
 // 我们使用种子来初始化随机数生成器
 type WOTSPlus struct {
-	n    Size
-	w    int
-	hash hash.Hash
-	l1   int
-	l2   int
+	n Size
+	w int
+	// 对消息进行哈希
+	hash *hash.Hasher
+	// 链式哈希中使用的函数，SPHINCS 中为 F
+	chain *hash.Hasher
+	l1    int
+	l2    int
 	// 掩码
 	mask []byte
 	// 随机数生成器
-	r rand.Rander
+	r io.Reader
 	// 种子
 	seed []byte
+	// WithHashSuite 设置的哈希函数族，为空时使用 SHA-2
+	suite suite.HashSuite
 }
 
-func newWOTSPlus(w, n int, mask []byte) (*WOTSPlus, error) {
-	if 8%w != 0 {
-		return nil, errors.New("w should be a divisor of 8")
+// w 为每个数字的 bit 数 (log2 Winternitz 参数)，取值 1 到 8，n 为 8 的倍数
+// chain 为空时根据 n 选择 SHA-2，此时 n 只能是 256 或者 512
+func newWOTSPlus(w, n int, mask []byte, chain *hash.Hasher) (*WOTSPlus, error) {
+	if w <= 0 || w > 8 {
+		return nil, errors.New("w should be in [1, 8]")
 	}
-	if n != 256 && n != 512 {
+	if n <= 0 || n%8 != 0 {
+		return nil, common.ErrSizeNotSupport
+	}
+	if chain == nil && n != 256 && n != 512 {
 		return nil, common.ErrSizeNotSupport
 	}
 
@@ -38,35 +49,54 @@ func newWOTSPlus(w, n int, mask []byte) (*WOTSPlus, error) {
 		return nil, common.ErrSizeNotMatch
 	}
 
-	// meet above conditions, then n can be divided by w
-	l1 := n / w
+	// n 不一定是 w 的倍数，最后一个数字不足 w bits 时高位补 0
+	l1 := (n + w - 1) / w
 
 	l2_ := l2(l1, w)
 
 	win := &WOTSPlus{
-		n:    Size(n),
-		w:    w,
-		hash: hash.Sha256,
-		l1:   l1,
-		l2:   l2_,
-		mask: mask,
+		n:     Size(n),
+		w:     w,
+		hash:  sha2(Size(n)),
+		chain: chain,
+		l1:    l1,
+		l2:    l2_,
+		mask:  mask,
 	}
-
-	if n == 512 {
-		win.hash = hash.Sha512
+	if win.chain == nil {
+		win.chain = win.hash
 	}
 	return win, nil
 }
 
 // NewWOTSPlusSignature return WOTS+
-// w should be a divisor of 8
-func NewWOTSPlusSignature(w int, n Size, seed []byte, mask []byte) (Signature, error) {
-	win, err := newWOTSPlus(w, int(n), mask)
+// w 取值 1 到 8，n 为 256 或者 512
+// 私钥由 seed 初始化的 ChaCha12 生成，seed 为空时使用 crypto/rand，也可以通过 WithRand 设置
+// 通过 WithHashSuite 设置哈希函数族时，链使用其中的 F，消息摘要为 H_msg(M)，PRG 代替 ChaCha12
+// 此时 n 可以是哈希函数族支持的任意大小，但不超过 512
+func NewWOTSPlusSignature(w int, n Size, seed []byte, mask []byte, opts ...Option) (Signature, error) {
+	st := applyOptions(opts).Suite
+	var chain *hash.Hasher
+	if st == nil {
+		if n != Size256 && n != Size512 {
+			return nil, common.ErrSizeNotSupport
+		}
+	} else {
+		if !st.Supports(int(n)) || n > Size512 {
+			return nil, common.ErrSizeNotSupport
+		}
+		chain = st.F(int(n))
+	}
+	win, err := newWOTSPlus(w, int(n), mask, chain)
 	if err != nil {
 		return nil, err
 	}
+	if st != nil {
+		win.hash = messageHasher(st, int(n))
+		win.suite = st
+	}
 	win.seed = seed
-	win.r = rand.New(seed)
+	win.r = seededRand(seed, opts)
 	return win, nil
 }
 
@@ -77,25 +107,28 @@ func (w *WOTSPlus) GenerateKey() ([]byte, []byte) {
 	l := w.l1 + w.l2
 
 	// 公私钥，我们可以实现确定公私钥的大小
-	private := make([]byte, 0, n/8*l)
+	private := make([]byte, n/8*l)
 	public := make([]byte, 0, n/8*l)
+	if _, err := io.ReadFull(w.r, private); err != nil {
+		panic(err)
+	}
 
-	// each secret key n bits, n/8 bytes
-	sk := make([]byte, n/8)
-
-	// key generation's iteration
+	// key generation's iteration, each secret key n bits, n/8 bytes
+	chain := w.chain.Clone()
 	for i := 0; i < l; i++ {
-		w.r.Read(sk)
-		private = append(private, sk...)
-		public = append(public, hash.HashTimesWithMask(sk, 0, 1<<w.w-1, w.hash, w.mask)...)
+		public = chain.Chain(public, private[i*n/8:(i+1)*n/8], 0, 1<<w.w-1, w.mask)
 	}
 
 	return private, public
 }
 
 func (w *WOTSPlus) Sign(message []byte, sk []byte) []byte {
-	digest := w.hash(message)
+	return w.sign(w.hash.Clone().Sum(nil, message), sk)
+}
 
+// sign 对 n bits 的摘要值直接进行签名
+// SPHINCS 中签名的是 HORST 或者下一层树的根节点，不再进行哈希
+func (w *WOTSPlus) sign(digest []byte, sk []byte) []byte {
 	// w bits as an integer, so after hash the message
 	// there will be l1 integers
 	// and each integer <= 2^w-1
@@ -107,19 +140,42 @@ func (w *WOTSPlus) Sign(message []byte, sk []byte) []byte {
 	res := make([]byte, 0, l*(int(w.n)/8))
 
 	n := int(w.n)
+	chain := w.chain.Clone()
 	for i := 0; i < l; i++ {
-		res = append(res, hash.HashTimesWithMask(sk[i*n/8:(i+1)*n/8], 0, 1<<w.w-1-int(block[i]), w.hash, w.mask)...)
+		res = chain.Chain(res, sk[i*n/8:(i+1)*n/8], 0, int(block[i]), w.mask)
 	}
 	return res
 }
 
 func (w *WOTSPlus) Verify(message []byte, pk []byte, signature []byte) bool {
-	pk_ := w.verify(message, signature)
-	return common.Equal(pk, pk_)
+	return w.VerifyErr(message, pk, signature) == nil
 }
 
-func (w *WOTSPlus) verify(message []byte, signature []byte) []byte {
-	digest := w.hash(message)
+// VerifyErr pk 和签名都是 l1+l2 个 n bits 的块
+func (w *WOTSPlus) VerifyErr(message []byte, pk []byte, signature []byte) error {
+	if len(pk) != w.size() {
+		return ErrInvalidPublicKey
+	}
+	pk_, ok := w.verify(w.hash.Clone().Sum(nil, message), signature)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	if !common.Equal(pk, pk_) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
+
+// size 公钥以及签名的大小 (bytes)
+func (w *WOTSPlus) size() int {
+	return (w.l1 + w.l2) * int(w.n) / 8
+}
+
+// verify 根据摘要值和签名计算出公钥，签名的长度不对时返回 false
+func (w *WOTSPlus) verify(digest []byte, signature []byte) ([]byte, bool) {
+	if len(signature) != w.size() {
+		return nil, false
+	}
 	block := w.baseW(digest, w.l1)
 
 	block = append(block, w.checksum(block)...)
@@ -127,53 +183,28 @@ func (w *WOTSPlus) verify(message []byte, signature []byte) []byte {
 	l := w.l1 + w.l2
 	n := int(w.n)
 	pk := make([]byte, 0, l*n/8)
+	chain := w.chain.Clone()
 	for i := 0; i < l; i++ {
 		s := signature[i*n/8 : (i+1)*n/8]
-		pk = append(pk, hash.HashTimesWithMask(s, 1<<w.w-1-int(block[i]), 1<<w.w-1, w.hash, w.mask)...)
+		pk = chain.Chain(pk, s, int(block[i]), 1<<w.w-1, w.mask)
 	}
-	return pk
+	return pk, true
 }
 
-//// sphincs l2 calculation
-//func l2(t1, w int) int {
-//	up := math.Log2(float64(t1 * (1<<w - 1)))
-//	return int(math.Floor(up/float64(w))) + 1
-//}
-
-// interprets an array of bytes as integers in tau w.
-// w should be a divisor of 8, ensured by constructor `NewWOTSPlusSignature`
+// interprets an array of bytes as integers in base 2^w.
+// 和 SPHINCS-256 参考实现一致，每个字节中先取低位，再取高位
+// input 看作低位在前的比特串，第 i 个数字为第 i*w 个 bit 开始的 w bits (见 common.ReadBits)
+// w 是 8 的因数时和逐字节拆分的结果一致
 func (w WOTSPlus) baseW(input []byte, length int) []byte {
 	res := make([]byte, length)
-
-	index := 0 // index in input
-
-	// index -> bit
-	var bit = input[index] // current work on
-
-	shift := BitSize
 	for i := 0; i < length; i++ {
-		if shift == 0 {
-			shift = BitSize
-			index++
-			bit = input[index]
-		}
-		res[i] = bit >> (shift - w.w) & (1<<w.w - 1)
-		shift -= w.w
+		res[i] = byte(common.ReadBits(input, uint64(i*w.w), uint64(w.w)))
 	}
 	return res
 }
 
-//
-//func ceil(n1, n2 int) int {
-//	res := n1 / n2
-//	if n1%n2 != 0 {
-//		res += 1
-//	}
-//	return res
-//}
-
-// checksum calculate checksum of tau w byte array
-// 计算出来的 sum，首先进行填充，bit 数能够被 w 整除
+// checksum calculate checksum of base 2^w byte array
+// 校验和同样以 2^w 为基数表示，低位在前
 func (w WOTSPlus) checksum(input []byte) []byte {
 	var sum uint64
 
@@ -181,23 +212,38 @@ func (w WOTSPlus) checksum(input []byte) []byte {
 		sum += 1<<w.w - 1 - uint64(input[i])
 	}
 
-	count := common.BitCount(sum)
-	// make sure expected empty zero bits are the least significant bits
-	// or just think this is padding
-	// eg: 1101_01 => 1101_0100, 1101_0001_110 => 1101_0001_1100_0000
-	if count%BitSize != 0 {
-		shift := (count/BitSize+1)*BitSize - count
-		sum = sum << shift
+	res := make([]byte, w.l2)
+	for i := 0; i < w.l2; i++ {
+		res[i] = byte(sum & (1<<w.w - 1))
+		sum >>= w.w
 	}
+	return res
+}
 
-	// convert sum to bytes
-	b := make([]byte, ceil(w.l2*w.w, BitSize))
+// Mask 返回构造时传入的掩码，校验签名时需要使用同样的掩码
+func (w *WOTSPlus) Mask() []byte {
+	return w.mask
+}
 
-	for i := len(b) - 1; i >= 0; i-- {
-		b[i] = byte(sum & 0xff)
-		sum >>= 8
-	}
+// HashSuite 返回 WithHashSuite 设置的哈希函数族，没有设置时为空
+func (w *WOTSPlus) HashSuite() suite.HashSuite {
+	return w.suite
+}
+
+// Identifier 参数为 w, n，设置了哈希函数族时最后为它的编号，掩码不包含在标识中
+func (w *WOTSPlus) Identifier() Identifier {
+	return Identifier{Alg: AlgWOTSPlus, Params: withSuite([]uint32{uint32(w.w), uint32(w.n)}, w.suite)}
+}
+
+// PublicKeySize 公钥，私钥和签名都是 l 个 n bits 的块，掩码不包含在公钥中
+func (w *WOTSPlus) PublicKeySize() int {
+	return w.size()
+}
+
+func (w *WOTSPlus) PrivateKeySize() int {
+	return w.size()
+}
 
-	// convert checksum to tau w
-	return w.baseW(b, w.l2)
+func (w *WOTSPlus) SignatureSize() int {
+	return w.size()
 }
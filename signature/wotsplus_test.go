@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/suite"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -72,6 +73,57 @@ func TestWinternitzPlusSignature4(t *testing.T) {
 	assert.True(w.Verify(msg, pk, signature))
 }
 
+func TestWOTSPlusHashSuite(t *testing.T) {
+	assert := assert.New(t)
+	msg := []byte("Hello World")
+	for _, name := range suite.Names() {
+		st, _ := suite.ByName(name)
+		// 设置哈希函数族之后 n 不再限于 256 和 512
+		for _, n := range []Size{128, 256} {
+			mask := make([]byte, int(n)/8*(1<<4-1))
+			w, err := NewWOTSPlusSignature(4, n, []byte("seed"), mask, WithHashSuite(st))
+			if !assert.Nil(err, name) {
+				continue
+			}
+			sk, pk := w.GenerateKey()
+			signature := w.Sign(msg, sk)
+			assert.True(w.Verify(msg, pk, signature), name)
+			assert.False(w.Verify([]byte("Hello"), pk, signature), name)
+		}
+	}
+
+	_, err := NewWOTSPlusSignature(4, 128, nil, make([]byte, 128/8*(1<<4-1)))
+	assert.Equal(common.ErrSizeNotSupport, err)
+	_, err = NewWOTSPlusSignature(4, Size512, nil, make([]byte, 512/8*(1<<4-1)), WithHashSuite(suite.SPHINCS256))
+	assert.Equal(common.ErrSizeNotSupport, err)
+}
+
+func TestWOTSPlusBaseW(t *testing.T) {
+	assert := assert.New(t)
+	w, err := newWOTSPlus(4, 256, make([]byte, 32*15), nil)
+	assert.Nil(err)
+
+	// 和参考实现一致，每个字节先取低 4 位
+	assert.Equal([]byte{0x2, 0x1, 0x4, 0x3}, w.baseW([]byte{0x12, 0x34}, 4))
+
+	// 64 个 0，校验和为 64 * 15 = 0x3c0，低位在前
+	assert.Equal([]byte{0x0, 0xc, 0x3}, w.checksum(make([]byte, 64)))
+
+	w, err = newWOTSPlus(2, 256, make([]byte, 32*3), nil)
+	assert.Nil(err)
+	assert.Equal([]byte{0x3, 0x2, 0x1, 0x0}, w.baseW([]byte{0x1b}, 4))
+
+	// w 不是 8 的因数时按 bit 拆分，256 bits 一共 ceil(256/3) = 86 个数字
+	w, err = newWOTSPlus(3, 256, make([]byte, 32*7), nil)
+	assert.Nil(err)
+	assert.Equal(86, w.l1)
+	// 0xc5fa = 0b1_100_010_111_111_010，从低位开始每 3 bits 一个数字
+	assert.Equal([]byte{0x2, 0x7, 0x7, 0x2, 0x4, 0x1}, w.baseW([]byte{0xfa, 0xc5}, 6))
+
+	_, err = newWOTSPlus(9, 256, nil, nil)
+	assert.NotNil(err)
+}
+
 type wotsArgs struct {
 	w    int
 	n    common.Size
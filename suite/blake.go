@@ -0,0 +1,189 @@
+package suite
+
+import (
+	stdhash "hash"
+	"io"
+
+	"github.com/junhaideng/sphincs/hash"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"lukechampine.com/blake3"
+)
+
+// BLAKE2b 基于 BLAKE2b-512 以及 BLAKE2Xb，n 最多为 512
+//
+//	F, H:  BLAKE2b-512(M)，截取前 n bits，F 和 H 的输入长度不同
+//	H_msg: BLAKE2Xb(R || M)
+//	PRF:   以 K 为密钥的 BLAKE2Xb(M)，K 超过 64 bytes 时先用 BLAKE2b-512 压缩
+//	PRG:   BLAKE2Xb(seed)，输出长度不限
+var BLAKE2b HashSuite = blake2bSuite{}
+
+// BLAKE2s 基于 BLAKE2s-256 以及 BLAKE2Xs，n 最多为 256，适合 32 位的平台
+//
+//	F, H:  BLAKE2s-256(M)，截取前 n bits，F 和 H 的输入长度不同
+//	H_msg: BLAKE2Xs(R || M)
+//	PRF:   以 K 为密钥的 BLAKE2Xs(M)，K 超过 32 bytes 时先用 BLAKE2s-256 压缩
+//	PRG:   BLAKE2Xs(seed)，输出长度不限
+var BLAKE2s HashSuite = blake2sSuite{}
+
+// BLAKE3 基于 BLAKE3，n 可以为 8 的任意倍数
+//
+//	F, H:  BLAKE3(M)，输出 n bits，F 和 H 的输入长度不同
+//	H_msg: BLAKE3(R || M)
+//	PRF:   BLAKE3 的 keyed 模式 BLAKE3(K, M)，K 不是 32 bytes 时先用 derive_key 模式得到 32 bytes 的密钥
+//	PRG:   BLAKE3(seed) 的 XOF 输出
+var BLAKE3 HashSuite = blake3Suite{}
+
+type blake2bSuite struct{}
+
+func (blake2bSuite) Name() string {
+	return "blake2b"
+}
+
+func (blake2bSuite) Supports(n int) bool {
+	return supports(n, 512)
+}
+
+func (s blake2bSuite) F(n int) *hash.Hasher {
+	checkN(s, n)
+	return hash.NewTruncatedHasher(newBlake2b512, n/8)
+}
+
+func (s blake2bSuite) H(n int) *hash.Hasher {
+	return s.F(n)
+}
+
+func (blake2bSuite) HMsg(r, message []byte, size int) []byte {
+	return squeeze(newBlake2bXOF(uint32(size), nil), size, r, message)
+}
+
+func (blake2bSuite) PRF(key, message []byte, size int) []byte {
+	if len(key) > blake2b.Size {
+		k := blake2b.Sum512(key)
+		key = k[:]
+	}
+	return squeeze(newBlake2bXOF(uint32(size), key), size, message)
+}
+
+func (blake2bSuite) PRG(seed []byte) io.Reader {
+	x := newBlake2bXOF(blake2b.OutputLengthUnknown, nil)
+	x.Write(seed)
+	return x
+}
+
+func newBlake2b512() stdhash.Hash {
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// newBlake2bXOF key 为空时不使用密钥
+func newBlake2bXOF(size uint32, key []byte) blake2b.XOF {
+	x, err := blake2b.NewXOF(size, key)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+type blake2sSuite struct{}
+
+func (blake2sSuite) Name() string {
+	return "blake2s"
+}
+
+func (blake2sSuite) Supports(n int) bool {
+	return supports(n, 256)
+}
+
+func (s blake2sSuite) F(n int) *hash.Hasher {
+	checkN(s, n)
+	return hash.NewTruncatedHasher(newBlake2s256, n/8)
+}
+
+func (s blake2sSuite) H(n int) *hash.Hasher {
+	return s.F(n)
+}
+
+func (blake2sSuite) HMsg(r, message []byte, size int) []byte {
+	return squeeze(newBlake2sXOF(uint16(size), nil), size, r, message)
+}
+
+func (blake2sSuite) PRF(key, message []byte, size int) []byte {
+	if len(key) > blake2s.Size {
+		k := blake2s.Sum256(key)
+		key = k[:]
+	}
+	return squeeze(newBlake2sXOF(uint16(size), key), size, message)
+}
+
+func (blake2sSuite) PRG(seed []byte) io.Reader {
+	x := newBlake2sXOF(blake2s.OutputLengthUnknown, nil)
+	x.Write(seed)
+	return x
+}
+
+func newBlake2s256() stdhash.Hash {
+	h, err := blake2s.New256(nil)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// newBlake2sXOF key 为空时不使用密钥
+func newBlake2sXOF(size uint16, key []byte) blake2s.XOF {
+	x, err := blake2s.NewXOF(size, key)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+type blake3Suite struct{}
+
+func (blake3Suite) Name() string {
+	return "blake3"
+}
+
+func (blake3Suite) Supports(n int) bool {
+	return supports(n, 0)
+}
+
+func (s blake3Suite) F(n int) *hash.Hasher {
+	checkN(s, n)
+	return hash.NewHasher(func() stdhash.Hash {
+		return blake3.New(n/8, nil)
+	})
+}
+
+func (s blake3Suite) H(n int) *hash.Hasher {
+	return s.F(n)
+}
+
+func (blake3Suite) HMsg(r, message []byte, size int) []byte {
+	return sum(blake3.New(size, nil), size, r, message)
+}
+
+func (blake3Suite) PRF(key, message []byte, size int) []byte {
+	if len(key) != blake3KeySize {
+		k := make([]byte, blake3KeySize)
+		blake3.DeriveKey(k, blake3PRFContext, key)
+		key = k
+	}
+	return sum(blake3.New(size, key), size, message)
+}
+
+// blake3KeySize BLAKE3 keyed 模式的密钥长度
+const blake3KeySize = 32
+
+// blake3PRFContext 其他长度的密钥通过 derive_key 模式转换时使用的上下文
+const blake3PRFContext = "github.com/junhaideng/sphincs suite.BLAKE3 PRF key"
+
+func (blake3Suite) PRG(seed []byte) io.Reader {
+	h := blake3.New(32, nil)
+	h.Write(seed)
+	return h.XOF()
+}
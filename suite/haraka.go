@@ -0,0 +1,72 @@
+package suite
+
+import (
+	"io"
+
+	"github.com/junhaideng/sphincs/hash"
+)
+
+// HARAKA 基于 Haraka v2，和 SPHINCS+ 中的 Haraka 实例相同，n 最多为 256
+//
+//	F:     Haraka-256(M || 0*)，截取前 n bits
+//	H:     Haraka-512(M1 || M2 || 0*)，截取前 n bits
+//	H_msg: Haraka-S(R || M)
+//	PRF:   Haraka-S(K || M)
+//	PRG:   Haraka-S(seed)
+//
+// Haraka-S 为 hash.HarakaS 海绵结构，轮常量为标准常量
+// SPHINCS+ 中根据 PK.seed 重新生成轮常量，这里的密钥中没有 PK.seed，所以不做这一步
+var HARAKA HashSuite = haraka{}
+
+type haraka struct{}
+
+func (haraka) Name() string {
+	return "haraka"
+}
+
+func (haraka) Supports(n int) bool {
+	return supports(n, 256)
+}
+
+func (s haraka) F(n int) *hash.Hasher {
+	checkN(s, n)
+	size := n / 8
+	return hash.NewFixedHasher(size, func(out, in []byte) {
+		if len(in) != size {
+			panic("F 的输入长度不正确")
+		}
+		var x [32]byte
+		copy(x[:], in)
+		hash.Haraka256(x[:], x[:])
+		copy(out, x[:size])
+	})
+}
+
+func (s haraka) H(n int) *hash.Hasher {
+	checkN(s, n)
+	size := n / 8
+	return hash.NewFixedHasher(size, func(out, in []byte) {
+		if len(in) != 2*size {
+			panic("H 的输入长度不正确")
+		}
+		var x [64]byte
+		copy(x[:], in)
+		var y [32]byte
+		hash.Haraka512(y[:], x[:])
+		copy(out, y[:size])
+	})
+}
+
+func (haraka) HMsg(r, message []byte, size int) []byte {
+	return squeeze(hash.NewHarakaS(), size, r, message)
+}
+
+func (haraka) PRF(key, message []byte, size int) []byte {
+	return squeeze(hash.NewHarakaS(), size, key, message)
+}
+
+func (haraka) PRG(seed []byte) io.Reader {
+	h := hash.NewHarakaS()
+	h.Write(seed)
+	return h
+}
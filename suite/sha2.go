@@ -0,0 +1,81 @@
+package suite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+
+	"github.com/junhaideng/sphincs/hash"
+)
+
+// SHA2 基于 SHA-2，安全性只依赖于 SHA-256 和 SHA-512
+//
+//	F, H:  n 不超过 256 时为 SHA-256，否则为 SHA-512，截取前 n bits，F 和 H 的输入长度不同
+//	H_msg: SHA-512(R || M)
+//	PRF:   HMAC-SHA-512(K, M)，SHA-2 有长度扩展攻击，所以不使用 SHA-512(K || M)
+//	PRG:   SHA-256 计数器模式，第 i 个分组为 SHA-256(seed || toByte(i, 8))
+var SHA2 HashSuite = sha2{}
+
+type sha2 struct{}
+
+func (sha2) Name() string {
+	return "sha2"
+}
+
+func (sha2) Supports(n int) bool {
+	return supports(n, 512)
+}
+
+func (s sha2) F(n int) *hash.Hasher {
+	checkN(s, n)
+	if n <= 256 {
+		return hash.NewTruncatedHasher(sha256.New, n/8)
+	}
+	return hash.NewTruncatedHasher(sha512.New, n/8)
+}
+
+func (s sha2) H(n int) *hash.Hasher {
+	return s.F(n)
+}
+
+func (sha2) HMsg(r, message []byte, size int) []byte {
+	return sum(sha512.New(), size, r, message)
+}
+
+func (sha2) PRF(key, message []byte, size int) []byte {
+	return sum(hmac.New(sha512.New, key), size, message)
+}
+
+func (sha2) PRG(seed []byte) io.Reader {
+	return &counterPRG{seed: append([]byte(nil), seed...), offset: sha256.Size}
+}
+
+// counterPRG 依次输出 SHA-256(seed || toByte(i, 8))，i 从 0 开始
+type counterPRG struct {
+	seed    []byte
+	counter uint64
+	// 当前分组中还未使用的部分
+	block  [sha256.Size]byte
+	offset int
+}
+
+func (r *counterPRG) Read(p []byte) (n int, err error) {
+	var ctr [8]byte
+	for n < len(p) {
+		if r.offset == len(r.block) {
+			binary.BigEndian.PutUint64(ctr[:], r.counter)
+			h := sha256.New()
+			h.Write(r.seed)
+			h.Write(ctr[:])
+			h.Sum(r.block[:0])
+			r.counter++
+			r.offset = 0
+		}
+		c := copy(p[n:], r.block[r.offset:])
+		r.offset += c
+		n += c
+	}
+	return n, nil
+}
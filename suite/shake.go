@@ -0,0 +1,48 @@
+package suite
+
+import (
+	"io"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/rand"
+	"golang.org/x/crypto/sha3"
+)
+
+// SHAKE256 基于 SHAKE256，n 可以为 8 的任意倍数
+//
+//	F, H:  SHAKE256(M)，输出 n bits，F 和 H 的输入长度不同
+//	H_msg: SHAKE256(R || M)
+//	PRF:   SHAKE256(K || M)，SHA-3 没有长度扩展攻击
+//	PRG:   SHAKE256(seed)，见 rand.NewShake256
+var SHAKE256 HashSuite = shake256{}
+
+type shake256 struct{}
+
+func (shake256) Name() string {
+	return "shake256"
+}
+
+func (shake256) Supports(n int) bool {
+	return supports(n, 0)
+}
+
+func (s shake256) F(n int) *hash.Hasher {
+	checkN(s, n)
+	return hash.NewShakeHasher(sha3.NewShake256, n/8)
+}
+
+func (s shake256) H(n int) *hash.Hasher {
+	return s.F(n)
+}
+
+func (shake256) HMsg(r, message []byte, size int) []byte {
+	return squeeze(sha3.NewShake256(), size, r, message)
+}
+
+func (shake256) PRF(key, message []byte, size int) []byte {
+	return squeeze(sha3.NewShake256(), size, key, message)
+}
+
+func (shake256) PRG(seed []byte) io.Reader {
+	return rand.NewShake256(seed)
+}
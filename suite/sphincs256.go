@@ -0,0 +1,53 @@
+package suite
+
+import (
+	"io"
+
+	"github.com/dchest/blake256"
+	"github.com/dchest/blake512"
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/rand"
+)
+
+// SPHINCS256 SPHINCS-256 中使用的哈希函数，见论文 表1 以及 hash 包
+//
+//	F, H:  基于 ChaCha12 置换，n 最多为 256
+//	H_msg: BLAKE-512(R || M)
+//	PRF:   输出不超过 32 bytes 时为 BLAKE-256(K || M)，否则为 BLAKE-512(K || M)
+//	PRG:   ChaCha12，见 rand.New
+var SPHINCS256 HashSuite = sphincs256{}
+
+type sphincs256 struct{}
+
+func (sphincs256) Name() string {
+	return "sphincs256"
+}
+
+func (sphincs256) Supports(n int) bool {
+	return supports(n, 256)
+}
+
+func (s sphincs256) F(n int) *hash.Hasher {
+	checkN(s, n)
+	return hash.NewFHasher(n)
+}
+
+func (s sphincs256) H(n int) *hash.Hasher {
+	checkN(s, n)
+	return hash.NewHHasher(n)
+}
+
+func (sphincs256) HMsg(r, message []byte, size int) []byte {
+	return sum(blake512.New(), size, r, message)
+}
+
+func (sphincs256) PRF(key, message []byte, size int) []byte {
+	if size <= blake256.Size {
+		return sum(blake256.New(), size, key, message)
+	}
+	return sum(blake512.New(), size, key, message)
+}
+
+func (sphincs256) PRG(seed []byte) io.Reader {
+	return rand.New(seed)
+}
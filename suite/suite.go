@@ -0,0 +1,136 @@
+// Package suite 提供 SPHINCS 等基于哈希的签名算法中使用的哈希函数族 HashSuite
+//
+// 一个哈希函数族包含论文 表1 中的五个函数
+//
+//	F      {0,1}^n -> {0,1}^n，WOTS+ 的链以及 HORST 的叶子节点
+//	H      {0,1}^2n -> {0,1}^n，L-Tree，Merkle 树以及 HORST 树的内部节点
+//	H_msg  随机化的消息摘要 H(R, M)
+//	PRF    SPHINCS 中的 F(M, SK2) 以及 Fα(A, SK1)
+//	PRG    由种子展开私钥的伪随机数生成器 G
+//
+// SPHINCS256 和论文以及参考实现一致，是 signature.Sphincs 的默认值
+// 其余的哈希函数族只依赖于对应哈希函数的安全性，可以用来比较不同哈希函数的性能以及安全性假设
+// 使用不同哈希函数族生成的密钥和签名互不兼容，密钥的标识和编码中包含哈希函数族的编号，见 ID
+package suite
+
+import (
+	"errors"
+	"fmt"
+	stdhash "hash"
+	"io"
+
+	"github.com/junhaideng/sphincs/hash"
+)
+
+// HashSuite 一组哈希函数，实现可以被多个 goroutine 同时使用
+// F 和 H 返回的 Hasher 只作为原型，每次使用之前 Clone，见 hash.Hasher
+type HashSuite interface {
+	// Name 名称，见 ByName
+	Name() string
+	// Supports 是否支持输出为 n bits 的 F 和 H
+	Supports(n int) bool
+	// F 输入以及输出都为 n bits，不支持 n 时 panic
+	F(n int) *hash.Hasher
+	// H 输入为两个 n bits 的块，输出为 n bits，不支持 n 时 panic
+	H(n int) *hash.Hasher
+	// HMsg 计算 H_msg(R, M)，输出 size bytes，size 最多为 MaxOutput
+	HMsg(r, message []byte, size int) []byte
+	// PRF 计算 PRF(K, M)，输出 size bytes，size 最多为 MaxOutput
+	PRF(key, message []byte, size int) []byte
+	// PRG 返回由 seed 初始化的伪随机数生成器，相同的 seed 输出相同
+	PRG(seed []byte) io.Reader
+}
+
+// MaxOutput HMsg 和 PRF 最多输出的字节数，SPHINCS 中 R 和消息摘要都是 512 bits
+const MaxOutput = 64
+
+// ErrUnknownSuite ByName 中的名称不存在
+var ErrUnknownSuite = errors.New("unknown hash suite")
+
+// suites 中的下标加 1 为哈希函数族的编号，已经写入密钥中，新的哈希函数族只能添加在最后
+var suites = []HashSuite{SPHINCS256, SHA2, SHAKE256, BLAKE2b, BLAKE2s, BLAKE3, HARAKA}
+
+// ByName 根据名称返回哈希函数族，名称见 Names
+func ByName(name string) (HashSuite, error) {
+	for _, s := range suites {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, ErrUnknownSuite
+}
+
+// ID 哈希函数族的编号，从 1 开始，自定义的 HashSuite 返回 0
+func ID(s HashSuite) uint32 {
+	for i, st := range suites {
+		if st == s {
+			return uint32(i + 1)
+		}
+	}
+	return 0
+}
+
+// ByID 根据编号返回哈希函数族，是 ID 的逆操作
+func ByID(id uint32) (HashSuite, error) {
+	if id == 0 || id > uint32(len(suites)) {
+		return nil, ErrUnknownSuite
+	}
+	return suites[id-1], nil
+}
+
+// Names 返回所有哈希函数族的名称
+func Names() []string {
+	res := make([]string, len(suites))
+	for i, s := range suites {
+		res[i] = s.Name()
+	}
+	return res
+}
+
+// supports n 为 8 的倍数，并且不超过 max，max 为 0 时没有上限
+func supports(n, max int) bool {
+	return n > 0 && n%8 == 0 && (max == 0 || n <= max)
+}
+
+func checkN(s HashSuite, n int) {
+	if !s.Supports(n) {
+		panic(fmt.Sprintf("%s 不支持 n = %d", s.Name(), n))
+	}
+}
+
+func checkOutput(size int) {
+	if size <= 0 || size > MaxOutput {
+		panic(fmt.Sprintf("输出应该在 1 到 %d bytes 之间", MaxOutput))
+	}
+}
+
+// sum 计算 parts 连接之后的哈希值，截取前 size bytes
+func sum(h stdhash.Hash, size int, parts ...[]byte) []byte {
+	checkOutput(size)
+	if size > h.Size() {
+		panic("输出超过了哈希值的长度")
+	}
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)[:size]
+}
+
+// xof 可扩展输出的哈希函数，例如 SHAKE256 以及 BLAKE2X
+type xof interface {
+	io.Writer
+	io.Reader
+}
+
+// squeeze 吸收 parts 之后输出 size bytes
+func squeeze(x xof, size int, parts ...[]byte) []byte {
+	checkOutput(size)
+	for _, p := range parts {
+		x.Write(p)
+	}
+	out := make([]byte, size)
+	if _, err := io.ReadFull(x, out); err != nil {
+		panic(err)
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package suite
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io"
+	"testing"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/rand"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/sha3"
+	"lukechampine.com/blake3"
+)
+
+func readN(r io.Reader, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func writeXOF(x xof, b []byte) io.Reader {
+	x.Write(b)
+	return x
+}
+
+// SPHINCS256 和 hash 包中的函数以及 ChaCha12 的输出完全相同
+func TestSPHINCS256(t *testing.T) {
+	assert := assert.New(t)
+	key := bytes.Repeat([]byte{1}, 32)
+	message := []byte("hello world")
+
+	for _, n := range []int{128, 256} {
+		in := bytes.Repeat([]byte{2}, n/8)
+		assert.Equal(hash.NewF(n)(in), SPHINCS256.F(n).Clone().Sum(nil, in))
+		in = bytes.Repeat([]byte{3}, n/4)
+		assert.Equal(hash.NewH(n)(in), SPHINCS256.H(n).Clone().Sum(nil, in))
+	}
+	assert.Equal(hash.HashMessage(key, message), SPHINCS256.HMsg(key, message, 64))
+	assert.Equal(hash.Func(message, key), SPHINCS256.PRF(key, message, 64))
+	assert.Equal(hash.FuncAlpha(message, key), SPHINCS256.PRF(key, message, 32))
+	assert.Equal(readN(rand.NewChaCha12(key), 100), readN(SPHINCS256.PRG(key), 100))
+
+	assert.False(SPHINCS256.Supports(512))
+	assert.Panics(func() { SPHINCS256.F(512) })
+}
+
+// 和标准库以及 golang.org/x/crypto 中的实现比较
+func TestSuitesKnownAnswer(t *testing.T) {
+	assert := assert.New(t)
+	key := bytes.Repeat([]byte{1}, 32)
+	message := []byte("hello world")
+
+	d256 := sha256.Sum256(message)
+	d512 := sha512.Sum512(message)
+	assert.Equal(d256[:16], SHA2.F(128).Clone().Sum(nil, message))
+	assert.Equal(d512[:48], SHA2.F(384).Clone().Sum(nil, message))
+	hm := hmac.New(sha512.New, key)
+	hm.Write(message)
+	assert.Equal(hm.Sum(nil)[:32], SHA2.PRF(key, message, 32))
+	first := sha256.Sum256(append(append([]byte{}, key...), make([]byte, 8)...))
+	assert.Equal(first[:], readN(SHA2.PRG(key), 32))
+
+	shake := make([]byte, 64)
+	sha3.ShakeSum256(shake, append(append([]byte{}, key...), message...))
+	assert.Equal(shake, SHAKE256.HMsg(key, message, 64))
+	assert.Equal(shake[:24], SHAKE256.F(192).Clone().Sum(nil, append(append([]byte{}, key...), message...)))
+
+	b2b := blake2b.Sum512(message)
+	assert.Equal(b2b[:], BLAKE2b.F(512).Clone().Sum(nil, message))
+	b2s := blake2s.Sum256(message)
+	assert.Equal(b2s[:16], BLAKE2s.F(128).Clone().Sum(nil, message))
+	b3 := blake3.Sum256(message)
+	assert.Equal(b3[:], BLAKE3.F(256).Clone().Sum(nil, message))
+
+	// PRF 使用 BLAKE2 以及 BLAKE3 的 keyed 模式
+	x, err := blake2b.NewXOF(48, key)
+	assert.Nil(err)
+	assert.Equal(readN(writeXOF(x, message), 48), BLAKE2b.PRF(key, message, 48))
+	long := bytes.Repeat([]byte{3}, 100)
+	k2b := blake2b.Sum512(long)
+	x, err = blake2b.NewXOF(48, k2b[:])
+	assert.Nil(err)
+	assert.Equal(readN(writeXOF(x, message), 48), BLAKE2b.PRF(long, message, 48))
+	xs, err := blake2s.NewXOF(32, key)
+	assert.Nil(err)
+	assert.Equal(readN(writeXOF(xs, message), 32), BLAKE2s.PRF(key, message, 32))
+	k2s := blake2s.Sum256(long)
+	xs, err = blake2s.NewXOF(32, k2s[:])
+	assert.Nil(err)
+	assert.Equal(readN(writeXOF(xs, message), 32), BLAKE2s.PRF(long, message, 32))
+	mac := blake3.New(64, key)
+	mac.Write(message)
+	assert.Equal(mac.Sum(nil), BLAKE3.PRF(key, message, 64))
+	derived := make([]byte, 32)
+	blake3.DeriveKey(derived, blake3PRFContext, key[:16])
+	mac = blake3.New(32, derived)
+	mac.Write(message)
+	assert.Equal(mac.Sum(nil), BLAKE3.PRF(key[:16], message, 32))
+
+	// F 和 H 的输入不足 256 和 512 bits 时在后面补 0
+	in := append(bytes.Repeat([]byte{2}, 16), make([]byte, 48)...)
+	out := make([]byte, 32)
+	hash.Haraka256(out, in[:32])
+	assert.Equal(out[:16], HARAKA.F(128).Clone().Sum(nil, in[:16]))
+	assert.Equal(out, HARAKA.F(256).Clone().Sum(nil, in[:32]))
+	hash.Haraka512(out, in)
+	assert.Equal(out[:16], HARAKA.H(128).Clone().Sum(nil, in[:32]))
+	assert.Equal(out, HARAKA.H(256).Clone().Sum(nil, in))
+	assert.Panics(func() { HARAKA.F(128).Clone().Sum(nil, in[:15]) })
+	sponge := hash.NewHarakaS()
+	sponge.Write(append(append([]byte{}, key...), message...))
+	assert.Equal(readN(sponge, 64), HARAKA.HMsg(key, message, 64))
+}
+
+func TestSuites(t *testing.T) {
+	assert := assert.New(t)
+	key := bytes.Repeat([]byte{1}, 32)
+	message := []byte("hello world")
+
+	assert.Equal([]string{"sphincs256", "sha2", "shake256", "blake2b", "blake2s", "blake3", "haraka"}, Names())
+	for _, name := range Names() {
+		s, err := ByName(name)
+		if !assert.Nil(err) {
+			continue
+		}
+		assert.Equal(name, s.Name())
+		byID, err := ByID(ID(s))
+		assert.Nil(err, name)
+		assert.Equal(s, byID, name)
+
+		for _, n := range []int{128, 256} {
+			assert.True(s.Supports(n), name)
+			in := bytes.Repeat([]byte{2}, n/8)
+			out := s.F(n).Clone().Sum(nil, in)
+			assert.Equal(n/8, len(out), name)
+			assert.Equal(out, s.F(n).Clone().Sum(nil, in), name)
+			assert.Equal(n/8, len(s.H(n).Clone().Sum(nil, append(in, in...))), name)
+		}
+		assert.False(s.Supports(0), name)
+		assert.False(s.Supports(100), name)
+
+		for _, size := range []int{17, 32, 64} {
+			d := s.HMsg(key, message, size)
+			assert.Equal(size, len(d), name)
+			assert.Equal(d, s.HMsg(key, message, size), name)
+			assert.NotEqual(d, s.HMsg(key[1:], message, size), name)
+			assert.Equal(size, len(s.PRF(key, message, size)), name)
+		}
+		assert.Panics(func() { s.HMsg(key, message, MaxOutput+1) }, name)
+		assert.Panics(func() { s.PRF(key, message, 0) }, name)
+
+		// 分多次读取和一次读取的结果相同
+		all := readN(s.PRG(key), 100)
+		r := s.PRG(key)
+		var parts []byte
+		for _, l := range []int{1, 31, 33, 35} {
+			parts = append(parts, readN(r, l)...)
+		}
+		assert.Equal(all, parts, name)
+		assert.NotEqual(all, readN(s.PRG(key[1:]), 100), name)
+	}
+
+	_, err := ByName("haraka-128f")
+	assert.Equal(ErrUnknownSuite, err)
+
+	// 编号已经写入密钥中，不能改变
+	assert.Equal(uint32(1), ID(SPHINCS256))
+	assert.Equal(uint32(7), ID(HARAKA))
+	assert.Equal(uint32(0), ID(nil))
+	for _, id := range []uint32{0, uint32(len(Names()) + 1)} {
+		_, err = ByID(id)
+		assert.Equal(ErrUnknownSuite, err)
+	}
+}
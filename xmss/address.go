@@ -0,0 +1,51 @@
+package xmss
+
+import "encoding/binary"
+
+// address RFC 8391 2.5 中的地址，8 个 32 bits 的字，按照大端序编码成 32 bytes
+//
+//	0: layer address
+//	1-2: tree address
+//	3: type，0 为 OTS，1 为 L-tree，2 为 hash tree
+//	4: OTS address / L-tree address / padding
+//	5: chain address / tree height
+//	6: hash address / tree index
+//	7: keyAndMask
+type address [8]uint32
+
+const (
+	addrOTS   = 0
+	addrLTree = 1
+	addrTree  = 2
+)
+
+// newAddress 返回指定子树中某一类型的地址，其余字段为 0
+func newAddress(layer uint32, tree uint64, t uint32) address {
+	var a address
+	a[0] = layer
+	a[1] = uint32(tree >> 32)
+	a[2] = uint32(tree)
+	a[3] = t
+	return a
+}
+
+func (a *address) setOTS(i uint32)        { a[4] = i }
+func (a *address) setLTree(i uint32)      { a[4] = i }
+func (a *address) setChain(i uint32)      { a[5] = i }
+func (a *address) setTreeHeight(i uint32) { a[5] = i }
+func (a *address) setHash(i uint32)       { a[6] = i }
+func (a *address) setTreeIndex(i uint32)  { a[6] = i }
+func (a *address) treeIndex() uint32      { return a[6] }
+func (a *address) setKeyAndMask(i uint32) { a[7] = i }
+
+func (a *address) bytes() []byte {
+	return a.put(make([]byte, 32))
+}
+
+// put 将地址编码到 b 中，b 至少为 32 bytes
+func (a *address) put(b []byte) []byte {
+	for i, v := range a {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b[:32]
+}
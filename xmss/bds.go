@@ -0,0 +1,165 @@
+package xmss
+
+import (
+	"encoding/binary"
+
+	"github.com/junhaideng/sphincs/hash"
+	"github.com/junhaideng/sphincs/merkle"
+)
+
+// 私钥中保存每一层当前子树的 BDS 状态 (见 merkle.BDS)，和参考实现中的 xmss_core_fast 类似
+// 签名时不再计算整棵子树，每次只需要把鉴权路径向前推进一个叶子节点
+// 一棵子树用完之后，遍历一次下一棵子树的叶子节点建立新的状态
+//
+// 状态的格式，接在 RFC 8391 格式的私钥后面
+//   idx || sig_1 || ... || sig_{d-1} || (u32(len) || BDS 状态 || 0 填充) * d
+// 其中 idx 为状态对应的下一次签名的索引，和私钥中的 idx 不同时 (例如私钥的 idx 被直接修改) 重新建立状态
+// sig_j 为第 j 层对第 j-1 层当前子树的根节点的 WOTS+ 签名，只有第 j-1 层换了一棵子树时才会改变
+
+// state 解析之后的 BDS 状态，bds[j] 为第 j 层当前子树的状态，sigs[j-1] 即上面的 sig_j
+type state struct {
+	bds  []*merkle.BDS
+	sigs [][]byte
+}
+
+// bdsK BDS 中保存的最上面的层数，需要 h/d - k 为偶数，这里取最小值
+func (x *XMSS) bdsK() int {
+	return x.p.H / x.p.D % 2
+}
+
+// bdsSize 一层的 BDS 状态在私钥中占用的长度
+func (x *XMSS) bdsSize() int {
+	return 4 + merkle.BDSStateSize(x.p.H/x.p.D+1, 8*x.p.N, x.bdsK())
+}
+
+// stateSize 私钥中状态的长度
+func (x *XMSS) stateSize() int {
+	return x.indexSize() + (x.p.D-1)*x.wots.len*x.p.N + x.p.D*x.bdsSize()
+}
+
+// subtree 第 layer 层中第 tree 棵子树的 TreeHash 以及叶子节点，节点的计算和 RFC 8391 算法 9 相同
+// 节点使用 x 中的 hasher 计算，所以 x 应该是 local 返回的副本
+func (x *XMSS) subtree(skSeed, seed []byte, layer uint32, tree uint64) (*merkle.TreeHash, merkle.LeafFunc) {
+	a := newAddress(layer, tree, addrTree)
+	t, err := merkle.NewTreeHashWithNode(x.p.H/x.p.D+1, 8*x.p.N, x.hash.proto,
+		func(_ *hash.Hasher, dst, left, right []byte, level, index int) []byte {
+			a.setTreeHeight(uint32(level))
+			a.setTreeIndex(uint32(index))
+			return x.hash.randHash(dst, left, right, seed, a)
+		})
+	if err != nil {
+		// 参数集中的高度和 n 都是合法的
+		panic(err)
+	}
+	leaf := func(i int) []byte {
+		return x.leaf(skSeed, seed, layer, tree, uint32(i))
+	}
+	return t, leaf
+}
+
+// position 签名 idx 时第 layer 层使用的子树以及叶子节点
+func (x *XMSS) position(idx uint64, layer int) (uint64, int) {
+	height := x.p.H / x.p.D
+	idx >>= uint(layer * height)
+	return idx >> height, int(idx & (1<<height - 1))
+}
+
+// newState 遍历每一层的一棵子树，建立签名 idx 时的状态
+func (x *XMSS) newState(skSeed, seed []byte, idx uint64) *state {
+	st := &state{bds: make([]*merkle.BDS, x.p.D), sigs: make([][]byte, x.p.D-1)}
+	for j := range st.bds {
+		tree, leaf := x.position(idx, j)
+		t, leafFunc := x.subtree(skSeed, seed, uint32(j), tree)
+		b, err := t.BDSAt(leafFunc, x.bdsK(), leaf)
+		if err != nil {
+			panic(err)
+		}
+		st.bds[j] = b
+		if j > 0 {
+			st.sigs[j-1] = x.wotsSign(skSeed, seed, idx, j, st.bds[j-1].Root())
+		}
+	}
+	return st
+}
+
+// wotsSign 签名 idx 时第 layer 层的 WOTS+ 签名，m 为消息的摘要 (最底层) 或者下一层子树的根节点
+func (x *XMSS) wotsSign(skSeed, seed []byte, idx uint64, layer int, m []byte) []byte {
+	tree, leaf := x.position(idx, layer)
+	ots := newAddress(uint32(layer), tree, addrOTS)
+	ots.setOTS(uint32(leaf))
+	return x.wots.sign(m, skSeed, seed, ots)
+}
+
+// loadState 读取私钥中签名 idx 时的状态，状态的 idx 不一致或者无法解析时重新建立
+func (x *XMSS) loadState(b, skSeed, seed []byte, idx uint64) *state {
+	if toInt(b[:x.indexSize()]) != idx {
+		return x.newState(skSeed, seed, idx)
+	}
+	b = b[x.indexSize():]
+	st := &state{bds: make([]*merkle.BDS, x.p.D), sigs: make([][]byte, x.p.D-1)}
+	size := x.wots.len * x.p.N
+	for j := range st.sigs {
+		st.sigs[j] = b[j*size : (j+1)*size]
+	}
+	b = b[len(st.sigs)*size:]
+	for j := range st.bds {
+		block := b[j*x.bdsSize() : (j+1)*x.bdsSize()]
+		length := int(binary.BigEndian.Uint32(block))
+		tree, leaf := x.position(idx, j)
+		t, leafFunc := x.subtree(skSeed, seed, uint32(j), tree)
+		if length > len(block)-4 {
+			return x.newState(skSeed, seed, idx)
+		}
+		bds, err := t.RestoreBDS(leafFunc, block[4:4+length])
+		if err != nil || bds.Index() != leaf {
+			return x.newState(skSeed, seed, idx)
+		}
+		st.bds[j] = bds
+	}
+	return st
+}
+
+// next 签名 idx 之后，更新为签名 idx+1 时的状态
+// 最底层推进一个叶子节点，子树用完时建立下一棵子树的状态，并且上一层推进一个叶子节点
+func (x *XMSS) next(st *state, skSeed, seed []byte, idx uint64) {
+	idx++
+	for j := range st.bds {
+		tree, leaf := x.position(idx, j)
+		if j > 0 {
+			st.sigs[j-1] = x.wotsSign(skSeed, seed, idx, j, st.bds[j-1].Root())
+		}
+		if leaf != 0 {
+			if err := st.bds[j].Next(); err != nil {
+				panic(err)
+			}
+			return
+		}
+		t, leafFunc := x.subtree(skSeed, seed, uint32(j), tree)
+		b, err := t.BDS(leafFunc, x.bdsK())
+		if err != nil {
+			panic(err)
+		}
+		st.bds[j] = b
+	}
+}
+
+// putState 将签名 idx 时的状态写入 b
+func (x *XMSS) putState(b []byte, st *state, idx uint64) {
+	copy(b, toByte(idx, x.indexSize()))
+	b = b[x.indexSize():]
+	for _, sig := range st.sigs {
+		b = b[copy(b, sig):]
+	}
+	for _, bds := range st.bds {
+		block := b[:x.bdsSize()]
+		b = b[x.bdsSize():]
+		data, err := bds.MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+		binary.BigEndian.PutUint32(block, uint32(len(data)))
+		for i := 4 + copy(block[4:], data); i < len(block); i++ {
+			block[i] = 0
+		}
+	}
+}
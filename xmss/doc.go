@@ -0,0 +1,8 @@
+// Package xmss 实现了 RFC 8391 中的 XMSS 以及 XMSS^MT 有状态签名
+//
+// RFC 8391 中 WOTS+ 链上每一步的 key 和掩码，以及 L-tree 和 Merkle 树中每一个节点的 key 和掩码
+// 都是由 SEED 和地址通过 PRF 生成的 (RAND_HASH)，而 signature.WOTSPlus，merkle.LTreeWithMask
+// 以及 merkle.Tree 使用的是 SPHINCS-256 中一组固定的掩码，所以这里单独实现了一份，结构和它们保持一致
+//
+// 支持 RFC 8391 5.3 和 5.4 中所有的参数集，w = 16，WOTS+ 私钥的生成方式和参考实现一致
+package xmss
@@ -0,0 +1,28 @@
+//go:build go1.18
+// +build go1.18
+
+package xmss
+
+import (
+	"testing"
+)
+
+// FuzzVerify 任意输入都不能 panic，并且 Verify 和 VerifyErr 的结果需要一致
+// 运行方式: go test -run '^$' -fuzz FuzzVerify ./xmss
+func FuzzVerify(f *testing.F) {
+	x := NewWithParams(Params{Name: "test", OID: 1, MT: true, Func: SHA2, N: 32, H: 2, D: 2, W: 16})
+	sk, pk := x.GenerateKey()
+	msg := []byte("hello world")
+	sig := x.Sign(msg, sk)
+
+	f.Add(msg, pk, sig)
+	f.Add(msg, pk, sig[:len(sig)/2])
+	f.Add(msg, pk[:len(pk)/2], sig)
+	f.Add([]byte{}, []byte{}, []byte{})
+	f.Fuzz(func(t *testing.T, msg, pk, sig []byte) {
+		err := x.VerifyErr(msg, pk, sig)
+		if ok := x.Verify(msg, pk, sig); ok != (err == nil) {
+			t.Fatalf("Verify returns %t but VerifyErr returns %v", ok, err)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package xmss
+
+import (
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/hash"
+	"golang.org/x/crypto/sha3"
+)
+
+// 不同用途的哈希通过前缀 toByte(x, n) 区分，见 RFC 8391 5.1
+// PRF_keygen 为参考实现以及 NIST SP 800-208 中使用的私钥生成方式
+const (
+	paddingF         = 0
+	paddingH         = 1
+	paddingHMsg      = 2
+	paddingPRF       = 3
+	paddingPRFKeygen = 4
+)
+
+// hasher 每次签名以及校验使用一个，见 XMSS.local，所以可以复用哈希函数的状态以及缓冲区
+type hasher struct {
+	n int
+	// proto 只作为原型，h 为 proto 的副本
+	proto *hash.Hasher
+	h     *hash.Hasher
+	// prefix 为 toByte(padding, n)，key 和 bm 为 PRF 生成的 key 以及掩码
+	// node 为链式哈希的中间值，secret 为 WOTS+ 的私钥块
+	prefix, key, bm, xor, node, secret []byte
+	addr                               [32]byte
+	parts                              [][]byte
+}
+
+func newHasher(p Params) *hasher {
+	var proto *hash.Hasher
+	switch {
+	case p.Func == SHA2 && p.N == 32:
+		proto = hash.NewSha256Hasher()
+	case p.Func == SHA2:
+		proto = hash.NewSha512Hasher()
+	case p.N == 32:
+		proto = hash.NewShakeHasher(sha3.NewShake128, p.N)
+	default:
+		proto = hash.NewShakeHasher(sha3.NewShake256, p.N)
+	}
+	return &hasher{n: p.N, proto: proto, h: proto}
+}
+
+// clone 返回使用单独的哈希函数状态以及缓冲区的 hasher
+func (h *hasher) clone() *hasher {
+	return &hasher{n: h.n, proto: h.proto, h: h.proto.Clone()}
+}
+
+// core 计算 Hash(toByte(padding, n) || data...)，输出 n bytes，追加到 dst 后面
+// 读取完所有输入之后才写入 dst，所以 dst 可以和 data 重叠
+func (h *hasher) core(dst []byte, padding byte, data ...[]byte) []byte {
+	if len(h.prefix) != h.n {
+		h.prefix = make([]byte, h.n)
+	}
+	h.prefix[h.n-1] = padding
+	h.parts = append(append(h.parts[:0], h.prefix), data...)
+	return h.h.Sum(dst, h.parts...)
+}
+
+// hMsg key 为 r || root || toByte(idx, n)
+func (h *hasher) hMsg(key, m []byte) []byte {
+	return h.core(nil, paddingHMsg, key, m)
+}
+
+func (h *hasher) prf(key, m []byte) []byte {
+	return h.core(nil, paddingPRF, key, m)
+}
+
+// prfKeygen 结果写入 dst
+func (h *hasher) prfKeygen(dst, skSeed, seed []byte, a address) []byte {
+	return h.core(dst, paddingPRFKeygen, skSeed, seed, a.put(h.addr[:]))
+}
+
+// chainStep 链式哈希中的一步，key 和掩码都由地址生成，结果写回 x
+func (h *hasher) chainStep(x, seed []byte, a address) {
+	a.setKeyAndMask(0)
+	h.key = h.core(h.key[:0], paddingPRF, seed, a.put(h.addr[:]))
+	a.setKeyAndMask(1)
+	h.bm = h.core(h.bm[:0], paddingPRF, seed, a.put(h.addr[:]))
+	h.xor = common.AppendXor(h.xor[:0], x, h.bm)
+	h.core(x[:0], paddingF, h.key, h.xor)
+}
+
+// randHash RFC 8391 算法 7 RAND_HASH，结果追加到 dst 后面，dst 可以和 left 或者 right 重叠
+func (h *hasher) randHash(dst, left, right, seed []byte, a address) []byte {
+	a.setKeyAndMask(0)
+	h.key = h.core(h.key[:0], paddingPRF, seed, a.put(h.addr[:]))
+	a.setKeyAndMask(1)
+	h.bm = h.core(h.bm[:0], paddingPRF, seed, a.put(h.addr[:]))
+	a.setKeyAndMask(2)
+	h.bm = h.core(h.bm, paddingPRF, seed, a.put(h.addr[:]))
+
+	h.xor = common.AppendXor(h.xor[:0], left, h.bm[:h.n])
+	h.xor = common.AppendXor(h.xor, right, h.bm[h.n:])
+	return h.core(dst, paddingH, h.key, h.xor)
+}
+
+// toByte 将 x 按照大端序编码成 n bytes
+func toByte(x uint64, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0 && x > 0; i-- {
+		b[i] = byte(x)
+		x >>= 8
+	}
+	return b
+}
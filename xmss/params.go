@@ -0,0 +1,100 @@
+package xmss
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrParamsNotSupport = errors.New("xmss: parameter set is not supported")
+
+// 哈希函数族
+const (
+	// SHA2 n = 32 时使用 SHA-256，n = 64 时使用 SHA-512
+	SHA2 = iota
+	// SHAKE n = 32 时使用 SHAKE128，n = 64 时使用 SHAKE256
+	SHAKE
+)
+
+// Params XMSS 以及 XMSS^MT 的参数，见 RFC 8391 第 5 节
+type Params struct {
+	Name string
+	// OID，XMSS 和 XMSS^MT 各自有一套编号
+	OID uint32
+	// MT 为 true 表示 XMSS^MT
+	MT   bool
+	Func int
+	// 哈希值的长度，单位 bytes
+	N int
+	// 整棵树的高度
+	H int
+	// 层数，XMSS 为 1
+	D int
+	// Winternitz 参数
+	W int
+}
+
+func newParams(mt bool, oid uint32, f, n, h, d int) Params {
+	p := Params{OID: oid, MT: mt, Func: f, N: n, H: h, D: d, W: 16}
+	fn := "SHA2"
+	if f == SHAKE {
+		fn = "SHAKE"
+	}
+	if mt {
+		p.Name = fmt.Sprintf("XMSSMT-%s_%d/%d_%d", fn, h, d, 8*n)
+	} else {
+		p.Name = fmt.Sprintf("XMSS-%s_%d_%d", fn, h, 8*n)
+	}
+	return p
+}
+
+// params RFC 8391 5.3 以及 5.4 中定义的所有参数集
+var params = func() []Params {
+	var res []Params
+	oid := uint32(1)
+	for _, f := range []int{SHA2, SHAKE} {
+		for _, n := range []int{32, 64} {
+			for _, h := range []int{10, 16, 20} {
+				res = append(res, newParams(false, oid, f, n, h, 1))
+				oid++
+			}
+		}
+	}
+	oid = 1
+	hd := [][2]int{{20, 2}, {20, 4}, {40, 2}, {40, 4}, {40, 8}, {60, 3}, {60, 6}, {60, 12}}
+	for _, f := range []int{SHA2, SHAKE} {
+		for _, n := range []int{32, 64} {
+			for _, v := range hd {
+				res = append(res, newParams(true, oid, f, n, v[0], v[1]))
+				oid++
+			}
+		}
+	}
+	return res
+}()
+
+// AllParams 返回所有支持的参数集
+func AllParams() []Params {
+	res := make([]Params, len(params))
+	copy(res, params)
+	return res
+}
+
+// ParamsByName 根据名称查找参数集，例如 XMSS-SHA2_10_256，XMSSMT-SHAKE_20/4_512
+func ParamsByName(name string) (Params, error) {
+	for _, p := range params {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Params{}, ErrParamsNotSupport
+}
+
+// ParamsByOID 根据 OID 查找参数集
+func ParamsByOID(mt bool, oid uint32) (Params, error) {
+	for _, p := range params {
+		if p.MT == mt && p.OID == oid {
+			return p, nil
+		}
+	}
+	return Params{}, ErrParamsNotSupport
+}
@@ -0,0 +1,61 @@
+package xmss
+
+// ltree RFC 8391 算法 8，将 WOTS+ 公钥压缩成一个 n bytes 的叶子节点
+// 每一层相邻的两个节点使用 RAND_HASH 合并，节点数为奇数时最后一个节点直接提升到上一层
+// 直接在 pk 上计算，pk 的内容会被覆盖，返回值为 pk 的前 n bytes
+func (x *XMSS) ltree(pk, seed []byte, a address) []byte {
+	n := x.p.N
+	l := len(pk) / n
+	a.setTreeHeight(0)
+	for height := uint32(0); l > 1; height++ {
+		a.setTreeHeight(height)
+		// 第 i 个父节点写入第 i 个块，2i 和 2i+1 在此之前已经读取
+		for i := 0; i < l/2; i++ {
+			a.setTreeIndex(uint32(i))
+			x.hash.randHash(pk[i*n:i*n], pk[2*i*n:(2*i+1)*n], pk[(2*i+1)*n:(2*i+2)*n], seed, a)
+		}
+		if l%2 == 1 {
+			copy(pk[l/2*n:], pk[(l-1)*n:l*n])
+		}
+		l = (l + 1) / 2
+	}
+	return pk[:n]
+}
+
+// leaf 计算子树中索引为 i 的叶子节点
+func (x *XMSS) leaf(skSeed, seed []byte, layer uint32, tree uint64, i uint32) []byte {
+	ots := newAddress(layer, tree, addrOTS)
+	ots.setOTS(i)
+	pk := x.wots.genPK(skSeed, seed, ots)
+
+	l := newAddress(layer, tree, addrLTree)
+	l.setLTree(i)
+	return x.ltree(pk, seed, l)
+}
+
+// rootFromSig RFC 8391 算法 13，根据 WOTS+ 签名以及鉴权路径计算出子树的根节点
+func (x *XMSS) rootFromSig(idx uint32, sig, auth, m, seed []byte, layer uint32, tree uint64) []byte {
+	n := x.p.N
+	ots := newAddress(layer, tree, addrOTS)
+	ots.setOTS(idx)
+	pk := x.wots.pkFromSig(sig, m, seed, ots)
+
+	l := newAddress(layer, tree, addrLTree)
+	l.setLTree(idx)
+	node := x.ltree(pk, seed, l)
+
+	a := newAddress(layer, tree, addrTree)
+	a.setTreeIndex(idx)
+	for k := 0; k < x.p.H/x.p.D; k++ {
+		a.setTreeHeight(uint32(k))
+		block := auth[k*n : (k+1)*n]
+		if idx>>k&1 == 0 {
+			a.setTreeIndex(a.treeIndex() / 2)
+			node = x.hash.randHash(node[:0], node, block, seed, a)
+		} else {
+			a.setTreeIndex((a.treeIndex() - 1) / 2)
+			node = x.hash.randHash(node[:0], block, node, seed, a)
+		}
+	}
+	return node
+}
@@ -0,0 +1,106 @@
+package xmss
+
+// RFC 8391 第 3 节中的 WOTS+
+// 和 signature.WOTSPlus 不同，这里每一步链式哈希的 key 和掩码都是由 SEED 和地址生成的
+// 私钥按照参考实现的方式生成: sk_i = PRF_keygen(SK_SEED, SEED || ADRS)
+
+type wots struct {
+	*hasher
+	w    int
+	logW int
+	len1 int
+	len2 int
+	len  int
+}
+
+func newWots(h *hasher, w int) *wots {
+	o := &wots{hasher: h, w: w}
+	for 1<<o.logW < w {
+		o.logW++
+	}
+	o.len1 = (8*h.n + o.logW - 1) / o.logW
+	// len2 = floor(log2(len1 * (w - 1)) / log2(w)) + 1
+	x := o.len1 * (w - 1)
+	lg := 0
+	for x > 1 {
+		x >>= 1
+		lg++
+	}
+	o.len2 = lg/o.logW + 1
+	o.len = o.len1 + o.len2
+	return o
+}
+
+// chain 从第 i 步开始，计算 s 次，结果追加到 dst 后面
+func (o *wots) chain(dst, x []byte, i, s int, seed []byte, a address) []byte {
+	o.node = append(o.node[:0], x...)
+	for j := i; j < i+s; j++ {
+		a.setHash(uint32(j))
+		o.chainStep(o.node, seed, a)
+	}
+	return append(dst, o.node...)
+}
+
+// baseW 将 x 按照大端序每 log2(w) bits 转换成一个整数
+func (o *wots) baseW(x []byte, outLen int) []int {
+	res := make([]int, outLen)
+	in, bits, total := 0, 0, 0
+	for i := 0; i < outLen; i++ {
+		if bits == 0 {
+			total = int(x[in])
+			in++
+			bits = 8
+		}
+		bits -= o.logW
+		res[i] = total >> bits & (o.w - 1)
+	}
+	return res
+}
+
+// digits 消息以及校验和对应的 len 个数字
+func (o *wots) digits(m []byte) []int {
+	d := o.baseW(m, o.len1)
+	csum := 0
+	for _, v := range d {
+		csum += o.w - 1 - v
+	}
+	csum <<= 8 - o.len2*o.logW%8
+	return append(d, o.baseW(toByte(uint64(csum), (o.len2*o.logW+7)/8), o.len2)...)
+}
+
+// sk 第 i 个私钥块，每次返回同一个缓冲区
+func (o *wots) sk(skSeed, seed []byte, a address, i int) []byte {
+	a.setChain(uint32(i))
+	a.setHash(0)
+	a.setKeyAndMask(0)
+	o.secret = o.prfKeygen(o.secret[:0], skSeed, seed, a)
+	return o.secret
+}
+
+// genPK 生成 WOTS+ 公钥，一共 len 个 n bytes 的块
+func (o *wots) genPK(skSeed, seed []byte, a address) []byte {
+	pk := make([]byte, 0, o.len*o.n)
+	for i := 0; i < o.len; i++ {
+		a.setChain(uint32(i))
+		pk = o.chain(pk, o.sk(skSeed, seed, a, i), 0, o.w-1, seed, a)
+	}
+	return pk
+}
+
+func (o *wots) sign(m, skSeed, seed []byte, a address) []byte {
+	sig := make([]byte, 0, o.len*o.n)
+	for i, v := range o.digits(m) {
+		a.setChain(uint32(i))
+		sig = o.chain(sig, o.sk(skSeed, seed, a, i), 0, v, seed, a)
+	}
+	return sig
+}
+
+func (o *wots) pkFromSig(sig, m, seed []byte, a address) []byte {
+	pk := make([]byte, 0, o.len*o.n)
+	for i, v := range o.digits(m) {
+		a.setChain(uint32(i))
+		pk = o.chain(pk, sig[i*o.n:(i+1)*o.n], v, o.w-1-v, seed, a)
+	}
+	return pk
+}
@@ -0,0 +1,271 @@
+package xmss
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/junhaideng/sphincs/common"
+	"github.com/junhaideng/sphincs/signature"
+)
+
+var ErrKeyExhausted = errors.New("xmss: all one-time keys have been used")
+
+// XMSS 以及 XMSS^MT 有状态签名，见 RFC 8391
+// XMSS 可以看作 d = 1 的 XMSS^MT，所以这里使用同一个结构体
+//
+// 密钥和签名的格式和 RFC 8391 以及参考实现保持一致
+// pk = OID || root || SEED
+// sk = OID || idx || SK_SEED || SK_PRF || root || SEED || BDS 状态
+// σ  = idx || r || (WOTS+ 签名 || 鉴权路径) * d
+// 其中 idx 在 XMSS 中为 4 bytes，在 XMSS^MT 中为 ceil(h/8) bytes，均为大端序
+// 私钥的前面部分和参考实现一致，后面的 BDS 状态用于加速签名，格式见 bds.go
+//
+// 私钥是有状态的，每次签名之后 sk 中的 idx 会加一，调用者需要保存更新之后的 sk
+// 同一个 idx 绝对不能签名两次
+type XMSS struct {
+	p    Params
+	hash *hasher
+	wots *wots
+	// 用于生成密钥
+	r io.Reader
+}
+
+var (
+	_ signature.Signature = (*XMSS)(nil)
+	_ signature.Verifier  = (*XMSS)(nil)
+)
+
+// New 根据参数集的名称创建 XMSS 或者 XMSS^MT，例如 XMSS-SHA2_10_256
+// 密钥默认使用 crypto/rand 生成，可以通过 signature.WithRand 设置
+func New(name string, opts ...signature.Option) (*XMSS, error) {
+	p, err := ParamsByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithParams(p, opts...), nil
+}
+
+// NewWithParams 根据参数创建 XMSS 或者 XMSS^MT
+func NewWithParams(p Params, opts ...signature.Option) *XMSS {
+	h := newHasher(p)
+	return &XMSS{
+		p:    p,
+		hash: h,
+		wots: newWots(h, p.W),
+		r:    signature.NewOptions(opts...).Rand,
+	}
+}
+
+// local 返回使用单独 hasher 的副本，哈希函数的状态以及中间结果都保存在 hasher 中
+// 每次生成密钥，签名以及校验都使用一个新的副本，所以同一个 XMSS 可以被多个 goroutine 同时使用
+func (x *XMSS) local() *XMSS {
+	h := x.hash.clone()
+	w := *x.wots
+	w.hasher = h
+	return &XMSS{p: x.p, hash: h, wots: &w, r: x.r}
+}
+
+// Params 返回使用的参数
+func (x *XMSS) Params() Params {
+	return x.p
+}
+
+// indexSize 签名以及私钥中 idx 的长度
+func (x *XMSS) indexSize() int {
+	if !x.p.MT {
+		return 4
+	}
+	return (x.p.H + 7) / 8
+}
+
+// PublicKeySize OID || root || SEED
+func (x *XMSS) PublicKeySize() int {
+	return 4 + 2*x.p.N
+}
+
+// PrivateKeySize OID || idx || SK_SEED || SK_PRF || root || SEED || BDS 状态
+func (x *XMSS) PrivateKeySize() int {
+	return 4 + x.indexSize() + 4*x.p.N + x.stateSize()
+}
+
+// SignatureSize idx || r || d 层的 WOTS+ 签名以及鉴权路径
+func (x *XMSS) SignatureSize() int {
+	return x.indexSize() + (1+x.p.D*x.wots.len+x.p.H)*x.p.N
+}
+
+// GenerateKey 生成密钥对，私钥中的 idx 从 0 开始
+func (x *XMSS) GenerateKey() ([]byte, []byte) {
+	seed := make([]byte, 3*x.p.N)
+	if _, err := io.ReadFull(x.r, seed); err != nil {
+		panic(err)
+	}
+	return x.keyGen(seed)
+}
+
+// keyGen 和参考实现中的 xmss(mt)_core_seed_keypair 一致
+// seed = SK_SEED || SK_PRF || SEED
+func (x *XMSS) keyGen(seed []byte) ([]byte, []byte) {
+	n := x.p.N
+	skSeed := seed[:n]
+	skPrf := seed[n : 2*n]
+	pubSeed := seed[2*n : 3*n]
+
+	// 每一层的第一棵子树，最顶层子树的根节点即公钥中的 root
+	st := x.local().newState(skSeed, pubSeed, 0)
+	root := st.bds[x.p.D-1].Root()
+
+	oid := make([]byte, 4)
+	binary.BigEndian.PutUint32(oid, x.p.OID)
+
+	sk := make([]byte, 0, x.PrivateKeySize())
+	sk = append(sk, oid...)
+	sk = append(sk, make([]byte, x.indexSize())...)
+	sk = append(sk, skSeed...)
+	sk = append(sk, skPrf...)
+	sk = append(sk, root...)
+	sk = append(sk, pubSeed...)
+	sk = sk[:x.PrivateKeySize()]
+	x.putState(sk[len(sk)-x.stateSize():], st, 0)
+
+	pk := make([]byte, 0, x.PublicKeySize())
+	pk = append(pk, oid...)
+	pk = append(pk, root...)
+	pk = append(pk, pubSeed...)
+	return sk, pk
+}
+
+// Index 返回私钥中下一次签名使用的 idx
+func (x *XMSS) Index(sk []byte) uint64 {
+	return toInt(sk[4 : 4+x.indexSize()])
+}
+
+// Remaining 返回私钥还可以签名的次数
+func (x *XMSS) Remaining(sk []byte) uint64 {
+	idx := x.Index(sk)
+	total := uint64(1) << x.p.H
+	if idx >= total {
+		return 0
+	}
+	return total - idx
+}
+
+// setIndex 将 idx 写回私钥
+func (x *XMSS) setIndex(sk []byte, idx uint64) {
+	copy(sk[4:4+x.indexSize()], toByte(idx, x.indexSize()))
+}
+
+// Sign 使用 sk 中的 idx 对消息进行签名，签名之后 sk 中的 idx 加一
+// 如果所有的一次性密钥都已经使用过，panic ErrKeyExhausted
+func (x *XMSS) Sign(message []byte, sk []byte) []byte {
+	sig, err := x.sign(message, sk)
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func (x *XMSS) sign(message []byte, sk []byte) ([]byte, error) {
+	if len(sk) != x.PrivateKeySize() {
+		return nil, common.ErrSizeNotMatch
+	}
+	n := x.p.N
+	idx := x.Index(sk)
+	if x.p.H < 64 && idx >= 1<<x.p.H {
+		return nil, ErrKeyExhausted
+	}
+	// 先更新状态，再进行签名
+	x.setIndex(sk, idx+1)
+
+	body := sk[4+x.indexSize():]
+	skSeed := body[:n]
+	skPrf := body[n : 2*n]
+	root := body[2*n : 3*n]
+	pubSeed := body[3*n : 4*n]
+	states := body[4*n:]
+
+	sig := make([]byte, 0, x.SignatureSize())
+	sig = append(sig, toByte(idx, x.indexSize())...)
+
+	l := x.local()
+	// r = PRF(SK_PRF, toByte(idx, 32))
+	r := l.hash.prf(skPrf, toByte(idx, 32))
+	sig = append(sig, r...)
+	m := l.digest(r, root, idx, message)
+
+	// 最底层对消息进行签名，上面各层对下一层子树的根节点的签名保存在状态中
+	st := l.loadState(states, skSeed, pubSeed, idx)
+	sig = append(sig, l.wotsSign(skSeed, pubSeed, idx, 0, m)...)
+	for j, b := range st.bds {
+		if j > 0 {
+			sig = append(sig, st.sigs[j-1]...)
+		}
+		for _, node := range b.AuthenticationPath() {
+			sig = append(sig, node...)
+		}
+	}
+	// 所有的叶子节点都已经使用过时不再需要状态
+	if x.p.H >= 64 || idx+1 < 1<<x.p.H {
+		l.next(st, skSeed, pubSeed, idx)
+		x.putState(states, st, idx+1)
+	}
+	return sig, nil
+}
+
+// Verify 校验签名
+func (x *XMSS) Verify(message []byte, pk []byte, sig []byte) bool {
+	return x.VerifyErr(message, pk, sig) == nil
+}
+
+// VerifyErr 和 Verify 一样，但是返回校验失败的原因，错误见 signature.Verifier
+func (x *XMSS) VerifyErr(message []byte, pk []byte, sig []byte) error {
+	if len(pk) != x.PublicKeySize() || binary.BigEndian.Uint32(pk[:4]) != x.p.OID {
+		return signature.ErrInvalidPublicKey
+	}
+	if len(sig) != x.SignatureSize() {
+		return signature.ErrInvalidSignature
+	}
+	n := x.p.N
+	root := pk[4 : 4+n]
+	pubSeed := pk[4+n:]
+
+	idx := toInt(sig[:x.indexSize()])
+	if x.p.H < 64 && idx >= 1<<x.p.H {
+		return signature.ErrVerifyFailed
+	}
+	sig = sig[x.indexSize():]
+	r := sig[:n]
+	sig = sig[n:]
+	l := x.local()
+	m := l.digest(r, root, idx, message)
+
+	height := x.p.H / x.p.D
+	size := (x.wots.len + height) * n
+	for j := 0; j < x.p.D; j++ {
+		leaf := uint32(idx & (1<<height - 1))
+		idx >>= height
+		block := sig[j*size : (j+1)*size]
+		m = l.rootFromSig(leaf, block[:x.wots.len*n], block[x.wots.len*n:], m, pubSeed, uint32(j), idx)
+	}
+	if !common.Equal(m, root) {
+		return signature.ErrVerifyFailed
+	}
+	return nil
+}
+
+// digest M' = H_msg(r || root || toByte(idx, n), M)
+func (x *XMSS) digest(r, root []byte, idx uint64, message []byte) []byte {
+	key := make([]byte, 0, 3*x.p.N)
+	key = append(key, r...)
+	key = append(key, root...)
+	key = append(key, toByte(idx, x.p.N)...)
+	return x.hash.hMsg(key, message)
+}
+
+func toInt(b []byte) uint64 {
+	var res uint64
+	for _, v := range b {
+		res = res<<8 | uint64(v)
+	}
+	return res
+}
@@ -0,0 +1,161 @@
+package xmss
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/junhaideng/sphincs/signature"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/sha3"
+)
+
+// refHash 参考实现 test/vectors.c 中输出的哈希值，SHAKE128 的前 10 bytes
+func refHash(b []byte) string {
+	out := make([]byte, 10)
+	sha3.ShakeSum128(out, b)
+	return hex.EncodeToString(out)
+}
+
+// TestVectors RFC 8391 参数集的测试向量，由 XMSS 参考实现 (github.com/XMSS/xmss-reference) 的 test/vectors 生成
+// seed 为 0, 1, ..., 3n-1，使用 idx = 2^(h-1) 对消息 {37} 进行签名
+// 公钥的哈希值不包括 OID
+func TestVectors(t *testing.T) {
+	vectors := []struct {
+		mt      bool
+		oid     uint32
+		pk, sig string
+	}{
+		{false, 1, "7de72d192121f414d4bb", "8b6cb278d50a3694ca38"},
+		{false, 4, "74ee7c42b4e42a424ed9", "b9e63b0376a550eabe1b"},
+		{false, 7, "764614ee2ce5e4bf0114", "3e9035cffa0fd4be98bd"},
+		{false, 10, "e47fe831b6ee463e2881", "ce2dc09cd7ad8c87ae06"},
+		{true, 2, "9df4c75282451bf2bc53", "fd4ff4c18801147b2804"},
+		{true, 10, "fdeb0cc4fed643bf70ce", "fbeb33a7aed7af7ea526"},
+		{true, 18, "dbe6fc388fbd610b3401", "2c2a66cae9a16414088d"},
+		{true, 26, "3739e7d3668932d9ca44", "ec8d62bb9d4ba74c6729"},
+	}
+	for _, v := range vectors {
+		p, err := ParamsByOID(v.mt, v.oid)
+		assert.Nil(t, err)
+		if testing.Short() && !p.MT && p.N == 64 {
+			continue
+		}
+		x := NewWithParams(p)
+
+		seed := make([]byte, 3*p.N)
+		for i := 0; i < len(seed); i++ {
+			seed[i] = byte(i)
+		}
+		sk, pk := x.keyGen(seed)
+		assert.Equal(t, v.pk, refHash(pk[4:]), p.Name)
+
+		x.setIndex(sk, 1<<(p.H-1))
+		msg := []byte{37}
+		sig := x.Sign(msg, sk)
+		assert.Equal(t, v.sig, refHash(sig), p.Name)
+		assert.True(t, x.Verify(msg, pk, sig), p.Name)
+	}
+}
+
+func TestParams(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := ParamsByName("XMSS-SHA2_10_256")
+	assert.Nil(err)
+	assert.Equal(uint32(1), p.OID)
+	p, err = ParamsByName("XMSSMT-SHAKE_60/12_512")
+	assert.Nil(err)
+	assert.Equal(uint32(0x20), p.OID)
+	assert.Equal(12, p.D)
+	p, err = ParamsByOID(false, 0x0c)
+	assert.Nil(err)
+	assert.Equal("XMSS-SHAKE_20_512", p.Name)
+	_, err = ParamsByOID(true, 0x21)
+	assert.Equal(ErrParamsNotSupport, err)
+	assert.Equal(12+32, len(AllParams()))
+
+	// RFC 8391 中的签名大小
+	x, _ := New("XMSS-SHA2_10_256")
+	assert.Equal(2500, x.SignatureSize())
+	x, _ = New("XMSSMT-SHA2_20/2_256")
+	assert.Equal(4963, x.SignatureSize())
+}
+
+func TestStatefulKey(t *testing.T) {
+	assert := assert.New(t)
+
+	// 高度为 2 的 XMSS^MT，一共可以签名 4 次
+	x := NewWithParams(Params{Name: "test", OID: 1, MT: true, Func: SHA2, N: 32, H: 2, D: 2, W: 16})
+	sk, pk := x.GenerateKey()
+	assert.Equal(uint64(4), x.Remaining(sk))
+
+	msg := []byte("hello world")
+	var sigs [][]byte
+	for i := 0; i < 4; i++ {
+		assert.Equal(uint64(i), x.Index(sk))
+		sig := x.Sign(msg, sk)
+		assert.True(x.Verify(msg, pk, sig))
+		assert.False(x.Verify([]byte("hello world!"), pk, sig))
+		sigs = append(sigs, sig)
+	}
+	// 每次签名使用不同的一次性密钥
+	assert.NotEqual(sigs[0], sigs[1])
+	assert.Equal(uint64(0), x.Remaining(sk))
+	assert.PanicsWithValue(ErrKeyExhausted, func() { x.Sign(msg, sk) })
+}
+
+func TestBDSState(t *testing.T) {
+	assert := assert.New(t)
+	for _, p := range []Params{
+		{Name: "test", OID: 1, MT: false, Func: SHA2, N: 32, H: 5, D: 1, W: 16},
+		{Name: "test", OID: 1, MT: true, Func: SHA2, N: 32, H: 6, D: 3, W: 16},
+		{Name: "test", OID: 1, MT: true, Func: SHA2, N: 32, H: 6, D: 2, W: 16},
+	} {
+		x := NewWithParams(p)
+		sk, pk := x.GenerateKey()
+		msg := []byte("hello world")
+		for i := 0; i < 1<<p.H; i++ {
+			// 状态中的 idx 和私钥中的不一致时重新建立状态，签名和使用保存的状态时相同
+			other := append([]byte(nil), sk...)
+			copy(other[len(other)-x.stateSize():], bytes.Repeat([]byte{0xff}, x.indexSize()))
+			sig := x.Sign(msg, sk)
+			assert.True(x.Verify(msg, pk, sig), "%d %d", p.H, i)
+			assert.Equal(sig, x.Sign(msg, other), "%d %d", p.H, i)
+		}
+		assert.PanicsWithValue(ErrKeyExhausted, func() { x.Sign(msg, sk) })
+	}
+}
+
+func TestVerifyErr(t *testing.T) {
+	assert := assert.New(t)
+	x := NewWithParams(Params{Name: "test", OID: 1, MT: true, Func: SHA2, N: 32, H: 2, D: 2, W: 16})
+	sk, pk := x.GenerateKey()
+	msg := []byte("hello world")
+	sig := x.Sign(msg, sk)
+	assert.Nil(x.VerifyErr(msg, pk, sig))
+
+	assert.Equal(signature.ErrInvalidPublicKey, x.VerifyErr(msg, pk[:len(pk)-1], sig))
+	assert.Equal(signature.ErrInvalidPublicKey, x.VerifyErr(msg, nil, sig))
+	assert.Equal(signature.ErrInvalidSignature, x.VerifyErr(msg, pk, sig[:len(sig)-1]))
+	sig[len(sig)-1] ^= 1
+	assert.Equal(signature.ErrVerifyFailed, x.VerifyErr(msg, pk, sig))
+	// idx 超出范围
+	sig[len(sig)-1] ^= 1
+	sig[0] = 0xff
+	assert.Equal(signature.ErrVerifyFailed, x.VerifyErr(msg, pk, sig))
+}
+
+func TestWithRand(t *testing.T) {
+	assert := assert.New(t)
+	p := Params{Name: "test", OID: 1, MT: true, Func: SHA2, N: 32, H: 2, D: 2, W: 16}
+	seed := make([]byte, 3*p.N)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i)
+	}
+	x := NewWithParams(p, signature.WithRand(bytes.NewReader(seed)))
+	sk, pk := x.GenerateKey()
+	sk2, pk2 := NewWithParams(p).keyGen(seed)
+	assert.Equal(sk2, sk)
+	assert.Equal(pk2, pk)
+}